@@ -20,8 +20,23 @@ const _ = grpc.SupportPackageIsVersion7
 type DatabaseClient interface {
 	Add(ctx context.Context, in *AddRequest, opts ...grpc.CallOption) (*AddReply, error)
 	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetReply, error)
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchReply, error)
 	Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*UpdateReply, error)
 	Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*RemoveReply, error)
+	Restore(ctx context.Context, in *RestoreRequest, opts ...grpc.CallOption) (*RestoreReply, error)
+	Purge(ctx context.Context, in *PurgeRequest, opts ...grpc.CallOption) (*PurgeReply, error)
+	History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryReply, error)
+	Revision(ctx context.Context, in *RevisionRequest, opts ...grpc.CallOption) (*RevisionReply, error)
+	Diff(ctx context.Context, in *DiffRequest, opts ...grpc.CallOption) (*DiffReply, error)
+	Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*RollbackReply, error)
+	BulkAdd(ctx context.Context, opts ...grpc.CallOption) (Database_BulkAddClient, error)
+	Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (Database_ExportClient, error)
+	PutContent(ctx context.Context, opts ...grpc.CallOption) (Database_PutContentClient, error)
+	GetContent(ctx context.Context, in *GetContentRequest, opts ...grpc.CallOption) (Database_GetContentClient, error)
+	FindSimilar(ctx context.Context, in *FindSimilarRequest, opts ...grpc.CallOption) (*FindSimilarReply, error)
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Database_WatchClient, error)
+	WatermarkJob(ctx context.Context, in *WatermarkJobRequest, opts ...grpc.CallOption) (*WatermarkJobReply, error)
+	TransitionWatermark(ctx context.Context, in *TransitionWatermarkRequest, opts ...grpc.CallOption) (*WatermarkJobReply, error)
 	ServiceStatus(ctx context.Context, in *ServiceStatusRequest, opts ...grpc.CallOption) (*ServiceStatusReply, error)
 }
 
@@ -51,6 +66,15 @@ func (c *databaseClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.C
 	return out, nil
 }
 
+func (c *databaseClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchReply, error) {
+	out := new(SearchReply)
+	err := c.cc.Invoke(ctx, "/pb.database/Search", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *databaseClient) Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*UpdateReply, error) {
 	out := new(UpdateReply)
 	err := c.cc.Invoke(ctx, "/pb.database/Update", in, out, opts...)
@@ -69,6 +93,251 @@ func (c *databaseClient) Remove(ctx context.Context, in *RemoveRequest, opts ...
 	return out, nil
 }
 
+func (c *databaseClient) Restore(ctx context.Context, in *RestoreRequest, opts ...grpc.CallOption) (*RestoreReply, error) {
+	out := new(RestoreReply)
+	err := c.cc.Invoke(ctx, "/pb.database/Restore", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *databaseClient) Purge(ctx context.Context, in *PurgeRequest, opts ...grpc.CallOption) (*PurgeReply, error) {
+	out := new(PurgeReply)
+	err := c.cc.Invoke(ctx, "/pb.database/Purge", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *databaseClient) History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryReply, error) {
+	out := new(HistoryReply)
+	err := c.cc.Invoke(ctx, "/pb.database/History", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *databaseClient) Revision(ctx context.Context, in *RevisionRequest, opts ...grpc.CallOption) (*RevisionReply, error) {
+	out := new(RevisionReply)
+	err := c.cc.Invoke(ctx, "/pb.database/Revision", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *databaseClient) Diff(ctx context.Context, in *DiffRequest, opts ...grpc.CallOption) (*DiffReply, error) {
+	out := new(DiffReply)
+	err := c.cc.Invoke(ctx, "/pb.database/Diff", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *databaseClient) Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*RollbackReply, error) {
+	out := new(RollbackReply)
+	err := c.cc.Invoke(ctx, "/pb.database/Rollback", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *databaseClient) BulkAdd(ctx context.Context, opts ...grpc.CallOption) (Database_BulkAddClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Database_ServiceDesc.Streams[0], "/pb.database/BulkAdd", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &databaseBulkAddClient{stream}
+	return x, nil
+}
+
+type Database_BulkAddClient interface {
+	Send(*BulkAddRequest) error
+	CloseAndRecv() (*BulkAddReply, error)
+	grpc.ClientStream
+}
+
+type databaseBulkAddClient struct {
+	grpc.ClientStream
+}
+
+func (x *databaseBulkAddClient) Send(m *BulkAddRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *databaseBulkAddClient) CloseAndRecv() (*BulkAddReply, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(BulkAddReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *databaseClient) Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (Database_ExportClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Database_ServiceDesc.Streams[1], "/pb.database/Export", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &databaseExportClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Database_ExportClient interface {
+	Recv() (*Document, error)
+	grpc.ClientStream
+}
+
+type databaseExportClient struct {
+	grpc.ClientStream
+}
+
+func (x *databaseExportClient) Recv() (*Document, error) {
+	m := new(Document)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *databaseClient) PutContent(ctx context.Context, opts ...grpc.CallOption) (Database_PutContentClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Database_ServiceDesc.Streams[2], "/pb.database/PutContent", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &databasePutContentClient{stream}
+	return x, nil
+}
+
+type Database_PutContentClient interface {
+	Send(*PutContentRequest) error
+	CloseAndRecv() (*PutContentReply, error)
+	grpc.ClientStream
+}
+
+type databasePutContentClient struct {
+	grpc.ClientStream
+}
+
+func (x *databasePutContentClient) Send(m *PutContentRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *databasePutContentClient) CloseAndRecv() (*PutContentReply, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(PutContentReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *databaseClient) GetContent(ctx context.Context, in *GetContentRequest, opts ...grpc.CallOption) (Database_GetContentClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Database_ServiceDesc.Streams[3], "/pb.database/GetContent", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &databaseGetContentClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Database_GetContentClient interface {
+	Recv() (*ContentChunk, error)
+	grpc.ClientStream
+}
+
+type databaseGetContentClient struct {
+	grpc.ClientStream
+}
+
+func (x *databaseGetContentClient) Recv() (*ContentChunk, error) {
+	m := new(ContentChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *databaseClient) FindSimilar(ctx context.Context, in *FindSimilarRequest, opts ...grpc.CallOption) (*FindSimilarReply, error) {
+	out := new(FindSimilarReply)
+	err := c.cc.Invoke(ctx, "/pb.database/FindSimilar", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *databaseClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Database_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Database_ServiceDesc.Streams[4], "/pb.database/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &databaseWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Database_WatchClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type databaseWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *databaseWatchClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *databaseClient) WatermarkJob(ctx context.Context, in *WatermarkJobRequest, opts ...grpc.CallOption) (*WatermarkJobReply, error) {
+	out := new(WatermarkJobReply)
+	err := c.cc.Invoke(ctx, "/pb.database/WatermarkJob", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *databaseClient) TransitionWatermark(ctx context.Context, in *TransitionWatermarkRequest, opts ...grpc.CallOption) (*WatermarkJobReply, error) {
+	out := new(WatermarkJobReply)
+	err := c.cc.Invoke(ctx, "/pb.database/TransitionWatermark", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *databaseClient) ServiceStatus(ctx context.Context, in *ServiceStatusRequest, opts ...grpc.CallOption) (*ServiceStatusReply, error) {
 	out := new(ServiceStatusReply)
 	err := c.cc.Invoke(ctx, "/pb.database/ServiceStatus", in, out, opts...)
@@ -84,8 +353,23 @@ func (c *databaseClient) ServiceStatus(ctx context.Context, in *ServiceStatusReq
 type DatabaseServer interface {
 	Add(context.Context, *AddRequest) (*AddReply, error)
 	Get(context.Context, *GetRequest) (*GetReply, error)
+	Search(context.Context, *SearchRequest) (*SearchReply, error)
 	Update(context.Context, *UpdateRequest) (*UpdateReply, error)
 	Remove(context.Context, *RemoveRequest) (*RemoveReply, error)
+	Restore(context.Context, *RestoreRequest) (*RestoreReply, error)
+	Purge(context.Context, *PurgeRequest) (*PurgeReply, error)
+	History(context.Context, *HistoryRequest) (*HistoryReply, error)
+	Revision(context.Context, *RevisionRequest) (*RevisionReply, error)
+	Diff(context.Context, *DiffRequest) (*DiffReply, error)
+	Rollback(context.Context, *RollbackRequest) (*RollbackReply, error)
+	BulkAdd(Database_BulkAddServer) error
+	Export(*ExportRequest, Database_ExportServer) error
+	PutContent(Database_PutContentServer) error
+	GetContent(*GetContentRequest, Database_GetContentServer) error
+	FindSimilar(context.Context, *FindSimilarRequest) (*FindSimilarReply, error)
+	Watch(*WatchRequest, Database_WatchServer) error
+	WatermarkJob(context.Context, *WatermarkJobRequest) (*WatermarkJobReply, error)
+	TransitionWatermark(context.Context, *TransitionWatermarkRequest) (*WatermarkJobReply, error)
 	ServiceStatus(context.Context, *ServiceStatusRequest) (*ServiceStatusReply, error)
 	mustEmbedUnimplementedDatabaseServer()
 }
@@ -100,12 +384,57 @@ func (UnimplementedDatabaseServer) Add(context.Context, *AddRequest) (*AddReply,
 func (UnimplementedDatabaseServer) Get(context.Context, *GetRequest) (*GetReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
 }
+func (UnimplementedDatabaseServer) Search(context.Context, *SearchRequest) (*SearchReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
 func (UnimplementedDatabaseServer) Update(context.Context, *UpdateRequest) (*UpdateReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
 }
 func (UnimplementedDatabaseServer) Remove(context.Context, *RemoveRequest) (*RemoveReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Remove not implemented")
 }
+func (UnimplementedDatabaseServer) Restore(context.Context, *RestoreRequest) (*RestoreReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Restore not implemented")
+}
+func (UnimplementedDatabaseServer) Purge(context.Context, *PurgeRequest) (*PurgeReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Purge not implemented")
+}
+func (UnimplementedDatabaseServer) History(context.Context, *HistoryRequest) (*HistoryReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method History not implemented")
+}
+func (UnimplementedDatabaseServer) Revision(context.Context, *RevisionRequest) (*RevisionReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Revision not implemented")
+}
+func (UnimplementedDatabaseServer) Diff(context.Context, *DiffRequest) (*DiffReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Diff not implemented")
+}
+func (UnimplementedDatabaseServer) Rollback(context.Context, *RollbackRequest) (*RollbackReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rollback not implemented")
+}
+func (UnimplementedDatabaseServer) BulkAdd(Database_BulkAddServer) error {
+	return status.Errorf(codes.Unimplemented, "method BulkAdd not implemented")
+}
+func (UnimplementedDatabaseServer) Export(*ExportRequest, Database_ExportServer) error {
+	return status.Errorf(codes.Unimplemented, "method Export not implemented")
+}
+func (UnimplementedDatabaseServer) PutContent(Database_PutContentServer) error {
+	return status.Errorf(codes.Unimplemented, "method PutContent not implemented")
+}
+func (UnimplementedDatabaseServer) GetContent(*GetContentRequest, Database_GetContentServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetContent not implemented")
+}
+func (UnimplementedDatabaseServer) FindSimilar(context.Context, *FindSimilarRequest) (*FindSimilarReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindSimilar not implemented")
+}
+func (UnimplementedDatabaseServer) Watch(*WatchRequest, Database_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedDatabaseServer) WatermarkJob(context.Context, *WatermarkJobRequest) (*WatermarkJobReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WatermarkJob not implemented")
+}
+func (UnimplementedDatabaseServer) TransitionWatermark(context.Context, *TransitionWatermarkRequest) (*WatermarkJobReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TransitionWatermark not implemented")
+}
 func (UnimplementedDatabaseServer) ServiceStatus(context.Context, *ServiceStatusRequest) (*ServiceStatusReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ServiceStatus not implemented")
 }
@@ -158,6 +487,24 @@ func _Database_Get_Handler(srv interface{}, ctx context.Context, dec func(interf
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Database_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.database/Search",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Database_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(UpdateRequest)
 	if err := dec(in); err != nil {
@@ -194,6 +541,283 @@ func _Database_Remove_Handler(srv interface{}, ctx context.Context, dec func(int
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Database_Restore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).Restore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.database/Restore",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).Restore(ctx, req.(*RestoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Database_Purge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PurgeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).Purge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.database/Purge",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).Purge(ctx, req.(*PurgeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Database_History_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).History(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.database/History",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).History(ctx, req.(*HistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Database_Revision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevisionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).Revision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.database/Revision",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).Revision(ctx, req.(*RevisionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Database_Diff_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiffRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).Diff(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.database/Diff",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).Diff(ctx, req.(*DiffRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Database_Rollback_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RollbackRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).Rollback(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.database/Rollback",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).Rollback(ctx, req.(*RollbackRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Database_BulkAdd_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(DatabaseServer).BulkAdd(&databaseBulkAddServer{stream})
+}
+
+type Database_BulkAddServer interface {
+	SendAndClose(*BulkAddReply) error
+	Recv() (*BulkAddRequest, error)
+	grpc.ServerStream
+}
+
+type databaseBulkAddServer struct {
+	grpc.ServerStream
+}
+
+func (x *databaseBulkAddServer) SendAndClose(m *BulkAddReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *databaseBulkAddServer) Recv() (*BulkAddRequest, error) {
+	m := new(BulkAddRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Database_Export_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DatabaseServer).Export(m, &databaseExportServer{stream})
+}
+
+type Database_ExportServer interface {
+	Send(*Document) error
+	grpc.ServerStream
+}
+
+type databaseExportServer struct {
+	grpc.ServerStream
+}
+
+func (x *databaseExportServer) Send(m *Document) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Database_PutContent_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(DatabaseServer).PutContent(&databasePutContentServer{stream})
+}
+
+type Database_PutContentServer interface {
+	SendAndClose(*PutContentReply) error
+	Recv() (*PutContentRequest, error)
+	grpc.ServerStream
+}
+
+type databasePutContentServer struct {
+	grpc.ServerStream
+}
+
+func (x *databasePutContentServer) SendAndClose(m *PutContentReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *databasePutContentServer) Recv() (*PutContentRequest, error) {
+	m := new(PutContentRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Database_GetContent_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetContentRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DatabaseServer).GetContent(m, &databaseGetContentServer{stream})
+}
+
+type Database_GetContentServer interface {
+	Send(*ContentChunk) error
+	grpc.ServerStream
+}
+
+type databaseGetContentServer struct {
+	grpc.ServerStream
+}
+
+func (x *databaseGetContentServer) Send(m *ContentChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Database_FindSimilar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindSimilarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).FindSimilar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.database/FindSimilar",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).FindSimilar(ctx, req.(*FindSimilarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Database_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DatabaseServer).Watch(m, &databaseWatchServer{stream})
+}
+
+type Database_WatchServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type databaseWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *databaseWatchServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Database_WatermarkJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WatermarkJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).WatermarkJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.database/WatermarkJob",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).WatermarkJob(ctx, req.(*WatermarkJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Database_TransitionWatermark_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransitionWatermarkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).TransitionWatermark(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.database/TransitionWatermark",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).TransitionWatermark(ctx, req.(*TransitionWatermarkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Database_ServiceStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ServiceStatusRequest)
 	if err := dec(in); err != nil {
@@ -227,6 +851,10 @@ var Database_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Get",
 			Handler:    _Database_Get_Handler,
 		},
+		{
+			MethodName: "Search",
+			Handler:    _Database_Search_Handler,
+		},
 		{
 			MethodName: "Update",
 			Handler:    _Database_Update_Handler,
@@ -235,11 +863,73 @@ var Database_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Remove",
 			Handler:    _Database_Remove_Handler,
 		},
+		{
+			MethodName: "Restore",
+			Handler:    _Database_Restore_Handler,
+		},
+		{
+			MethodName: "Purge",
+			Handler:    _Database_Purge_Handler,
+		},
+		{
+			MethodName: "History",
+			Handler:    _Database_History_Handler,
+		},
+		{
+			MethodName: "Revision",
+			Handler:    _Database_Revision_Handler,
+		},
+		{
+			MethodName: "Diff",
+			Handler:    _Database_Diff_Handler,
+		},
+		{
+			MethodName: "Rollback",
+			Handler:    _Database_Rollback_Handler,
+		},
+		{
+			MethodName: "FindSimilar",
+			Handler:    _Database_FindSimilar_Handler,
+		},
+		{
+			MethodName: "WatermarkJob",
+			Handler:    _Database_WatermarkJob_Handler,
+		},
+		{
+			MethodName: "TransitionWatermark",
+			Handler:    _Database_TransitionWatermark_Handler,
+		},
 		{
 			MethodName: "ServiceStatus",
 			Handler:    _Database_ServiceStatus_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BulkAdd",
+			Handler:       _Database_BulkAdd_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Export",
+			Handler:       _Database_Export_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PutContent",
+			Handler:       _Database_PutContent_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "GetContent",
+			Handler:       _Database_GetContent_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _Database_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "api/v1/pb/db/dbsvc.proto",
 }
@@ -19,10 +19,14 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type WatermarkClient interface {
 	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetReply, error)
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchReply, error)
 	Watermark(ctx context.Context, in *WatermarkRequest, opts ...grpc.CallOption) (*WatermarkReply, error)
 	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusReply, error)
 	AddDocument(ctx context.Context, in *AddDocumentRequest, opts ...grpc.CallOption) (*AddDocumentReply, error)
+	UploadDocument(ctx context.Context, opts ...grpc.CallOption) (Watermark_UploadDocumentClient, error)
+	DownloadDocument(ctx context.Context, in *DownloadDocumentRequest, opts ...grpc.CallOption) (Watermark_DownloadDocumentClient, error)
 	ServiceStatus(ctx context.Context, in *ServiceStatusRequest, opts ...grpc.CallOption) (*ServiceStatusReply, error)
+	Detect(ctx context.Context, in *DetectRequest, opts ...grpc.CallOption) (*DetectReply, error)
 }
 
 type watermarkClient struct {
@@ -42,6 +46,15 @@ func (c *watermarkClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.
 	return out, nil
 }
 
+func (c *watermarkClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchReply, error) {
+	out := new(SearchReply)
+	err := c.cc.Invoke(ctx, "/pb.Watermark/Search", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *watermarkClient) Watermark(ctx context.Context, in *WatermarkRequest, opts ...grpc.CallOption) (*WatermarkReply, error) {
 	out := new(WatermarkReply)
 	err := c.cc.Invoke(ctx, "/pb.Watermark/Watermark", in, out, opts...)
@@ -69,6 +82,72 @@ func (c *watermarkClient) AddDocument(ctx context.Context, in *AddDocumentReques
 	return out, nil
 }
 
+func (c *watermarkClient) UploadDocument(ctx context.Context, opts ...grpc.CallOption) (Watermark_UploadDocumentClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Watermark_ServiceDesc.Streams[0], "/pb.Watermark/UploadDocument", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &watermarkUploadDocumentClient{stream}
+	return x, nil
+}
+
+type Watermark_UploadDocumentClient interface {
+	Send(*UploadDocumentRequest) error
+	CloseAndRecv() (*UploadDocumentReply, error)
+	grpc.ClientStream
+}
+
+type watermarkUploadDocumentClient struct {
+	grpc.ClientStream
+}
+
+func (x *watermarkUploadDocumentClient) Send(m *UploadDocumentRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *watermarkUploadDocumentClient) CloseAndRecv() (*UploadDocumentReply, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(UploadDocumentReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *watermarkClient) DownloadDocument(ctx context.Context, in *DownloadDocumentRequest, opts ...grpc.CallOption) (Watermark_DownloadDocumentClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Watermark_ServiceDesc.Streams[1], "/pb.Watermark/DownloadDocument", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &watermarkDownloadDocumentClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Watermark_DownloadDocumentClient interface {
+	Recv() (*DocumentChunk, error)
+	grpc.ClientStream
+}
+
+type watermarkDownloadDocumentClient struct {
+	grpc.ClientStream
+}
+
+func (x *watermarkDownloadDocumentClient) Recv() (*DocumentChunk, error) {
+	m := new(DocumentChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *watermarkClient) ServiceStatus(ctx context.Context, in *ServiceStatusRequest, opts ...grpc.CallOption) (*ServiceStatusReply, error) {
 	out := new(ServiceStatusReply)
 	err := c.cc.Invoke(ctx, "/pb.Watermark/ServiceStatus", in, out, opts...)
@@ -78,15 +157,28 @@ func (c *watermarkClient) ServiceStatus(ctx context.Context, in *ServiceStatusRe
 	return out, nil
 }
 
+func (c *watermarkClient) Detect(ctx context.Context, in *DetectRequest, opts ...grpc.CallOption) (*DetectReply, error) {
+	out := new(DetectReply)
+	err := c.cc.Invoke(ctx, "/pb.Watermark/Detect", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // WatermarkServer is the server API for Watermark service.
 // All implementations must embed UnimplementedWatermarkServer
 // for forward compatibility
 type WatermarkServer interface {
 	Get(context.Context, *GetRequest) (*GetReply, error)
+	Search(context.Context, *SearchRequest) (*SearchReply, error)
 	Watermark(context.Context, *WatermarkRequest) (*WatermarkReply, error)
 	Status(context.Context, *StatusRequest) (*StatusReply, error)
 	AddDocument(context.Context, *AddDocumentRequest) (*AddDocumentReply, error)
+	UploadDocument(Watermark_UploadDocumentServer) error
+	DownloadDocument(*DownloadDocumentRequest, Watermark_DownloadDocumentServer) error
 	ServiceStatus(context.Context, *ServiceStatusRequest) (*ServiceStatusReply, error)
+	Detect(context.Context, *DetectRequest) (*DetectReply, error)
 	mustEmbedUnimplementedWatermarkServer()
 }
 
@@ -97,6 +189,9 @@ type UnimplementedWatermarkServer struct {
 func (UnimplementedWatermarkServer) Get(context.Context, *GetRequest) (*GetReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
 }
+func (UnimplementedWatermarkServer) Search(context.Context, *SearchRequest) (*SearchReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
 func (UnimplementedWatermarkServer) Watermark(context.Context, *WatermarkRequest) (*WatermarkReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Watermark not implemented")
 }
@@ -106,9 +201,18 @@ func (UnimplementedWatermarkServer) Status(context.Context, *StatusRequest) (*St
 func (UnimplementedWatermarkServer) AddDocument(context.Context, *AddDocumentRequest) (*AddDocumentReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method AddDocument not implemented")
 }
+func (UnimplementedWatermarkServer) UploadDocument(Watermark_UploadDocumentServer) error {
+	return status.Errorf(codes.Unimplemented, "method UploadDocument not implemented")
+}
+func (UnimplementedWatermarkServer) DownloadDocument(*DownloadDocumentRequest, Watermark_DownloadDocumentServer) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadDocument not implemented")
+}
 func (UnimplementedWatermarkServer) ServiceStatus(context.Context, *ServiceStatusRequest) (*ServiceStatusReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ServiceStatus not implemented")
 }
+func (UnimplementedWatermarkServer) Detect(context.Context, *DetectRequest) (*DetectReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Detect not implemented")
+}
 func (UnimplementedWatermarkServer) mustEmbedUnimplementedWatermarkServer() {}
 
 // UnsafeWatermarkServer may be embedded to opt out of forward compatibility for this service.
@@ -140,6 +244,24 @@ func _Watermark_Get_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Watermark_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WatermarkServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Watermark/Search",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WatermarkServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Watermark_Watermark_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(WatermarkRequest)
 	if err := dec(in); err != nil {
@@ -194,6 +316,53 @@ func _Watermark_AddDocument_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Watermark_UploadDocument_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(WatermarkServer).UploadDocument(&watermarkUploadDocumentServer{stream})
+}
+
+type Watermark_UploadDocumentServer interface {
+	SendAndClose(*UploadDocumentReply) error
+	Recv() (*UploadDocumentRequest, error)
+	grpc.ServerStream
+}
+
+type watermarkUploadDocumentServer struct {
+	grpc.ServerStream
+}
+
+func (x *watermarkUploadDocumentServer) SendAndClose(m *UploadDocumentReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *watermarkUploadDocumentServer) Recv() (*UploadDocumentRequest, error) {
+	m := new(UploadDocumentRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Watermark_DownloadDocument_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadDocumentRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WatermarkServer).DownloadDocument(m, &watermarkDownloadDocumentServer{stream})
+}
+
+type Watermark_DownloadDocumentServer interface {
+	Send(*DocumentChunk) error
+	grpc.ServerStream
+}
+
+type watermarkDownloadDocumentServer struct {
+	grpc.ServerStream
+}
+
+func (x *watermarkDownloadDocumentServer) Send(m *DocumentChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _Watermark_ServiceStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ServiceStatusRequest)
 	if err := dec(in); err != nil {
@@ -212,6 +381,24 @@ func _Watermark_ServiceStatus_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Watermark_Detect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DetectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WatermarkServer).Detect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.Watermark/Detect",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WatermarkServer).Detect(ctx, req.(*DetectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Watermark_ServiceDesc is the grpc.ServiceDesc for Watermark service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -223,6 +410,10 @@ var Watermark_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Get",
 			Handler:    _Watermark_Get_Handler,
 		},
+		{
+			MethodName: "Search",
+			Handler:    _Watermark_Search_Handler,
+		},
 		{
 			MethodName: "Watermark",
 			Handler:    _Watermark_Watermark_Handler,
@@ -239,7 +430,22 @@ var Watermark_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ServiceStatus",
 			Handler:    _Watermark_ServiceStatus_Handler,
 		},
+		{
+			MethodName: "Detect",
+			Handler:    _Watermark_Detect_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "UploadDocument",
+			Handler:       _Watermark_UploadDocument_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "DownloadDocument",
+			Handler:       _Watermark_DownloadDocument_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "api/v1/pb/watermark/watermarksvc.proto",
 }
@@ -1,28 +1,42 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"flag"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"publisher/internal/database"
+	"publisher/pkg/blob"
 	dbsvc "publisher/pkg/database"
 	"publisher/pkg/database/endpoints"
 	"publisher/pkg/database/transport"
+	"strconv"
 	"syscall"
+	"time"
 
 	pb "publisher/api/v1/pb/db"
 
 	kitgrpc "github.com/go-kit/kit/transport/grpc"
 	"github.com/go-kit/log"
-	"github.com/oklog/run"
+	oklog "github.com/oklog/run"
 	"google.golang.org/grpc"
+	"gorm.io/gorm"
 )
 
 const (
-	defaultHTTPPort = "8081"
-	defaultGRPCPort = "8082"
+	defaultHTTPPort     = "8081"
+	defaultGRPCPort     = "8082"
+	defaultDatabaseFile = "publisher-db.json"
+	defaultBlobDir      = "blobs"
+	defaultGCInterval   = time.Hour
+	defaultGCGrace      = 24 * time.Hour
+	// defaultPurgeInterval is how often expired idempotency keys and events
+	// are deleted
+	defaultPurgeInterval = time.Hour
 )
 
 var (
@@ -32,20 +46,92 @@ var (
 )
 
 func main() {
+	// the postgres connection is configured by the db-* flags, the
+	// DATABASE_* environment variables or a config file
+	resolveConfig := database.ConfigFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [migrate <command>]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	config, err := resolveConfig(os.Getenv)
+	if err != nil {
+		logger.Log("FATAL", "invalid database config", "err", err)
+		os.Exit(2)
+	}
+
+	if args := flag.Args(); len(args) > 0 {
+		if args[0] != "migrate" {
+			flag.Usage()
+			os.Exit(2)
+		}
+		os.Exit(migrateCommand(config, args[1:]))
+	}
+
+	policy, err := duplicatePolicy()
+	if err != nil {
+		logger.Log("FATAL", "invalid duplicate policy", "err", err)
+		os.Exit(2)
+	}
+	// The relay posts the changes recorded in the outbox to the /events
+	// route of the watermark node at OUTBOX_URL, signed by OUTBOX_SECRET
+	var outboxSecret []byte
+	if os.Getenv("OUTBOX_URL") != "" {
+		if outboxSecret, err = envSecret("OUTBOX_SECRET"); err != nil || len(outboxSecret) == 0 {
+			logger.Log("FATAL", "OUTBOX_SECRET is required with OUTBOX_URL", "err", err)
+			os.Exit(2)
+		}
+	}
+
+	// run returns before exiting, so its deferred calls close the database
+	if err := run(config, policy, outboxSecret); err != nil {
+		logger.Log("FATAL", err)
+		os.Exit(1)
+	}
+}
+
+// run serves the database node until it is interrupted.
+func run(config database.Config, policy dbsvc.DuplicatePolicy, outboxSecret []byte) error {
+	// DATABASE_BACKEND selects where the documents are stored
+	backend := envString("DATABASE_BACKEND", dbsvc.BackendPostgres)
+	blobs, err := openBlobStore(backend)
+	if err != nil {
+		return fmt.Errorf("failed to open blob store: %w", err)
+	}
+	repo, err := openRepository(backend, config, blobs)
+	if err != nil {
+		return fmt.Errorf("failed to load db: %w", err)
+	}
+	defer func() {
+		if err := repo.Close(); err != nil {
+			logger.Log("ERROR::Failed to close the database connection", err.Error())
+		}
+	}()
+	// documents stored before fingerprints existed are not found as duplicates
+	if indexed, err := dbsvc.IndexFingerprints(context.Background(), repo, blobs); err != nil {
+		logger.Log("fingerprint", "index", "err", err)
+	} else if indexed > 0 {
+		logger.Log("fingerprint", "index", "indexed", indexed)
+	}
+
 	var (
-		service     = dbsvc.NewService()
+		service = dbsvc.NewService(repo, blobs,
+			dbsvc.WithIdempotencyTTL(envDuration("IDEMPOTENCY_TTL", dbsvc.DefaultIdempotencyTTL)),
+			dbsvc.WithDuplicatePolicy(policy),
+		)
 		endpointSet = endpoints.NewEndpointSet(service)
-		httpHandler = transport.NewHTTPHandler(endpointSet)
-		grpcServer  = transport.NewGRPCServer(endpointSet)
+		// ADMIN_TOKEN is the bearer token of the admins, who may list
+		// removed documents, restore and purge them
+		httpHandler = transport.NewHTTPHandler(endpointSet, os.Getenv("ADMIN_TOKEN"))
+		grpcServer  = transport.NewGRPCServer(endpointSet, os.Getenv("ADMIN_TOKEN"))
 	)
 
-	var g run.Group
+	var g oklog.Group
 	{
 		// HTTP Listener by Go Kit Http Handler
 		httpListener, err := net.Listen("tcp", httpAddr)
 		if err != nil {
-			logger.Log("transport", "HTTP", "during", "Listen", "err", err)
-			os.Exit(1)
+			return fmt.Errorf("failed to listen on %s: %w", httpAddr, err)
 		}
 		g.Add(func() error {
 			logger.Log("transport", "HTTP", "addr", httpAddr)
@@ -58,8 +144,7 @@ func main() {
 		// GRPC Listener by Go Kit gRPC Server
 		grpcListener, err := net.Listen("tcp", grpcAddr)
 		if err != nil {
-			logger.Log("transport", "gRPC", "during", "Listen", "err", err)
-			os.Exit(1)
+			return fmt.Errorf("failed to listen on %s: %w", grpcAddr, err)
 		}
 		g.Add(func() error {
 			logger.Log("transport", "gRPC", "addr", grpcAddr)
@@ -70,6 +155,42 @@ func main() {
 			grpcListener.Close()
 		})
 	}
+	{
+		// Blob GC deletes the content no document or revision refers to
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			collectGarbage(ctx, repo, blobs)
+			return nil
+		}, func(error) {
+			cancel()
+		})
+	}
+	{
+		// Expired idempotency keys, events and outbox messages are deleted
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			purgeExpired(ctx, repo,
+				envDuration("EVENT_RETENTION", dbsvc.DefaultEventRetention),
+				envDuration("OUTBOX_RETENTION", dbsvc.DefaultOutboxRetention))
+			return nil
+		}, func(error) {
+			cancel()
+		})
+	}
+	if url := os.Getenv("OUTBOX_URL"); url != "" {
+		timeout := envDuration("OUTBOX_TIMEOUT", dbsvc.DefaultPublishTimeout)
+		relay := dbsvc.NewRelay(repo, dbsvc.NewWebhookPublisher(url, outboxSecret, timeout), envInt("OUTBOX_MAX_ATTEMPTS", dbsvc.DefaultMaxAttempts), timeout)
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			logger.Log("outbox", "relay", "url", url)
+			relay.Run(ctx, envDuration("OUTBOX_POLL_INTERVAL", dbsvc.DefaultRelayInterval))
+			return nil
+		}, func(error) {
+			cancel()
+		})
+	} else {
+		logger.Log("outbox", "relay", "msg", "OUTBOX_URL is not set, the outbox is kept until a relay delivers it")
+	}
 	{
 		// This function just sits and waits for ctrl-C
 		cancelInterrupt := make(chan struct{})
@@ -87,24 +208,161 @@ func main() {
 		})
 	}
 	logger.Log("exit", g.Run())
+	return nil
 }
 
 func init() {
 	logger = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
 	logger = log.With(logger, "ts", log.DefaultTimestampUTC)
+}
 
-	// try connect postgreSQL database
-	db, err := database.Init(database.DefaultDatabase, database.DefaultHost, database.DefaultPort, database.DefaultDBUser, database.DefaultPassword, database.DefaultTimeZone)
-	defer func() {
-		sqlDB, err := db.DB()
-		err = sqlDB.Close()
+// openBlobStore opens the store of the document content, BLOB_DIR sets its
+// directory. The memory backend keeps the content in memory too.
+func openBlobStore(backend string) (blob.Store, error) {
+	if backend == dbsvc.BackendMemory {
+		return blob.NewMemoryStore(), nil
+	}
+	return blob.NewFileStore(envString("BLOB_DIR", defaultBlobDir))
+}
+
+// openRepository opens the storage backend of the given name.
+func openRepository(backend string, config database.Config, blobs blob.Store) (dbsvc.Repository, error) {
+	switch backend {
+	case dbsvc.BackendPostgres:
+		db, err := openPostgres(config)
 		if err != nil {
-			logger.Log("ERROR::Failed to close the database connection", err.Error())
+			return nil, err
 		}
-	}()
+		checkSchema(db)
+		moved, err := dbsvc.MigrateInlineContent(context.Background(), db, blobs)
+		if err != nil {
+			closeDB(db)
+			return nil, err
+		}
+		if moved > 0 {
+			logger.Log("blob", "migrate", "moved", moved)
+		}
+		return dbsvc.NewGormRepository(db), nil
+	case dbsvc.BackendMemory:
+		return dbsvc.NewMemoryRepository(), nil
+	case dbsvc.BackendFile:
+		return dbsvc.NewFileRepository(envString("DATABASE_FILE", defaultDatabaseFile))
+	}
+	return nil, fmt.Errorf("unknown database backend %q", backend)
+}
+
+// openPostgres connects to the postgreSQL database, the connection retries
+// stop on an interrupt.
+func openPostgres(config database.Config) (*gorm.DB, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	return database.Open(ctx, config, logger)
+}
+
+func closeDB(db *gorm.DB) {
+	sqlDB, err := db.DB()
+	if err == nil {
+		err = sqlDB.Close()
+	}
 	if err != nil {
-		logger.Log(fmt.Sprintf("FATAL: failed to load db with error: %s", err.Error()))
+		logger.Log("ERROR::Failed to close the database connection", err.Error())
+	}
+}
+
+// collectGarbage runs the blob GC every BLOB_GC_INTERVAL until the context is
+// done, blobs stored less than BLOB_GC_GRACE ago are kept.
+func collectGarbage(ctx context.Context, repo dbsvc.Repository, blobs blob.Store) {
+	interval := envDuration("BLOB_GC_INTERVAL", defaultGCInterval)
+	grace := envDuration("BLOB_GC_GRACE", defaultGCGrace)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		stats, err := dbsvc.CollectGarbage(ctx, repo, blobs, grace)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Log("blob", "gc", "err", err)
+			}
+			continue
+		}
+		logger.Log("blob", "gc", "scanned", stats.Scanned, "removed", stats.Removed, "freed", stats.Freed)
+	}
+}
+
+// purgeExpired deletes the expired idempotency keys, the events older than
+// eventRetention and the outbox messages handled longer than outboxRetention
+// ago every defaultPurgeInterval until the context is canceled.
+func purgeExpired(ctx context.Context, repo dbsvc.Repository, eventRetention, outboxRetention time.Duration) {
+	ticker := time.NewTicker(defaultPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, p := range []struct {
+			name  string
+			purge func() (int, error)
+		}{
+			{"idempotency", func() (int, error) { return dbsvc.PurgeIdempotencyKeys(ctx, repo) }},
+			{"events", func() (int, error) { return dbsvc.PurgeEvents(ctx, repo, eventRetention) }},
+			{"outbox", func() (int, error) { return dbsvc.PurgeOutbox(ctx, repo, outboxRetention) }},
+		} {
+			n, err := p.purge()
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Log(p.name, "purge", "err", err)
+				}
+				continue
+			}
+			if n > 0 {
+				logger.Log(p.name, "purge", "removed", n)
+			}
+		}
+	}
+}
+
+// duplicatePolicy reads the policy for similar content from
+// DUPLICATE_THRESHOLD, a similarity from 0 (disabled) to 1, and
+// DUPLICATE_ACTION, flag or reject.
+func duplicatePolicy() (dbsvc.DuplicatePolicy, error) {
+	policy := dbsvc.DefaultDuplicatePolicy
+	if v := os.Getenv("DUPLICATE_THRESHOLD"); v != "" {
+		threshold, err := strconv.ParseFloat(v, 64)
+		if err != nil || threshold < 0 || threshold > 1 {
+			return policy, fmt.Errorf("DUPLICATE_THRESHOLD %q is not a number from 0 to 1", v)
+		}
+		policy.Threshold = threshold
+	}
+	switch action := envString("DUPLICATE_ACTION", "flag"); action {
+	case "flag":
+	case "reject":
+		policy.Reject = true
+	default:
+		return policy, fmt.Errorf("DUPLICATE_ACTION %q is neither flag nor reject", action)
+	}
+	return policy, nil
+}
+
+func envDuration(env string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(env))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+func envInt(env string, fallback int) int {
+	n, err := strconv.Atoi(os.Getenv(env))
+	if err != nil || n <= 0 {
+		return fallback
 	}
+	return n
 }
 
 func envString(env, fallback string) string {
@@ -114,3 +372,17 @@ func envString(env, fallback string) string {
 	}
 	return e
 }
+
+// envSecret returns the secret of the environment variable, or the content
+// of the file named by the variable with the _FILE suffix, without the
+// trailing newline.
+func envSecret(env string) ([]byte, error) {
+	if path := os.Getenv(env + "_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.TrimRight(b, "\r\n"), nil
+	}
+	return []byte(os.Getenv(env)), nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"publisher/internal/database"
+
+	"gorm.io/gorm"
+)
+
+const migrateUsage = `usage: database migrate <command> [flags]
+
+commands:
+  up      apply pending migrations (-to limits the target version)
+  down    revert applied migrations (-steps sets how many, default 1)
+  status  list migrations and whether they are applied
+`
+
+// migrateCommand runs the migrate subcommand against postgres and returns
+// the exit code, the other backends have no schema.
+func migrateCommand(config database.Config, args []string) int {
+	db, err := openPostgres(config)
+	if err != nil {
+		logger.Log("FATAL", "failed to load db", "err", err)
+		return 1
+	}
+	defer closeDB(db)
+	return runMigrate(db, args)
+}
+
+// runMigrate executes the migrate subcommand and returns the exit code.
+func runMigrate(db *gorm.DB, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	to := fs.Int64("to", 0, "target version, 0 migrates to the latest")
+	steps := fs.Int("steps", 1, "number of migrations to revert")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	migrator, err := database.NewMigrator(db, database.Migrations)
+	if err != nil {
+		logger.Log("migrate", args[0], "err", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx, *to)
+		for _, m := range applied {
+			logger.Log("migrate", "up", "version", m.Version, "name", m.Name)
+		}
+		if err != nil {
+			logger.Log("migrate", "up", "err", err)
+			return 1
+		}
+		logger.Log("migrate", "up", "applied", len(applied))
+	case "down":
+		reverted, err := migrator.Down(ctx, *steps)
+		for _, m := range reverted {
+			logger.Log("migrate", "down", "version", m.Version, "name", m.Name)
+		}
+		if err != nil {
+			logger.Log("migrate", "down", "err", err)
+			return 1
+		}
+		logger.Log("migrate", "down", "reverted", len(reverted))
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			logger.Log("migrate", "status", "err", err)
+			return 1
+		}
+		if err := migrator.Verify(ctx); err != nil {
+			logger.Log("migrate", "status", "err", err)
+		}
+		for _, s := range status {
+			applied := "pending"
+			if s.Applied {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d  %-32s  %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
+
+// checkSchema warns about pending or modified migrations at server startup.
+func checkSchema(db *gorm.DB) {
+	migrator, err := database.NewMigrator(db, database.Migrations)
+	if err != nil {
+		logger.Log("schema", "check", "err", err)
+		return
+	}
+	ctx := context.Background()
+	if err := migrator.Verify(ctx); err != nil {
+		logger.Log("schema", "check", "err", err)
+		return
+	}
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		logger.Log("schema", "check", "err", err)
+		return
+	}
+	if len(pending) > 0 {
+		logger.Log("schema", "check", "pending", len(pending), "hint", "run `database migrate up`")
+	}
+}
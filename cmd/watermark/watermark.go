@@ -1,15 +1,28 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"flag"
 	"fmt"
+	"image/png"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"publisher/internal/database"
+	dbtransport "publisher/pkg/database/transport"
 	"publisher/pkg/watermark"
 	"publisher/pkg/watermark/endpoints"
+	"publisher/pkg/watermark/epub"
+	"publisher/pkg/watermark/pdf"
+	"publisher/pkg/watermark/raster"
+	"publisher/pkg/watermark/text"
 	"publisher/pkg/watermark/transport"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	pb "publisher/api/v1/pb/watermark"
 
@@ -20,8 +33,10 @@ import (
 )
 
 const (
-	defaultHTTPPort = "8081"
-	defaultGRPCPort = "8082"
+	defaultHTTPPort  = "8081"
+	defaultGRPCPort  = "8082"
+	defaultUploadDir = "uploads"
+	defaultQueueFile = "watermark-queue.json"
 )
 
 func main() {
@@ -34,10 +49,85 @@ func main() {
 	logger = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
 	logger = log.With(logger, "ts", log.DefaultTimestampUTC)
 
+	// the documents are stored by the database node, which listens on the
+	// same default ports, so its address has no default
+	databaseAddr := os.Getenv("DATABASE_ADDR")
+	if databaseAddr == "" {
+		logger.Log("database", "client", "err", "DATABASE_ADDR is required")
+		os.Exit(2)
+	}
+	db, err := dbtransport.NewHTTPClient(databaseAddr)
+	if err != nil {
+		logger.Log("database", "client", "err", err)
+		os.Exit(1)
+	}
+
+	// WATERMARK_QUEUE selects where the jobs wait for a worker
+	queue, err := openQueue(envString("WATERMARK_QUEUE", watermark.QueueFile), logger)
+	if err != nil {
+		logger.Log("queue", "open", "err", err)
+		os.Exit(1)
+	}
+	defer queue.Close()
+
+	// WATERMARK_TEXT_ENCODINGS lists the encodings hiding the mark in text
+	textOptions, err := parseTextOptions(os.Getenv("WATERMARK_TEXT_ENCODINGS"))
+	if err != nil {
+		logger.Log("text", "options", "err", err)
+		os.Exit(1)
+	}
+
+	// WATERMARK_IMAGE_* configure the marks of PNG and JPEG images
+	imageOptions, err := parseImageOptions(os.Getenv)
+	if err != nil {
+		logger.Log("image", "options", "err", err)
+		os.Exit(1)
+	}
+
+	// WATERMARK_PDF_* configure the stamp of PDF documents
+	pdfOptions, err := parsePDFOptions(os.Getenv)
+	if err != nil {
+		logger.Log("pdf", "options", "err", err)
+		os.Exit(1)
+	}
+
+	// the chapters of an e-book are marked like text, WATERMARK_EPUB_COLOPHON
+	// adds a page printing the mark
+	epubOptions := parseEPUBOptions(os.Getenv, textOptions)
+
+	// WATERMARK_WORKERS jobs run at once, a job runs at most
+	// WATERMARK_MAX_ATTEMPTS times for up to WATERMARK_VISIBILITY_TIMEOUT each
+	pool := watermark.NewPool(db, queue,
+		watermark.WithWorkers(envInt("WATERMARK_WORKERS", watermark.DefaultWorkers)),
+		watermark.WithVisibilityTimeout(envDuration("WATERMARK_VISIBILITY_TIMEOUT", watermark.DefaultVisibilityTimeout)),
+		watermark.WithMaxAttempts(envInt("WATERMARK_MAX_ATTEMPTS", watermark.DefaultMaxAttempts)),
+		watermark.WithTextOptions(textOptions),
+		watermark.WithImageOptions(imageOptions),
+		watermark.WithPDFOptions(pdfOptions),
+		watermark.WithEPUBOptions(epubOptions),
+	)
+
+	// UPLOAD_DIR keeps the content of unfinished uploads
+	service, err := watermark.NewService(db, envString("UPLOAD_DIR", defaultUploadDir), pool)
+	if err != nil {
+		logger.Log("uploads", "open", "err", err)
+		os.Exit(1)
+	}
+
+	// OUTBOX_SECRET verifies the events posted by the database node, the
+	// /events route is not served without it
+	eventSecret, err := envSecret("OUTBOX_SECRET")
+	if err != nil {
+		logger.Log("events", "secret", "err", err)
+		os.Exit(1)
+	}
+	if len(eventSecret) == 0 {
+		logger.Log("events", "secret", "msg", "OUTBOX_SECRET is not set, the events of the database node are refused")
+	}
+
 	var (
-		service     = watermark.NewService()
 		eps         = endpoints.NewEndpointSet(service)
-		httpHandler = transport.NewHttpHandler(eps)
+		httpHandler = transport.NewHttpHandler(eps, eventSecret)
 		grpcServer  = transport.NewGRPCServer(eps)
 	)
 
@@ -75,6 +165,16 @@ func main() {
 			grpcListener.Close()
 		})
 	}
+	{
+		// The workers finish the running jobs for up to SHUTDOWN_TIMEOUT on exit
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			pool.Run(ctx, envDuration("SHUTDOWN_TIMEOUT", watermark.DefaultDrainTimeout))
+			return nil
+		}, func(error) {
+			cancel()
+		})
+	}
 	{
 		// This function just sits and waits for ctrl-C.
 		cancelInterrupt := make(chan struct{})
@@ -95,6 +195,148 @@ func main() {
 	logger.Log("exit", g.Run())
 }
 
+// openQueue opens the watermark queue backend of the given name, the
+// postgres queue is configured by the DATABASE_* environment variables or
+// the config file named by DATABASE_CONFIG, like the database node.
+func openQueue(backend string, logger log.Logger) (watermark.Queue, error) {
+	switch backend {
+	case watermark.QueueFile:
+		return watermark.NewFileQueue(envString("WATERMARK_QUEUE_FILE", defaultQueueFile))
+	case watermark.QueuePostgres:
+		config, err := database.ConfigFlags(flag.NewFlagSet("queue", flag.ContinueOnError))(os.Getenv)
+		if err != nil {
+			return nil, err
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		db, err := database.Open(ctx, config, logger)
+		if err != nil {
+			return nil, err
+		}
+		return watermark.NewPostgresQueue(db), nil
+	}
+	return nil, fmt.Errorf("unknown watermark queue %q", backend)
+}
+
+// parseTextOptions reads a comma separated list of text encodings, the
+// defaults are used for an empty list.
+func parseTextOptions(list string) (text.Options, error) {
+	opts := text.DefaultOptions()
+	if strings.TrimSpace(list) == "" {
+		return opts, nil
+	}
+	opts.Encodings = nil
+	for _, name := range strings.Split(list, ",") {
+		e := text.Encoding(strings.TrimSpace(name))
+		if !text.ValidEncoding(e) {
+			return opts, fmt.Errorf("unknown text encoding %q", e)
+		}
+		opts.Encodings = append(opts.Encodings, e)
+	}
+	return opts, nil
+}
+
+// parseImageOptions reads the image options from the environment.
+// WATERMARK_IMAGE_OVERLAY draws the mark as "text", the PNG at
+// WATERMARK_IMAGE_LOGO as "logo" or no visible overlay for "none". The
+// overlay is placed by WATERMARK_IMAGE_OPACITY, WATERMARK_IMAGE_ROTATION,
+// WATERMARK_IMAGE_POSITION and WATERMARK_IMAGE_TILE. WATERMARK_IMAGE_INVISIBLE
+// set to false skips the invisible mark.
+func parseImageOptions(getenv func(string) string) (raster.Options, error) {
+	opts := raster.DefaultOptions()
+	switch overlay := getenv("WATERMARK_IMAGE_OVERLAY"); overlay {
+	case "", "text":
+	case "none":
+		opts.Overlay = nil
+	case "logo":
+		f, err := os.Open(getenv("WATERMARK_IMAGE_LOGO"))
+		if err != nil {
+			return opts, err
+		}
+		defer f.Close()
+		logo, err := png.Decode(f)
+		if err != nil {
+			return opts, fmt.Errorf("reading the logo: %w", err)
+		}
+		opts.Overlay.Logo = logo
+	default:
+		return opts, fmt.Errorf("unknown image overlay %q", overlay)
+	}
+	if opts.Overlay != nil {
+		if v := getenv("WATERMARK_IMAGE_OPACITY"); v != "" {
+			opacity, err := strconv.ParseFloat(v, 64)
+			if err != nil || opacity < 0 || opacity > 1 {
+				return opts, fmt.Errorf("invalid image opacity %q", v)
+			}
+			opts.Overlay.Opacity = opacity
+		}
+		if v := getenv("WATERMARK_IMAGE_ROTATION"); v != "" {
+			rotation, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return opts, fmt.Errorf("invalid image rotation %q", v)
+			}
+			opts.Overlay.Rotation = rotation
+		}
+		if v := getenv("WATERMARK_IMAGE_POSITION"); v != "" {
+			if !raster.ValidPosition(raster.Position(v)) {
+				return opts, fmt.Errorf("unknown image position %q", v)
+			}
+			opts.Overlay.Position = raster.Position(v)
+		}
+		opts.Overlay.Tile = getenv("WATERMARK_IMAGE_TILE") == "true"
+	}
+	opts.Invisible = getenv("WATERMARK_IMAGE_INVISIBLE") != "false"
+	return opts, nil
+}
+
+// parsePDFOptions reads the stamp options from the environment,
+// WATERMARK_PDF_TEXT is drawn instead of the mark with the opacity
+// WATERMARK_PDF_OPACITY.
+func parsePDFOptions(getenv func(string) string) (pdf.Options, error) {
+	opts := pdf.DefaultOptions()
+	opts.Text = getenv("WATERMARK_PDF_TEXT")
+	if v := getenv("WATERMARK_PDF_OPACITY"); v != "" {
+		opacity, err := strconv.ParseFloat(v, 64)
+		if err != nil || opacity < 0 || opacity > 1 {
+			return opts, fmt.Errorf("invalid PDF opacity %q", v)
+		}
+		opts.Opacity = opacity
+	}
+	return opts, nil
+}
+
+// parseEPUBOptions reads the e-book options from the environment. The
+// chapters carry the mark in the text encodings, WATERMARK_EPUB_COLOPHON set
+// to true adds a colophon printing WATERMARK_EPUB_COLOPHON_TEXT, in which
+// {mark} stands for the mark.
+func parseEPUBOptions(getenv func(string) string, textOptions text.Options) epub.Options {
+	opts := epub.DefaultOptions()
+	opts.Text = textOptions
+	if getenv("WATERMARK_EPUB_COLOPHON") == "true" {
+		opts.Colophon = getenv("WATERMARK_EPUB_COLOPHON_TEXT")
+		if opts.Colophon == "" {
+			opts.Colophon = epub.DefaultColophon
+		}
+	}
+	return opts
+}
+
+func envInt(env string, fallback int) int {
+	n, err := strconv.Atoi(os.Getenv(env))
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	return n
+}
+
+func envDuration(env string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(env))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
 func envString(env, fallback string) string {
 	e := os.Getenv(env)
 	if e == "" {
@@ -102,3 +344,17 @@ func envString(env, fallback string) string {
 	}
 	return e
 }
+
+// envSecret returns the secret of the environment variable, or the content
+// of the file named by the variable with the _FILE suffix, without the
+// trailing newline.
+func envSecret(env string) ([]byte, error) {
+	if path := os.Getenv(env + "_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.TrimRight(b, "\r\n"), nil
+	}
+	return []byte(os.Getenv(env)), nil
+}
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"publisher/internal/util"
+	"unicode/utf8"
+)
+
+// Formats of the bulk import and export.
+const (
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// maxFieldLength is the size of the varchar columns of the documents.
+const maxFieldLength = 100
+
+// DocumentReader iterates over the rows of a bulk import or export. Next
+// returns io.EOF after the last row and a *RowError for a row which could
+// not be decoded, reading may continue after a RowError.
+type DocumentReader interface {
+	Next() (*Document, error)
+}
+
+// DocumentReadCloser is a DocumentReader holding resources, such as the
+// body of a response, which must be released by calling Close.
+type DocumentReadCloser interface {
+	DocumentReader
+	io.Closer
+}
+
+// RowError reports why a single row of a bulk import was rejected, rows are
+// counted from 1 and headers are not counted.
+type RowError struct {
+	Row int    `json:"row"`
+	Err string `json:"err"`
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.Err)
+}
+
+// ImportResult summarizes a bulk import. In a dry run nothing is stored and
+// Imported counts the rows which would have been stored.
+type ImportResult struct {
+	DryRun    bool       `json:"dryRun,omitempty"`
+	Rows      int        `json:"rows"`
+	Imported  int        `json:"imported"`
+	TicketIDs []string   `json:"ticketIDs,omitempty"`
+	Errors    []RowError `json:"errors,omitempty"`
+}
+
+// ValidateDocument checks that the document has content and that its fields
+// fit into the database columns, util.ErrInvalidArgument is returned otherwise.
+func ValidateDocument(doc *Document) error {
+	if doc == nil {
+		return util.ErrInvalidArgument
+	}
+	if doc.Content == "" && doc.Title == "" {
+		return fmt.Errorf("%w: title or content is required", util.ErrInvalidArgument)
+	}
+	for _, f := range []struct{ key, value string }{
+		{KeyTicketID, doc.TicketID},
+		{KeyTitle, doc.Title},
+		{KeyAuthor, doc.Author},
+		{KeyTopic, doc.Topic},
+		{KeyWatermark, doc.Watermark},
+	} {
+		if utf8.RuneCountInString(f.value) > maxFieldLength {
+			return fmt.Errorf("%w: %s is longer than %d characters", util.ErrInvalidArgument, f.key, maxFieldLength)
+		}
+	}
+	return nil
+}
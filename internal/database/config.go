@@ -0,0 +1,346 @@
+package database
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config configures the connection to the postgreSQL database.
+type Config struct {
+	// DSN is a complete connection string, either key=value pairs or a
+	// postgres:// URL. It replaces the connection settings below, the pool
+	// and retry settings still apply.
+	DSN      string
+	Host     string
+	Port     string
+	Database string
+	User     string
+	Password string
+	TimeZone string
+	// SSLMode is disable, allow, prefer, require, verify-ca or verify-full
+	SSLMode     string
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+	// ConnectTimeout bounds a single connection attempt
+	ConnectTimeout time.Duration
+
+	// MaxOpenConns of 0 allows any number of connections
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// ConnectRetries is the number of retries of a failing connection at
+	// startup, the wait starts at RetryBackoff and doubles up to RetryMaxBackoff.
+	ConnectRetries  int
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+}
+
+// DefaultConfig returns the configuration of a local development database.
+func DefaultConfig() Config {
+	return Config{
+		Host:            "127.0.0.1",
+		Port:            "5432",
+		Database:        "publisher",
+		User:            "root",
+		Password:        "root",
+		TimeZone:        "Asia/Shanghai",
+		SSLMode:         "disable",
+		ConnectTimeout:  10 * time.Second,
+		MaxOpenConns:    25,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: 30 * time.Minute,
+		ConnMaxIdleTime: 5 * time.Minute,
+		ConnectRetries:  5,
+		RetryBackoff:    500 * time.Millisecond,
+		RetryMaxBackoff: 30 * time.Second,
+	}
+}
+
+var sslModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true, "require": true, "verify-ca": true, "verify-full": true,
+}
+
+// Validate reports the first invalid setting.
+func (c Config) Validate() error {
+	switch {
+	case c.DSN == "" && (c.Host == "" || c.Database == "" || c.User == ""):
+		return fmt.Errorf("database config: host, name and user are required without a dsn")
+	case c.DSN == "" && !sslModes[c.SSLMode]:
+		return fmt.Errorf("database config: unknown sslmode %q", c.SSLMode)
+	case c.MaxOpenConns < 0 || c.MaxIdleConns < 0 || c.ConnectRetries < 0:
+		return fmt.Errorf("database config: pool sizes and retries must not be negative")
+	case c.ConnectTimeout < 0 || c.ConnMaxLifetime < 0 || c.ConnMaxIdleTime < 0 || c.RetryBackoff < 0 || c.RetryMaxBackoff < 0:
+		return fmt.Errorf("database config: durations must not be negative")
+	}
+	if c.DSN == "" {
+		if _, err := strconv.ParseUint(c.Port, 10, 16); err != nil {
+			return fmt.Errorf("database config: invalid port %q", c.Port)
+		}
+	}
+	return nil
+}
+
+// DataSourceName returns the DSN or the connection string built from the
+// connection settings.
+func (c Config) DataSourceName() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+	pairs := []struct{ key, value string }{
+		{"host", c.Host},
+		{"port", c.Port},
+		{"dbname", c.Database},
+		{"user", c.User},
+		{"password", c.Password},
+		{"TimeZone", c.TimeZone},
+		{"sslmode", c.SSLMode},
+		{"sslrootcert", c.SSLRootCert},
+		{"sslcert", c.SSLCert},
+		{"sslkey", c.SSLKey},
+	}
+	if c.ConnectTimeout > 0 {
+		// the timeout is given in whole seconds, at least one
+		seconds := int64((c.ConnectTimeout + time.Second - 1) / time.Second)
+		pairs = append(pairs, struct{ key, value string }{"connect_timeout", strconv.FormatInt(seconds, 10)})
+	}
+	var b strings.Builder
+	for _, p := range pairs {
+		if p.value == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(p.key)
+		b.WriteByte('=')
+		b.WriteString(quoteDSNValue(p.value))
+	}
+	return b.String()
+}
+
+// quoteDSNValue quotes values containing spaces or quotes as libpq expects.
+func quoteDSNValue(v string) string {
+	if !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// setting is a configuration value settable by flag, environment variable
+// and config file key.
+type setting struct {
+	key   string
+	env   string
+	usage string
+	value flag.Value
+}
+
+// settings binds the settings to the fields of c. The *-file settings are
+// read into file names which are resolved by readSecrets.
+func (c *Config) settings(files *secretFiles) []setting {
+	return []setting{
+		{"dsn", "DATABASE_DSN", "connection string, replaces the connection settings", (*stringValue)(&c.DSN)},
+		{"dsn-file", "DATABASE_DSN_FILE", "file containing the connection string", (*stringValue)(&files.dsn)},
+		{"host", "DATABASE_HOST", "database host", (*stringValue)(&c.Host)},
+		{"port", "DATABASE_PORT", "database port", (*stringValue)(&c.Port)},
+		{"name", "DATABASE_NAME", "database name", (*stringValue)(&c.Database)},
+		{"user", "DATABASE_USER", "database user", (*stringValue)(&c.User)},
+		{"password", "DATABASE_PASSWORD", "database password, prefer password-file", (*stringValue)(&c.Password)},
+		{"password-file", "DATABASE_PASSWORD_FILE", "file containing the database password", (*stringValue)(&files.password)},
+		{"timezone", "DATABASE_TIMEZONE", "time zone of the session", (*stringValue)(&c.TimeZone)},
+		{"sslmode", "DATABASE_SSLMODE", "disable, allow, prefer, require, verify-ca or verify-full", (*stringValue)(&c.SSLMode)},
+		{"sslrootcert", "DATABASE_SSLROOTCERT", "CA certificate file verifying the server", (*stringValue)(&c.SSLRootCert)},
+		{"sslcert", "DATABASE_SSLCERT", "client certificate file", (*stringValue)(&c.SSLCert)},
+		{"sslkey", "DATABASE_SSLKEY", "client key file", (*stringValue)(&c.SSLKey)},
+		{"connect-timeout", "DATABASE_CONNECT_TIMEOUT", "timeout of a connection attempt", (*durationValue)(&c.ConnectTimeout)},
+		{"max-open-conns", "DATABASE_MAX_OPEN_CONNS", "maximum open connections, 0 is unlimited", (*intValue)(&c.MaxOpenConns)},
+		{"max-idle-conns", "DATABASE_MAX_IDLE_CONNS", "maximum idle connections", (*intValue)(&c.MaxIdleConns)},
+		{"conn-max-lifetime", "DATABASE_CONN_MAX_LIFETIME", "maximum lifetime of a connection, 0 is unlimited", (*durationValue)(&c.ConnMaxLifetime)},
+		{"conn-max-idle-time", "DATABASE_CONN_MAX_IDLE_TIME", "maximum idle time of a connection, 0 is unlimited", (*durationValue)(&c.ConnMaxIdleTime)},
+		{"connect-retries", "DATABASE_CONNECT_RETRIES", "retries of a failing connection at startup", (*intValue)(&c.ConnectRetries)},
+		{"retry-backoff", "DATABASE_RETRY_BACKOFF", "first wait between connection retries, doubled per retry", (*durationValue)(&c.RetryBackoff)},
+		{"retry-max-backoff", "DATABASE_RETRY_MAX_BACKOFF", "maximum wait between connection retries", (*durationValue)(&c.RetryMaxBackoff)},
+	}
+}
+
+type secretFiles struct {
+	dsn      string
+	password string
+}
+
+// FlagPrefix prefixes the flags of the database settings.
+const FlagPrefix = "db-"
+
+// ConfigFlags registers the database settings as flags on fs, together with
+// the db-config flag naming a JSON config file. The returned function
+// resolves the configuration once fs is parsed, later sources override
+// earlier ones: defaults, config file, environment, flags. Secrets named by
+// the *-file settings are read last.
+func ConfigFlags(fs *flag.FlagSet) func(getenv func(string) string) (Config, error) {
+	var (
+		// the defaults are only shown by the usage, unset flags are ignored
+		flagConfig = DefaultConfig()
+		flagFiles  secretFiles
+		configFile = fs.String(FlagPrefix+"config", "", "JSON config file of the database settings (env DATABASE_CONFIG)")
+	)
+	flagSettings := flagConfig.settings(&flagFiles)
+	for _, s := range flagSettings {
+		fs.Var(s.value, FlagPrefix+s.key, fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+
+	return func(getenv func(string) string) (Config, error) {
+		var (
+			cfg   = DefaultConfig()
+			files secretFiles
+		)
+		settings := cfg.settings(&files)
+
+		path := *configFile
+		if path == "" {
+			path = getenv("DATABASE_CONFIG")
+		}
+		if path != "" {
+			if err := loadConfigFile(path, settings); err != nil {
+				return cfg, err
+			}
+		}
+		for _, s := range settings {
+			if v := getenv(s.env); v != "" {
+				if err := s.value.Set(v); err != nil {
+					return cfg, fmt.Errorf("database config: %s: %w", s.env, err)
+				}
+			}
+		}
+		set := map[string]bool{}
+		fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		for i, s := range flagSettings {
+			if set[FlagPrefix+s.key] {
+				// flag values were already validated by the parse
+				settings[i].value.Set(s.value.String())
+			}
+		}
+		if err := readSecrets(&cfg, files); err != nil {
+			return cfg, err
+		}
+		return cfg, cfg.Validate()
+	}
+}
+
+// loadConfigFile applies the settings of a JSON object keyed by setting,
+// durations are strings such as "30s".
+func loadConfigFile(path string, settings []setting) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("database config: %w", err)
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(b, &values); err != nil {
+		return fmt.Errorf("database config: %s: %w", path, err)
+	}
+	known := map[string]flag.Value{}
+	for _, s := range settings {
+		known[s.key] = s.value
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, ok := known[key]
+		if !ok {
+			return fmt.Errorf("database config: %s: unknown setting %q", path, key)
+		}
+		raw := values[key]
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			// numbers are used as written
+			s = string(raw)
+		}
+		if err := value.Set(s); err != nil {
+			return fmt.Errorf("database config: %s: %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// readSecrets replaces the DSN and password by the content of their files,
+// a trailing newline is removed.
+func readSecrets(cfg *Config, files secretFiles) error {
+	for _, f := range []struct {
+		path   string
+		target *string
+	}{
+		{files.dsn, &cfg.DSN},
+		{files.password, &cfg.Password},
+	} {
+		if f.path == "" {
+			continue
+		}
+		b, err := os.ReadFile(f.path)
+		if err != nil {
+			return fmt.Errorf("database config: %w", err)
+		}
+		*f.target = strings.TrimRight(string(b), "\r\n")
+	}
+	return nil
+}
+
+type stringValue string
+
+func (s *stringValue) Set(v string) error {
+	*s = stringValue(v)
+	return nil
+}
+
+func (s *stringValue) String() string {
+	if s == nil {
+		return ""
+	}
+	return string(*s)
+}
+
+type intValue int
+
+func (i *intValue) Set(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", v)
+	}
+	*i = intValue(n)
+	return nil
+}
+
+func (i *intValue) String() string {
+	if i == nil {
+		return "0"
+	}
+	return strconv.Itoa(int(*i))
+}
+
+type durationValue time.Duration
+
+func (d *durationValue) Set(v string) error {
+	duration, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", v)
+	}
+	*d = durationValue(duration)
+	return nil
+}
+
+func (d *durationValue) String() string {
+	if d == nil {
+		return "0s"
+	}
+	return time.Duration(*d).String()
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resolveConfig parses the flags and resolves the configuration with the
+// environment, a config file is written for a non-empty file.
+func resolveConfig(t *testing.T, args []string, env map[string]string, file string) (Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	resolve := ConfigFlags(fs)
+	if file != "" {
+		path := filepath.Join(t.TempDir(), "database.json")
+		if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		args = append(args, "-"+FlagPrefix+"config", path)
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	return resolve(func(key string) string { return env[key] })
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	var (
+		file = `{"host": "file-host", "max-open-conns": 10, "retry-backoff": "2s"}`
+		env  = map[string]string{"DATABASE_HOST": "env-host", "DATABASE_MAX_OPEN_CONNS": "20"}
+		args = []string{"-db-host", "flag-host"}
+	)
+	for _, tt := range []struct {
+		name         string
+		args         []string
+		env          map[string]string
+		file         string
+		host         string
+		maxOpenConns int
+		retryBackoff time.Duration
+	}{
+		{"default", nil, nil, "", "127.0.0.1", 25, 500 * time.Millisecond},
+		{"file", nil, nil, file, "file-host", 10, 2 * time.Second},
+		{"env", nil, env, "", "env-host", 20, 500 * time.Millisecond},
+		{"env over file", nil, env, file, "env-host", 20, 2 * time.Second},
+		{"flag", args, nil, "", "flag-host", 25, 500 * time.Millisecond},
+		{"flag over file", args, nil, file, "flag-host", 10, 2 * time.Second},
+		{"flag over env and file", args, env, file, "flag-host", 20, 2 * time.Second},
+		// an empty variable is unset
+		{"empty env", nil, map[string]string{"DATABASE_HOST": ""}, file, "file-host", 10, 2 * time.Second},
+	} {
+		cfg, err := resolveConfig(t, tt.args, tt.env, tt.file)
+		if err != nil {
+			t.Errorf("%s: returned %v", tt.name, err)
+			continue
+		}
+		if cfg.Host != tt.host || cfg.MaxOpenConns != tt.maxOpenConns || cfg.RetryBackoff != tt.retryBackoff {
+			t.Errorf("%s: host %q, max open conns %d, retry backoff %v, want %q, %d, %v",
+				tt.name, cfg.Host, cfg.MaxOpenConns, cfg.RetryBackoff, tt.host, tt.maxOpenConns, tt.retryBackoff)
+		}
+		// the settings no source sets keep their defaults
+		if cfg.Database != "publisher" || cfg.Port != "5432" {
+			t.Errorf("%s: database %q port %q, want the defaults", tt.name, cfg.Database, cfg.Port)
+		}
+	}
+}
+
+func TestConfigFileFromEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	if err := os.WriteFile(path, []byte(`{"name": "other"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := resolveConfig(t, nil, map[string]string{"DATABASE_CONFIG": path}, "")
+	if err != nil || cfg.Database != "other" {
+		t.Errorf("returned %q, %v, want %q", cfg.Database, err, "other")
+	}
+}
+
+func TestConfigSecrets(t *testing.T) {
+	dir := t.TempDir()
+	secret := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	password := secret("password", "s3cret\n")
+	other := secret("other", "other\r\n")
+	dsn := secret("dsn", "postgres://u:p@db/publisher")
+	missing := filepath.Join(dir, "missing")
+
+	for _, tt := range []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		file     string
+		password string
+		dsn      string
+		err      bool
+	}{
+		{"env", nil, map[string]string{"DATABASE_PASSWORD_FILE": password}, "", "s3cret", "", false},
+		{"flag", []string{"-db-password-file", password}, nil, "", "s3cret", "", false},
+		{"config file", nil, nil, `{"password-file": "` + password + `"}`, "s3cret", "", false},
+		// the file replaces a password given directly by any source
+		{"file over password", []string{"-db-password", "plain"}, map[string]string{"DATABASE_PASSWORD_FILE": password}, "", "s3cret", "", false},
+		{"flag file over env file", []string{"-db-password-file", other}, map[string]string{"DATABASE_PASSWORD_FILE": password}, "", "other", "", false},
+		{"dsn", nil, map[string]string{"DATABASE_DSN_FILE": dsn}, "", "root", "postgres://u:p@db/publisher", false},
+		{"missing", nil, map[string]string{"DATABASE_PASSWORD_FILE": missing}, "", "", "", true},
+	} {
+		cfg, err := resolveConfig(t, tt.args, tt.env, tt.file)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: returned %v", tt.name, err)
+			continue
+		}
+		if err == nil && (cfg.Password != tt.password || cfg.DSN != tt.dsn) {
+			t.Errorf("%s: password %q dsn %q, want %q %q", tt.name, cfg.Password, cfg.DSN, tt.password, tt.dsn)
+		}
+	}
+}
+
+func TestConfigInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args []string
+		env  map[string]string
+		file string
+		err  string
+	}{
+		{"unknown key", nil, nil, `{"hots": "db"}`, `unknown setting "hots"`},
+		{"invalid json", nil, nil, `{`, "unexpected end of JSON input"},
+		{"invalid file number", nil, nil, `{"max-open-conns": "many"}`, `max-open-conns: invalid number "many"`},
+		{"invalid env duration", nil, map[string]string{"DATABASE_RETRY_BACKOFF": "soon"}, "", `DATABASE_RETRY_BACKOFF: invalid duration "soon"`},
+		{"invalid flag", []string{"-db-connect-retries", "often"}, nil, "", `invalid number "often"`},
+		{"invalid port", nil, map[string]string{"DATABASE_PORT": "70000"}, "", `invalid port "70000"`},
+		{"invalid sslmode", []string{"-db-sslmode", "always"}, nil, "", `unknown sslmode "always"`},
+		{"negative pool", nil, map[string]string{"DATABASE_MAX_IDLE_CONNS": "-1"}, "", "must not be negative"},
+	} {
+		_, err := resolveConfig(t, tt.args, tt.env, tt.file)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: returned %v, want an error containing %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestDataSourceName(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Password = "it's a secret"
+	cfg.ConnectTimeout = 1500 * time.Millisecond
+	want := `host=127.0.0.1 port=5432 dbname=publisher user=root password='it\'s a secret' TimeZone=Asia/Shanghai sslmode=disable connect_timeout=2`
+	if got := cfg.DataSourceName(); got != want {
+		t.Errorf("DataSourceName returned %s, want %s", got, want)
+	}
+	cfg.DSN = "postgres://db/publisher"
+	if got := cfg.DataSourceName(); got != cfg.DSN {
+		t.Errorf("DataSourceName returned %s, want the DSN %s", got, cfg.DSN)
+	}
+}
@@ -1,10 +0,0 @@
-package database
-
-const (
-	DefaultDatabase = "publisher"
-	DefaultHost     = "127.0.0.1"
-	DefaultPort     = "5432"
-	DefaultDBUser   = "root"
-	DefaultPassword = "root"
-	DefaultTimeZone = "Asia/Shanghai"
-)
@@ -0,0 +1,260 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationLockID is the postgres advisory lock key held while a migration
+// runs, so that two nodes never migrate the same database at once.
+const migrationLockID = 7310452
+
+// Migration is a single versioned schema change. Up and Down are plain SQL
+// statements executed inside one transaction.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum identifies the content of the migration, an applied migration
+// must never change afterwards.
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up + "\x00" + m.Down))
+	return hex.EncodeToString(sum[:])
+}
+
+// SchemaMigration is the bookkeeping row of an applied migration.
+type SchemaMigration struct {
+	Version   int64  `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"type:varchar(255)"`
+	Checksum  string `gorm:"type:char(64)"`
+	AppliedAt time.Time
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationStatus reports whether a registered migration has been applied.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator returns a migrator for the given migrations, they must have
+// unique and positive versions.
+func NewMigrator(db *gorm.DB, migrations []Migration) (*Migrator, error) {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, m := range sorted {
+		if m.Version <= 0 {
+			return nil, fmt.Errorf("migration %q has invalid version %d", m.Name, m.Version)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("duplicate migration version %d", m.Version)
+		}
+	}
+	return &Migrator{db: db, migrations: sorted}, nil
+}
+
+// Up applies every pending migration up to and including target, a target
+// of zero means the latest version.
+func (m *Migrator) Up(ctx context.Context, target int64) ([]Migration, error) {
+	var done []Migration
+	for _, mig := range m.migrations {
+		if target > 0 && mig.Version > target {
+			break
+		}
+		mig := mig
+		applied := false
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := lock(tx); err != nil {
+				return err
+			}
+			if err := createTable(tx); err != nil {
+				return err
+			}
+			rows, err := m.verified(tx)
+			if err != nil {
+				return err
+			}
+			if _, ok := rows[mig.Version]; ok {
+				return nil
+			}
+			if err := tx.Exec(mig.Up).Error; err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			applied = true
+			return tx.Create(&SchemaMigration{
+				Version:   mig.Version,
+				Name:      mig.Name,
+				Checksum:  mig.Checksum(),
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return done, err
+		}
+		if applied {
+			done = append(done, mig)
+		}
+	}
+	return done, nil
+}
+
+// Down reverts the given number of most recently applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	for ; steps > 0; steps-- {
+		var reverted *Migration
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := lock(tx); err != nil {
+				return err
+			}
+			rows, err := m.verified(tx)
+			if err != nil || len(rows) == 0 {
+				return err
+			}
+			var last int64
+			for version := range rows {
+				if version > last {
+					last = version
+				}
+			}
+			// verified makes sure the applied migrations are registered
+			mig, _ := m.lookup(last)
+			if err := tx.Exec(mig.Down).Error; err != nil {
+				return fmt.Errorf("revert migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			reverted = &mig
+			return tx.Delete(&SchemaMigration{}, "version = ?", mig.Version).Error
+		})
+		if err != nil {
+			return done, err
+		}
+		if reverted == nil {
+			break
+		}
+		done = append(done, *reverted)
+	}
+	return done, nil
+}
+
+// Status lists every registered migration with its applied state. It only
+// reads, a database never migrated has no migration applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := applied(m.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	status := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := MigrationStatus{Migration: mig}
+		if row, ok := applied[mig.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = row.AppliedAt
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// Pending returns the registered migrations which are not applied yet.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, s := range status {
+		if !s.Applied {
+			pending = append(pending, s.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Verify checks that every applied migration is still registered with the
+// very same content it had when it was applied. Like Status it only reads,
+// Up and Down verify again while they hold the migration lock.
+func (m *Migrator) Verify(ctx context.Context) error {
+	_, err := m.verified(m.db.WithContext(ctx))
+	return err
+}
+
+// verified returns the applied migrations once they passed Verify.
+func (m *Migrator) verified(db *gorm.DB) (map[int64]SchemaMigration, error) {
+	rows, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+	for version, row := range rows {
+		mig, ok := m.lookup(version)
+		if !ok {
+			return nil, fmt.Errorf("applied migration %d_%s is not registered", version, row.Name)
+		}
+		if mig.Checksum() != row.Checksum {
+			return nil, fmt.Errorf("checksum mismatch for migration %d_%s", version, mig.Name)
+		}
+	}
+	return rows, nil
+}
+
+// applied returns the applied migrations by version, none if the
+// bookkeeping table was not created yet.
+func applied(db *gorm.DB) (map[int64]SchemaMigration, error) {
+	var exists bool
+	if err := db.Raw("SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists).Error; err != nil {
+		return nil, err
+	}
+	applied := map[int64]SchemaMigration{}
+	if !exists {
+		return applied, nil
+	}
+	var rows []SchemaMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// createTable creates the bookkeeping table, it must run under the lock.
+func createTable(tx *gorm.DB) error {
+	return tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		checksum char(64) NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`).Error
+}
+
+func (m *Migrator) lookup(version int64) (Migration, bool) {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig, true
+		}
+	}
+	return Migration{}, false
+}
+
+func lock(tx *gorm.DB) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockID).Error
+}
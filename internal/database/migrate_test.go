@@ -0,0 +1,320 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeDB stands in for postgres, it understands the statements of the
+// migrator and records every other statement as executed.
+type fakeDB struct {
+	mu sync.Mutex
+	// table tells whether schema_migrations was created
+	table    bool
+	rows     map[int64]SchemaMigration
+	executed []string
+	// fail is a statement which returns an error
+	fail string
+	// saved is the state at the start of the open transaction
+	saved *fakeDB
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+func (f *fakeDB) snapshot() *fakeDB {
+	rows := make(map[int64]SchemaMigration, len(f.rows))
+	for k, v := range f.rows {
+		rows[k] = v
+	}
+	return &fakeDB{table: f.table, rows: rows, executed: append([]string(nil), f.executed...)}
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c fakeConn) Close() error                        { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.saved = c.db.snapshot()
+	return c, nil
+}
+
+func (c fakeConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.saved = nil
+	return nil
+}
+
+func (c fakeConn) Rollback() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.table, c.db.rows, c.db.executed, c.db.saved = c.db.saved.table, c.db.saved.rows, c.db.saved.executed, nil
+	return nil
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	switch {
+	case strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+		c.db.table = true
+	case strings.HasPrefix(query, "SELECT pg_advisory_xact_lock"):
+	case strings.HasPrefix(query, `INSERT INTO "schema_migrations"`):
+		c.db.rows[args[0].Value.(int64)] = SchemaMigration{
+			Version:   args[0].Value.(int64),
+			Name:      args[1].Value.(string),
+			Checksum:  args[2].Value.(string),
+			AppliedAt: args[3].Value.(time.Time),
+		}
+	case strings.HasPrefix(query, `DELETE FROM "schema_migrations" WHERE version = `):
+		delete(c.db.rows, args[0].Value.(int64))
+	case query == c.db.fail:
+		return nil, errors.New("syntax error")
+	default:
+		c.db.executed = append(c.db.executed, query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	var rows []SchemaMigration
+	for _, row := range c.db.rows {
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Version < rows[j].Version })
+	switch query {
+	case "SELECT to_regclass('schema_migrations') IS NOT NULL":
+		return &fakeRows{columns: []string{"exists"}, values: [][]driver.Value{{c.db.table}}}, nil
+	case `SELECT * FROM "schema_migrations" ORDER BY version`:
+		if !c.db.table {
+			return nil, errors.New(`relation "schema_migrations" does not exist`)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected query %q", query)
+	}
+	result := &fakeRows{columns: []string{"version", "name", "checksum", "applied_at"}}
+	for _, row := range rows {
+		result.values = append(result.values, []driver.Value{row.Version, row.Name, row.Checksum, row.AppliedAt})
+	}
+	return result, nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func openFakeDB(t *testing.T, fake *fakeDB) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(fake), WithoutReturning: true}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+var testMigrations = []Migration{
+	{Version: 3, Name: "three", Up: "up 3", Down: "down 3"},
+	{Version: 1, Name: "one", Up: "up 1", Down: "down 1"},
+	{Version: 2, Name: "two", Up: "up 2", Down: "down 2"},
+}
+
+func newTestMigrator(t *testing.T, fake *fakeDB, migrations []Migration) *Migrator {
+	t.Helper()
+	m, err := NewMigrator(openFakeDB(t, fake), migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func versions(migrations []Migration) []int64 {
+	v := []int64{}
+	for _, m := range migrations {
+		v = append(v, m.Version)
+	}
+	return v
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeDB{rows: map[int64]SchemaMigration{}}
+	m := newTestMigrator(t, fake, testMigrations)
+
+	for _, tt := range []struct {
+		name     string
+		run      func() ([]Migration, error)
+		done     []int64
+		executed []string
+		pending  []int64
+	}{
+		{"up to 2", func() ([]Migration, error) { return m.Up(ctx, 2) }, []int64{1, 2}, []string{"up 1", "up 2"}, []int64{3}},
+		{"up to 2 again", func() ([]Migration, error) { return m.Up(ctx, 2) }, []int64{}, nil, []int64{3}},
+		{"up", func() ([]Migration, error) { return m.Up(ctx, 0) }, []int64{3}, []string{"up 3"}, []int64{}},
+		{"down 2", func() ([]Migration, error) { return m.Down(ctx, 2) }, []int64{3, 2}, []string{"down 3", "down 2"}, []int64{2, 3}},
+		{"up again", func() ([]Migration, error) { return m.Up(ctx, 0) }, []int64{2, 3}, []string{"up 2", "up 3"}, []int64{}},
+		{"down too many", func() ([]Migration, error) { return m.Down(ctx, 5) }, []int64{3, 2, 1}, []string{"down 3", "down 2", "down 1"}, []int64{1, 2, 3}},
+		{"down without migrations", func() ([]Migration, error) { return m.Down(ctx, 1) }, []int64{}, nil, []int64{1, 2, 3}},
+	} {
+		fake.executed = nil
+		done, err := tt.run()
+		if err != nil {
+			t.Errorf("%s returned %v", tt.name, err)
+			continue
+		}
+		if got := versions(done); !reflect.DeepEqual(got, tt.done) {
+			t.Errorf("%s migrated %v, want %v", tt.name, got, tt.done)
+		}
+		if !reflect.DeepEqual(fake.executed, tt.executed) {
+			t.Errorf("%s executed %q, want %q", tt.name, fake.executed, tt.executed)
+		}
+		pending, err := m.Pending(ctx)
+		if got := versions(pending); err != nil || !reflect.DeepEqual(got, tt.pending) {
+			t.Errorf("%s: Pending returned %v, %v, want %v", tt.name, got, err, tt.pending)
+		}
+	}
+}
+
+func TestMigratorReadOnly(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeDB{rows: map[int64]SchemaMigration{}}
+	m := newTestMigrator(t, fake, testMigrations)
+
+	// a database never migrated has nothing applied and is left as it is
+	status, err := m.Status(ctx)
+	if err != nil || len(status) != 3 || status[0].Applied {
+		t.Errorf("Status returned %+v, %v, want 3 migrations not applied", status, err)
+	}
+	if err := m.Verify(ctx); err != nil {
+		t.Errorf("Verify returned %v", err)
+	}
+	if _, err := m.Down(ctx, 1); err != nil {
+		t.Errorf("Down returned %v", err)
+	}
+	if fake.table || len(fake.executed) > 0 {
+		t.Errorf("reading the status created the table %v and executed %q", fake.table, fake.executed)
+	}
+
+	if _, err := m.Up(ctx, 1); err != nil || !fake.table {
+		t.Errorf("Up returned %v and created the table %v", err, fake.table)
+	}
+	if pending, err := m.Pending(ctx); err != nil || !reflect.DeepEqual(versions(pending), []int64{2, 3}) {
+		t.Errorf("Pending after Up returned %v, %v, want [2 3]", versions(pending), err)
+	}
+}
+
+func TestMigratorFailure(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeDB{rows: map[int64]SchemaMigration{}, fail: "up 2"}
+	m := newTestMigrator(t, fake, testMigrations)
+
+	done, err := m.Up(ctx, 0)
+	if err == nil || !strings.Contains(err.Error(), "2_two") {
+		t.Errorf("Up returned %v, want the error of migration 2", err)
+	}
+	// the migrations before stay applied, the failed one is rolled back
+	if got := versions(done); !reflect.DeepEqual(got, []int64{1}) {
+		t.Errorf("Up migrated %v, want [1]", got)
+	}
+	if len(fake.rows) != 1 || !reflect.DeepEqual(fake.executed, []string{"up 1"}) {
+		t.Errorf("Up left %d migrations and executed %q, want 1 and [up 1]", len(fake.rows), fake.executed)
+	}
+}
+
+func TestMigratorChecksum(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeDB{rows: map[int64]SchemaMigration{}}
+	if _, err := newTestMigrator(t, fake, testMigrations).Up(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	edited := append([]Migration(nil), testMigrations...)
+	edited[1].Up = "up 1 changed"
+	unregistered := append([]Migration(nil), testMigrations[0], testMigrations[2])
+
+	for _, tt := range []struct {
+		name       string
+		migrations []Migration
+		err        string
+	}{
+		{"edited", edited, "checksum mismatch for migration 1_one"},
+		{"unregistered", unregistered, "applied migration 1_one is not registered"},
+	} {
+		m := newTestMigrator(t, fake, tt.migrations)
+		fake.executed = nil
+		if err := m.Verify(ctx); err == nil || err.Error() != tt.err {
+			t.Errorf("%s: Verify returned %v, want %q", tt.name, err, tt.err)
+		}
+		if _, err := m.Up(ctx, 0); err == nil || err.Error() != tt.err {
+			t.Errorf("%s: Up returned %v, want %q", tt.name, err, tt.err)
+		}
+		if _, err := m.Down(ctx, 1); err == nil || err.Error() != tt.err {
+			t.Errorf("%s: Down returned %v, want %q", tt.name, err, tt.err)
+		}
+		if len(fake.executed) > 0 || len(fake.rows) != 2 {
+			t.Errorf("%s: executed %q with %d migrations applied", tt.name, fake.executed, len(fake.rows))
+		}
+	}
+
+	// a migration not yet applied may still change
+	pending := append([]Migration(nil), testMigrations...)
+	pending[0].Up = "up 3 changed"
+	fake.executed = nil
+	if _, err := newTestMigrator(t, fake, pending).Up(ctx, 0); err != nil || !reflect.DeepEqual(fake.executed, []string{"up 3 changed"}) {
+		t.Errorf("Up returned %v and executed %q", err, fake.executed)
+	}
+}
+
+func TestNewMigrator(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		migrations []Migration
+		ok         bool
+	}{
+		{"sorted", testMigrations, true},
+		{"none", nil, true},
+		{"duplicate", append([]Migration{{Version: 2, Name: "again"}}, testMigrations...), false},
+		{"zero", []Migration{{Version: 0, Name: "zero"}}, false},
+		{"negative", []Migration{{Version: -1, Name: "negative"}}, false},
+	} {
+		if _, err := NewMigrator(nil, tt.migrations); (err == nil) != tt.ok {
+			t.Errorf("%s: NewMigrator returned %v", tt.name, err)
+		}
+	}
+}
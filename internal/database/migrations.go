@@ -0,0 +1,217 @@
+package database
+
+// Migrations is the ordered schema history of the database node. Applied
+// migrations must never be edited, append a new one instead.
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_documents",
+		Up: `CREATE TABLE documents (
+			id bigserial PRIMARY KEY,
+			created_at timestamptz,
+			updated_at timestamptz,
+			deleted_at timestamptz,
+			ticket_id varchar(100),
+			content varchar(100),
+			title varchar(100),
+			author varchar(100),
+			topic varchar(100),
+			watermark varchar(100)
+		);
+		CREATE UNIQUE INDEX idx_documents_ticket_id ON documents (ticket_id);
+		CREATE INDEX idx_documents_deleted_at ON documents (deleted_at);`,
+		Down: `DROP TABLE documents;`,
+	},
+	{
+		Version: 2,
+		Name:    "widen_document_content",
+		Up:      `ALTER TABLE documents ALTER COLUMN content TYPE text;`,
+		Down:    `ALTER TABLE documents ALTER COLUMN content TYPE varchar(100) USING left(content, 100);`,
+	},
+	{
+		Version: 3,
+		Name:    "add_document_search",
+		Up: `ALTER TABLE documents ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+			setweight(to_tsvector('simple', coalesce(author, '')), 'B') ||
+			setweight(to_tsvector('simple', coalesce(topic, '')), 'B') ||
+			setweight(to_tsvector('simple', coalesce(content, '')), 'C')
+		) STORED;
+		CREATE INDEX idx_documents_search ON documents USING GIN (search_vector);`,
+		Down: `DROP INDEX idx_documents_search;
+		ALTER TABLE documents DROP COLUMN search_vector;`,
+	},
+	{
+		Version: 4,
+		Name:    "add_document_version",
+		Up:      `ALTER TABLE documents ADD COLUMN version bigint NOT NULL DEFAULT 1;`,
+		Down:    `ALTER TABLE documents DROP COLUMN version;`,
+	},
+	{
+		Version: 5,
+		Name:    "create_document_revisions",
+		Up: `CREATE TABLE document_revisions (
+			id bigserial PRIMARY KEY,
+			created_at timestamptz,
+			ticket_id varchar(100) NOT NULL REFERENCES documents (ticket_id) ON DELETE CASCADE,
+			number bigint NOT NULL,
+			editor varchar(100),
+			changes text,
+			rollback_of bigint NOT NULL DEFAULT 0,
+			content text,
+			title varchar(100),
+			author varchar(100),
+			topic varchar(100),
+			watermark varchar(100)
+		);
+		CREATE UNIQUE INDEX idx_revisions_ticket_number ON document_revisions (ticket_id, number);
+		INSERT INTO document_revisions (created_at, ticket_id, number, content, title, author, topic, watermark)
+			SELECT updated_at, ticket_id, version, content, title, author, topic, watermark FROM documents;`,
+		Down: `DROP TABLE document_revisions;`,
+	},
+	{
+		Version: 6,
+		Name:    "move_content_to_blobs",
+		// the inline content columns are emptied by the database node, which
+		// moves their content to the blob store at startup
+		Up: `ALTER TABLE documents
+			ADD COLUMN content_digest varchar(64) NOT NULL DEFAULT '',
+			ADD COLUMN content_size bigint NOT NULL DEFAULT 0,
+			ADD COLUMN content_vector tsvector;
+		UPDATE documents SET content_vector = to_tsvector('simple', coalesce(content, ''));
+		DROP INDEX idx_documents_search;
+		ALTER TABLE documents DROP COLUMN search_vector;
+		ALTER TABLE documents ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+			setweight(to_tsvector('simple', coalesce(author, '')), 'B') ||
+			setweight(to_tsvector('simple', coalesce(topic, '')), 'B') ||
+			setweight(coalesce(content_vector, ''::tsvector), 'C')
+		) STORED;
+		CREATE INDEX idx_documents_search ON documents USING GIN (search_vector);
+		CREATE INDEX idx_documents_content_digest ON documents (content_digest);
+		ALTER TABLE document_revisions
+			ADD COLUMN content_digest varchar(64) NOT NULL DEFAULT '',
+			ADD COLUMN content_size bigint NOT NULL DEFAULT 0;
+		CREATE INDEX idx_document_revisions_content_digest ON document_revisions (content_digest);`,
+		// content moved to the blob store is not copied back
+		Down: `DROP INDEX idx_document_revisions_content_digest;
+		ALTER TABLE document_revisions DROP COLUMN content_digest, DROP COLUMN content_size;
+		DROP INDEX idx_documents_content_digest;
+		DROP INDEX idx_documents_search;
+		ALTER TABLE documents DROP COLUMN search_vector;
+		ALTER TABLE documents DROP COLUMN content_vector, DROP COLUMN content_digest, DROP COLUMN content_size;
+		ALTER TABLE documents ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+			setweight(to_tsvector('simple', coalesce(author, '')), 'B') ||
+			setweight(to_tsvector('simple', coalesce(topic, '')), 'B') ||
+			setweight(to_tsvector('simple', coalesce(content, '')), 'C')
+		) STORED;
+		CREATE INDEX idx_documents_search ON documents USING GIN (search_vector);`,
+	},
+	{
+		Version: 7,
+		Name:    "create_idempotency_keys",
+		Up: `CREATE TABLE idempotency_keys (
+			scope varchar(100) NOT NULL,
+			key varchar(255) NOT NULL,
+			request_hash varchar(64) NOT NULL,
+			ticket_id varchar(100) NOT NULL,
+			created_at timestamptz NOT NULL,
+			expires_at timestamptz NOT NULL,
+			PRIMARY KEY (scope, key)
+		);
+		CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);`,
+		Down: `DROP TABLE idempotency_keys;`,
+	},
+	{
+		Version: 8,
+		Name:    "create_document_fingerprints",
+		Up: `ALTER TABLE documents
+			ADD COLUMN similar_to varchar(100) NOT NULL DEFAULT '',
+			ADD COLUMN similarity double precision NOT NULL DEFAULT 0;
+		CREATE TABLE document_fingerprints (
+			ticket_id varchar(100) PRIMARY KEY REFERENCES documents (ticket_id) ON DELETE CASCADE,
+			content_digest varchar(64) NOT NULL,
+			signature bytea,
+			updated_at timestamptz
+		);
+		CREATE INDEX idx_document_fingerprints_content_digest ON document_fingerprints (content_digest);
+		CREATE TABLE document_fingerprint_bands (
+			ticket_id varchar(100) NOT NULL REFERENCES documents (ticket_id) ON DELETE CASCADE,
+			band smallint NOT NULL,
+			hash bigint NOT NULL,
+			PRIMARY KEY (ticket_id, band)
+		);
+		CREATE INDEX idx_fingerprint_bands_band_hash ON document_fingerprint_bands (band, hash);`,
+		Down: `DROP TABLE document_fingerprint_bands;
+		DROP TABLE document_fingerprints;
+		ALTER TABLE documents DROP COLUMN similar_to, DROP COLUMN similarity;`,
+	},
+	{
+		Version: 9,
+		Name:    "create_document_events",
+		Up: `CREATE TABLE document_events (
+			id bigserial PRIMARY KEY,
+			created_at timestamptz NOT NULL,
+			type varchar(20) NOT NULL,
+			ticket_id varchar(100) NOT NULL,
+			version bigint NOT NULL DEFAULT 0,
+			editor varchar(100),
+			document text
+		);
+		CREATE INDEX idx_document_events_ticket_id ON document_events (ticket_id);
+		CREATE INDEX idx_document_events_created_at ON document_events (created_at);`,
+		Down: `DROP TABLE document_events;`,
+	},
+	{
+		Version: 10,
+		Name:    "create_outbox_messages",
+		Up: `CREATE TABLE outbox_messages (
+			id bigserial PRIMARY KEY,
+			created_at timestamptz NOT NULL,
+			event_id bigint NOT NULL,
+			event_type varchar(20) NOT NULL,
+			ticket_id varchar(100) NOT NULL,
+			payload text NOT NULL,
+			status varchar(20) NOT NULL DEFAULT 'pending',
+			attempts integer NOT NULL DEFAULT 0,
+			next_attempt_at timestamptz NOT NULL,
+			delivered_at timestamptz,
+			last_error text NOT NULL DEFAULT ''
+		);
+		CREATE INDEX idx_outbox_messages_status_next ON outbox_messages (status, next_attempt_at);`,
+		Down: `DROP TABLE outbox_messages;`,
+	},
+	{
+		Version: 11,
+		Name:    "create_watermark_jobs",
+		Up: `CREATE TABLE watermark_jobs (
+			ticket_id varchar(100) PRIMARY KEY REFERENCES documents (ticket_id) ON DELETE CASCADE,
+			status varchar(20) NOT NULL,
+			mark varchar(100) NOT NULL,
+			reason text NOT NULL DEFAULT '',
+			created_at timestamptz,
+			started_at timestamptz,
+			finished_at timestamptz,
+			updated_at timestamptz
+		);
+		CREATE INDEX idx_watermark_jobs_status ON watermark_jobs (status);`,
+		Down: `DROP TABLE watermark_jobs;`,
+	},
+	{
+		Version: 12,
+		Name:    "create_watermark_queue",
+		Up: `CREATE TABLE watermark_queue (
+			id bigserial PRIMARY KEY,
+			ticket_id varchar(100) NOT NULL,
+			status varchar(20) NOT NULL DEFAULT 'queued',
+			attempts integer NOT NULL DEFAULT 0,
+			visible_at timestamptz NOT NULL,
+			enqueued_at timestamptz NOT NULL,
+			last_error text NOT NULL DEFAULT ''
+		);
+		CREATE UNIQUE INDEX idx_watermark_queue_ticket_id ON watermark_queue (ticket_id) WHERE status = 'queued';
+		CREATE INDEX idx_watermark_queue_visible_at ON watermark_queue (visible_at) WHERE status = 'queued';`,
+		Down: `DROP TABLE watermark_queue;`,
+	},
+}
@@ -1,32 +1,380 @@
 package database
 
 import (
-	"errors"
+	"context"
+	"encoding/json"
 	"fmt"
+	"publisher/internal"
+	"strings"
+	"time"
 
+	"github.com/go-kit/log"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+// Document is the row of a document, its content is kept in the blob store
+// and referenced by digest.
 type Document struct {
 	gorm.Model
-	TicketID  string `gorm:"type:varchar(100);unique_index"`
-	Content   string `gorm:"type:varchar(100)"`
-	Title     string `gorm:"type:varchar(100)"`
-	Author    string `gorm:"type:varchar(100)"`
-	Topic     string `gorm:"type:varchar(100)"`
-	Watermark string `gorm:"type:varchar(100)"`
+	TicketID      string `gorm:"type:varchar(100);uniqueIndex"`
+	ContentDigest string `gorm:"type:varchar(64);not null;default:'';index"`
+	ContentSize   int64  `gorm:"not null;default:0"`
+	Title         string `gorm:"type:varchar(100)"`
+	Author        string `gorm:"type:varchar(100)"`
+	Topic         string `gorm:"type:varchar(100)"`
+	Watermark     string `gorm:"type:varchar(100)"`
+	Version       int64  `gorm:"not null;default:1"`
+	// SimilarTo flags a document whose content was found similar to the
+	// content of another document when it was submitted
+	SimilarTo  string  `gorm:"type:varchar(100);not null;default:''"`
+	Similarity float64 `gorm:"not null;default:0"`
 }
 
-func Init(dbname, host, port, user, password, timeZone string) (*gorm.DB, error) {
-	// look there: https://gorm.io/docs/connecting_to_the_database.html#PostgreSQL
-	dsn := fmt.Sprintf("dbname=%s host=%s port=%s user=%s password=%s TimeZone=%s sslmode=disable", dbname, host, port, user, password, timeZone)
+// NewDocument builds a database row from the service level document, the
+// content reference is set by the caller once the content is stored.
+func NewDocument(ticketID string, doc *internal.Document) *Document {
+	return &Document{
+		TicketID:  ticketID,
+		Title:     doc.Title,
+		Author:    doc.Author,
+		Topic:     doc.Topic,
+		Watermark: doc.Watermark,
+		Version:   1,
+	}
+}
+
+// ToInternal converts the database row back to the service level document.
+func (d *Document) ToInternal() internal.Document {
+	doc := internal.Document{
+		TicketID:      d.TicketID,
+		ContentDigest: d.ContentDigest,
+		ContentSize:   d.ContentSize,
+		Title:         d.Title,
+		Author:        d.Author,
+		Topic:         d.Topic,
+		Watermark:     d.Watermark,
+		Version:       d.Version,
+		SimilarTo:     d.SimilarTo,
+		Similarity:    d.Similarity,
+	}
+	if d.DeletedAt.Valid {
+		deletedAt := d.DeletedAt.Time
+		doc.DeletedAt = &deletedAt
+	}
+	return doc
+}
+
+// Revision is the immutable snapshot of a document at one version.
+type Revision struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	TicketID  string `gorm:"type:varchar(100);uniqueIndex:idx_revisions_ticket_number"`
+	Number    int64  `gorm:"uniqueIndex:idx_revisions_ticket_number"`
+	Editor    string `gorm:"type:varchar(100)"`
+	// Changes holds the comma separated keys of the changed fields
+	Changes       string `gorm:"type:text"`
+	RollbackOf    int64
+	ContentDigest string `gorm:"type:varchar(64);not null;default:'';index"`
+	ContentSize   int64
+	Title         string `gorm:"type:varchar(100)"`
+	Author        string `gorm:"type:varchar(100)"`
+	Topic         string `gorm:"type:varchar(100)"`
+	Watermark     string `gorm:"type:varchar(100)"`
+}
+
+func (Revision) TableName() string {
+	return "document_revisions"
+}
+
+// NewRevision snapshots the row at its current version.
+func NewRevision(row *Document, editor string, changes []string) *Revision {
+	return &Revision{
+		TicketID:      row.TicketID,
+		Number:        row.Version,
+		Editor:        editor,
+		Changes:       strings.Join(changes, ","),
+		ContentDigest: row.ContentDigest,
+		ContentSize:   row.ContentSize,
+		Title:         row.Title,
+		Author:        row.Author,
+		Topic:         row.Topic,
+		Watermark:     row.Watermark,
+	}
+}
+
+// ToInternal converts the database row back to the service level revision.
+func (r *Revision) ToInternal() internal.Revision {
+	rev := internal.Revision{
+		TicketID:   r.TicketID,
+		Number:     r.Number,
+		Editor:     r.Editor,
+		CreatedAt:  r.CreatedAt,
+		RollbackOf: r.RollbackOf,
+		Document: internal.Document{
+			TicketID:      r.TicketID,
+			ContentDigest: r.ContentDigest,
+			ContentSize:   r.ContentSize,
+			Title:         r.Title,
+			Author:        r.Author,
+			Topic:         r.Topic,
+			Watermark:     r.Watermark,
+			Version:       r.Number,
+		},
+	}
+	if r.Changes != "" {
+		rev.Changes = strings.Split(r.Changes, ",")
+	}
+	return rev
+}
+
+// Event is a change of a document, its ID is the offset of the event.
+// Events are kept after the document is purged.
+type Event struct {
+	ID        int64 `gorm:"primarykey"`
+	CreatedAt time.Time
+	Type      string `gorm:"type:varchar(20);not null"`
+	TicketID  string `gorm:"type:varchar(100);not null;index"`
+	Version   int64
+	Editor    string `gorm:"type:varchar(100)"`
+	// Document holds the JSON encoded document after the change
+	Document string `gorm:"type:text"`
+}
+
+func (Event) TableName() string {
+	return "document_events"
+}
+
+// NewEvent records a change of the row, the row of a purged document is not
+// stored with the event.
+func NewEvent(typ internal.EventType, row *Document, editor string) *Event {
+	ev := &Event{Type: string(typ), TicketID: row.TicketID, Version: row.Version, Editor: editor}
+	if typ != internal.EventPurged {
+		b, _ := json.Marshal(row.ToInternal())
+		ev.Document = string(b)
+	}
+	return ev
+}
+
+// ToInternal converts the database row back to the service level event.
+func (e *Event) ToInternal() (internal.Event, error) {
+	ev := internal.Event{
+		Offset:   e.ID,
+		Type:     internal.EventType(e.Type),
+		TicketID: e.TicketID,
+		Version:  e.Version,
+		Editor:   e.Editor,
+		Time:     e.CreatedAt,
+	}
+	if e.Document != "" {
+		ev.Document = &internal.Document{}
+		if err := json.Unmarshal([]byte(e.Document), ev.Document); err != nil {
+			return ev, err
+		}
+	}
+	return ev, nil
+}
+
+// Fingerprint is the MinHash signature of the content of a document, it is
+// looked up by the hashes of its bands.
+type Fingerprint struct {
+	TicketID      string `gorm:"type:varchar(100);primaryKey"`
+	ContentDigest string `gorm:"type:varchar(64);not null;index"`
+	Signature     []byte
+	// Bands are stored in the document_fingerprint_bands table
+	Bands     []int64 `gorm:"-"`
+	UpdatedAt time.Time
+}
+
+func (Fingerprint) TableName() string {
+	return "document_fingerprints"
+}
+
+// FingerprintBand is the hash of one band of a fingerprint.
+type FingerprintBand struct {
+	TicketID string `gorm:"type:varchar(100);primaryKey"`
+	Band     int16  `gorm:"primaryKey;index:idx_fingerprint_bands_band_hash,priority:1"`
+	Hash     int64  `gorm:"index:idx_fingerprint_bands_band_hash,priority:2"`
+}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+func (FingerprintBand) TableName() string {
+	return "document_fingerprint_bands"
+}
+
+// IdempotencyKey remembers the ticket created by a request carrying the key
+// until ExpiresAt, repeated requests return the same ticket.
+type IdempotencyKey struct {
+	// Scope is the operation the key was used with
+	Scope string `gorm:"type:varchar(100);primaryKey"`
+	Key   string `gorm:"type:varchar(255);primaryKey"`
+	// RequestHash is the digest of the request, a repeated request must match it
+	RequestHash string `gorm:"type:varchar(64);not null"`
+	TicketID    string `gorm:"type:varchar(100);not null"`
+	CreatedAt   time.Time
+	ExpiresAt   time.Time `gorm:"index"`
+}
+
+// Outbox delivery states of a message.
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxFailed    = "failed"
+)
 
+// OutboxMessage is an event waiting to be delivered to the watermark node,
+// it is written in the transaction of the change it reports, so the
+// notification is never lost once the change is committed.
+type OutboxMessage struct {
+	ID        int64 `gorm:"primarykey"`
+	CreatedAt time.Time
+	// EventID is the offset of the reported event
+	EventID   int64  `gorm:"not null"`
+	EventType string `gorm:"type:varchar(20);not null"`
+	TicketID  string `gorm:"type:varchar(100);not null"`
+	// Payload holds the JSON encoded event
+	Payload string `gorm:"type:text;not null"`
+	Status  string `gorm:"type:varchar(20);not null;default:'pending';index:idx_outbox_messages_status_next,priority:1"`
+	// Attempts counts the deliveries started so far
+	Attempts int `gorm:"not null;default:0"`
+	// NextAttemptAt is when a pending message may be claimed by a relay
+	NextAttemptAt time.Time `gorm:"not null;index:idx_outbox_messages_status_next,priority:2"`
+	DeliveredAt   *time.Time
+	LastError     string `gorm:"type:text;not null;default:''"`
+}
+
+// NewOutboxMessage returns the pending message reporting the event, the
+// event must have been added so its offset is known.
+func NewOutboxMessage(ev *Event) (*OutboxMessage, error) {
+	e, err := ev.ToInternal()
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(e)
 	if err != nil {
-		return nil, errors.New("don't open database connection")
+		return nil, err
 	}
+	return &OutboxMessage{
+		CreatedAt:     ev.CreatedAt,
+		EventID:       ev.ID,
+		EventType:     ev.Type,
+		TicketID:      ev.TicketID,
+		Payload:       string(b),
+		Status:        OutboxPending,
+		NextAttemptAt: ev.CreatedAt,
+	}, nil
+}
+
+// Event decodes the event reported by the message.
+func (m *OutboxMessage) Event() (internal.Event, error) {
+	var ev internal.Event
+	err := json.Unmarshal([]byte(m.Payload), &ev)
+	return ev, err
+}
+
+// WatermarkJob is the state of the watermark requested for a document, it is
+// deleted together with the document.
+type WatermarkJob struct {
+	TicketID   string `gorm:"type:varchar(100);primaryKey"`
+	Status     string `gorm:"type:varchar(20);not null;index"`
+	Mark       string `gorm:"type:varchar(100);not null"`
+	Reason     string `gorm:"type:text;not null;default:''"`
+	CreatedAt  time.Time
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+	UpdatedAt  time.Time
+}
 
+// ToInternal converts the database row back to the service level job.
+func (j *WatermarkJob) ToInternal() internal.WatermarkJob {
+	return internal.WatermarkJob{
+		TicketID:   j.TicketID,
+		Status:     internal.Status(j.Status),
+		Mark:       j.Mark,
+		Reason:     j.Reason,
+		CreatedAt:  j.CreatedAt,
+		StartedAt:  j.StartedAt,
+		FinishedAt: j.FinishedAt,
+		UpdatedAt:  j.UpdatedAt,
+	}
+}
+
+// Open connects to the database and configures the connection pool. A
+// failing connection is retried cfg.ConnectRetries times with exponential
+// backoff, e.g. while the database is still starting.
+func Open(ctx context.Context, cfg Config, logger log.Logger) (*gorm.DB, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	backoff := cfg.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		db, err := open(ctx, cfg)
+		if err == nil {
+			return db, nil
+		}
+		if attempt > cfg.ConnectRetries || ctx.Err() != nil {
+			return nil, fmt.Errorf("open database connection: %w", err)
+		}
+		logger.Log("database", "connect", "attempt", attempt, "retry", backoff, "err", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("open database connection: %w", ctx.Err())
+		}
+		if backoff *= 2; backoff > cfg.RetryMaxBackoff {
+			backoff = cfg.RetryMaxBackoff
+		}
+	}
+}
+
+func open(ctx context.Context, cfg Config) (*gorm.DB, error) {
+	// look there: https://gorm.io/docs/connecting_to_the_database.html#PostgreSQL
+	db, err := gorm.Open(postgres.Open(cfg.DataSourceName()), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	if cfg.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.ConnectTimeout)
+		defer cancel()
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
 	return db, nil
 }
+
+// Watermark queue states of an entry.
+const (
+	QueueQueued = "queued"
+	QueueDead   = "dead"
+)
+
+// WatermarkQueueEntry is a watermark job waiting for a worker of the
+// watermark node. A claimed entry stays queued but is hidden until VisibleAt,
+// so the job of a worker which crashed is claimed again once it reappears.
+// Entries which failed too often are kept as dead letters.
+type WatermarkQueueEntry struct {
+	ID       int64  `gorm:"primarykey"`
+	TicketID string `gorm:"type:varchar(100);not null"`
+	Status   string `gorm:"type:varchar(20);not null;default:'queued'"`
+	// Attempts counts the claims so far, it also tells the claims of an
+	// entry apart
+	Attempts   int       `gorm:"not null;default:0"`
+	VisibleAt  time.Time `gorm:"not null;index"`
+	EnqueuedAt time.Time `gorm:"not null"`
+	LastError  string    `gorm:"type:text;not null;default:''"`
+}
+
+func (WatermarkQueueEntry) TableName() string {
+	return "watermark_queue"
+}
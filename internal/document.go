@@ -1,17 +1,52 @@
 package internal
 
+import (
+	"io"
+	"time"
+)
+
 type Document struct {
-	Content   string `json:"content"`
-	Title     string `json:"title"`
-	Author    string `json:"author"`
-	Topic     string `json:"topic"`
-	Watermark string `json:"watermark,omitempty"`
+	TicketID string `json:"ticketID,omitempty"`
+	// Content is stored in the blob store, it is only set when the content is
+	// written inline or read explicitly, e.g. by an export
+	Content string `json:"content,omitempty"`
+	// ContentDigest is the hex encoded SHA-256 digest of the content
+	ContentDigest string `json:"contentDigest,omitempty"`
+	ContentSize   int64  `json:"contentSize,omitempty"`
+	Title         string `json:"title"`
+	Author        string `json:"author"`
+	Topic         string `json:"topic"`
+	Watermark     string `json:"watermark,omitempty"`
+	// Version is increased by every update, it is used as ETag of the document
+	Version int64 `json:"version,omitempty"`
+	// DeletedAt is only set on removed documents listed with IncludeDeleted
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	// SimilarTo flags a document whose content was similar to the content of
+	// the ticket when it was submitted, Similarity is their estimated similarity
+	SimilarTo  string  `json:"similarTo,omitempty"`
+	Similarity float64 `json:"similarity,omitempty"`
+}
+
+// SimilarDocument is a document whose content is similar to the content of
+// another document.
+type SimilarDocument struct {
+	TicketID string `json:"ticketID"`
+	Title    string `json:"title"`
+	// Similarity is the estimated share of shared word sequences, from 0 to 1
+	Similarity float64 `json:"similarity"`
+	// Exact is set if the contents are identical
+	Exact bool `json:"exact,omitempty"`
 }
 
-type Filter struct {
-	Key string `json:"key"`
-	// If value is empty, just return everything but sorted with the key
-	Value string `json:"value,omitempty"`
+// Content is a byte range of the content of a document, Size is the size
+// of the whole content.
+type Content struct {
+	TicketID string        `json:"ticketID"`
+	Digest   string        `json:"digest"`
+	Size     int64         `json:"size"`
+	Offset   int64         `json:"offset"`
+	Length   int64         `json:"length"`
+	Body     io.ReadCloser `json:"-"`
 }
 
 type Status string
@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"time"
+)
+
+// EventType names the change of a document reported by an event.
+type EventType string
+
+const (
+	EventAdded       EventType = "added"
+	EventUpdated     EventType = "updated"
+	EventWatermarked EventType = "watermarked"
+	EventRemoved     EventType = "removed"
+	EventRestored    EventType = "restored"
+	EventPurged      EventType = "purged"
+)
+
+// ValidEventType reports whether t is one of the event types above.
+func ValidEventType(t EventType) bool {
+	switch t {
+	case EventAdded, EventUpdated, EventWatermarked, EventRemoved, EventRestored, EventPurged:
+		return true
+	}
+	return false
+}
+
+// Event reports a change of a document. Offsets increase in the order the
+// changes were committed, a consumer resumes after the last offset it saw.
+type Event struct {
+	Offset   int64     `json:"offset"`
+	Type     EventType `json:"type"`
+	TicketID string    `json:"ticketID"`
+	// Version is the version of the document after the change
+	Version int64     `json:"version"`
+	Editor  string    `json:"editor,omitempty"`
+	Time    time.Time `json:"time"`
+	// Document is the document after the change without its content, it is
+	// not set for purged documents
+	Document *Document `json:"document,omitempty"`
+}
+
+// EventSignatureHeader carries the signature of an event posted by the
+// database node to the watermark node.
+const EventSignatureHeader = "X-Event-Signature"
+
+// SignEvent returns the signature of the body of a posted event, the HMAC
+// SHA-256 of the body keyed by the secret shared by both nodes.
+func SignEvent(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidEventSignature reports whether sig is the signature of the body, no
+// signature is valid without a secret.
+func ValidEventSignature(secret, body []byte, sig string) bool {
+	if len(secret) == 0 || !strings.HasPrefix(sig, "sha256=") {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(SignEvent(secret, body)))
+}
+
+// WatchQuery selects the events of a watch.
+type WatchQuery struct {
+	// After is the offset of the last event seen, 0 starts with the oldest
+	// retained event and -1 with the next new event
+	After int64 `json:"after"`
+	// TicketID limits the events to a single document
+	TicketID string `json:"ticketID,omitempty"`
+	// Types limits the events to the given types, all types if empty
+	Types []EventType `json:"types,omitempty"`
+}
+
+// Match reports whether the event is selected by the query, the offset is
+// not compared.
+func (q WatchQuery) Match(ev *Event) bool {
+	if q.TicketID != "" && q.TicketID != ev.TicketID {
+		return false
+	}
+	if len(q.Types) == 0 {
+		return true
+	}
+	for _, t := range q.Types {
+		if t == ev.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// EventReader returns the events of a watch. Next blocks until the next
+// event is committed, it returns the error of the context once the watch is
+// canceled. The watch is stopped by calling Close.
+type EventReader interface {
+	Next() (*Event, error)
+	io.Closer
+}
@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"publisher/internal/util"
+	"time"
+)
+
+type Operator string
+
+const (
+	OpEq       Operator = "eq"
+	OpNeq      Operator = "neq"
+	OpContains Operator = "contains"
+	OpPrefix   Operator = "prefix"
+	OpIn       Operator = "in"
+	// OpRange matches timestamps between From and To, either bound may be omitted
+	OpRange Operator = "range"
+)
+
+type SortOrder string
+
+const (
+	Asc  SortOrder = "asc"
+	Desc SortOrder = "desc"
+)
+
+// Keys which can be used in filters, the time keys only support OpRange.
+const (
+	KeyTicketID = "ticketID"
+	// KeyContent filters on the whole content, it is matched through the
+	// digest of the value and only supports OpEq, OpNeq and OpIn
+	KeyContent       = "content"
+	KeyContentDigest = "contentDigest"
+	KeyTitle         = "title"
+	KeyAuthor        = "author"
+	KeyTopic         = "topic"
+	KeyWatermark     = "watermark"
+	KeyCreatedAt     = "createdAt"
+	KeyUpdatedAt     = "updatedAt"
+)
+
+var filterKeys = map[string]bool{
+	KeyTicketID:      false,
+	KeyContent:       false,
+	KeyContentDigest: false,
+	KeyTitle:         false,
+	KeyAuthor:        false,
+	KeyTopic:         false,
+	KeyWatermark:     false,
+	KeyCreatedAt:     true,
+	KeyUpdatedAt:     true,
+}
+
+// Filter is either a condition on a key, a sort on a key, or a group of
+// nested filters. The top level filters of a query are combined with AND.
+type Filter struct {
+	Key string `json:"key,omitempty"`
+	// If value is empty and no operator is set, just return everything but sorted with the key
+	Value  string     `json:"value,omitempty"`
+	Op     Operator   `json:"op,omitempty"`
+	Values []string   `json:"values,omitempty"`
+	From   *time.Time `json:"from,omitempty"`
+	To     *time.Time `json:"to,omitempty"`
+	// Sort orders the result by the key, it is only allowed on top level filters
+	Sort SortOrder `json:"sort,omitempty"`
+	// Any matches if one of the nested filters matches, All if every one does
+	Any []Filter `json:"any,omitempty"`
+	All []Filter `json:"all,omitempty"`
+}
+
+// IsGroup reports whether the filter only groups nested filters.
+func (f Filter) IsGroup() bool {
+	return f.Key == "" && (len(f.Any) > 0 || len(f.All) > 0)
+}
+
+// Operator returns the effective operator, a bare value means equality.
+func (f Filter) Operator() Operator {
+	if f.Op == "" && f.Value != "" {
+		return OpEq
+	}
+	return f.Op
+}
+
+// SortOrder returns the effective sort order, a bare key sorts ascending.
+func (f Filter) SortOrder() SortOrder {
+	if f.Sort == "" && f.Operator() == "" && !f.IsGroup() {
+		return Asc
+	}
+	return f.Sort
+}
+
+// IsTimeKey reports whether the key refers to a timestamp.
+func IsTimeKey(key string) bool {
+	return filterKeys[key]
+}
+
+// ValidateFilters returns util.ErrInvalidArgument if any filter uses an
+// unknown key, an unknown operator or an operator not suited to its key.
+func ValidateFilters(filters []Filter) error {
+	for _, f := range filters {
+		if err := f.validate(true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f Filter) validate(topLevel bool) error {
+	switch f.Sort {
+	case "", Asc, Desc:
+	default:
+		return util.ErrInvalidArgument
+	}
+	if f.IsGroup() {
+		if f.Sort != "" || f.Op != "" || f.Value != "" {
+			return util.ErrInvalidArgument
+		}
+		for _, group := range [][]Filter{f.Any, f.All} {
+			for _, nested := range group {
+				if err := nested.validate(false); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	isTime, ok := filterKeys[f.Key]
+	if !ok || len(f.Any) > 0 || len(f.All) > 0 {
+		return util.ErrInvalidArgument
+	}
+	if f.Sort != "" && !topLevel {
+		return util.ErrInvalidArgument
+	}
+	if f.Key == KeyContent {
+		// only a whole content has a digest, neither parts of it nor its
+		// order can be matched
+		switch f.Operator() {
+		case OpEq, OpNeq:
+		case OpIn:
+			if len(f.Values) == 0 {
+				return util.ErrInvalidArgument
+			}
+		default:
+			return util.ErrInvalidArgument
+		}
+		if f.Sort != "" {
+			return util.ErrInvalidArgument
+		}
+		return nil
+	}
+	switch f.Operator() {
+	case "":
+		if !topLevel {
+			return util.ErrInvalidArgument
+		}
+	case OpEq, OpNeq, OpContains, OpPrefix:
+		if isTime {
+			return util.ErrInvalidArgument
+		}
+	case OpIn:
+		if isTime || len(f.Values) == 0 {
+			return util.ErrInvalidArgument
+		}
+	case OpRange:
+		if !isTime || (f.From == nil && f.To == nil) {
+			return util.ErrInvalidArgument
+		}
+	default:
+		return util.ErrInvalidArgument
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"publisher/internal/util"
+)
+
+// MaxIdempotencyKeyLength is the length limit of an idempotency key.
+const MaxIdempotencyKeyLength = 255
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a context carrying the key of a request which
+// may be retried, repeated requests with the same key return the result of
+// the first one.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// IdempotencyKeyFromContext returns the key set by WithIdempotencyKey.
+func IdempotencyKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	return key
+}
+
+// ValidateIdempotencyKey accepts keys of 1 to MaxIdempotencyKeyLength
+// printable ASCII characters.
+func ValidateIdempotencyKey(key string) error {
+	if key == "" || len(key) > MaxIdempotencyKeyLength {
+		return util.ErrInvalidArgument
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return util.ErrInvalidArgument
+		}
+	}
+	return nil
+}
+
+// DocumentHash returns the hex encoded SHA-256 digest of the fields of the
+// document set by a client, it tells whether a repeated request carries the
+// same document.
+func DocumentHash(doc *Document) string {
+	h := sha256.New()
+	for _, field := range []string{doc.Title, doc.Author, doc.Topic, doc.Watermark, doc.Content} {
+		// the length prefix keeps the field boundaries apart
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(field)))
+		h.Write(n[:])
+		h.Write([]byte(field))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
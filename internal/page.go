@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"publisher/internal/util"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// Query selects a page of documents. An empty cursor starts at the first page.
+type Query struct {
+	Filters  []Filter `json:"filters,omitempty"`
+	PageSize int      `json:"pageSize,omitempty"`
+	Cursor   string   `json:"cursor,omitempty"`
+	// IncludeDeleted also lists removed documents, only admins may set it
+	IncludeDeleted bool `json:"includeDeleted,omitempty"`
+}
+
+// Limit returns the page size bounded by MaxPageSize.
+func (q Query) Limit() int {
+	switch {
+	case q.PageSize <= 0:
+		return DefaultPageSize
+	case q.PageSize > MaxPageSize:
+		return MaxPageSize
+	default:
+		return q.PageSize
+	}
+}
+
+// Page is one page of documents, NextCursor is empty on the last page.
+type Page struct {
+	Documents  []Document `json:"documents"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+	Total      int64      `json:"total"`
+}
+
+// Cursor points right after the last document of a page. It holds the sort
+// values and the row id of that document, so pages stay stable when new
+// documents are inserted concurrently.
+type Cursor struct {
+	Values []string `json:"v,omitempty"`
+	ID     uint     `json:"id"`
+	// Query is the fingerprint of the filters the cursor was created for
+	Query string `json:"q"`
+}
+
+// Encode returns the opaque representation of the cursor.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses an opaque cursor which must belong to the filters,
+// util.ErrInvalidArgument is returned otherwise.
+func DecodeCursor(s string, filters []Filter) (Cursor, error) {
+	var c Cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, util.ErrInvalidArgument
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, util.ErrInvalidArgument
+	}
+	if c.Query != Fingerprint(filters) {
+		return c, util.ErrInvalidArgument
+	}
+	return c, nil
+}
+
+// Fingerprint identifies a set of filters.
+func Fingerprint(filters []Filter) string {
+	b, _ := json.Marshal(filters)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+// Revision is the immutable snapshot of a document written by every change,
+// revision n holds the document at version n.
+type Revision struct {
+	TicketID string `json:"ticketID"`
+	Number   int64  `json:"number"`
+	// Editor is the account which made the change, empty if unknown
+	Editor    string    `json:"editor,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Changes lists the keys of the fields changed by this revision
+	Changes []string `json:"changes,omitempty"`
+	// RollbackOf is the revision restored by a rollback
+	RollbackOf int64    `json:"rollbackOf,omitempty"`
+	Document   Document `json:"document"`
+}
+
+// FieldChange is the difference of a single field between two revisions.
+type FieldChange struct {
+	Key  string `json:"key"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Diff lists the fields which differ between two revisions of a document.
+type Diff struct {
+	TicketID string        `json:"ticketID"`
+	From     int64         `json:"from"`
+	To       int64         `json:"to"`
+	Changes  []FieldChange `json:"changes"`
+}
+
+// DiffDocuments compares the fields of two documents, the content is compared
+// by its digest so From and To of a content change hold the digests.
+func DiffDocuments(from, to Document) []FieldChange {
+	changes := []FieldChange{}
+	for _, f := range []struct {
+		key      string
+		from, to string
+	}{
+		{KeyContent, from.ContentDigest, to.ContentDigest},
+		{KeyTitle, from.Title, to.Title},
+		{KeyAuthor, from.Author, to.Author},
+		{KeyTopic, from.Topic, to.Topic},
+		{KeyWatermark, from.Watermark, to.Watermark},
+	} {
+		if f.from != f.to {
+			changes = append(changes, FieldChange{Key: f.key, From: f.from, To: f.to})
+		}
+	}
+	return changes
+}
+
+type editorKey struct{}
+
+// WithEditor returns a context carrying the account making the changes.
+func WithEditor(ctx context.Context, editor string) context.Context {
+	return context.WithValue(ctx, editorKey{}, editor)
+}
+
+// EditorFromContext returns the account set by WithEditor.
+func EditorFromContext(ctx context.Context) string {
+	editor, _ := ctx.Value(editorKey{}).(string)
+	return editor
+}
+
+type adminKey struct{}
+
+// WithAdmin returns a context of an admin, who may list removed documents,
+// restore and purge them.
+func WithAdmin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adminKey{}, true)
+}
+
+// IsAdmin tells whether the context was returned by WithAdmin.
+func IsAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminKey{}).(bool)
+	return admin
+}
@@ -0,0 +1,39 @@
+package internal
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// Highlighted terms of search snippets are wrapped in these markers.
+const (
+	HighlightStart = "<mark>"
+	HighlightStop  = "</mark>"
+)
+
+// SearchQuery is a full text query in web search syntax: words must all
+// match, "quoted phrases" must match in order and -words must not match.
+type SearchQuery struct {
+	Text  string `json:"text"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+// MaxResults returns the number of results bounded by MaxSearchLimit.
+func (q SearchQuery) MaxResults() int {
+	switch {
+	case q.Limit <= 0:
+		return DefaultSearchLimit
+	case q.Limit > MaxSearchLimit:
+		return MaxSearchLimit
+	default:
+		return q.Limit
+	}
+}
+
+// SearchResult is a matching document, Highlights holds the snippets of the
+// matching fields keyed by the filter key of the field.
+type SearchResult struct {
+	Document   Document          `json:"document"`
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
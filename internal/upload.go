@@ -0,0 +1,16 @@
+package internal
+
+// Upload is the state of a resumable upload of the content of a document.
+type Upload struct {
+	TicketID string `json:"ticketID"`
+	// Size is the announced size of the content, 0 if unknown. An upload of
+	// unknown size completes once its size is announced and received or its
+	// final chunk is received.
+	Size int64 `json:"size,omitempty"`
+	// Offset is the number of bytes received so far, an interrupted upload
+	// is resumed at this offset
+	Offset   int64 `json:"offset"`
+	Complete bool  `json:"complete"`
+	// Document is the stored document once the upload is complete
+	Document *Document `json:"document,omitempty"`
+}
@@ -1,8 +1,29 @@
 package util
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 var (
 	ErrUnknown         = errors.New("unknown argument passed")
 	ErrInvalidArgument = errors.New("invalid argument passed")
+	ErrConflict        = errors.New("document was modified concurrently")
+	ErrOutOfRange      = errors.New("range not satisfiable")
+	ErrForbidden       = errors.New("permission denied")
 )
+
+// ParseError returns the error of a message received from a remote node, the
+// errors above are restored so they can be matched with errors.Is.
+func ParseError(msg string) error {
+	for _, err := range []error{ErrUnknown, ErrInvalidArgument, ErrConflict, ErrOutOfRange, ErrForbidden} {
+		if msg == err.Error() {
+			return err
+		}
+		if rest := strings.TrimPrefix(msg, err.Error()); rest != msg && strings.HasPrefix(rest, ": ") {
+			return fmt.Errorf("%w%s", err, rest)
+		}
+	}
+	return errors.New(msg)
+}
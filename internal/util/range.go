@@ -0,0 +1,31 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParseRange parses a "bytes=first-last" or "bytes=first-" Range header, the
+// length of an open range is 0. Suffix and multiple ranges are not supported.
+func ParseRange(header string) (offset, length int64, ok bool) {
+	spec := strings.TrimPrefix(strings.TrimSpace(header), "bytes=")
+	if spec == header || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	i := strings.IndexByte(spec, '-')
+	if i <= 0 {
+		return 0, 0, false
+	}
+	first, err := strconv.ParseInt(strings.TrimSpace(spec[:i]), 10, 64)
+	if err != nil || first < 0 {
+		return 0, 0, false
+	}
+	if last := strings.TrimSpace(spec[i+1:]); last != "" {
+		end, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || end < first {
+			return 0, 0, false
+		}
+		return first, end - first + 1, true
+	}
+	return first, 0, true
+}
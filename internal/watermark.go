@@ -0,0 +1,81 @@
+package internal
+
+import "time"
+
+// WatermarkJob is the state of the watermark requested for a ticket.
+type WatermarkJob struct {
+	TicketID string `json:"ticketID"`
+	Status   Status `json:"status"`
+	Mark     string `json:"mark"`
+	// Reason tells why a failed job failed or why a pending job runs again
+	Reason string `json:"reason,omitempty"`
+	// CreatedAt is when the watermark was last requested
+	CreatedAt time.Time  `json:"createdAt"`
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	// FinishedAt is when the job finished or failed
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// WatermarkTransition moves a watermark job to the status To. Mark is the
+// watermark of a job moved to Pending, Reason the failure of a job moved to
+// Failed. Retry moves a started job whose run failed or was interrupted back
+// to Pending with its mark, Reason then tells why it is run again.
+type WatermarkTransition struct {
+	To     Status `json:"to"`
+	Mark   string `json:"mark,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Retry  bool   `json:"retry,omitempty"`
+}
+
+// ValidStatus reports whether s is one of the job statuses.
+func ValidStatus(s Status) bool {
+	switch s {
+	case Pending, Started, InProgress, Finished, Failed:
+		return true
+	}
+	return false
+}
+
+// CanRetry reports whether a job of status s may be run again, which is the
+// case once it was started and until it finished or failed.
+func (s Status) CanRetry() bool {
+	return s == Started || s == InProgress
+}
+
+// CanTransition reports whether a job of status s may move to the status to,
+// s is empty for a ticket without a job. A watermark is requested again only
+// while the job is pending or after it failed, a job runs through Started and
+// InProgress to Finished and may fail at any step before.
+func (s Status) CanTransition(to Status) bool {
+	switch to {
+	case Pending:
+		return s == "" || s == Pending || s == Failed
+	case Started:
+		return s == Pending
+	case InProgress:
+		return s == Started
+	case Finished:
+		return s == InProgress
+	case Failed:
+		return s == Pending || s == Started || s == InProgress
+	}
+	return false
+}
+
+// Detection is a watermark recovered from a suspect content.
+type Detection struct {
+	Mark string `json:"mark"`
+	// Confidence is the share of the copies of a mark found in the content
+	// which carry this mark, from 0 to 1
+	Confidence float64 `json:"confidence"`
+	// Frames counts the copies of the mark found
+	Frames int `json:"frames"`
+	// Encodings lists the encodings the mark was found in
+	Encodings []string `json:"encodings"`
+	// TicketID is the document the mark was applied to, it is empty if no
+	// document or several documents carry the mark
+	TicketID string `json:"ticketID,omitempty"`
+	// Candidates lists the documents carrying the mark if there are several
+	Candidates []string `json:"candidates,omitempty"`
+}
@@ -0,0 +1,41 @@
+package internal
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	statuses := []Status{"", Pending, Started, InProgress, Finished, Failed}
+	allowed := map[Status][]Status{
+		"":         {Pending},
+		Pending:    {Pending, Started, Failed},
+		Started:    {InProgress, Failed},
+		InProgress: {Finished, Failed},
+		Finished:   nil,
+		Failed:     {Pending},
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := false
+			for _, s := range allowed[from] {
+				want = want || s == to
+			}
+			if got := from.CanTransition(to); got != want {
+				t.Errorf("%q.CanTransition(%q) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestCanRetry(t *testing.T) {
+	for s, want := range map[Status]bool{
+		"":         false,
+		Pending:    false,
+		Started:    true,
+		InProgress: true,
+		Finished:   false,
+		Failed:     false,
+	} {
+		if got := s.CanRetry(); got != want {
+			t.Errorf("%q.CanRetry() = %v, want %v", s, got, want)
+		}
+	}
+}
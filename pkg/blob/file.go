@@ -0,0 +1,130 @@
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type fileStore struct {
+	dir string
+}
+
+// NewFileStore returns a Store keeping the blobs as files below dir. Blobs
+// are written to a temporary file first and renamed into place once their
+// digest is known, so readers never see partially written blobs.
+func NewFileStore(dir string) (Store, error) {
+	for _, d := range []string{filepath.Join(dir, "sha256"), filepath.Join(dir, "tmp")} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+// path fans the blobs out over directories named by the first two digits.
+func (f *fileStore) path(digest string) string {
+	return filepath.Join(f.dir, "sha256", digest[:2], digest)
+}
+
+func (f *fileStore) Put(ctx context.Context, r io.Reader) (Ref, error) {
+	tmp, err := os.CreateTemp(filepath.Join(f.dir, "tmp"), "blob-*")
+	if err != nil {
+		return Ref{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), contextReader{ctx: ctx, r: r})
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Ref{}, err
+	}
+
+	ref := Ref{Digest: hex.EncodeToString(h.Sum(nil)), Size: size}
+	path := f.path(ref.Digest)
+	if _, err := os.Stat(path); err == nil {
+		// deduplicated, refresh the time so a running GC keeps the blob
+		now := time.Now()
+		return ref, os.Chtimes(path, now, now)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return Ref{}, err
+	}
+	return ref, os.Rename(tmp.Name(), path)
+}
+
+func (f *fileStore) Open(_ context.Context, digest string) (io.ReadSeekCloser, error) {
+	if !ValidDigest(digest) {
+		return nil, ErrInvalidDigest
+	}
+	file, err := os.Open(f.path(digest))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (f *fileStore) Stat(_ context.Context, digest string) (Info, error) {
+	if !ValidDigest(digest) {
+		return Info{}, ErrInvalidDigest
+	}
+	fi, err := os.Stat(f.path(digest))
+	if os.IsNotExist(err) {
+		return Info{}, ErrNotFound
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{Ref: Ref{Digest: digest, Size: fi.Size()}, ModTime: fi.ModTime()}, nil
+}
+
+func (f *fileStore) Delete(_ context.Context, digest string) error {
+	if !ValidDigest(digest) {
+		return ErrInvalidDigest
+	}
+	err := os.Remove(f.path(digest))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (f *fileStore) Walk(ctx context.Context, fn func(Info) error) error {
+	return filepath.Walk(filepath.Join(f.dir, "sha256"), func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := filepath.Base(path)
+		if fi.IsDir() || !ValidDigest(name) || !strings.HasPrefix(name, filepath.Base(filepath.Dir(path))) {
+			return nil
+		}
+		return fn(Info{Ref: Ref{Digest: name, Size: fi.Size()}, ModTime: fi.ModTime()})
+	})
+}
+
+// contextReader stops reading once the context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,140 @@
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestFileStore(t *testing.T) (Store, string) {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, dir
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	store, dir := newTestFileStore(t)
+	content := "the content of a blob"
+	sum := sha256.Sum256([]byte(content))
+	digest := hex.EncodeToString(sum[:])
+
+	ref, err := store.Put(ctx, strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Digest != digest || ref.Size != int64(len(content)) {
+		t.Errorf("Put returned %+v, want digest %s and size %d", ref, digest, len(content))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sha256", digest[:2], digest)); err != nil {
+		t.Errorf("blob file: %v", err)
+	}
+	if tmp, _ := os.ReadDir(filepath.Join(dir, "tmp")); len(tmp) != 0 {
+		t.Errorf("%d temporary files were left", len(tmp))
+	}
+
+	r, err := store.Open(ctx, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != content {
+		t.Errorf("Open returned %q, %v, want %q", data, err, content)
+	}
+	if info, err := store.Stat(ctx, digest); err != nil || info.Size != int64(len(content)) {
+		t.Errorf("Stat returned %+v, %v", info, err)
+	}
+
+	missing := strings.Repeat("0", 64)
+	for _, tt := range []struct {
+		digest string
+		err    error
+	}{
+		{missing, ErrNotFound},
+		{"../" + digest[3:], ErrInvalidDigest},
+		{digest[:63], ErrInvalidDigest},
+		{strings.Repeat("z", 64), ErrInvalidDigest},
+	} {
+		if _, err := store.Open(ctx, tt.digest); err != tt.err {
+			t.Errorf("Open(%q) returned %v, want %v", tt.digest, err, tt.err)
+		}
+		if _, err := store.Stat(ctx, tt.digest); err != tt.err {
+			t.Errorf("Stat(%q) returned %v, want %v", tt.digest, err, tt.err)
+		}
+		if err := store.Delete(ctx, tt.digest); err != tt.err {
+			t.Errorf("Delete(%q) returned %v, want %v", tt.digest, err, tt.err)
+		}
+	}
+
+	if err := store.Delete(ctx, digest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Open(ctx, digest); err != ErrNotFound {
+		t.Errorf("Open after Delete returned %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestFileStoreDeduplication(t *testing.T) {
+	ctx := context.Background()
+	store, dir := newTestFileStore(t)
+	first, err := store.Put(ctx, strings.NewReader("same"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "sha256", first.Digest[:2], first.Digest)
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := store.Put(ctx, strings.NewReader("same"))
+	if err != nil || second != first {
+		t.Fatalf("second Put returned %+v, %v, want %+v", second, err, first)
+	}
+	if _, err := store.Put(ctx, strings.NewReader("other")); err != nil {
+		t.Fatal(err)
+	}
+	// storing the content again refreshes the time the GC goes by
+	if info, err := store.Stat(ctx, first.Digest); err != nil || !info.ModTime.After(old) {
+		t.Errorf("Stat returned %+v, %v, want a time after %v", info, err, old)
+	}
+
+	var digests []string
+	if err := store.Walk(ctx, func(info Info) error {
+		digests = append(digests, info.Digest)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(digests) != 2 {
+		t.Errorf("Walk found %d blobs, want 2", len(digests))
+	}
+	if tmp, _ := os.ReadDir(filepath.Join(dir, "tmp")); len(tmp) != 0 {
+		t.Errorf("%d temporary files were left", len(tmp))
+	}
+}
+
+func TestFileStoreCanceled(t *testing.T) {
+	store, dir := newTestFileStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.Put(ctx, strings.NewReader("content")); err != context.Canceled {
+		t.Errorf("Put returned %v, want %v", err, context.Canceled)
+	}
+	for _, d := range []string{"sha256", "tmp"} {
+		if entries, _ := os.ReadDir(filepath.Join(dir, d)); len(entries) != 0 {
+			t.Errorf("%s holds %d entries after a canceled Put", d, len(entries))
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sync"
+	"time"
+)
+
+type memoryBlob struct {
+	data    []byte
+	modTime time.Time
+}
+
+type memoryStore struct {
+	mu    sync.RWMutex
+	blobs map[string]*memoryBlob
+}
+
+// NewMemoryStore returns a Store keeping the blobs in memory, meant for tests
+// and local runs.
+func NewMemoryStore() Store {
+	return &memoryStore{blobs: map[string]*memoryBlob{}}
+}
+
+func (m *memoryStore) Put(ctx context.Context, r io.Reader) (Ref, error) {
+	data, err := io.ReadAll(contextReader{ctx: ctx, r: r})
+	if err != nil {
+		return Ref{}, err
+	}
+	sum := sha256.Sum256(data)
+	ref := Ref{Digest: hex.EncodeToString(sum[:]), Size: int64(len(data))}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if b, ok := m.blobs[ref.Digest]; ok {
+		b.modTime = time.Now()
+		return ref, nil
+	}
+	m.blobs[ref.Digest] = &memoryBlob{data: data, modTime: time.Now()}
+	return ref, nil
+}
+
+func (m *memoryStore) Open(_ context.Context, digest string) (io.ReadSeekCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, ok := m.blobs[digest]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return nopCloser{bytes.NewReader(b.data)}, nil
+}
+
+func (m *memoryStore) Stat(_ context.Context, digest string) (Info, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, ok := m.blobs[digest]
+	if !ok {
+		return Info{}, ErrNotFound
+	}
+	return Info{Ref: Ref{Digest: digest, Size: int64(len(b.data))}, ModTime: b.modTime}, nil
+}
+
+func (m *memoryStore) Delete(_ context.Context, digest string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.blobs[digest]; !ok {
+		return ErrNotFound
+	}
+	delete(m.blobs, digest)
+	return nil
+}
+
+func (m *memoryStore) Walk(ctx context.Context, fn func(Info) error) error {
+	m.mu.RLock()
+	infos := make([]Info, 0, len(m.blobs))
+	for digest, b := range m.blobs {
+		infos = append(infos, Info{Ref: Ref{Digest: digest, Size: int64(len(b.data))}, ModTime: b.modTime})
+	}
+	m.mu.RUnlock()
+	for _, info := range infos {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
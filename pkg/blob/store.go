@@ -0,0 +1,92 @@
+package blob
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrNotFound      = errors.New("blob not found")
+	ErrInvalidDigest = errors.New("invalid blob digest")
+)
+
+// Store keeps immutable blobs addressed by the hex encoded SHA-256 digest of
+// their content. Storing the same content twice stores it once.
+type Store interface {
+	// Put stores the content read from r and returns its reference.
+	Put(ctx context.Context, r io.Reader) (Ref, error)
+	// Open returns the content of the blob, ErrNotFound if it does not exist.
+	Open(ctx context.Context, digest string) (io.ReadSeekCloser, error)
+	Stat(ctx context.Context, digest string) (Info, error)
+	Delete(ctx context.Context, digest string) error
+	// Walk calls fn for every stored blob.
+	Walk(ctx context.Context, fn func(Info) error) error
+}
+
+// Ref references the content of a blob.
+type Ref struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+type Info struct {
+	Ref
+	// ModTime is the time the blob was last stored
+	ModTime time.Time `json:"modTime"`
+}
+
+// ValidDigest reports whether s is a hex encoded SHA-256 digest.
+func ValidDigest(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// GCStats summarizes a garbage collection run.
+type GCStats struct {
+	Scanned int   `json:"scanned"`
+	Removed int   `json:"removed"`
+	Freed   int64 `json:"freed"`
+}
+
+// GC deletes the blobs which are not referenced and were stored more than
+// grace ago. The grace period protects blobs which were stored but whose
+// reference is not committed yet.
+func GC(ctx context.Context, store Store, referenced map[string]bool, grace time.Duration) (GCStats, error) {
+	var (
+		stats   GCStats
+		garbage []Info
+		cutoff  = time.Now().Add(-grace)
+	)
+	err := store.Walk(ctx, func(info Info) error {
+		stats.Scanned++
+		if !referenced[info.Digest] && info.ModTime.Before(cutoff) {
+			garbage = append(garbage, info)
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+	for _, info := range garbage {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		// check again, the blob may have been stored again since the walk
+		current, err := store.Stat(ctx, info.Digest)
+		if err != nil || !current.ModTime.Before(cutoff) {
+			continue
+		}
+		if err := store.Delete(ctx, info.Digest); err != nil && err != ErrNotFound {
+			return stats, err
+		}
+		stats.Removed++
+		stats.Freed += info.Size
+	}
+	return stats, nil
+}
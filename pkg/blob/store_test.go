@@ -0,0 +1,79 @@
+package blob
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGC(t *testing.T) {
+	ctx := context.Background()
+	store, dir := newTestFileStore(t)
+	put := func(content string, age time.Duration) Ref {
+		t.Helper()
+		ref, err := store.Put(ctx, strings.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		modTime := time.Now().Add(-age)
+		if err := os.Chtimes(filepath.Join(dir, "sha256", ref.Digest[:2], ref.Digest), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		return ref
+	}
+	garbage := put("old and unreferenced", 2*time.Hour)
+	referenced := put("old and referenced", 2*time.Hour)
+	recent := put("recent and unreferenced", time.Minute)
+
+	stats, err := GC(ctx, store, map[string]bool{referenced.Digest: true}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (GCStats{Scanned: 3, Removed: 1, Freed: garbage.Size}); stats != want {
+		t.Errorf("GC returned %+v, want %+v", stats, want)
+	}
+	for _, tt := range []struct {
+		ref  Ref
+		kept bool
+	}{
+		{garbage, false},
+		{referenced, true},
+		{recent, true},
+	} {
+		if _, err := store.Stat(ctx, tt.ref.Digest); (err == nil) != tt.kept {
+			t.Errorf("Stat of %s returned %v, want kept %v", tt.ref.Digest, err, tt.kept)
+		}
+	}
+
+	// a blob stored again during its grace period survives too
+	put("old and unreferenced", 2*time.Hour)
+	if _, err := store.Put(ctx, strings.NewReader("old and unreferenced")); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err := GC(ctx, store, nil, time.Hour); err != nil || stats.Removed != 1 {
+		t.Errorf("GC returned %+v, %v, want only the referenced blob removed", stats, err)
+	}
+	if _, err := store.Stat(ctx, garbage.Digest); err != nil {
+		t.Errorf("Stat of the blob stored again returned %v", err)
+	}
+}
+
+func TestGCMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	kept, _ := store.Put(ctx, strings.NewReader("kept"))
+	removed, _ := store.Put(ctx, strings.NewReader("removed"))
+
+	if stats, err := GC(ctx, store, map[string]bool{kept.Digest: true}, 0); err != nil || stats.Removed != 1 {
+		t.Errorf("GC returned %+v, %v, want 1 blob removed", stats, err)
+	}
+	if _, err := store.Stat(ctx, removed.Digest); err != ErrNotFound {
+		t.Errorf("Stat of the unreferenced blob returned %v, want %v", err, ErrNotFound)
+	}
+	if _, err := store.Stat(ctx, kept.Digest); err != nil {
+		t.Errorf("Stat of the referenced blob returned %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"publisher/internal"
+	"publisher/internal/util"
+
+	"github.com/google/uuid"
+)
+
+// Import adds the rows one by one, so a failing row never rolls back the rows
+// imported before it. Rows may carry the ticket ID of an export to keep it,
+// the ticket must not exist yet.
+func (d *dbService) Import(ctx context.Context, rows internal.DocumentReader, dryRun bool) (internal.ImportResult, error) {
+	result := internal.ImportResult{DryRun: dryRun}
+	if rows == nil {
+		return result, util.ErrInvalidArgument
+	}
+	seen := map[string]bool{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		doc, err := rows.Next()
+		if err == io.EOF {
+			return result, nil
+		}
+		var rowErr *internal.RowError
+		if err != nil && !errors.As(err, &rowErr) {
+			logger.Log("method", "Import", "row", result.Rows+1, "err", err)
+			return result, err
+		}
+		result.Rows++
+		if rowErr == nil {
+			var ticketID string
+			if ticketID, err = d.importRow(ctx, doc, dryRun, seen); err == nil {
+				result.Imported++
+				if !dryRun {
+					result.TicketIDs = append(result.TicketIDs, ticketID)
+				}
+				continue
+			}
+		}
+		result.Errors = append(result.Errors, internal.RowError{Row: result.Rows, Err: errorMessage(err)})
+	}
+}
+
+// importRow validates the document and stores it unless dryRun is set.
+func (d *dbService) importRow(ctx context.Context, doc *internal.Document, dryRun bool, seen map[string]bool) (string, error) {
+	if err := internal.ValidateDocument(doc); err != nil {
+		return "", err
+	}
+	ticketID := doc.TicketID
+	if ticketID == "" {
+		ticketID = uuid.New().String()
+	} else {
+		if _, err := uuid.Parse(ticketID); err != nil {
+			return "", errors.New("ticketID is not a valid UUID")
+		}
+		if seen[ticketID] {
+			return "", errors.New("ticketID occurs more than once in the import")
+		}
+		_, err := d.repo.Find(ctx, ticketID, true)
+		if err == nil {
+			return "", errors.New("ticketID already exists")
+		}
+		if err != util.ErrUnknown {
+			return "", err
+		}
+		seen[ticketID] = true
+	}
+	if dryRun {
+		return ticketID, nil
+	}
+	return d.create(ctx, ticketID, doc, false, nil)
+}
+
+// errorMessage returns the text reported for a rejected row.
+func errorMessage(err error) string {
+	var rowErr *internal.RowError
+	if errors.As(err, &rowErr) {
+		return rowErr.Err
+	}
+	return err.Error()
+}
+
+func (d *dbService) Export(ctx context.Context, query internal.Query) (internal.DocumentReadCloser, error) {
+	if err := internal.ValidateFilters(query.Filters); err != nil {
+		return nil, err
+	}
+	if query.IncludeDeleted && !internal.IsAdmin(ctx) {
+		return nil, util.ErrForbidden
+	}
+	query.PageSize = internal.MaxPageSize
+	query.Cursor = ""
+	return &exportReader{ctx: ctx, svc: d, query: query}, nil
+}
+
+// exportReader walks the pages of a query, documents added while the export
+// runs are included if they sort after the current page. The content of every
+// document is read from the blob store so an export can be imported again.
+type exportReader struct {
+	ctx   context.Context
+	svc   *dbService
+	query internal.Query
+	docs  []internal.Document
+	done  bool
+}
+
+func (e *exportReader) Next() (*internal.Document, error) {
+	for len(e.docs) == 0 {
+		if e.done {
+			return nil, io.EOF
+		}
+		page, err := e.svc.Get(e.ctx, e.query)
+		if err != nil {
+			return nil, err
+		}
+		e.docs = page.Documents
+		e.query.Cursor = page.NextCursor
+		e.done = page.NextCursor == ""
+	}
+	doc := e.docs[0]
+	e.docs = e.docs[1:]
+	content, err := e.svc.readContent(e.ctx, doc.ContentDigest)
+	if err != nil {
+		return nil, err
+	}
+	doc.Content = content
+	return &doc, nil
+}
+
+func (e *exportReader) Close() error {
+	e.docs, e.done = nil, true
+	return nil
+}
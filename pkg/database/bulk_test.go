@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"publisher/internal"
+	"publisher/internal/util"
+	"publisher/pkg/blob"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sliceReader returns its rows, a row error stands for a row the decoder
+// could not read.
+type sliceReader struct {
+	rows []interface{}
+}
+
+func (r *sliceReader) Next() (*internal.Document, error) {
+	if len(r.rows) == 0 {
+		return nil, io.EOF
+	}
+	row := r.rows[0]
+	r.rows = r.rows[1:]
+	switch row := row.(type) {
+	case internal.Document:
+		return &row, nil
+	case error:
+		return nil, row
+	}
+	panic("unexpected row")
+}
+
+const existingTicket = "4b1ea1b5-3e2b-4c56-9f0e-6a9a7c9b7a10"
+
+func importRows() []interface{} {
+	return []interface{}{
+		internal.Document{Title: "a", Content: "first content"},
+		&internal.RowError{Err: "invalid JSON"},
+		internal.Document{Author: "nobody"},
+		internal.Document{TicketID: "not-a-uuid", Title: "d"},
+		internal.Document{TicketID: "0b7e0a0e-9f1b-4d3c-8a43-8b1f1c2d3e4f", Title: "e"},
+		internal.Document{TicketID: "0b7e0a0e-9f1b-4d3c-8a43-8b1f1c2d3e4f", Title: "f"},
+		internal.Document{TicketID: existingTicket, Title: "g"},
+		internal.Document{Title: strings.Repeat("h", 1000)},
+		internal.Document{Title: "i", Content: "last content"},
+	}
+}
+
+var importErrors = []internal.RowError{
+	{Row: 2, Err: "invalid JSON"},
+	{Row: 3, Err: util.ErrInvalidArgument.Error() + ": title or content is required"},
+	{Row: 4, Err: "ticketID is not a valid UUID"},
+	{Row: 6, Err: "ticketID occurs more than once in the import"},
+	{Row: 7, Err: "ticketID already exists"},
+}
+
+// newImportService returns a service holding a single removed document with
+// the existing ticket.
+func newImportService(t *testing.T) (Service, Repository, blob.Store) {
+	t.Helper()
+	ctx := context.Background()
+	repo, blobs := NewMemoryRepository(), blob.NewMemoryStore()
+	svc := NewService(repo, blobs, WithDuplicatePolicy(DuplicatePolicy{}))
+	result, err := svc.Import(ctx, &sliceReader{rows: []interface{}{internal.Document{TicketID: existingTicket, Title: "existing"}}}, false)
+	if err != nil || result.Imported != 1 {
+		t.Fatalf("Import returned %+v, %v", result, err)
+	}
+	if _, err := svc.Remove(ctx, existingTicket); err != nil {
+		t.Fatal(err)
+	}
+	return svc, repo, blobs
+}
+
+func countDocuments(t *testing.T, svc Service) int64 {
+	t.Helper()
+	page, err := svc.Get(context.Background(), internal.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page.Total
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newImportService(t)
+	result, err := svc.Import(ctx, &sliceReader{rows: importRows()}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the row of the too long title is reported with its field
+	if len(result.Errors) != len(importErrors)+1 || !strings.Contains(result.Errors[len(result.Errors)-1].Err, internal.KeyTitle) {
+		t.Fatalf("Import reported %+v", result.Errors)
+	}
+	if last := result.Errors[len(result.Errors)-1]; last.Row != 8 {
+		t.Errorf("too long title reported for row %d, want 8", last.Row)
+	}
+	if errs := result.Errors[:len(importErrors)]; !reflect.DeepEqual(errs, importErrors) {
+		t.Errorf("Import reported %+v, want %+v", errs, importErrors)
+	}
+	if result.Rows != 9 || result.Imported != 3 || len(result.TicketIDs) != 3 || result.DryRun {
+		t.Errorf("Import returned %+v, want 3 of 9 rows imported", result)
+	}
+	if result.TicketIDs[1] != "0b7e0a0e-9f1b-4d3c-8a43-8b1f1c2d3e4f" {
+		t.Errorf("Import created %s, want the ticket of the row", result.TicketIDs[1])
+	}
+	if n := countDocuments(t, svc); n != 3 {
+		t.Errorf("%d documents after the import, want 3", n)
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, blobs := newImportService(t)
+	offset, _ := repo.LastEventOffset(ctx)
+	stored := 0
+	blobs.Walk(ctx, func(blob.Info) error { stored++; return nil })
+
+	dry, err := svc.Import(ctx, &sliceReader{rows: importRows()}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dry.DryRun || dry.Rows != 9 || dry.Imported != 3 || len(dry.TicketIDs) != 0 {
+		t.Errorf("dry run returned %+v, want 3 of 9 rows counted and no tickets", dry)
+	}
+
+	// nothing was written: no document, no content and no event
+	if n := countDocuments(t, svc); n != 0 {
+		t.Errorf("%d documents after the dry run, want 0", n)
+	}
+	if last, _ := repo.LastEventOffset(ctx); last != offset {
+		t.Errorf("the dry run recorded events up to %d, want %d", last, offset)
+	}
+	after := 0
+	blobs.Walk(ctx, func(blob.Info) error { after++; return nil })
+	if after != stored {
+		t.Errorf("%d blobs after the dry run, want %d", after, stored)
+	}
+
+	// the dry run reports what the import does
+	result, err := svc.Import(ctx, &sliceReader{rows: importRows()}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Imported != dry.Imported || !reflect.DeepEqual(result.Errors, dry.Errors) {
+		t.Errorf("Import returned %+v after the dry run %+v", result, dry)
+	}
+}
+
+func TestImportReadError(t *testing.T) {
+	svc := NewService(NewMemoryRepository(), blob.NewMemoryStore())
+	failed := errors.New("connection reset")
+	result, err := svc.Import(context.Background(), &sliceReader{rows: []interface{}{
+		internal.Document{Title: "a"},
+		failed,
+		internal.Document{Title: "c"},
+	}}, false)
+	// a failing body ends the import, the rows before it stay imported
+	if err != failed || result.Imported != 1 || result.Rows != 1 {
+		t.Errorf("Import returned %+v, %v, want 1 row imported and %v", result, err, failed)
+	}
+	if _, err := svc.Import(context.Background(), nil, false); err != util.ErrInvalidArgument {
+		t.Errorf("Import without rows returned %v, want %v", err, util.ErrInvalidArgument)
+	}
+}
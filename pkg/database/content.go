@@ -0,0 +1,237 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"publisher/internal"
+	orm "publisher/internal/database"
+	"publisher/internal/util"
+	"publisher/pkg/blob"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// maxIndexedContent is the size of the content prefix indexed for the search,
+// postgres limits a tsvector to 1MB.
+const maxIndexedContent = 512 << 10
+
+// storeContent writes the content to the blob store and returns the prefix
+// of it which is indexed for the search.
+func (d *dbService) storeContent(ctx context.Context, r io.Reader) (blob.Ref, string, error) {
+	prefix := &prefixWriter{limit: maxIndexedContent}
+	ref, err := d.blobs.Put(ctx, io.TeeReader(r, prefix))
+	if err != nil {
+		return ref, "", err
+	}
+	return ref, indexText(prefix.buf.Bytes()), nil
+}
+
+// indexedContent reads the indexed prefix of a stored content.
+func (d *dbService) indexedContent(ctx context.Context, digest string) (string, error) {
+	if digest == "" {
+		return "", nil
+	}
+	f, err := d.blobs.Open(ctx, digest)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	b, err := io.ReadAll(io.LimitReader(f, maxIndexedContent))
+	if err != nil {
+		return "", err
+	}
+	return indexText(b), nil
+}
+
+// readContent returns the whole content of the document.
+func (d *dbService) readContent(ctx context.Context, digest string) (string, error) {
+	if digest == "" {
+		return "", nil
+	}
+	f, err := d.blobs.Open(ctx, digest)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var b strings.Builder
+	_, err = io.Copy(&b, f)
+	return b.String(), err
+}
+
+// PutContent replaces the content of the document by the content read from
+// r, which is streamed into the blob store. A version other than 0 must match
+// the current version of the document. The duplicate policy only applies to
+// the first content of the document.
+func (d *dbService) PutContent(ctx context.Context, ticketID string, r io.Reader, version int64) (internal.Document, error) {
+	if r == nil {
+		return internal.Document{}, util.ErrInvalidArgument
+	}
+	if _, err := d.repo.Find(ctx, ticketID, false); err != nil {
+		return internal.Document{}, d.logError("PutContent", ticketID, err)
+	}
+	ref, text, err := d.storeContent(ctx, r)
+	if err != nil {
+		return internal.Document{}, d.logError("PutContent", ticketID, err)
+	}
+	fp := newFingerprint(ticketID, ref.Digest, text)
+	var doc internal.Document
+	err = d.transaction(ctx, func(tx Repository) error {
+		row, err := tx.Find(ctx, ticketID, false)
+		if err != nil {
+			return err
+		}
+		if version != 0 && version != row.Version {
+			return util.ErrConflict
+		}
+		before := row.ToInternal()
+		// only the first content is a submission, later contents such as
+		// the watermarked one replace a content which was screened already
+		if row.ContentDigest == "" {
+			if err := d.screen(ctx, tx, row, fp); err != nil {
+				return err
+			}
+		}
+		row.ContentDigest, row.ContentSize = ref.Digest, ref.Size
+		row.Version++
+		if err := tx.Save(ctx, row); err != nil {
+			return err
+		}
+		if err := d.indexContent(ctx, tx, fp, text); err != nil {
+			return err
+		}
+		doc = row.ToInternal()
+		if err := tx.AddRevision(ctx, orm.NewRevision(row, internal.EditorFromContext(ctx), changedKeys(before, doc))); err != nil {
+			return err
+		}
+		return d.record(ctx, tx, internal.EventUpdated, row)
+	})
+	return doc, d.logError("PutContent", ticketID, err)
+}
+
+// OpenContent returns length bytes of the content starting at offset, a
+// length of 0 reads to the end. The caller must close the body.
+func (d *dbService) OpenContent(ctx context.Context, ticketID string, offset, length int64) (internal.Content, error) {
+	row, err := d.repo.Find(ctx, ticketID, false)
+	if err != nil {
+		return internal.Content{}, d.logError("OpenContent", ticketID, err)
+	}
+	content := internal.Content{TicketID: ticketID, Digest: row.ContentDigest, Size: row.ContentSize, Offset: offset}
+	if offset < 0 || length < 0 {
+		return content, util.ErrInvalidArgument
+	}
+	if offset > row.ContentSize || (offset == row.ContentSize && offset > 0) {
+		return content, util.ErrOutOfRange
+	}
+	if length == 0 || offset+length > row.ContentSize {
+		length = row.ContentSize - offset
+	}
+	content.Length = length
+	if row.ContentDigest == "" {
+		content.Body = io.NopCloser(strings.NewReader(""))
+		return content, nil
+	}
+	f, err := d.blobs.Open(ctx, row.ContentDigest)
+	if err != nil {
+		return content, d.logError("OpenContent", ticketID, err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return content, d.logError("OpenContent", ticketID, err)
+	}
+	content.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(f, length), f}
+	return content, nil
+}
+
+// CollectGarbage deletes the blobs no document or revision refers to. Blobs
+// stored less than grace ago are kept, their reference may not be committed yet.
+func CollectGarbage(ctx context.Context, repo Repository, blobs blob.Store, grace time.Duration) (blob.GCStats, error) {
+	referenced, err := repo.ContentDigests(ctx)
+	if err != nil {
+		return blob.GCStats{}, err
+	}
+	return blob.GC(ctx, blobs, referenced, grace)
+}
+
+// MigrateInlineContent moves the content still stored in the rows of
+// documents and revisions written before the blob store existed into the
+// blob store, it returns the number of moved rows.
+func MigrateInlineContent(ctx context.Context, db *gorm.DB, blobs blob.Store) (int, error) {
+	moved := 0
+	for _, table := range []string{"documents", "document_revisions"} {
+		var hasContent bool
+		err := db.WithContext(ctx).Raw(`SELECT EXISTS (SELECT 1 FROM information_schema.columns
+			WHERE table_name = ? AND column_name = 'content')`, table).Scan(&hasContent).Error
+		if err != nil {
+			return moved, err
+		}
+		if !hasContent {
+			continue
+		}
+		for {
+			var rows []struct {
+				ID      uint
+				Content string
+			}
+			err := db.WithContext(ctx).Table(table).Select("id, content").
+				Where("content <> '' AND content_digest = ''").Order("id").Limit(100).Find(&rows).Error
+			if err != nil {
+				return moved, err
+			}
+			if len(rows) == 0 {
+				break
+			}
+			for _, row := range rows {
+				ref, err := blobs.Put(ctx, strings.NewReader(row.Content))
+				if err != nil {
+					return moved, err
+				}
+				err = db.WithContext(ctx).Table(table).Where("id = ?", row.ID).Updates(map[string]interface{}{
+					"content_digest": ref.Digest,
+					"content_size":   ref.Size,
+					"content":        "",
+				}).Error
+				if err != nil {
+					return moved, err
+				}
+				moved++
+			}
+		}
+	}
+	return moved, nil
+}
+
+// prefixWriter keeps the first limit bytes written to it.
+type prefixWriter struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	if rest := p.limit - p.buf.Len(); rest > 0 {
+		if len(b) < rest {
+			rest = len(b)
+		}
+		p.buf.Write(b[:rest])
+	}
+	return len(b), nil
+}
+
+// indexText cuts an incomplete UTF-8 sequence at the end of the prefix and
+// drops invalid bytes, postgres rejects invalid UTF-8.
+func indexText(b []byte) string {
+	for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+		r, size := utf8.DecodeLastRune(b)
+		if r != utf8.RuneError || size > 1 {
+			break
+		}
+		b = b[:len(b)-1]
+	}
+	return strings.ToValidUTF8(string(bytes.ReplaceAll(b, []byte{0}, nil)), "")
+}
@@ -2,42 +2,400 @@ package database
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"os"
 	"publisher/internal"
+	orm "publisher/internal/database"
+	"publisher/internal/util"
+	"publisher/pkg/blob"
+	"strings"
+	"time"
 
 	"github.com/go-kit/log"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-type dbService struct{}
+type dbService struct {
+	repo  Repository
+	blobs blob.Store
+	// idempotencyTTL is how long the idempotency keys of Add are kept
+	idempotencyTTL time.Duration
+	duplicates     DuplicatePolicy
+	events         *eventHub
+}
+
+// Option configures the database service.
+type Option func(d *dbService)
+
+// WithIdempotencyTTL sets how long a repeated Add with the same idempotency
+// key returns the ticket of the first one, the default is a day.
+func WithIdempotencyTTL(ttl time.Duration) Option {
+	return func(d *dbService) {
+		if ttl > 0 {
+			d.idempotencyTTL = ttl
+		}
+	}
+}
 
-func NewService() Service {
-	return &dbService{}
+// NewService returns the database service storing the documents in repo and
+// their content in blobs.
+func NewService(repo Repository, blobs blob.Store, options ...Option) Service {
+	d := &dbService{repo: repo, blobs: blobs, idempotencyTTL: DefaultIdempotencyTTL, duplicates: DefaultDuplicatePolicy}
+	d.events = newEventHub()
+	for _, option := range options {
+		option(d)
+	}
+	return d
 }
 
 // implement service interface;
 
-func (d *dbService) Add(_ context.Context, doc *internal.Document) (string, error) {
-	return "", nil
+// Add creates a ticket for the document. If the context carries an
+// idempotency key, repeating the request with the key returns the ticket of
+// the first request instead, see addOnce.
+func (d *dbService) Add(ctx context.Context, doc *internal.Document) (string, error) {
+	if doc == nil {
+		return "", util.ErrInvalidArgument
+	}
+	if key := internal.IdempotencyKeyFromContext(ctx); key != "" {
+		ticketID, err := d.addOnce(ctx, key, doc)
+		return ticketID, d.logError("Add", ticketID, err)
+	}
+	ticketID, err := d.create(ctx, uuid.New().String(), doc, true, nil)
+	if err != nil {
+		return "", d.logError("Add", ticketID, err)
+	}
+	return ticketID, nil
 }
 
-func (d *dbService) Get(_ context.Context, filters ...internal.Filter) ([]internal.Document, error) {
-	return []internal.Document{}, nil
+// create stores the document with its first revision. claim is called first
+// in the transaction unless it is nil, an error of it aborts the creation.
+// With screen set the duplicate policy is applied to the content.
+func (d *dbService) create(ctx context.Context, ticketID string, doc *internal.Document, screen bool, claim func(tx Repository) error) (string, error) {
+	row := orm.NewDocument(ticketID, doc)
+	var (
+		text string
+		fp   *orm.Fingerprint
+	)
+	if doc.Content != "" {
+		ref, prefix, err := d.storeContent(ctx, strings.NewReader(doc.Content))
+		if err != nil {
+			return "", err
+		}
+		row.ContentDigest, row.ContentSize, text = ref.Digest, ref.Size, prefix
+		fp = newFingerprint(ticketID, ref.Digest, text)
+	}
+	err := d.transaction(ctx, func(tx Repository) error {
+		if claim != nil {
+			if err := claim(tx); err != nil {
+				return err
+			}
+		}
+		if screen {
+			if err := d.screen(ctx, tx, row, fp); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(ctx, row); err != nil {
+			return err
+		}
+		if fp != nil {
+			if err := d.indexContent(ctx, tx, fp, text); err != nil {
+				return err
+			}
+		}
+		changes := changedKeys(internal.Document{}, row.ToInternal())
+		if err := tx.AddRevision(ctx, orm.NewRevision(row, internal.EditorFromContext(ctx), changes)); err != nil {
+			return err
+		}
+		return d.record(ctx, tx, internal.EventAdded, row)
+	})
+	return row.TicketID, err
 }
 
-func (d *dbService) Update(_ context.Context, ticketID string, doc *internal.Document) (int, error) {
-	return http.StatusOK, nil
+func (d *dbService) Get(ctx context.Context, query internal.Query) (internal.Page, error) {
+	if err := internal.ValidateFilters(query.Filters); err != nil {
+		return internal.Page{Documents: []internal.Document{}}, err
+	}
+	if query.IncludeDeleted && !internal.IsAdmin(ctx) {
+		return internal.Page{Documents: []internal.Document{}}, util.ErrForbidden
+	}
+	page, err := d.repo.List(ctx, query)
+	if err != nil && err != util.ErrInvalidArgument {
+		logger.Log("method", "Get", "err", err)
+	}
+	return page, err
 }
 
-func (d *dbService) Remove(_ context.Context, ticketID string) (int, error) {
-	return http.StatusOK, nil
+func (d *dbService) Search(ctx context.Context, query internal.SearchQuery) ([]internal.SearchResult, error) {
+	if strings.TrimSpace(query.Text) == "" {
+		return []internal.SearchResult{}, util.ErrInvalidArgument
+	}
+	results, err := d.repo.Search(ctx, query)
+	if err != nil {
+		logger.Log("method", "Search", "err", err)
+	}
+	return results, err
+}
+
+// Update changes the non empty fields of the document. If doc.Version is set
+// the update only succeeds if the stored document still has that version,
+// otherwise util.ErrConflict is returned. On success doc.Version is set to
+// the new version of the document.
+func (d *dbService) Update(ctx context.Context, ticketID string, doc *internal.Document) (int, error) {
+	if doc == nil {
+		return http.StatusBadRequest, util.ErrInvalidArgument
+	}
+	var (
+		ref  blob.Ref
+		text string
+	)
+	if doc.Content != "" {
+		var err error
+		if ref, text, err = d.storeContent(ctx, strings.NewReader(doc.Content)); err != nil {
+			return d.code("Update", ticketID, err)
+		}
+	}
+	var version int64
+	err := d.transaction(ctx, func(tx Repository) error {
+		row, err := tx.Find(ctx, ticketID, false)
+		if err != nil {
+			return err
+		}
+		if doc.Version != 0 && doc.Version != row.Version {
+			return util.ErrConflict
+		}
+		before := row.ToInternal()
+		applyFields(row, doc)
+		if ref.Digest != "" {
+			row.ContentDigest, row.ContentSize = ref.Digest, ref.Size
+			if err := d.indexContent(ctx, tx, newFingerprint(ticketID, ref.Digest, text), text); err != nil {
+				return err
+			}
+		}
+		row.Version++
+		version = row.Version
+		if err := tx.Save(ctx, row); err != nil {
+			return err
+		}
+		changes := changedKeys(before, row.ToInternal())
+		if err := tx.AddRevision(ctx, orm.NewRevision(row, internal.EditorFromContext(ctx), changes)); err != nil {
+			return err
+		}
+		return d.record(ctx, tx, updateEvent(changes), row)
+	})
+	if err == nil {
+		doc.Version = version
+	}
+	return d.code("Update", ticketID, err)
+}
+
+func (d *dbService) Remove(ctx context.Context, ticketID string) (int, error) {
+	err := d.transaction(ctx, func(tx Repository) error {
+		if err := tx.Remove(ctx, ticketID); err != nil {
+			return err
+		}
+		row, err := tx.Find(ctx, ticketID, true)
+		if err != nil {
+			return err
+		}
+		return d.record(ctx, tx, internal.EventRemoved, row)
+	})
+	return d.code("Remove", ticketID, err)
+}
+
+// Restore brings back a removed document, restoring an active document is a
+// no-op. Only admins may restore.
+func (d *dbService) Restore(ctx context.Context, ticketID string) (int, error) {
+	if !internal.IsAdmin(ctx) {
+		return d.code("Restore", ticketID, util.ErrForbidden)
+	}
+	err := d.transaction(ctx, func(tx Repository) error {
+		row, err := tx.Find(ctx, ticketID, true)
+		if err != nil || !row.DeletedAt.Valid {
+			return err
+		}
+		if err := tx.Restore(ctx, ticketID); err != nil {
+			return err
+		}
+		row.DeletedAt = gorm.DeletedAt{}
+		return d.record(ctx, tx, internal.EventRestored, row)
+	})
+	return d.code("Restore", ticketID, err)
+}
+
+// Purge deletes the document permanently, whether it was removed before or
+// not. Only admins may purge.
+func (d *dbService) Purge(ctx context.Context, ticketID string) (int, error) {
+	if !internal.IsAdmin(ctx) {
+		return d.code("Purge", ticketID, util.ErrForbidden)
+	}
+	err := d.transaction(ctx, func(tx Repository) error {
+		row, err := tx.Find(ctx, ticketID, true)
+		if err != nil {
+			return err
+		}
+		if err := tx.Purge(ctx, ticketID); err != nil {
+			return err
+		}
+		return d.record(ctx, tx, internal.EventPurged, row)
+	})
+	return d.code("Purge", ticketID, err)
+}
+
+// History also returns the revisions of removed documents.
+func (d *dbService) History(ctx context.Context, ticketID string) ([]internal.Revision, error) {
+	history := []internal.Revision{}
+	if _, err := d.repo.Find(ctx, ticketID, true); err != nil {
+		return history, d.logError("History", ticketID, err)
+	}
+	revs, err := d.repo.Revisions(ctx, ticketID)
+	if err != nil {
+		return history, d.logError("History", ticketID, err)
+	}
+	for i := range revs {
+		history = append(history, revs[i].ToInternal())
+	}
+	return history, nil
+}
+
+func (d *dbService) Revision(ctx context.Context, ticketID string, number int64) (internal.Revision, error) {
+	if number <= 0 {
+		return internal.Revision{}, util.ErrInvalidArgument
+	}
+	rev, err := d.repo.FindRevision(ctx, ticketID, number)
+	if err != nil {
+		return internal.Revision{}, d.logError("Revision", ticketID, err)
+	}
+	return rev.ToInternal(), nil
+}
+
+func (d *dbService) Diff(ctx context.Context, ticketID string, from, to int64) (internal.Diff, error) {
+	diff := internal.Diff{TicketID: ticketID, From: from, To: to, Changes: []internal.FieldChange{}}
+	a, err := d.Revision(ctx, ticketID, from)
+	if err != nil {
+		return diff, err
+	}
+	b, err := d.Revision(ctx, ticketID, to)
+	if err != nil {
+		return diff, err
+	}
+	diff.Changes = internal.DiffDocuments(a.Document, b.Document)
+	return diff, nil
+}
+
+// Rollback writes the fields of the revision to the document, the document
+// gets a new version so the history is never rewritten.
+func (d *dbService) Rollback(ctx context.Context, ticketID string, number int64) (internal.Revision, error) {
+	if number <= 0 {
+		return internal.Revision{}, util.ErrInvalidArgument
+	}
+	var rev *orm.Revision
+	err := d.transaction(ctx, func(tx Repository) error {
+		row, err := tx.Find(ctx, ticketID, false)
+		if err != nil {
+			return err
+		}
+		target, err := tx.FindRevision(ctx, ticketID, number)
+		if err != nil {
+			return err
+		}
+		before := row.ToInternal()
+		if target.ContentDigest != row.ContentDigest {
+			text, err := d.indexedContent(ctx, target.ContentDigest)
+			if err != nil {
+				return err
+			}
+			if err := d.indexContent(ctx, tx, newFingerprint(ticketID, target.ContentDigest, text), text); err != nil {
+				return err
+			}
+		}
+		row.ContentDigest, row.ContentSize = target.ContentDigest, target.ContentSize
+		row.Title, row.Author, row.Topic, row.Watermark = target.Title, target.Author, target.Topic, target.Watermark
+		row.Version++
+		if err := tx.Save(ctx, row); err != nil {
+			return err
+		}
+		rev = orm.NewRevision(row, internal.EditorFromContext(ctx), changedKeys(before, row.ToInternal()))
+		rev.RollbackOf = number
+		if err := tx.AddRevision(ctx, rev); err != nil {
+			return err
+		}
+		return d.record(ctx, tx, updateEvent(rev.ToInternal().Changes), row)
+	})
+	if err != nil {
+		return internal.Revision{}, d.logError("Rollback", ticketID, err)
+	}
+	return rev.ToInternal(), nil
 }
 
-func (d *dbService) ServiceStatus(_ context.Context) (int, error) {
+func (d *dbService) ServiceStatus(ctx context.Context) (int, error) {
 	logger.Log("Checking the Service health...")
+	if err := d.repo.Ping(ctx); err != nil {
+		return http.StatusServiceUnavailable, err
+	}
 	return http.StatusOK, nil
 }
 
+// code maps the error of a ticket operation to the returned status code.
+func (d *dbService) code(method, ticketID string, err error) (int, error) {
+	switch {
+	case err == nil:
+		return http.StatusOK, nil
+	case errors.Is(err, util.ErrUnknown):
+		return http.StatusNotFound, err
+	case errors.Is(err, util.ErrInvalidArgument):
+		return http.StatusBadRequest, err
+	case errors.Is(err, util.ErrConflict):
+		return http.StatusConflict, err
+	case errors.Is(err, util.ErrForbidden):
+		return http.StatusForbidden, err
+	default:
+		logger.Log("method", method, "ticketID", ticketID, "err", err)
+		return http.StatusInternalServerError, err
+	}
+}
+
+// logError logs the unexpected errors of a ticket operation.
+func (d *dbService) logError(method, ticketID string, err error) error {
+	switch {
+	case err == nil, errors.Is(err, util.ErrUnknown), errors.Is(err, util.ErrInvalidArgument),
+		errors.Is(err, util.ErrConflict), errors.Is(err, util.ErrOutOfRange), errors.Is(err, util.ErrForbidden):
+	default:
+		logger.Log("method", method, "ticketID", ticketID, "err", err)
+	}
+	return err
+}
+
+// changedKeys returns the keys of the fields which differ between the documents.
+func changedKeys(before, after internal.Document) []string {
+	var keys []string
+	for _, c := range internal.DiffDocuments(before, after) {
+		keys = append(keys, c.Key)
+	}
+	return keys
+}
+
+// applyFields copies the non empty fields of the document to the row, the
+// content is stored separately.
+func applyFields(row *orm.Document, doc *internal.Document) {
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&row.Title, doc.Title},
+		{&row.Author, doc.Author},
+		{&row.Topic, doc.Topic},
+		{&row.Watermark, doc.Watermark},
+	} {
+		if f.src != "" {
+			*f.dst = f.src
+		}
+	}
+}
+
 var logger log.Logger
 
 func init() {
@@ -0,0 +1,474 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"publisher/internal"
+	orm "publisher/internal/database"
+	"publisher/internal/util"
+	"publisher/pkg/blob"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// openTestDB opens a migrated schema of its own in the postgres database
+// the DATABASE_TEST_DSN, in key=value form, points to. The test is skipped
+// without it, the schema is dropped when the test ends.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("DATABASE_TEST_DSN")
+	if dsn == "" {
+		t.Skip("DATABASE_TEST_DSN is not set")
+	}
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := "test_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(postgres.Open(dsn+" search_path="+schema), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		closeDB(db)
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		closeDB(admin)
+	})
+	m, err := orm.NewMigrator(db, orm.Migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func closeDB(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+// newTestRepository returns an empty repository, on postgres if
+// DATABASE_TEST_DSN is set and in memory otherwise.
+func newTestRepository(t *testing.T) Repository {
+	t.Helper()
+	if os.Getenv("DATABASE_TEST_DSN") == "" {
+		return NewMemoryRepository()
+	}
+	return NewGormRepository(openTestDB(t))
+}
+
+// newTestService returns a service on an empty repository, which accepts
+// duplicate content.
+func newTestService(t *testing.T) Service {
+	t.Helper()
+	return NewService(newTestRepository(t), blob.NewMemoryStore(), WithDuplicatePolicy(DuplicatePolicy{}))
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	want := internal.Document{Content: "Content", Title: "Title", Author: "Author", Topic: "Topic"}
+	ticketID, err := svc.Add(ctx, &want)
+	if err != nil || ticketID == "" {
+		t.Fatalf("Add returned %q, %v", ticketID, err)
+	}
+	// the content is kept in the blob store, the document refers to it
+	sum := sha256.Sum256([]byte(want.Content))
+	want.TicketID, want.Version = ticketID, 1
+	want.Content, want.ContentDigest, want.ContentSize = "", hex.EncodeToString(sum[:]), int64(len(want.Content))
+	if _, err := svc.Add(ctx, &internal.Document{Title: "Other"}); err != nil {
+		t.Fatal(err)
+	}
+	get := func(step string) []internal.Document {
+		t.Helper()
+		page, err := svc.Get(ctx, internal.Query{Filters: []internal.Filter{{Key: internal.KeyTicketID, Value: ticketID}}})
+		if err != nil {
+			t.Fatalf("%s: Get returned %v", step, err)
+		}
+		return page.Documents
+	}
+	if docs := get("Add"); !reflect.DeepEqual(docs, []internal.Document{want}) {
+		t.Errorf("Get after Add returned %+v, want %+v", docs, want)
+	}
+	content, err := svc.OpenContent(ctx, ticketID, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(content.Body)
+	content.Body.Close()
+	if err != nil || string(b) != "Content" || content.Digest != want.ContentDigest {
+		t.Errorf("OpenContent returned %q of %s, %v, want %q of %s", b, content.Digest, err, "Content", want.ContentDigest)
+	}
+
+	// the empty fields are left as they are
+	if code, err := svc.Update(ctx, ticketID, &internal.Document{Title: "Changed", Watermark: "mark"}); err != nil || code != http.StatusOK {
+		t.Errorf("Update returned %d, %v", code, err)
+	}
+	want.Title, want.Watermark, want.Version = "Changed", "mark", 2
+	if docs := get("Update"); !reflect.DeepEqual(docs, []internal.Document{want}) {
+		t.Errorf("Get after Update returned %+v, want %+v", docs, want)
+	}
+
+	if code, err := svc.Remove(ctx, ticketID); err != nil || code != http.StatusOK {
+		t.Errorf("Remove returned %d, %v", code, err)
+	}
+	if docs := get("Remove"); len(docs) != 0 {
+		t.Errorf("Get after Remove returned %+v, want none", docs)
+	}
+	if page, err := svc.Get(ctx, internal.Query{}); err != nil || len(page.Documents) != 1 || page.Documents[0].Title != "Other" {
+		t.Errorf("Get of all documents returned %+v, %v, want the other document", page, err)
+	}
+
+	// the removed ticket is unknown now
+	if code, err := svc.Update(ctx, ticketID, &internal.Document{Title: "Again"}); !errors.Is(err, util.ErrUnknown) || code != http.StatusNotFound {
+		t.Errorf("Update of a removed ticket returned %d, %v, want %d, %v", code, err, http.StatusNotFound, util.ErrUnknown)
+	}
+	if code, err := svc.Remove(ctx, ticketID); !errors.Is(err, util.ErrUnknown) || code != http.StatusNotFound {
+		t.Errorf("Remove of a removed ticket returned %d, %v, want %d, %v", code, err, http.StatusNotFound, util.ErrUnknown)
+	}
+	if _, err := svc.Add(ctx, nil); !errors.Is(err, util.ErrInvalidArgument) {
+		t.Errorf("Add without document returned %v, want %v", err, util.ErrInvalidArgument)
+	}
+	if _, err := svc.Get(ctx, internal.Query{Filters: []internal.Filter{{Key: "publisher", Value: "x"}}}); !errors.Is(err, util.ErrInvalidArgument) {
+		t.Errorf("Get with an unknown key returned %v, want %v", err, util.ErrInvalidArgument)
+	}
+}
+
+func TestUpdateStaleVersion(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	ticketID, err := svc.Add(ctx, &internal.Document{Title: "Title", Author: "Author", Topic: "Topic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := svc.Get(ctx, internal.Query{})
+	if err != nil || len(page.Documents) != 1 {
+		t.Fatalf("Get returned %+v, %v", page, err)
+	}
+	read := page.Documents[0].Version
+
+	// both editors read the same version, the second one to write loses
+	alice := &internal.Document{Title: "Alice", Version: read}
+	bob := &internal.Document{Title: "Bob", Version: read}
+	if code, err := svc.Update(internal.WithEditor(ctx, "alice"), ticketID, alice); err != nil || code != http.StatusOK {
+		t.Fatalf("Update of alice returned %d, %v", code, err)
+	}
+	if alice.Version != read+1 {
+		t.Errorf("Update of alice set version %d, want %d", alice.Version, read+1)
+	}
+	code, err := svc.Update(internal.WithEditor(ctx, "bob"), ticketID, bob)
+	if !errors.Is(err, util.ErrConflict) || code != http.StatusConflict {
+		t.Errorf("Update of bob returned %d, %v, want %d, %v", code, err, http.StatusConflict, util.ErrConflict)
+	}
+	page, _ = svc.Get(ctx, internal.Query{})
+	if doc := page.Documents[0]; doc.Title != "Alice" || doc.Version != read+1 {
+		t.Errorf("document is %q version %d, want %q version %d", doc.Title, doc.Version, "Alice", read+1)
+	}
+
+	// bob reads the current version and retries
+	bob.Version = alice.Version
+	if _, err := svc.Update(internal.WithEditor(ctx, "bob"), ticketID, bob); err != nil {
+		t.Errorf("Update of bob with the current version returned %v", err)
+	}
+	// without a version the update is unconditional
+	if _, err := svc.Update(ctx, ticketID, &internal.Document{Topic: "Other"}); err != nil {
+		t.Errorf("Update without version returned %v", err)
+	}
+}
+
+func TestUpdateRace(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	ticketID, err := svc.Add(ctx, &internal.Document{Title: "Title", Author: "Author", Topic: "Topic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const editors = 8
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+		conflicts int
+	)
+	for i := 0; i < editors; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.Update(ctx, ticketID, &internal.Document{Title: "Changed", Version: 1})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				succeeded++
+			case errors.Is(err, util.ErrConflict):
+				conflicts++
+			default:
+				t.Errorf("Update returned %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if succeeded != 1 || conflicts != editors-1 {
+		t.Errorf("%d updates succeeded and %d conflicted, want 1 and %d", succeeded, conflicts, editors-1)
+	}
+}
+
+func TestRemoveRestorePurge(t *testing.T) {
+	ctx := context.Background()
+	admin := internal.WithAdmin(ctx)
+	repo := newTestRepository(t)
+	svc := NewService(repo, blob.NewMemoryStore(), WithDuplicatePolicy(DuplicatePolicy{}))
+	removed, err := svc.Add(ctx, &internal.Document{Title: "Removed", Content: "removed content"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := svc.Add(ctx, &internal.Document{Title: "Kept"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only admins see removed documents
+	list := func(step string, includeDeleted bool) map[string]internal.Document {
+		t.Helper()
+		listCtx := ctx
+		if includeDeleted {
+			listCtx = admin
+		}
+		page, err := svc.Get(listCtx, internal.Query{IncludeDeleted: includeDeleted})
+		if err != nil {
+			t.Fatalf("%s: Get returned %v", step, err)
+		}
+		docs := map[string]internal.Document{}
+		for _, doc := range page.Documents {
+			docs[doc.Title] = doc
+		}
+		return docs
+	}
+	expect := func(step string, err error, code int, wantErr error, wantCode int) {
+		t.Helper()
+		if !errors.Is(err, wantErr) || code != wantCode {
+			t.Errorf("%s returned %d, %v, want %d, %v", step, code, err, wantCode, wantErr)
+		}
+	}
+
+	code, err := svc.Remove(ctx, removed)
+	expect("Remove", err, code, nil, http.StatusOK)
+	// a removed document is hidden unless removed documents are asked for
+	if docs := list("Remove", false); len(docs) != 1 || docs["Kept"].TicketID != kept {
+		t.Errorf("Get after Remove returned %+v, want only the kept document", docs)
+	}
+	docs := list("Remove", true)
+	if len(docs) != 2 || docs["Removed"].DeletedAt == nil || docs["Kept"].DeletedAt != nil {
+		t.Errorf("Get of removed documents returned %+v, want both with the removed one marked", docs)
+	}
+	if page, err := svc.Get(ctx, internal.Query{IncludeDeleted: true}); !errors.Is(err, util.ErrForbidden) || len(page.Documents) != 0 {
+		t.Errorf("Get of removed documents by a non-admin returned %+v, %v, want %v", page, err, util.ErrForbidden)
+	}
+	code, err = svc.Remove(ctx, removed)
+	expect("second Remove", err, code, util.ErrUnknown, http.StatusNotFound)
+	code, err = svc.Update(ctx, removed, &internal.Document{Title: "Changed"})
+	expect("Update of a removed document", err, code, util.ErrUnknown, http.StatusNotFound)
+
+	code, err = svc.Restore(ctx, removed)
+	expect("Restore by a non-admin", err, code, util.ErrForbidden, http.StatusForbidden)
+	code, err = svc.Purge(ctx, removed)
+	expect("Purge by a non-admin", err, code, util.ErrForbidden, http.StatusForbidden)
+	if docs := list("refused Purge", true); len(docs) != 2 || docs["Removed"].DeletedAt == nil {
+		t.Errorf("Get after the refused calls returned %+v, want the removed document unchanged", docs)
+	}
+	code, err = svc.Restore(admin, removed)
+	expect("Restore", err, code, nil, http.StatusOK)
+	if docs := list("Restore", false); len(docs) != 2 || docs["Removed"].DeletedAt != nil {
+		t.Errorf("Get after Restore returned %+v, want both documents", docs)
+	}
+	code, err = svc.Restore(admin, kept)
+	expect("Restore of an active document", err, code, nil, http.StatusOK)
+
+	// purge deletes active and removed documents alike
+	if _, err := repo.FindFingerprint(ctx, removed); err != nil {
+		t.Fatalf("FindFingerprint before Purge returned %v", err)
+	}
+	if _, err := svc.TransitionWatermark(ctx, removed, internal.WatermarkTransition{To: internal.Pending, Mark: "mark"}); err != nil {
+		t.Fatal(err)
+	}
+	code, err = svc.Purge(admin, removed)
+	expect("Purge", err, code, nil, http.StatusOK)
+	if _, err := svc.Remove(ctx, kept); err != nil {
+		t.Fatal(err)
+	}
+	code, err = svc.Purge(admin, kept)
+	expect("Purge of a removed document", err, code, nil, http.StatusOK)
+	if docs := list("Purge", true); len(docs) != 0 {
+		t.Errorf("Get after Purge returned %+v, want none", docs)
+	}
+	// nothing of a purged document is left behind
+	for _, ticketID := range []string{removed, kept} {
+		if revs, err := repo.Revisions(ctx, ticketID); err != nil || len(revs) != 0 {
+			t.Errorf("%s has the revisions %+v, %v after Purge, want none", ticketID, revs, err)
+		}
+		if fp, err := repo.FindFingerprint(ctx, ticketID); !errors.Is(err, util.ErrUnknown) {
+			t.Errorf("%s has the fingerprint %+v, %v after Purge, want %v", ticketID, fp, err, util.ErrUnknown)
+		}
+		if job, err := repo.FindWatermarkJob(ctx, ticketID); !errors.Is(err, util.ErrUnknown) {
+			t.Errorf("%s has the watermark job %+v, %v after Purge, want %v", ticketID, job, err, util.ErrUnknown)
+		}
+	}
+	code, err = svc.Restore(admin, removed)
+	expect("Restore of a purged document", err, code, util.ErrUnknown, http.StatusNotFound)
+	code, err = svc.Purge(admin, removed)
+	expect("second Purge", err, code, util.ErrUnknown, http.StatusNotFound)
+}
+
+func TestRevisions(t *testing.T) {
+	ctx := internal.WithEditor(context.Background(), "alice")
+	svc := newTestService(t)
+	ticketID, err := svc.Add(ctx, &internal.Document{Content: "Content", Title: "Title", Author: "Author", Topic: "Topic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []struct {
+		editor string
+		doc    internal.Document
+	}{
+		{"bob", internal.Document{Title: "Second"}},
+		{"alice", internal.Document{Title: "Third", Topic: "Other"}},
+		// an update changing nothing still writes a revision
+		{"", internal.Document{Title: "Third"}},
+	} {
+		if _, err := svc.Update(internal.WithEditor(ctx, u.editor), ticketID, &u.doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := svc.History(ctx, ticketID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// revision n holds the document at version n
+	for i, want := range []struct {
+		editor  string
+		title   string
+		changes []string
+	}{
+		{"alice", "Title", []string{internal.KeyContent, internal.KeyTitle, internal.KeyAuthor, internal.KeyTopic}},
+		{"bob", "Second", []string{internal.KeyTitle}},
+		{"alice", "Third", []string{internal.KeyTitle, internal.KeyTopic}},
+		{"", "Third", nil},
+	} {
+		if i >= len(history) {
+			t.Fatalf("History returned %d revisions, want 4", len(history))
+		}
+		rev := history[i]
+		if rev.Number != int64(i+1) || rev.Document.Version != rev.Number || rev.TicketID != ticketID {
+			t.Errorf("revision %d has number %d and version %d", i+1, rev.Number, rev.Document.Version)
+		}
+		if rev.Editor != want.editor || rev.Document.Title != want.title || !reflect.DeepEqual(rev.Changes, want.changes) {
+			t.Errorf("revision %d by %q titled %q changed %q, want by %q titled %q changing %q",
+				i+1, rev.Editor, rev.Document.Title, rev.Changes, want.editor, want.title, want.changes)
+		}
+	}
+	if len(history) != 4 {
+		t.Errorf("History returned %d revisions, want 4", len(history))
+	}
+	if rev, err := svc.Revision(ctx, ticketID, 2); err != nil || !reflect.DeepEqual(rev, history[1]) {
+		t.Errorf("Revision 2 returned %+v, %v, want %+v", rev, err, history[1])
+	}
+
+	for _, tt := range []struct {
+		from, to int64
+		want     []internal.FieldChange
+	}{
+		{1, 3, []internal.FieldChange{{Key: internal.KeyTitle, From: "Title", To: "Third"}, {Key: internal.KeyTopic, From: "Topic", To: "Other"}}},
+		{3, 1, []internal.FieldChange{{Key: internal.KeyTitle, From: "Third", To: "Title"}, {Key: internal.KeyTopic, From: "Other", To: "Topic"}}},
+		{3, 4, []internal.FieldChange{}},
+	} {
+		diff, err := svc.Diff(ctx, ticketID, tt.from, tt.to)
+		if err != nil || !reflect.DeepEqual(diff.Changes, tt.want) || diff.From != tt.from || diff.To != tt.to {
+			t.Errorf("Diff from %d to %d returned %+v, %v, want %+v", tt.from, tt.to, diff, err, tt.want)
+		}
+	}
+
+	// a rollback writes a new revision with the fields of the old one
+	rev, err := svc.Rollback(internal.WithEditor(ctx, "carol"), ticketID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev.Number != 5 || rev.RollbackOf != 1 || rev.Editor != "carol" || rev.Document.Title != "Title" || rev.Document.Topic != "Topic" ||
+		!reflect.DeepEqual(rev.Changes, []string{internal.KeyTitle, internal.KeyTopic}) {
+		t.Errorf("Rollback returned %+v", rev)
+	}
+	page, err := svc.Get(ctx, internal.Query{})
+	if err != nil || len(page.Documents) != 1 {
+		t.Fatalf("Get returned %+v, %v", page, err)
+	}
+	if doc := page.Documents[0]; doc.Title != "Title" || doc.Topic != "Topic" || doc.Version != 5 {
+		t.Errorf("document after Rollback is %+v, want the fields of revision 1 at version 5", doc)
+	}
+	// the version read before the rollback is stale now
+	if _, err := svc.Update(ctx, ticketID, &internal.Document{Title: "Stale", Version: 4}); !errors.Is(err, util.ErrConflict) {
+		t.Errorf("Update of the version before the rollback returned %v, want %v", err, util.ErrConflict)
+	}
+	if history, _ := svc.History(ctx, ticketID); len(history) != 5 || history[0].Document.Title != "Title" || history[3].Document.Title != "Third" {
+		t.Errorf("History after Rollback returned %+v, want the earlier revisions unchanged", history)
+	}
+
+	for _, tt := range []struct {
+		name string
+		call func() error
+		err  error
+	}{
+		{"Revision of an unknown number", func() error { _, err := svc.Revision(ctx, ticketID, 6); return err }, util.ErrUnknown},
+		{"Revision of an unknown ticket", func() error { _, err := svc.Revision(ctx, "unknown", 1); return err }, util.ErrUnknown},
+		{"Revision 0", func() error { _, err := svc.Revision(ctx, ticketID, 0); return err }, util.ErrInvalidArgument},
+		{"History of an unknown ticket", func() error { _, err := svc.History(ctx, "unknown"); return err }, util.ErrUnknown},
+		{"Diff to an unknown number", func() error { _, err := svc.Diff(ctx, ticketID, 1, 9); return err }, util.ErrUnknown},
+		{"Rollback to an unknown number", func() error { _, err := svc.Rollback(ctx, ticketID, 9); return err }, util.ErrUnknown},
+		{"Rollback of an unknown ticket", func() error { _, err := svc.Rollback(ctx, "unknown", 1); return err }, util.ErrUnknown},
+		{"Rollback to 0", func() error { _, err := svc.Rollback(ctx, ticketID, 0); return err }, util.ErrInvalidArgument},
+	} {
+		if err := tt.call(); !errors.Is(err, tt.err) {
+			t.Errorf("%s returned %v, want %v", tt.name, err, tt.err)
+		}
+	}
+	if history, _ := svc.History(ctx, ticketID); len(history) != 5 {
+		t.Errorf("the failed calls left %d revisions, want 5", len(history))
+	}
+}
+
+func TestCode(t *testing.T) {
+	d := &dbService{}
+	for _, tt := range []struct {
+		err  error
+		want int
+	}{
+		{nil, http.StatusOK},
+		{util.ErrUnknown, http.StatusNotFound},
+		{fmt.Errorf("%w: ticket a", util.ErrUnknown), http.StatusNotFound},
+		{fmt.Errorf("%w: no title", util.ErrInvalidArgument), http.StatusBadRequest},
+		{fmt.Errorf("%w: version 2", util.ErrConflict), http.StatusConflict},
+		{fmt.Errorf("%w: not an admin", util.ErrForbidden), http.StatusForbidden},
+		{errors.New("connection refused"), http.StatusInternalServerError},
+	} {
+		if got, err := d.code("Test", "a", tt.err); got != tt.want || err != tt.err {
+			t.Errorf("code(%v) returned %d, %v, want %d", tt.err, got, err, tt.want)
+		}
+	}
+}
@@ -3,9 +3,11 @@ package endpoints
 import (
 	"context"
 	"errors"
+	"io"
 	"net/http"
 	"os"
 	"publisher/internal"
+	"publisher/internal/util"
 	"publisher/pkg/database"
 
 	"github.com/go-kit/kit/endpoint"
@@ -15,29 +17,63 @@ import (
 type Set struct {
 	AddEndpoint           endpoint.Endpoint
 	GetEndpoint           endpoint.Endpoint
+	SearchEndpoint        endpoint.Endpoint
 	UpdateEndpoint        endpoint.Endpoint
 	RemoveEndpoint        endpoint.Endpoint
+	RestoreEndpoint       endpoint.Endpoint
+	PurgeEndpoint         endpoint.Endpoint
+	HistoryEndpoint       endpoint.Endpoint
+	RevisionEndpoint      endpoint.Endpoint
+	DiffEndpoint          endpoint.Endpoint
+	RollbackEndpoint      endpoint.Endpoint
+	ImportEndpoint        endpoint.Endpoint
+	ExportEndpoint        endpoint.Endpoint
+	PutContentEndpoint    endpoint.Endpoint
+	OpenContentEndpoint   endpoint.Endpoint
+	FindSimilarEndpoint   endpoint.Endpoint
+	WatchEndpoint         endpoint.Endpoint
 	ServiceStatusEndpoint endpoint.Endpoint
+
+	// WatermarkJobEndpoint and TransitionWatermarkEndpoint keep the state of
+	// the watermark jobs of the watermark node
+	WatermarkJobEndpoint        endpoint.Endpoint
+	TransitionWatermarkEndpoint endpoint.Endpoint
 }
 
 func NewEndpointSet(svc database.Service) Set {
 	return Set{
 		AddEndpoint:           MakeAddEndpoint(svc),
 		GetEndpoint:           MakeGetEndpoint(svc),
+		SearchEndpoint:        MakeSearchEndpoint(svc),
 		UpdateEndpoint:        MakeUpdateEndpoint(svc),
 		RemoveEndpoint:        MakeRemoveEndpoint(svc),
+		RestoreEndpoint:       MakeRestoreEndpoint(svc),
+		PurgeEndpoint:         MakePurgeEndpoint(svc),
+		HistoryEndpoint:       MakeHistoryEndpoint(svc),
+		RevisionEndpoint:      MakeRevisionEndpoint(svc),
+		DiffEndpoint:          MakeDiffEndpoint(svc),
+		RollbackEndpoint:      MakeRollbackEndpoint(svc),
+		ImportEndpoint:        MakeImportEndpoint(svc),
+		ExportEndpoint:        MakeExportEndpoint(svc),
+		PutContentEndpoint:    MakePutContentEndpoint(svc),
+		OpenContentEndpoint:   MakeOpenContentEndpoint(svc),
+		FindSimilarEndpoint:   MakeFindSimilarEndpoint(svc),
+		WatchEndpoint:         MakeWatchEndpoint(svc),
 		ServiceStatusEndpoint: MakeServiceStatusEndpoint(svc),
+
+		WatermarkJobEndpoint:        MakeWatermarkJobEndpoint(svc),
+		TransitionWatermarkEndpoint: MakeTransitionWatermarkEndpoint(svc),
 	}
 }
 
 func (s *Set) Add(ctx context.Context, doc *internal.Document) (string, error) {
-	resp, err := s.AddEndpoint(ctx, AddRequest{Document: doc})
+	resp, err := s.AddEndpoint(ctx, AddRequest{Document: doc, IdempotencyKey: internal.IdempotencyKeyFromContext(ctx)})
 	if err != nil {
 		return "", err
 	}
 	addResp := resp.(AddResponse)
 	if addResp.Err != "" {
-		return "", errors.New(addResp.Err)
+		return "", util.ParseError(addResp.Err)
 	}
 	return addResp.TicketID, nil
 }
@@ -45,68 +81,114 @@ func (s *Set) Add(ctx context.Context, doc *internal.Document) (string, error) {
 func MakeAddEndpoint(svc database.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(AddRequest)
+		if req.IdempotencyKey != "" {
+			ctx = internal.WithIdempotencyKey(ctx, req.IdempotencyKey)
+		}
 		ticketID, err := svc.Add(ctx, req.Document)
 		if err != nil {
-			return AddResponse{TicketID: ticketID, Err: err.Error()}, nil
+			return AddResponse{TicketID: ticketID, Code: errorCode(err), Err: err.Error()}, nil
 		}
 		return AddResponse{TicketID: ticketID, Err: ""}, nil
 	}
 }
 
-func (s *Set) Get(ctx context.Context, filters ...internal.Filter) ([]internal.Document, error) {
-	resp, err := s.GetEndpoint(ctx, GetRequest{Filters: filters})
+func (s *Set) Get(ctx context.Context, query internal.Query) (internal.Page, error) {
+	resp, err := s.GetEndpoint(ctx, GetRequest{
+		Filters:        query.Filters,
+		PageSize:       query.PageSize,
+		Cursor:         query.Cursor,
+		IncludeDeleted: query.IncludeDeleted,
+	})
 	if err != nil {
-		return []internal.Document{}, err
+		return internal.Page{Documents: []internal.Document{}}, err
 	}
 	getResp := resp.(GetResponse)
 	if getResp.Err != "" {
-		return []internal.Document{}, errors.New(getResp.Err)
+		return internal.Page{Documents: []internal.Document{}}, util.ParseError(getResp.Err)
 	}
-	return getResp.Documents, nil
+	return internal.Page{Documents: getResp.Documents, NextCursor: getResp.NextCursor, Total: getResp.Total}, nil
 }
 
 func MakeGetEndpoint(svc database.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(GetRequest)
-		docs, err := svc.Get(ctx, req.Filters...)
+		page, err := svc.Get(ctx, internal.Query{
+			Filters:        req.Filters,
+			PageSize:       req.PageSize,
+			Cursor:         req.Cursor,
+			IncludeDeleted: req.IncludeDeleted,
+		})
 		if err != nil {
-			return GetResponse{Documents: docs, Err: err.Error()}, nil
+			return GetResponse{Documents: page.Documents, Err: err.Error()}, nil
 		}
-		return GetResponse{docs, ""}, nil
+		return GetResponse{Documents: page.Documents, NextCursor: page.NextCursor, Total: page.Total, Err: ""}, nil
+	}
+}
+
+func (s *Set) Search(ctx context.Context, query internal.SearchQuery) ([]internal.SearchResult, error) {
+	resp, err := s.SearchEndpoint(ctx, SearchRequest{Text: query.Text, Limit: query.Limit})
+	if err != nil {
+		return []internal.SearchResult{}, err
+	}
+	searchResp := resp.(SearchResponse)
+	if searchResp.Err != "" {
+		return []internal.SearchResult{}, util.ParseError(searchResp.Err)
 	}
+	return searchResp.Results, nil
 }
 
+func MakeSearchEndpoint(svc database.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SearchRequest)
+		results, err := svc.Search(ctx, internal.SearchQuery{Text: req.Text, Limit: req.Limit})
+		if err != nil {
+			return SearchResponse{Results: results, Err: err.Error()}, nil
+		}
+		return SearchResponse{Results: results, Err: ""}, nil
+	}
+}
+
+// Update sends doc.Version as expected version and sets it to the new version
+// of the document on success.
 func (s *Set) Update(ctx context.Context, ticketID string, doc *internal.Document) (int, error) {
-	resp, err := s.UpdateEndpoint(ctx, UpdateRequest{TicketID: ticketID, Document: doc})
+	var version int64
+	if doc != nil {
+		version = doc.Version
+	}
+	resp, err := s.UpdateEndpoint(ctx, UpdateRequest{TicketID: ticketID, Document: doc, Version: version})
 	if err != nil {
 		return http.StatusBadRequest, err
 	}
 	updateResp := resp.(UpdateResponse)
 	if updateResp.Err != "" {
-		return http.StatusConflict, errors.New(updateResp.Err)
+		return updateResp.StatusCode(), util.ParseError(updateResp.Err)
 	}
+	doc.Version = updateResp.Version
 	return http.StatusOK, nil
 }
 
 func MakeUpdateEndpoint(svc database.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(UpdateRequest)
+		if req.Document != nil && req.Version != 0 {
+			req.Document.Version = req.Version
+		}
 		code, err := svc.Update(ctx, req.TicketID, req.Document)
 		if err != nil {
 			return UpdateResponse{Code: code, Err: err.Error()}, nil
 		}
-		return UpdateResponse{Code: code, Err: ""}, nil
+		return UpdateResponse{Code: code, Version: req.Document.Version, Err: ""}, nil
 	}
 }
 
 func (s *Set) Remove(ctx context.Context, ticketID string) (int, error) {
 	resp, err := s.RemoveEndpoint(ctx, RemoveRequest{TicketID: ticketID})
-	removeResp := resp.(RemoveResponse)
 	if err != nil {
-		return removeResp.Code, err
+		return http.StatusInternalServerError, err
 	}
+	removeResp := resp.(RemoveResponse)
 	if removeResp.Err != "" {
-		return removeResp.Code, errors.New(removeResp.Err)
+		return removeResp.Code, util.ParseError(removeResp.Err)
 	}
 	return removeResp.Code, nil
 }
@@ -116,20 +198,365 @@ func MakeRemoveEndpoint(svc database.Service) endpoint.Endpoint {
 		req := request.(RemoveRequest)
 		code, err := svc.Remove(ctx, req.TicketID)
 		if err != nil {
-			return UpdateResponse{Code: code, Err: err.Error()}, nil
+			return RemoveResponse{Code: code, Err: err.Error()}, nil
+		}
+		return RemoveResponse{Code: code, Err: ""}, nil
+	}
+}
+
+func (s *Set) Restore(ctx context.Context, ticketID string) (int, error) {
+	resp, err := s.RestoreEndpoint(ctx, RestoreRequest{TicketID: ticketID})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	restoreResp := resp.(RestoreResponse)
+	if restoreResp.Err != "" {
+		return restoreResp.Code, util.ParseError(restoreResp.Err)
+	}
+	return restoreResp.Code, nil
+}
+
+func MakeRestoreEndpoint(svc database.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RestoreRequest)
+		code, err := svc.Restore(ctx, req.TicketID)
+		if err != nil {
+			return RestoreResponse{Code: code, Err: err.Error()}, nil
+		}
+		return RestoreResponse{Code: code, Err: ""}, nil
+	}
+}
+
+func (s *Set) Purge(ctx context.Context, ticketID string) (int, error) {
+	resp, err := s.PurgeEndpoint(ctx, PurgeRequest{TicketID: ticketID})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	purgeResp := resp.(PurgeResponse)
+	if purgeResp.Err != "" {
+		return purgeResp.Code, util.ParseError(purgeResp.Err)
+	}
+	return purgeResp.Code, nil
+}
+
+func MakePurgeEndpoint(svc database.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(PurgeRequest)
+		code, err := svc.Purge(ctx, req.TicketID)
+		if err != nil {
+			return PurgeResponse{Code: code, Err: err.Error()}, nil
+		}
+		return PurgeResponse{Code: code, Err: ""}, nil
+	}
+}
+
+func (s *Set) History(ctx context.Context, ticketID string) ([]internal.Revision, error) {
+	resp, err := s.HistoryEndpoint(ctx, HistoryRequest{TicketID: ticketID})
+	if err != nil {
+		return []internal.Revision{}, err
+	}
+	historyResp := resp.(HistoryResponse)
+	if historyResp.Err != "" {
+		return []internal.Revision{}, util.ParseError(historyResp.Err)
+	}
+	return historyResp.Revisions, nil
+}
+
+func MakeHistoryEndpoint(svc database.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(HistoryRequest)
+		revisions, err := svc.History(ctx, req.TicketID)
+		if err != nil {
+			return HistoryResponse{Revisions: revisions, Code: errorCode(err), Err: err.Error()}, nil
 		}
-		return UpdateResponse{Code: code, Err: ""}, nil
+		return HistoryResponse{Revisions: revisions, Code: http.StatusOK, Err: ""}, nil
+	}
+}
+
+func (s *Set) Revision(ctx context.Context, ticketID string, number int64) (internal.Revision, error) {
+	resp, err := s.RevisionEndpoint(ctx, RevisionRequest{TicketID: ticketID, Number: number})
+	if err != nil {
+		return internal.Revision{}, err
+	}
+	revisionResp := resp.(RevisionResponse)
+	if revisionResp.Err != "" {
+		return internal.Revision{}, util.ParseError(revisionResp.Err)
+	}
+	return revisionResp.Revision, nil
+}
+
+func MakeRevisionEndpoint(svc database.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RevisionRequest)
+		revision, err := svc.Revision(ctx, req.TicketID, req.Number)
+		if err != nil {
+			return RevisionResponse{Code: errorCode(err), Err: err.Error()}, nil
+		}
+		return RevisionResponse{Revision: revision, Code: http.StatusOK, Err: ""}, nil
+	}
+}
+
+func (s *Set) Diff(ctx context.Context, ticketID string, from, to int64) (internal.Diff, error) {
+	resp, err := s.DiffEndpoint(ctx, DiffRequest{TicketID: ticketID, From: from, To: to})
+	if err != nil {
+		return internal.Diff{}, err
+	}
+	diffResp := resp.(DiffResponse)
+	if diffResp.Err != "" {
+		return internal.Diff{}, util.ParseError(diffResp.Err)
+	}
+	return diffResp.Diff, nil
+}
+
+func MakeDiffEndpoint(svc database.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(DiffRequest)
+		diff, err := svc.Diff(ctx, req.TicketID, req.From, req.To)
+		if err != nil {
+			return DiffResponse{Diff: diff, Code: errorCode(err), Err: err.Error()}, nil
+		}
+		return DiffResponse{Diff: diff, Code: http.StatusOK, Err: ""}, nil
+	}
+}
+
+func (s *Set) Rollback(ctx context.Context, ticketID string, number int64) (internal.Revision, error) {
+	resp, err := s.RollbackEndpoint(ctx, RollbackRequest{TicketID: ticketID, Number: number})
+	if err != nil {
+		return internal.Revision{}, err
+	}
+	rollbackResp := resp.(RollbackResponse)
+	if rollbackResp.Err != "" {
+		return internal.Revision{}, util.ParseError(rollbackResp.Err)
+	}
+	return rollbackResp.Revision, nil
+}
+
+func MakeRollbackEndpoint(svc database.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RollbackRequest)
+		revision, err := svc.Rollback(ctx, req.TicketID, req.Number)
+		if err != nil {
+			return RollbackResponse{Code: errorCode(err), Err: err.Error()}, nil
+		}
+		return RollbackResponse{Revision: revision, Code: http.StatusOK, Err: ""}, nil
+	}
+}
+
+func (s *Set) Import(ctx context.Context, rows internal.DocumentReader, dryRun bool) (internal.ImportResult, error) {
+	resp, err := s.ImportEndpoint(ctx, ImportRequest{Rows: rows, DryRun: dryRun})
+	if err != nil {
+		return internal.ImportResult{DryRun: dryRun}, err
+	}
+	importResp := resp.(ImportResponse)
+	if importResp.Err != "" {
+		return importResp.Result, util.ParseError(importResp.Err)
+	}
+	return importResp.Result, nil
+}
+
+func MakeImportEndpoint(svc database.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ImportRequest)
+		result, err := svc.Import(ctx, req.Rows, req.DryRun)
+		if err != nil {
+			return ImportResponse{Result: result, Code: errorCode(err), Err: err.Error()}, nil
+		}
+		return ImportResponse{Result: result, Code: http.StatusOK, Err: ""}, nil
+	}
+}
+
+func (s *Set) Export(ctx context.Context, query internal.Query) (internal.DocumentReadCloser, error) {
+	resp, err := s.ExportEndpoint(ctx, ExportRequest{Filters: query.Filters, IncludeDeleted: query.IncludeDeleted})
+	if err != nil {
+		return nil, err
+	}
+	exportResp := resp.(ExportResponse)
+	if exportResp.Err != "" {
+		return nil, util.ParseError(exportResp.Err)
+	}
+	return exportResp.Documents, nil
+}
+
+func MakeExportEndpoint(svc database.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ExportRequest)
+		docs, err := svc.Export(ctx, internal.Query{Filters: req.Filters, IncludeDeleted: req.IncludeDeleted})
+		if err != nil {
+			return ExportResponse{Code: errorCode(err), Err: err.Error()}, nil
+		}
+		return ExportResponse{Format: req.Format, Documents: docs, Code: http.StatusOK, Err: ""}, nil
+	}
+}
+
+func (s *Set) PutContent(ctx context.Context, ticketID string, r io.Reader, version int64) (internal.Document, error) {
+	resp, err := s.PutContentEndpoint(ctx, PutContentRequest{TicketID: ticketID, Version: version, Body: r})
+	if err != nil {
+		return internal.Document{}, err
+	}
+	putContentResp := resp.(PutContentResponse)
+	if putContentResp.Err != "" {
+		return putContentResp.Document, util.ParseError(putContentResp.Err)
+	}
+	return putContentResp.Document, nil
+}
+
+func MakePutContentEndpoint(svc database.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(PutContentRequest)
+		doc, err := svc.PutContent(ctx, req.TicketID, req.Body, req.Version)
+		if err != nil {
+			return PutContentResponse{Code: errorCode(err), Err: err.Error()}, nil
+		}
+		return PutContentResponse{Document: doc, Code: http.StatusOK, Err: ""}, nil
+	}
+}
+
+func (s *Set) OpenContent(ctx context.Context, ticketID string, offset, length int64) (internal.Content, error) {
+	resp, err := s.OpenContentEndpoint(ctx, OpenContentRequest{TicketID: ticketID, Offset: offset, Length: length})
+	if err != nil {
+		return internal.Content{}, err
+	}
+	openContentResp := resp.(OpenContentResponse)
+	openContentResp.Content.TicketID = ticketID
+	if openContentResp.Err != "" {
+		return openContentResp.Content, util.ParseError(openContentResp.Err)
+	}
+	return openContentResp.Content, nil
+}
+
+func MakeOpenContentEndpoint(svc database.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(OpenContentRequest)
+		content, err := svc.OpenContent(ctx, req.TicketID, req.Offset, req.Length)
+		if err != nil {
+			return OpenContentResponse{Content: content, Code: errorCode(err), Err: err.Error()}, nil
+		}
+		return OpenContentResponse{Content: content, Code: http.StatusOK, Err: ""}, nil
+	}
+}
+
+// errorCode maps the errors of the service to HTTP status codes.
+func (s *Set) FindSimilar(ctx context.Context, ticketID string, threshold float64) ([]internal.SimilarDocument, error) {
+	resp, err := s.FindSimilarEndpoint(ctx, FindSimilarRequest{TicketID: ticketID, Threshold: threshold})
+	if err != nil {
+		return []internal.SimilarDocument{}, err
+	}
+	similarResp := resp.(FindSimilarResponse)
+	if similarResp.Err != "" {
+		return []internal.SimilarDocument{}, util.ParseError(similarResp.Err)
+	}
+	return similarResp.Documents, nil
+}
+
+func MakeFindSimilarEndpoint(svc database.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(FindSimilarRequest)
+		docs, err := svc.FindSimilar(ctx, req.TicketID, req.Threshold)
+		if err != nil {
+			return FindSimilarResponse{Documents: docs, Code: errorCode(err), Err: err.Error()}, nil
+		}
+		return FindSimilarResponse{Documents: docs, Code: http.StatusOK, Err: ""}, nil
+	}
+}
+
+func (s *Set) WatermarkJob(ctx context.Context, ticketID string) (internal.WatermarkJob, error) {
+	resp, err := s.WatermarkJobEndpoint(ctx, WatermarkJobRequest{TicketID: ticketID})
+	if err != nil {
+		return internal.WatermarkJob{TicketID: ticketID}, err
+	}
+	jobResp := resp.(WatermarkJobResponse)
+	if jobResp.Err != "" {
+		return jobResp.Job, util.ParseError(jobResp.Err)
+	}
+	return jobResp.Job, nil
+}
+
+func MakeWatermarkJobEndpoint(svc database.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(WatermarkJobRequest)
+		job, err := svc.WatermarkJob(ctx, req.TicketID)
+		if err != nil {
+			return WatermarkJobResponse{Job: job, Code: errorCode(err), Err: err.Error()}, nil
+		}
+		return WatermarkJobResponse{Job: job, Code: http.StatusOK, Err: ""}, nil
+	}
+}
+
+func (s *Set) TransitionWatermark(ctx context.Context, ticketID string, change internal.WatermarkTransition) (internal.WatermarkJob, error) {
+	resp, err := s.TransitionWatermarkEndpoint(ctx, TransitionWatermarkRequest{TicketID: ticketID, WatermarkTransition: change})
+	if err != nil {
+		return internal.WatermarkJob{TicketID: ticketID}, err
+	}
+	jobResp := resp.(WatermarkJobResponse)
+	if jobResp.Err != "" {
+		return jobResp.Job, util.ParseError(jobResp.Err)
+	}
+	return jobResp.Job, nil
+}
+
+func MakeTransitionWatermarkEndpoint(svc database.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(TransitionWatermarkRequest)
+		job, err := svc.TransitionWatermark(ctx, req.TicketID, req.WatermarkTransition)
+		if err != nil {
+			return WatermarkJobResponse{Job: job, Code: errorCode(err), Err: err.Error()}, nil
+		}
+		return WatermarkJobResponse{Job: job, Code: http.StatusOK, Err: ""}, nil
+	}
+}
+
+func (s *Set) Watch(ctx context.Context, query internal.WatchQuery) (internal.EventReader, error) {
+	resp, err := s.WatchEndpoint(ctx, WatchRequest{After: query.After, TicketID: query.TicketID, Types: query.Types})
+	if err != nil {
+		return nil, err
+	}
+	watchResp := resp.(WatchResponse)
+	if watchResp.Err != "" {
+		return nil, util.ParseError(watchResp.Err)
+	}
+	return watchResp.Events, nil
+}
+
+func MakeWatchEndpoint(svc database.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(WatchRequest)
+		events, err := svc.Watch(ctx, internal.WatchQuery{After: req.After, TicketID: req.TicketID, Types: req.Types})
+		if errors.Is(err, util.ErrOutOfRange) {
+			// the events to resume after were purged, the consumer resyncs
+			return WatchResponse{Code: http.StatusGone, Err: err.Error()}, nil
+		}
+		if err != nil {
+			return WatchResponse{Code: errorCode(err), Err: err.Error()}, nil
+		}
+		return WatchResponse{Events: events, Code: http.StatusOK, Err: ""}, nil
+	}
+}
+
+func errorCode(err error) int {
+	switch {
+	case errors.Is(err, util.ErrUnknown):
+		return http.StatusNotFound
+	case errors.Is(err, util.ErrInvalidArgument):
+		return http.StatusBadRequest
+	case errors.Is(err, util.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, util.ErrOutOfRange):
+		return http.StatusRequestedRangeNotSatisfiable
+	case errors.Is(err, util.ErrForbidden):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
 	}
 }
 
 func (s *Set) ServiceStatus(ctx context.Context) (int, error) {
 	resp, err := s.ServiceStatusEndpoint(ctx, ServiceStatusRequest{})
-	serviceStatusResp := resp.(ServiceStatusResponse)
 	if err != nil {
-		return serviceStatusResp.Code, err
+		return http.StatusServiceUnavailable, err
 	}
+	serviceStatusResp := resp.(ServiceStatusResponse)
 	if serviceStatusResp.Err != "" {
-		return serviceStatusResp.Code, errors.New(serviceStatusResp.Err)
+		return serviceStatusResp.Code, util.ParseError(serviceStatusResp.Err)
 	}
 	return serviceStatusResp.Code, nil
 }
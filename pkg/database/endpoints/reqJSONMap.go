@@ -1,33 +1,83 @@
 package endpoints
 
-import "publisher/internal"
+import (
+	"io"
+	"net/http"
+	"publisher/internal"
+	"strconv"
+)
 
 type AddRequest struct {
 	Document *internal.Document `json:"document"`
+	// IdempotencyKey makes retries of the request return the first ticket
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
 }
 
 type AddResponse struct {
 	TicketID string `json:"ticketID"`
+	Code     int    `json:"code,omitempty"`
 	Err      string `json:"err"`
 }
 
+func (r AddResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
 type GetRequest struct {
-	Filters []internal.Filter `json:"filters,omitempty"`
+	Filters  []internal.Filter `json:"filters,omitempty"`
+	PageSize int               `json:"pageSize,omitempty"`
+	Cursor   string            `json:"cursor,omitempty"`
+	// IncludeDeleted also lists removed documents, only admins may set it
+	IncludeDeleted bool `json:"includeDeleted,omitempty"`
 }
 
 type GetResponse struct {
-	Documents []internal.Document `json:"documents"`
-	Err       string              `json:"err,omitempty"`
+	Documents  []internal.Document `json:"documents"`
+	NextCursor string              `json:"nextCursor,omitempty"`
+	Total      int64               `json:"total"`
+	Err        string              `json:"err,omitempty"`
+}
+
+type SearchRequest struct {
+	Text  string `json:"text"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+type SearchResponse struct {
+	Results []internal.SearchResult `json:"results"`
+	Err     string                  `json:"err,omitempty"`
 }
 
 type UpdateRequest struct {
 	TicketID string             `json:"ticketID"`
 	Document *internal.Document `json:"document"`
+	// Version is the expected current version of the document, 0 skips the check
+	Version int64 `json:"version,omitempty"`
 }
 
 type UpdateResponse struct {
-	Code int    `json:"code"`
-	Err  string `json:"err,omitempty"`
+	Code    int    `json:"code"`
+	Version int64  `json:"version,omitempty"`
+	Err     string `json:"err,omitempty"`
+}
+
+func (r UpdateResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
+// Headers returns the ETag of the updated document.
+func (r UpdateResponse) Headers() http.Header {
+	h := http.Header{}
+	if r.Version != 0 {
+		h.Set("ETag", strconv.Quote(strconv.FormatInt(r.Version, 10)))
+	}
+	return h
 }
 
 type RemoveRequest struct {
@@ -39,9 +89,286 @@ type RemoveResponse struct {
 	Err  string `json:"err"`
 }
 
+func (r RemoveResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
+type RestoreRequest struct {
+	TicketID string `json:"ticketID"`
+}
+
+type RestoreResponse struct {
+	Code int    `json:"code"`
+	Err  string `json:"err,omitempty"`
+}
+
+func (r RestoreResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
+type PurgeRequest struct {
+	TicketID string `json:"ticketID"`
+}
+
+type PurgeResponse struct {
+	Code int    `json:"code"`
+	Err  string `json:"err,omitempty"`
+}
+
+func (r PurgeResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
+type HistoryRequest struct {
+	TicketID string `json:"ticketID"`
+}
+
+type HistoryResponse struct {
+	Revisions []internal.Revision `json:"revisions"`
+	Code      int                 `json:"code"`
+	Err       string              `json:"err,omitempty"`
+}
+
+func (r HistoryResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
+type RevisionRequest struct {
+	TicketID string `json:"ticketID"`
+	Number   int64  `json:"number"`
+}
+
+type RevisionResponse struct {
+	Revision internal.Revision `json:"revision"`
+	Code     int               `json:"code"`
+	Err      string            `json:"err,omitempty"`
+}
+
+func (r RevisionResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
+type DiffRequest struct {
+	TicketID string `json:"ticketID"`
+	From     int64  `json:"from"`
+	To       int64  `json:"to"`
+}
+
+type DiffResponse struct {
+	Diff internal.Diff `json:"diff"`
+	Code int           `json:"code"`
+	Err  string        `json:"err,omitempty"`
+}
+
+func (r DiffResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
+type RollbackRequest struct {
+	TicketID string `json:"ticketID"`
+	// Number is the revision to restore
+	Number int64 `json:"number"`
+}
+
+type RollbackResponse struct {
+	Revision internal.Revision `json:"revision"`
+	Code     int               `json:"code"`
+	Err      string            `json:"err,omitempty"`
+}
+
+func (r RollbackResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
+// Headers returns the ETag of the rolled back document.
+func (r RollbackResponse) Headers() http.Header {
+	h := http.Header{}
+	if r.Revision.Number != 0 {
+		h.Set("ETag", strconv.Quote(strconv.FormatInt(r.Revision.Number, 10)))
+	}
+	return h
+}
+
+type ImportRequest struct {
+	// Format is internal.FormatNDJSON or internal.FormatCSV
+	Format string `json:"format,omitempty"`
+	DryRun bool   `json:"dryRun,omitempty"`
+	// Rows is streamed from the request body instead of being encoded as JSON
+	Rows internal.DocumentReader `json:"-"`
+}
+
+type ImportResponse struct {
+	Result internal.ImportResult `json:"result"`
+	Code   int                   `json:"code"`
+	Err    string                `json:"err,omitempty"`
+}
+
+func (r ImportResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
+type ExportRequest struct {
+	Filters        []internal.Filter `json:"filters,omitempty"`
+	IncludeDeleted bool              `json:"includeDeleted,omitempty"`
+	Format         string            `json:"format,omitempty"`
+}
+
+type ExportResponse struct {
+	Format string `json:"-"`
+	// Documents is streamed as response body, the receiver must close it
+	Documents internal.DocumentReadCloser `json:"-"`
+	Code      int                         `json:"code"`
+	Err       string                      `json:"err,omitempty"`
+}
+
+func (r ExportResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
+type PutContentRequest struct {
+	TicketID string `json:"ticketID"`
+	// Version is the expected current version of the document, 0 skips the check
+	Version int64 `json:"version"`
+	// Body is streamed from the request body instead of being encoded as JSON
+	Body io.Reader `json:"-"`
+}
+
+type PutContentResponse struct {
+	Document internal.Document `json:"document"`
+	Code     int               `json:"code"`
+	Err      string            `json:"err,omitempty"`
+}
+
+func (r PutContentResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
+// Headers returns the ETag of the updated document.
+func (r PutContentResponse) Headers() http.Header {
+	h := http.Header{}
+	if r.Document.Version != 0 {
+		h.Set("ETag", strconv.Quote(strconv.FormatInt(r.Document.Version, 10)))
+	}
+	return h
+}
+
+type OpenContentRequest struct {
+	TicketID string `json:"ticketID"`
+	Offset   int64  `json:"offset"`
+	// Length of 0 reads to the end of the content
+	Length int64 `json:"length"`
+}
+
+type OpenContentResponse struct {
+	// Content.Body is streamed as response body, the receiver must close it
+	Content internal.Content `json:"content"`
+	Code    int              `json:"code"`
+	Err     string           `json:"err,omitempty"`
+}
+
+func (r OpenContentResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
 type ServiceStatusRequest struct{}
 
 type ServiceStatusResponse struct {
 	Code int    `json:"code"`
 	Err  string `json:"err,omitempty"`
 }
+
+type FindSimilarRequest struct {
+	TicketID string `json:"ticketID"`
+	// Threshold is the minimum similarity from 0 to 1, 0 uses the default
+	Threshold float64 `json:"threshold,omitempty"`
+}
+
+type FindSimilarResponse struct {
+	Documents []internal.SimilarDocument `json:"documents"`
+	Code      int                        `json:"code"`
+	Err       string                     `json:"err,omitempty"`
+}
+
+func (r FindSimilarResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
+type WatermarkJobRequest struct {
+	TicketID string `json:"ticketID"`
+}
+
+type TransitionWatermarkRequest struct {
+	TicketID string `json:"ticketID"`
+	internal.WatermarkTransition
+}
+
+// WatermarkJobResponse is the response of WatermarkJob and TransitionWatermark.
+type WatermarkJobResponse struct {
+	Job  internal.WatermarkJob `json:"job"`
+	Code int                   `json:"code"`
+	Err  string                `json:"err,omitempty"`
+}
+
+func (r WatermarkJobResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
+type WatchRequest struct {
+	// After is the offset of the last event seen, -1 only watches new events
+	After    int64                `json:"after"`
+	TicketID string               `json:"ticketID,omitempty"`
+	Types    []internal.EventType `json:"types,omitempty"`
+}
+
+type WatchResponse struct {
+	// Events is streamed as response body, the receiver must close it
+	Events internal.EventReader `json:"-"`
+	Code   int                  `json:"code"`
+	Err    string               `json:"err,omitempty"`
+}
+
+func (r WatchResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
@@ -0,0 +1,200 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"publisher/internal"
+	orm "publisher/internal/database"
+	"publisher/internal/util"
+	"sync"
+	"time"
+)
+
+const (
+	// eventPollInterval is how often a watch looks for events committed by
+	// other nodes sharing the database
+	eventPollInterval = time.Second
+	// eventBatchSize is the number of events a watch reads at once
+	eventBatchSize = 100
+	// DefaultEventRetention is how long events are kept by default.
+	DefaultEventRetention = 7 * 24 * time.Hour
+)
+
+// eventHub wakes the watches up when events were committed.
+type eventHub struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{ch: make(chan struct{})}
+}
+
+// wait returns a channel which is closed by the next notify.
+func (h *eventHub) wait() <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.ch
+}
+
+func (h *eventHub) notify() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	close(h.ch)
+	h.ch = make(chan struct{})
+}
+
+// transaction runs fn in a transaction of the repository and wakes the
+// watches up once the events recorded by fn are committed.
+func (d *dbService) transaction(ctx context.Context, fn func(tx Repository) error) error {
+	err := d.repo.Transaction(ctx, fn)
+	if err == nil && d.events != nil {
+		d.events.notify()
+	}
+	return err
+}
+
+// record adds the event of a change of the row to the transaction, together
+// with the outbox message notifying the watermark node of it.
+func (d *dbService) record(ctx context.Context, tx Repository, typ internal.EventType, row *orm.Document) error {
+	ev := orm.NewEvent(typ, row, internal.EditorFromContext(ctx))
+	if err := tx.AddEvent(ctx, ev); err != nil {
+		return err
+	}
+	return recordOutbox(ctx, tx, ev)
+}
+
+// updateEvent returns the type of the event of an update with the changes.
+func updateEvent(changes []string) internal.EventType {
+	for _, key := range changes {
+		if key == internal.KeyWatermark {
+			return internal.EventWatermarked
+		}
+	}
+	return internal.EventUpdated
+}
+
+// Watch returns the events after query.After which match the query, the
+// reader waits for new events until it is closed or the context is canceled.
+// It returns util.ErrOutOfRange if events after query.After were purged, the
+// reader does so once they are purged while it reads, so the consumer knows
+// to resync.
+func (d *dbService) Watch(ctx context.Context, query internal.WatchQuery) (internal.EventReader, error) {
+	if query.After < -1 {
+		return nil, util.ErrInvalidArgument
+	}
+	for _, t := range query.Types {
+		if !internal.ValidEventType(t) {
+			return nil, util.ErrInvalidArgument
+		}
+	}
+	if query.After == -1 {
+		offset, err := d.repo.LastEventOffset(ctx)
+		if err != nil {
+			return nil, d.logError("Watch", query.TicketID, err)
+		}
+		query.After = offset
+	}
+	if err := d.retained(ctx, query.After); err != nil {
+		return nil, d.logError("Watch", query.TicketID, err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &eventReader{ctx: ctx, cancel: cancel, svc: d, query: query}, nil
+}
+
+// eventReader reads the events in batches and polls for new ones once it
+// caught up.
+type eventReader struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	svc    *dbService
+	query  internal.WatchQuery
+	events []orm.Event
+}
+
+func (r *eventReader) Next() (*internal.Event, error) {
+	for {
+		for len(r.events) > 0 {
+			row := r.events[0]
+			r.events = r.events[1:]
+			r.query.After = row.ID
+			ev, err := row.ToInternal()
+			if err != nil {
+				return nil, err
+			}
+			if r.query.Match(&ev) {
+				return &ev, nil
+			}
+		}
+		// wait is called before reading, so an event committed meanwhile
+		// wakes the reader up
+		wake := r.svc.events.wait()
+		events, err := r.svc.repo.Events(r.ctx, r.query.After, eventBatchSize)
+		if err != nil {
+			if r.ctx.Err() != nil {
+				return nil, r.ctx.Err()
+			}
+			return nil, err
+		}
+		if len(events) > 0 && events[0].ID > r.query.After+1 {
+			// the offsets are not contiguous, the events between may have
+			// been purged
+			if err := r.svc.retained(r.ctx, r.query.After); err != nil {
+				return nil, err
+			}
+		}
+		if len(events) > 0 {
+			r.events = events
+			continue
+		}
+		timer := time.NewTimer(eventPollInterval)
+		select {
+		case <-wake:
+		case <-timer.C:
+		case <-r.ctx.Done():
+			timer.Stop()
+			return nil, r.ctx.Err()
+		}
+		timer.Stop()
+	}
+}
+
+func (r *eventReader) Close() error {
+	r.cancel()
+	return nil
+}
+
+// retained returns util.ErrOutOfRange if events after the offset were purged,
+// a consumer resuming after it would miss them. Offset 0 starts with the
+// oldest retained event.
+func (d *dbService) retained(ctx context.Context, after int64) error {
+	if after <= 0 {
+		return nil
+	}
+	first, err := d.repo.FirstEventOffset(ctx)
+	if err != nil {
+		return err
+	}
+	if first == 0 {
+		// all events were purged, the offsets are kept so the latest one
+		// tells whether the consumer missed any
+		last, err := d.repo.LastEventOffset(ctx)
+		if err != nil {
+			return err
+		}
+		if after < last {
+			return fmt.Errorf("%w: the events after offset %d were purged, no event is retained up to offset %d", util.ErrOutOfRange, after, last)
+		}
+		return nil
+	}
+	if after < first-1 {
+		return fmt.Errorf("%w: the events after offset %d were purged, the oldest retained event is %d", util.ErrOutOfRange, after, first)
+	}
+	return nil
+}
+
+// PurgeEvents deletes the events older than the retention and returns their
+// number.
+func PurgeEvents(ctx context.Context, repo Repository, retention time.Duration) (int, error) {
+	return repo.PurgeEvents(ctx, time.Now().Add(-retention))
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"publisher/internal"
+	"publisher/internal/util"
+	"publisher/pkg/blob"
+	"testing"
+	"time"
+)
+
+func addDocuments(t *testing.T, svc Service, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := svc.Add(context.Background(), &internal.Document{Title: "Title", Author: "Author", Topic: "Topic"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWatchPurgedEvents(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	svc := NewService(repo, blob.NewMemoryStore())
+	addDocuments(t, svc, 3)
+	// a reader opened before the purge falls behind the retained events
+	behind, err := svc.Watch(ctx, internal.WatchQuery{After: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer behind.Close()
+	if _, err := repo.PurgeEvents(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	addDocuments(t, svc, 1)
+
+	for _, tt := range []struct {
+		after int64
+		err   error
+		first int64
+	}{
+		{0, nil, 4},
+		{-1, nil, 0},
+		{3, nil, 4},
+		{2, util.ErrOutOfRange, 0},
+		{1, util.ErrOutOfRange, 0},
+	} {
+		events, err := svc.Watch(ctx, internal.WatchQuery{After: tt.after})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Watch after %d returned %v, want %v", tt.after, err, tt.err)
+			continue
+		}
+		if err != nil || tt.first == 0 {
+			continue
+		}
+		if ev, err := events.Next(); err != nil || ev.Offset != tt.first {
+			t.Errorf("Watch after %d: first event %+v, %v, want offset %d", tt.after, ev, err, tt.first)
+		}
+		events.Close()
+	}
+
+	if ev, err := behind.Next(); !errors.Is(err, util.ErrOutOfRange) {
+		t.Errorf("Next of a reader behind the purge = %+v, %v, want %v", ev, err, util.ErrOutOfRange)
+	}
+}
+
+func TestWatchAllEventsPurged(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	svc := NewService(repo, blob.NewMemoryStore())
+	addDocuments(t, svc, 3)
+	if _, err := repo.PurgeEvents(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if first, err := repo.FirstEventOffset(ctx); err != nil || first != 0 {
+		t.Fatalf("FirstEventOffset after the purge returned %d, %v, want 0", first, err)
+	}
+
+	for _, tt := range []struct {
+		after int64
+		err   error
+	}{
+		{0, nil},
+		{-1, nil},
+		{3, nil},
+		{2, util.ErrOutOfRange},
+		{1, util.ErrOutOfRange},
+	} {
+		events, err := svc.Watch(ctx, internal.WatchQuery{After: tt.after})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Watch after %d of the purged events returned %v, want %v", tt.after, err, tt.err)
+		}
+		if err == nil {
+			events.Close()
+		}
+	}
+}
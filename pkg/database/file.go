@@ -0,0 +1,46 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"publisher/internal/util"
+)
+
+// NewFileRepository returns a Repository which keeps the documents in memory
+// and writes them to a single JSON file after every committed change. The
+// file is created if it does not exist yet.
+//
+// The backend is meant for development only: every commit rewrites the whole
+// state, including the revisions and events, so the cost of a write grows
+// with the data set. Use postgres for anything else.
+func NewFileRepository(path string) (Repository, error) {
+	state := newMemoryState()
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(b, state); err != nil {
+			return nil, err
+		}
+		state.init()
+	}
+
+	repo := &memoryRepository{
+		state:   state,
+		persist: func(state *memoryState) error { return writeState(path, state) },
+	}
+	return repo, writeState(path, state)
+}
+
+// writeState replaces the file atomically, so a crash never leaves a
+// partially written file behind.
+func writeState(path string, state *memoryState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(path, b)
+}
@@ -0,0 +1,239 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"publisher/internal"
+	orm "publisher/internal/database"
+	"publisher/internal/util"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// filterColumns maps the filter keys accepted by Get to the document columns.
+var filterColumns = map[string]string{
+	internal.KeyTicketID:      "ticket_id",
+	internal.KeyContentDigest: "content_digest",
+	internal.KeyTitle:         "title",
+	internal.KeyAuthor:        "author",
+	internal.KeyTopic:         "topic",
+	internal.KeyWatermark:     "watermark",
+	internal.KeyCreatedAt:     "created_at",
+	internal.KeyUpdatedAt:     "updated_at",
+}
+
+// contentFilter turns a filter on the content into the same filter on the
+// digests of its values, other filters are returned unchanged.
+func contentFilter(f internal.Filter) internal.Filter {
+	if f.Key != internal.KeyContent {
+		return f
+	}
+	f.Key = internal.KeyContentDigest
+	f.Value = contentDigest(f.Value)
+	if f.Values != nil {
+		values := make([]string, len(f.Values))
+		for i, v := range f.Values {
+			values[i] = contentDigest(v)
+		}
+		f.Values = values
+	}
+	return f
+}
+
+// contentDigest returns the digest stored for the content, a document
+// without content has none.
+func contentDigest(content string) string {
+	if content == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// applyFilters adds the conditions of the filters to the query,
+// util.ErrInvalidArgument is returned for invalid filters.
+func applyFilters(query *gorm.DB, filters []internal.Filter) (*gorm.DB, error) {
+	if err := internal.ValidateFilters(filters); err != nil {
+		return nil, err
+	}
+	for _, f := range filters {
+		if f.IsGroup() || f.Operator() != "" {
+			clause, args := filterClause(f)
+			query = query.Where(clause, args...)
+		}
+	}
+	return query, nil
+}
+
+type sortKey struct {
+	key   string
+	order internal.SortOrder
+}
+
+// sortKeys returns the sort order requested by the filters.
+func sortKeys(filters []internal.Filter) []sortKey {
+	var keys []sortKey
+	for _, f := range filters {
+		if order := f.SortOrder(); order != "" {
+			keys = append(keys, sortKey{key: f.Key, order: order})
+		}
+	}
+	return keys
+}
+
+// applyOrder sorts the query by the keys and finally by the row id, which
+// makes the order total as required by the cursors.
+func applyOrder(query *gorm.DB, keys []sortKey) *gorm.DB {
+	for _, k := range keys {
+		query = query.Order(filterColumns[k.key] + " " + strings.ToUpper(string(k.order)))
+	}
+	return query.Order("id")
+}
+
+// applyCursor restricts the query to the rows sorted after the cursor.
+func applyCursor(query *gorm.DB, keys []sortKey, cursor internal.Cursor) (*gorm.DB, error) {
+	if len(cursor.Values) != len(keys) {
+		return nil, util.ErrInvalidArgument
+	}
+	values := make([]interface{}, len(keys))
+	for i, k := range keys {
+		v, err := parseSortValue(k.key, cursor.Values[i])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+
+	// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ... OR (k1 = v1 AND ... AND id > id)
+	var (
+		clauses []string
+		args    []interface{}
+	)
+	for i := 0; i <= len(keys); i++ {
+		var (
+			parts    []string
+			partArgs []interface{}
+		)
+		for j := 0; j < i; j++ {
+			parts = append(parts, filterColumns[keys[j].key]+" = ?")
+			partArgs = append(partArgs, values[j])
+		}
+		if i == len(keys) {
+			parts = append(parts, "id > ?")
+			partArgs = append(partArgs, cursor.ID)
+		} else {
+			op := " > ?"
+			if keys[i].order == internal.Desc {
+				op = " < ?"
+			}
+			parts = append(parts, filterColumns[keys[i].key]+op)
+			partArgs = append(partArgs, values[i])
+		}
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+		args = append(args, partArgs...)
+	}
+	return query.Where(strings.Join(clauses, " OR "), args...), nil
+}
+
+// nextCursor returns the cursor pointing right after the row.
+func nextCursor(row *orm.Document, keys []sortKey, filters []internal.Filter) string {
+	c := internal.Cursor{ID: row.ID, Query: internal.Fingerprint(filters)}
+	for _, k := range keys {
+		c.Values = append(c.Values, sortValue(row, k.key))
+	}
+	return c.Encode()
+}
+
+func sortValue(row *orm.Document, key string) string {
+	switch key {
+	case internal.KeyTicketID:
+		return row.TicketID
+	case internal.KeyContentDigest:
+		return row.ContentDigest
+	case internal.KeyTitle:
+		return row.Title
+	case internal.KeyAuthor:
+		return row.Author
+	case internal.KeyTopic:
+		return row.Topic
+	case internal.KeyWatermark:
+		return row.Watermark
+	case internal.KeyCreatedAt:
+		return row.CreatedAt.Format(time.RFC3339Nano)
+	case internal.KeyUpdatedAt:
+		return row.UpdatedAt.Format(time.RFC3339Nano)
+	}
+	return ""
+}
+
+func parseSortValue(key, value string) (interface{}, error) {
+	if !internal.IsTimeKey(key) {
+		return value, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, util.ErrInvalidArgument
+	}
+	return t, nil
+}
+
+func filterClause(f internal.Filter) (string, []interface{}) {
+	if f.IsGroup() {
+		and, andArgs := joinClauses(f.All, " AND ")
+		or, orArgs := joinClauses(f.Any, " OR ")
+		switch {
+		case and == "":
+			return or, orArgs
+		case or == "":
+			return and, andArgs
+		default:
+			return and + " AND " + or, append(andArgs, orArgs...)
+		}
+	}
+
+	f = contentFilter(f)
+	column := filterColumns[f.Key]
+	switch f.Operator() {
+	case internal.OpNeq:
+		return column + " <> ?", []interface{}{f.Value}
+	case internal.OpContains:
+		return column + " LIKE ?", []interface{}{"%" + likeEscaper.Replace(f.Value) + "%"}
+	case internal.OpPrefix:
+		return column + " LIKE ?", []interface{}{likeEscaper.Replace(f.Value) + "%"}
+	case internal.OpIn:
+		return column + " IN ?", []interface{}{f.Values}
+	case internal.OpRange:
+		switch {
+		case f.From == nil:
+			return column + " <= ?", []interface{}{*f.To}
+		case f.To == nil:
+			return column + " >= ?", []interface{}{*f.From}
+		default:
+			return column + " BETWEEN ? AND ?", []interface{}{*f.From, *f.To}
+		}
+	default:
+		return column + " = ?", []interface{}{f.Value}
+	}
+}
+
+// joinClauses wraps every clause of the filters in parentheses and joins
+// them with the separator.
+func joinClauses(filters []internal.Filter, sep string) (string, []interface{}) {
+	if len(filters) == 0 {
+		return "", nil
+	}
+	var (
+		clauses []string
+		args    []interface{}
+	)
+	for _, f := range filters {
+		clause, a := filterClause(f)
+		clauses = append(clauses, "("+clause+")")
+		args = append(args, a...)
+	}
+	return "(" + strings.Join(clauses, sep) + ")", args
+}
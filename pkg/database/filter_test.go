@@ -0,0 +1,234 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"publisher/internal"
+	"publisher/internal/util"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestGetFilters(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	add := func(title, author, topic string) {
+		t.Helper()
+		if _, err := svc.Add(ctx, &internal.Document{Title: title, Author: author, Topic: topic}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := time.Now()
+	add("Go in Action", "Kennedy", "go")
+	add("The Go Programming Language", "Donovan", "go")
+	middle := time.Now()
+	add("100% Rust", "Klabnik", "rust")
+	add("snake_case", "Kernighan", "style")
+	add("Gopher", "Pike", "go")
+	after := time.Now()
+
+	for _, tt := range []struct {
+		name    string
+		filters []internal.Filter
+		want    []string
+	}{
+		{"eq", []internal.Filter{{Key: internal.KeyTopic, Value: "go"}}, []string{"Go in Action", "Gopher", "The Go Programming Language"}},
+		{"eq op", []internal.Filter{{Key: internal.KeyAuthor, Op: internal.OpEq, Value: "Pike"}}, []string{"Gopher"}},
+		{"eq case", []internal.Filter{{Key: internal.KeyTopic, Value: "Go"}}, nil},
+		{"neq", []internal.Filter{{Key: internal.KeyTopic, Op: internal.OpNeq, Value: "go"}}, []string{"100% Rust", "snake_case"}},
+		{"contains", []internal.Filter{{Key: internal.KeyTitle, Op: internal.OpContains, Value: "Go"}}, []string{"Go in Action", "Gopher", "The Go Programming Language"}},
+		{"prefix", []internal.Filter{{Key: internal.KeyAuthor, Op: internal.OpPrefix, Value: "K"}}, []string{"Go in Action", "100% Rust", "snake_case"}},
+		{"in", []internal.Filter{{Key: internal.KeyAuthor, Op: internal.OpIn, Values: []string{"Pike", "Donovan", "Ritchie"}}}, []string{"Gopher", "The Go Programming Language"}},
+		// the wildcards of LIKE match themselves only
+		{"contains percent", []internal.Filter{{Key: internal.KeyTitle, Op: internal.OpContains, Value: "0%"}}, []string{"100% Rust"}},
+		{"contains underscore", []internal.Filter{{Key: internal.KeyTitle, Op: internal.OpContains, Value: "_"}}, []string{"snake_case"}},
+		{"prefix percent", []internal.Filter{{Key: internal.KeyTitle, Op: internal.OpPrefix, Value: "%"}}, nil},
+		{"prefix underscore", []internal.Filter{{Key: internal.KeyTitle, Op: internal.OpPrefix, Value: "_o"}}, nil},
+		{"range", []internal.Filter{{Key: internal.KeyCreatedAt, Op: internal.OpRange, From: &before, To: &middle}}, []string{"Go in Action", "The Go Programming Language"}},
+		{"range from", []internal.Filter{{Key: internal.KeyCreatedAt, Op: internal.OpRange, From: &middle}}, []string{"100% Rust", "Gopher", "snake_case"}},
+		{"range to", []internal.Filter{{Key: internal.KeyUpdatedAt, Op: internal.OpRange, To: &middle}}, []string{"Go in Action", "The Go Programming Language"}},
+		{"range empty", []internal.Filter{{Key: internal.KeyCreatedAt, Op: internal.OpRange, From: &after}}, nil},
+		// the top level filters are combined with AND
+		{"and", []internal.Filter{
+			{Key: internal.KeyTopic, Value: "go"},
+			{Key: internal.KeyAuthor, Op: internal.OpPrefix, Value: "K"},
+		}, []string{"Go in Action"}},
+		{"any", []internal.Filter{{Any: []internal.Filter{
+			{Key: internal.KeyTopic, Value: "rust"},
+			{Key: internal.KeyAuthor, Value: "Pike"},
+		}}}, []string{"100% Rust", "Gopher"}},
+		{"all", []internal.Filter{{All: []internal.Filter{
+			{Key: internal.KeyTopic, Value: "go"},
+			{Key: internal.KeyTitle, Op: internal.OpPrefix, Value: "Go"},
+		}}}, []string{"Go in Action", "Gopher"}},
+		{"all and any", []internal.Filter{{
+			All: []internal.Filter{{Key: internal.KeyTopic, Op: internal.OpNeq, Value: "style"}},
+			Any: []internal.Filter{{Key: internal.KeyAuthor, Value: "Klabnik"}, {Key: internal.KeyAuthor, Value: "Kernighan"}},
+		}}, []string{"100% Rust"}},
+		{"nested", []internal.Filter{
+			{Key: internal.KeyTopic, Value: "go"},
+			{Any: []internal.Filter{
+				{Key: internal.KeyAuthor, Value: "Pike"},
+				{All: []internal.Filter{
+					{Key: internal.KeyTitle, Op: internal.OpContains, Value: "Go"},
+					{Key: internal.KeyAuthor, Op: internal.OpNeq, Value: "Kennedy"},
+				}},
+			}},
+		}, []string{"Gopher", "The Go Programming Language"}},
+	} {
+		page, err := svc.Get(ctx, internal.Query{Filters: tt.filters})
+		if err != nil {
+			t.Errorf("%s: Get returned %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, doc := range page.Documents {
+			got = append(got, doc.Title)
+		}
+		sort.Strings(got)
+		sort.Strings(tt.want)
+		if !reflect.DeepEqual(got, tt.want) || page.Total != int64(len(tt.want)) {
+			t.Errorf("%s: Get returned %q, total %d, want %q", tt.name, got, page.Total, tt.want)
+		}
+	}
+}
+
+func TestGetContentFilter(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	for _, doc := range []internal.Document{
+		{Title: "a", Content: "the same text"},
+		{Title: "b", Content: "the same text"},
+		{Title: "c", Content: "another text"},
+		{Title: "d"},
+	} {
+		doc := doc
+		if _, err := svc.Add(ctx, &doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		name   string
+		filter internal.Filter
+		want   []string
+	}{
+		{"eq", internal.Filter{Key: internal.KeyContent, Value: "the same text"}, []string{"a", "b"}},
+		{"eq part", internal.Filter{Key: internal.KeyContent, Value: "the same"}, nil},
+		{"neq", internal.Filter{Key: internal.KeyContent, Op: internal.OpNeq, Value: "the same text"}, []string{"c", "d"}},
+		{"in", internal.Filter{Key: internal.KeyContent, Op: internal.OpIn, Values: []string{"another text", "other"}}, []string{"c"}},
+		{"no content", internal.Filter{Key: internal.KeyContent, Op: internal.OpEq}, []string{"d"}},
+		{"nested", internal.Filter{Any: []internal.Filter{
+			{Key: internal.KeyContent, Value: "another text"},
+			{Key: internal.KeyTitle, Value: "a"},
+		}}, []string{"a", "c"}},
+	} {
+		page, err := svc.Get(ctx, internal.Query{Filters: []internal.Filter{tt.filter}})
+		if err != nil {
+			t.Errorf("%s: Get returned %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, doc := range page.Documents {
+			got = append(got, doc.Title)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Get returned %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetSort(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	for _, doc := range []internal.Document{
+		{Title: "b", Author: "x"},
+		{Title: "a", Author: "y"},
+		{Title: "c", Author: "x"},
+	} {
+		doc := doc
+		if _, err := svc.Add(ctx, &doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		filters []internal.Filter
+		want    []string
+	}{
+		{nil, []string{"b", "a", "c"}},
+		{[]internal.Filter{{Key: internal.KeyTitle}}, []string{"a", "b", "c"}},
+		{[]internal.Filter{{Key: internal.KeyTitle, Sort: internal.Desc}}, []string{"c", "b", "a"}},
+		{[]internal.Filter{{Key: internal.KeyAuthor}, {Key: internal.KeyTitle, Sort: internal.Desc}}, []string{"c", "b", "a"}},
+		{[]internal.Filter{{Key: internal.KeyAuthor, Value: "x", Sort: internal.Desc}, {Key: internal.KeyTitle}}, []string{"b", "c"}},
+		{[]internal.Filter{{Key: internal.KeyCreatedAt, Sort: internal.Desc}}, []string{"c", "a", "b"}},
+	} {
+		page, err := svc.Get(ctx, internal.Query{Filters: tt.filters})
+		if err != nil {
+			t.Errorf("%+v: Get returned %v", tt.filters, err)
+			continue
+		}
+		var got []string
+		for _, doc := range page.Documents {
+			got = append(got, doc.Title)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: Get returned %q, want %q", tt.filters, got, tt.want)
+		}
+	}
+}
+
+func TestGetInvalidFilters(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	now := time.Now()
+	for _, tt := range []struct {
+		name   string
+		filter internal.Filter
+	}{
+		{"unknown key", internal.Filter{Key: "publisher", Value: "x"}},
+		{"contains content", internal.Filter{Key: internal.KeyContent, Op: internal.OpContains, Value: "x"}},
+		{"prefix content", internal.Filter{Key: internal.KeyContent, Op: internal.OpPrefix, Value: "x"}},
+		{"sort content", internal.Filter{Key: internal.KeyContent}},
+		{"unknown operator", internal.Filter{Key: internal.KeyTitle, Op: "like", Value: "x"}},
+		{"unknown sort", internal.Filter{Key: internal.KeyTitle, Sort: "up"}},
+		{"in without values", internal.Filter{Key: internal.KeyTitle, Op: internal.OpIn}},
+		{"range on text", internal.Filter{Key: internal.KeyTitle, Op: internal.OpRange, From: &now}},
+		{"range without bounds", internal.Filter{Key: internal.KeyCreatedAt, Op: internal.OpRange}},
+		{"eq on time", internal.Filter{Key: internal.KeyCreatedAt, Value: "2024-01-01"}},
+		{"unknown nested key", internal.Filter{Any: []internal.Filter{{Key: internal.KeyTitle, Value: "a"}, {Key: "publisher", Value: "x"}}}},
+		{"nested sort", internal.Filter{All: []internal.Filter{{Key: internal.KeyTitle, Value: "a", Sort: internal.Asc}}}},
+		{"nested bare key", internal.Filter{Any: []internal.Filter{{Key: internal.KeyTitle}}}},
+		{"sorted group", internal.Filter{Any: []internal.Filter{{Key: internal.KeyTitle, Value: "a"}}, Sort: internal.Asc}},
+		{"key with group", internal.Filter{Key: internal.KeyTitle, Value: "a", Any: []internal.Filter{{Key: internal.KeyTitle, Value: "b"}}}},
+	} {
+		if _, err := svc.Get(ctx, internal.Query{Filters: []internal.Filter{tt.filter}}); !errors.Is(err, util.ErrInvalidArgument) {
+			t.Errorf("%s: Get returned %v, want %v", tt.name, err, util.ErrInvalidArgument)
+		}
+	}
+}
+
+func TestFilterClause(t *testing.T) {
+	for _, tt := range []struct {
+		filter internal.Filter
+		clause string
+		args   []interface{}
+	}{
+		{internal.Filter{Key: internal.KeyTitle, Op: internal.OpContains, Value: `50%_off\`}, "title LIKE ?", []interface{}{`%50\%\_off\\%`}},
+		{internal.Filter{Key: internal.KeyAuthor, Op: internal.OpPrefix, Value: "a_b"}, "author LIKE ?", []interface{}{`a\_b%`}},
+		{internal.Filter{Key: internal.KeyTopic, Value: "50%"}, "topic = ?", []interface{}{"50%"}},
+		{internal.Filter{Key: internal.KeyContent, Value: "abc"}, "content_digest = ?", []interface{}{"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"}},
+		{internal.Filter{
+			All: []internal.Filter{{Key: internal.KeyTopic, Value: "go"}},
+			Any: []internal.Filter{{Key: internal.KeyAuthor, Value: "a"}, {Key: internal.KeyAuthor, Op: internal.OpNeq, Value: "b"}},
+		}, "((topic = ?)) AND ((author = ?) OR (author <> ?))", []interface{}{"go", "a", "b"}},
+	} {
+		clause, args := filterClause(tt.filter)
+		if clause != tt.clause || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("filterClause(%+v) returned %q %q, want %q %q", tt.filter, clause, args, tt.clause, tt.args)
+		}
+	}
+}
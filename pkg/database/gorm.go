@@ -0,0 +1,432 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"publisher/internal"
+	orm "publisher/internal/database"
+	"publisher/internal/util"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormRepository struct {
+	db     *gorm.DB
+	search Searcher
+	// tx is set on the repository of a transaction, only there the rows read
+	// for a change are locked
+	tx bool
+}
+
+// NewGormRepository returns a Repository storing the documents in postgres.
+func NewGormRepository(db *gorm.DB) Repository {
+	return &gormRepository{db: db, search: NewPostgresSearcher(db)}
+}
+
+func (g *gormRepository) Transaction(ctx context.Context, fn func(tx Repository) error) error {
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&gormRepository{db: tx, search: NewPostgresSearcher(tx), tx: true})
+	})
+}
+
+func (g *gormRepository) Create(ctx context.Context, row *orm.Document) error {
+	return g.db.WithContext(ctx).Create(row).Error
+}
+
+func (g *gormRepository) Find(ctx context.Context, ticketID string, includeDeleted bool) (*orm.Document, error) {
+	db := g.db.WithContext(ctx)
+	if includeDeleted {
+		db = db.Unscoped()
+	}
+	if g.tx {
+		db = db.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	var row orm.Document
+	err := db.Where("ticket_id = ?", ticketID).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, util.ErrUnknown
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+func (g *gormRepository) Save(ctx context.Context, row *orm.Document) error {
+	return g.db.WithContext(ctx).Unscoped().Save(row).Error
+}
+
+func (g *gormRepository) List(ctx context.Context, query internal.Query) (internal.Page, error) {
+	page := internal.Page{Documents: []internal.Document{}}
+	db := g.db.WithContext(ctx)
+	if query.IncludeDeleted {
+		db = db.Unscoped()
+	}
+	base, err := applyFilters(db.Model(&orm.Document{}), query.Filters)
+	if err != nil {
+		return page, err
+	}
+	if err := base.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return page, err
+	}
+
+	keys := sortKeys(query.Filters)
+	rows := base.Session(&gorm.Session{})
+	if query.Cursor != "" {
+		cursor, err := internal.DecodeCursor(query.Cursor, query.Filters)
+		if err != nil {
+			return page, err
+		}
+		if rows, err = applyCursor(rows, keys, cursor); err != nil {
+			return page, err
+		}
+	}
+
+	// fetch one more row to find out whether another page follows
+	limit := query.Limit()
+	var found []orm.Document
+	if err := applyOrder(rows, keys).Limit(limit + 1).Find(&found).Error; err != nil {
+		return page, err
+	}
+	if len(found) > limit {
+		found = found[:limit]
+		page.NextCursor = nextCursor(&found[limit-1], keys, query.Filters)
+	}
+	for i := range found {
+		page.Documents = append(page.Documents, found[i].ToInternal())
+	}
+	return page, nil
+}
+
+func (g *gormRepository) Search(ctx context.Context, query internal.SearchQuery) ([]internal.SearchResult, error) {
+	return g.search.Search(ctx, query)
+}
+
+func (g *gormRepository) Remove(ctx context.Context, ticketID string) error {
+	res := g.db.WithContext(ctx).Where("ticket_id = ?", ticketID).Delete(&orm.Document{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return util.ErrUnknown
+	}
+	return nil
+}
+
+func (g *gormRepository) Restore(ctx context.Context, ticketID string) error {
+	res := g.db.WithContext(ctx).Unscoped().Model(&orm.Document{}).
+		Where("ticket_id = ? AND deleted_at IS NOT NULL", ticketID).
+		Update("deleted_at", nil)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		_, err := g.Find(ctx, ticketID, false)
+		return err
+	}
+	return nil
+}
+
+func (g *gormRepository) Purge(ctx context.Context, ticketID string) error {
+	res := g.db.WithContext(ctx).Unscoped().Where("ticket_id = ?", ticketID).Delete(&orm.Document{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return util.ErrUnknown
+	}
+	return nil
+}
+
+func (g *gormRepository) AddRevision(ctx context.Context, rev *orm.Revision) error {
+	return g.db.WithContext(ctx).Create(rev).Error
+}
+
+func (g *gormRepository) Revisions(ctx context.Context, ticketID string) ([]orm.Revision, error) {
+	var revs []orm.Revision
+	err := g.db.WithContext(ctx).Where("ticket_id = ?", ticketID).Order("number").Find(&revs).Error
+	return revs, err
+}
+
+func (g *gormRepository) FindRevision(ctx context.Context, ticketID string, number int64) (*orm.Revision, error) {
+	var rev orm.Revision
+	err := g.db.WithContext(ctx).Where("ticket_id = ? AND number = ?", ticketID, number).First(&rev).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, util.ErrUnknown
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+func (g *gormRepository) IndexContent(ctx context.Context, ticketID, text string) error {
+	res := g.db.WithContext(ctx).Exec(`UPDATE documents SET content_vector = to_tsvector('simple', ?) WHERE ticket_id = ?`, text, ticketID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return util.ErrUnknown
+	}
+	return nil
+}
+
+func (g *gormRepository) ContentDigests(ctx context.Context) (map[string]bool, error) {
+	rows, err := g.db.WithContext(ctx).Raw(`SELECT content_digest FROM documents WHERE content_digest <> ''
+		UNION SELECT content_digest FROM document_revisions WHERE content_digest <> ''`).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	digests := map[string]bool{}
+	for rows.Next() {
+		var digest string
+		if err := rows.Scan(&digest); err != nil {
+			return nil, err
+		}
+		digests[digest] = true
+	}
+	return digests, rows.Err()
+}
+
+func (g *gormRepository) SaveFingerprint(ctx context.Context, fp *orm.Fingerprint) error {
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(fp).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("ticket_id = ?", fp.TicketID).Delete(&orm.FingerprintBand{}).Error; err != nil {
+			return err
+		}
+		if len(fp.Bands) == 0 {
+			return nil
+		}
+		bands := make([]orm.FingerprintBand, len(fp.Bands))
+		for i, hash := range fp.Bands {
+			bands[i] = orm.FingerprintBand{TicketID: fp.TicketID, Band: int16(i), Hash: hash}
+		}
+		return tx.Create(&bands).Error
+	})
+}
+
+func (g *gormRepository) FindFingerprint(ctx context.Context, ticketID string) (*orm.Fingerprint, error) {
+	var fp orm.Fingerprint
+	err := g.db.WithContext(ctx).Where("ticket_id = ?", ticketID).Take(&fp).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, util.ErrUnknown
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := g.loadBands(ctx, []*orm.Fingerprint{&fp}); err != nil {
+		return nil, err
+	}
+	return &fp, nil
+}
+
+func (g *gormRepository) SimilarFingerprints(ctx context.Context, fp *orm.Fingerprint) ([]orm.Fingerprint, error) {
+	var fps []orm.Fingerprint
+	// the band hashes are passed as array, one (band, hash) pair per index
+	bands := make([]string, len(fp.Bands))
+	for i, hash := range fp.Bands {
+		bands[i] = fmt.Sprintf("(%d,%d)", i, hash)
+	}
+	query := `SELECT f.* FROM document_fingerprints f JOIN documents d ON d.ticket_id = f.ticket_id
+		WHERE d.deleted_at IS NULL AND f.ticket_id <> ? AND (f.content_digest = ?`
+	args := []interface{}{fp.TicketID, fp.ContentDigest}
+	if len(bands) > 0 {
+		query += ` OR f.ticket_id IN (SELECT ticket_id FROM document_fingerprint_bands WHERE (band, hash) IN (` + strings.Join(bands, ",") + `))`
+	}
+	err := g.db.WithContext(ctx).Raw(query+`)`, args...).Scan(&fps).Error
+	if err != nil {
+		return nil, err
+	}
+	ptrs := make([]*orm.Fingerprint, len(fps))
+	for i := range fps {
+		ptrs[i] = &fps[i]
+	}
+	return fps, g.loadBands(ctx, ptrs)
+}
+
+// loadBands sets the bands of the fingerprints.
+func (g *gormRepository) loadBands(ctx context.Context, fps []*orm.Fingerprint) error {
+	if len(fps) == 0 {
+		return nil
+	}
+	byTicket := make(map[string]*orm.Fingerprint, len(fps))
+	ticketIDs := make([]string, len(fps))
+	for i, fp := range fps {
+		byTicket[fp.TicketID] = fp
+		ticketIDs[i] = fp.TicketID
+	}
+	var bands []orm.FingerprintBand
+	if err := g.db.WithContext(ctx).Where("ticket_id IN ?", ticketIDs).Order("band").Find(&bands).Error; err != nil {
+		return err
+	}
+	for _, b := range bands {
+		fp := byTicket[b.TicketID]
+		fp.Bands = append(fp.Bands, b.Hash)
+	}
+	return nil
+}
+
+func (g *gormRepository) MissingFingerprints(ctx context.Context, limit int) ([]orm.Document, error) {
+	var rows []orm.Document
+	err := g.db.WithContext(ctx).
+		Where("content_digest <> '' AND NOT EXISTS (SELECT 1 FROM document_fingerprints f WHERE f.ticket_id = documents.ticket_id)").
+		Order("id").Limit(limit).Find(&rows).Error
+	return rows, err
+}
+
+// eventLock serializes the transactions adding events, so a transaction
+// holding a lower offset always commits before one holding a higher offset
+// and consumers never skip an event committed late.
+const eventLock = 0x65766e74
+
+func (g *gormRepository) AddEvent(ctx context.Context, ev *orm.Event) error {
+	db := g.db.WithContext(ctx)
+	if err := db.Exec(`SELECT pg_advisory_xact_lock(?)`, eventLock).Error; err != nil {
+		return err
+	}
+	return db.Create(ev).Error
+}
+
+func (g *gormRepository) Events(ctx context.Context, after int64, limit int) ([]orm.Event, error) {
+	var events []orm.Event
+	err := g.db.WithContext(ctx).Where("id > ?", after).Order("id").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+func (g *gormRepository) LastEventOffset(ctx context.Context) (int64, error) {
+	var offset int64
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// waiting for the transactions adding events lets the sequence only
+		// run ahead of the events by the offsets of rolled back ones
+		if err := tx.Exec(`SELECT pg_advisory_xact_lock(?)`, eventLock).Error; err != nil {
+			return err
+		}
+		// purged events keep their offsets
+		return tx.Raw(`SELECT coalesce((SELECT max(id) FROM document_events),
+			(SELECT CASE WHEN is_called THEN last_value ELSE 0 END FROM document_events_id_seq))`).Scan(&offset).Error
+	})
+	return offset, err
+}
+
+func (g *gormRepository) FirstEventOffset(ctx context.Context) (int64, error) {
+	var offset int64
+	err := g.db.WithContext(ctx).Raw(`SELECT coalesce(min(id), 0) FROM document_events`).Scan(&offset).Error
+	return offset, err
+}
+
+func (g *gormRepository) PurgeEvents(ctx context.Context, before time.Time) (int, error) {
+	res := g.db.WithContext(ctx).Where("created_at < ?", before).Delete(&orm.Event{})
+	return int(res.RowsAffected), res.Error
+}
+
+func (g *gormRepository) FindWatermarkJob(ctx context.Context, ticketID string) (*orm.WatermarkJob, error) {
+	var job orm.WatermarkJob
+	err := g.db.WithContext(ctx).Where("ticket_id = ?", ticketID).Take(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, util.ErrUnknown
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (g *gormRepository) SaveWatermarkJob(ctx context.Context, job *orm.WatermarkJob) error {
+	return g.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(job).Error
+}
+
+func (g *gormRepository) AddOutboxMessage(ctx context.Context, msg *orm.OutboxMessage) error {
+	return g.db.WithContext(ctx).Create(msg).Error
+}
+
+func (g *gormRepository) ClaimOutboxMessages(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]orm.OutboxMessage, error) {
+	// SKIP LOCKED lets relays of several nodes claim distinct messages
+	var msgs []orm.OutboxMessage
+	err := g.db.WithContext(ctx).Raw(`UPDATE outbox_messages SET attempts = attempts + 1, next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM outbox_messages WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, now.Add(lease), orm.OutboxPending, now, limit).Scan(&msgs).Error
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].ID < msgs[j].ID })
+	return msgs, nil
+}
+
+func (g *gormRepository) UpdateOutboxMessage(ctx context.Context, msg *orm.OutboxMessage) error {
+	// a relay whose lease ended must not overwrite the state recorded by the
+	// relay which claimed the message again
+	res := g.db.WithContext(ctx).Model(msg).Where("attempts = ? AND status = ?", msg.Attempts, orm.OutboxPending).
+		Select("status", "next_attempt_at", "delivered_at", "last_error").Updates(msg)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return util.ErrConflict
+	}
+	return nil
+}
+
+func (g *gormRepository) PurgeOutboxMessages(ctx context.Context, before time.Time) (int, error) {
+	res := g.db.WithContext(ctx).Where("status <> ? AND coalesce(delivered_at, next_attempt_at) < ?", orm.OutboxPending, before).
+		Delete(&orm.OutboxMessage{})
+	return int(res.RowsAffected), res.Error
+}
+
+func (g *gormRepository) FindIdempotencyKey(ctx context.Context, scope, key string) (*orm.IdempotencyKey, error) {
+	var row orm.IdempotencyKey
+	err := g.db.WithContext(ctx).Where("scope = ? AND key = ? AND expires_at > ?", scope, key, time.Now()).Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, util.ErrUnknown
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+func (g *gormRepository) CreateIdempotencyKey(ctx context.Context, row *orm.IdempotencyKey) (bool, error) {
+	// a concurrent insert of the same key blocks until the other transaction
+	// ends, the key is then only replaced if it expired
+	res := g.db.WithContext(ctx).Exec(`INSERT INTO idempotency_keys (scope, key, request_hash, ticket_id, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (scope, key) DO UPDATE SET request_hash = excluded.request_hash, ticket_id = excluded.ticket_id,
+			created_at = excluded.created_at, expires_at = excluded.expires_at
+		WHERE idempotency_keys.expires_at <= excluded.created_at`,
+		row.Scope, row.Key, row.RequestHash, row.TicketID, row.CreatedAt, row.ExpiresAt)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (g *gormRepository) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	res := g.db.WithContext(ctx).Where("expires_at <= ?", before).Delete(&orm.IdempotencyKey{})
+	return int(res.RowsAffected), res.Error
+}
+
+func (g *gormRepository) Ping(ctx context.Context) error {
+	sqlDB, err := g.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func (g *gormRepository) Close() error {
+	sqlDB, err := g.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
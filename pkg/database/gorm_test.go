@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestGormFindLocking(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var queries []string
+	db.Callback().Query().After("gorm:query").Register("test:record", func(tx *gorm.DB) {
+		queries = append(queries, tx.Statement.SQL.String())
+	})
+
+	// a dry run cannot begin a transaction, the repository of one is built
+	// as Transaction does
+	ctx := context.Background()
+	NewGormRepository(db).Find(ctx, "a", false)
+	(&gormRepository{db: db, search: NewPostgresSearcher(db), tx: true}).Find(ctx, "a", false)
+	if len(queries) != 2 {
+		t.Fatalf("Find ran %q, want 2 queries", queries)
+	}
+	if strings.Contains(queries[0], "FOR UPDATE") {
+		t.Errorf("Find outside a transaction ran %q, want no lock", queries[0])
+	}
+	if !strings.Contains(queries[1], "FOR UPDATE") {
+		t.Errorf("Find in a transaction ran %q, want FOR UPDATE", queries[1])
+	}
+}
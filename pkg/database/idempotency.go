@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"publisher/internal"
+	orm "publisher/internal/database"
+	"publisher/internal/util"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultIdempotencyTTL is how long idempotency keys are kept by default.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// scopeAdd is the scope of the idempotency keys of Add.
+const scopeAdd = "Add"
+
+// errKeyClaimed aborts a creation whose idempotency key was stored by a
+// concurrent request meanwhile.
+var errKeyClaimed = errors.New("idempotency key claimed concurrently")
+
+// addOnce creates the document unless the key was used before. A repeated
+// request returns the ticket of the first one, a different document sent
+// with a used key is rejected with util.ErrConflict. The key is stored in the
+// transaction creating the document, so either both or none are committed.
+func (d *dbService) addOnce(ctx context.Context, key string, doc *internal.Document) (string, error) {
+	if err := internal.ValidateIdempotencyKey(key); err != nil {
+		return "", err
+	}
+	hash := internal.DocumentHash(doc)
+	if ticketID, err := d.replay(ctx, key, hash); !errors.Is(err, util.ErrUnknown) {
+		return ticketID, err
+	}
+	now := time.Now()
+	row := &orm.IdempotencyKey{
+		Scope:       scopeAdd,
+		Key:         key,
+		RequestHash: hash,
+		TicketID:    uuid.New().String(),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(d.idempotencyTTL),
+	}
+	ticketID, err := d.create(ctx, row.TicketID, doc, true, func(tx Repository) error {
+		created, err := tx.CreateIdempotencyKey(ctx, row)
+		if err == nil && !created {
+			err = errKeyClaimed
+		}
+		return err
+	})
+	if err == errKeyClaimed {
+		return d.replay(ctx, key, hash)
+	}
+	return ticketID, err
+}
+
+// replay returns the ticket created by the request with the key, or
+// util.ErrUnknown if the key is unused.
+func (d *dbService) replay(ctx context.Context, key, hash string) (string, error) {
+	row, err := d.repo.FindIdempotencyKey(ctx, scopeAdd, key)
+	if err != nil {
+		return "", err
+	}
+	if row.RequestHash != hash {
+		return "", fmt.Errorf("%w: the idempotency key was used for another document", util.ErrConflict)
+	}
+	return row.TicketID, nil
+}
+
+// PurgeIdempotencyKeys deletes the expired idempotency keys and returns their
+// number.
+func PurgeIdempotencyKeys(ctx context.Context, repo Repository) (int, error) {
+	return repo.PurgeIdempotencyKeys(ctx, time.Now())
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"publisher/internal"
+	"publisher/internal/util"
+	"publisher/pkg/blob"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// expireIdempotencyKeys moves the expiry of the stored keys into the past.
+func expireIdempotencyKeys(repo *memoryRepository) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for id, row := range repo.state.IdempotencyKeys {
+		row.ExpiresAt = time.Now().Add(-time.Second)
+		repo.state.IdempotencyKeys[id] = row
+	}
+}
+
+func TestAddIdempotency(t *testing.T) {
+	repo := NewMemoryRepository().(*memoryRepository)
+	svc := NewService(repo, blob.NewMemoryStore(), WithDuplicatePolicy(DuplicatePolicy{}))
+	ctx := internal.WithIdempotencyKey(context.Background(), "key-1")
+	doc := func() *internal.Document {
+		return &internal.Document{Title: "Title", Author: "Author", Topic: "Topic", Content: "content"}
+	}
+
+	first, err := svc.Add(ctx, doc())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a repeated request within the TTL returns the first ticket
+	if again, err := svc.Add(ctx, doc()); err != nil || again != first {
+		t.Errorf("repeated Add returned %q, %v, want %q", again, err, first)
+	}
+	if n := countDocuments(t, svc); n != 1 {
+		t.Errorf("%d documents after the repeated Add, want 1", n)
+	}
+
+	// the key may not be reused for another document
+	other := doc()
+	other.Content = "other content"
+	if _, err := svc.Add(ctx, other); !errors.Is(err, util.ErrConflict) {
+		t.Errorf("Add of another document returned %v, want %v", err, util.ErrConflict)
+	}
+	other = doc()
+	other.Title = "Other"
+	if _, err := svc.Add(ctx, other); !errors.Is(err, util.ErrConflict) {
+		t.Errorf("Add of another title returned %v, want %v", err, util.ErrConflict)
+	}
+	// the key is scoped to its value, another key creates another ticket
+	second, err := svc.Add(internal.WithIdempotencyKey(context.Background(), "key-2"), doc())
+	if err != nil || second == first {
+		t.Errorf("Add with another key returned %q, %v", second, err)
+	}
+
+	// once the key expired the request creates a new ticket, even for
+	// another document
+	expireIdempotencyKeys(repo)
+	third, err := svc.Add(ctx, other)
+	if err != nil || third == first || third == second {
+		t.Errorf("Add after the TTL returned %q, %v, want a new ticket", third, err)
+	}
+	if again, err := svc.Add(ctx, other); err != nil || again != third {
+		t.Errorf("repeated Add after the TTL returned %q, %v, want %q", again, err, third)
+	}
+	if n := countDocuments(t, svc); n != 3 {
+		t.Errorf("%d documents, want 3", n)
+	}
+}
+
+func TestAddIdempotencyExpiry(t *testing.T) {
+	ttl := 50 * time.Millisecond
+	svc := NewService(NewMemoryRepository(), blob.NewMemoryStore(), WithDuplicatePolicy(DuplicatePolicy{}), WithIdempotencyTTL(ttl))
+	ctx := internal.WithIdempotencyKey(context.Background(), "key")
+	doc := internal.Document{Title: "Title", Author: "Author", Topic: "Topic"}
+
+	first, err := svc.Add(ctx, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * ttl)
+	if again, err := svc.Add(ctx, &doc); err != nil || again == first {
+		t.Errorf("Add after the TTL returned %q, %v, want a new ticket", again, err)
+	}
+}
+
+func TestAddIdempotencyConcurrent(t *testing.T) {
+	svc := NewService(NewMemoryRepository(), blob.NewMemoryStore(), WithDuplicatePolicy(DuplicatePolicy{}))
+	ctx := internal.WithIdempotencyKey(context.Background(), "key")
+
+	const requests = 8
+	var (
+		wg      sync.WaitGroup
+		tickets = make([]string, requests)
+	)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if tickets[i], err = svc.Add(ctx, &internal.Document{Title: "Title", Author: "Author", Topic: "Topic"}); err != nil {
+				t.Errorf("Add returned %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, ticketID := range tickets[1:] {
+		if ticketID != tickets[0] {
+			t.Errorf("concurrent Add returned %q and %q, want the same ticket", tickets[0], ticketID)
+		}
+	}
+	if n := countDocuments(t, svc); n != 1 {
+		t.Errorf("%d documents, want 1", n)
+	}
+}
+
+func TestAddInvalidIdempotencyKey(t *testing.T) {
+	svc := NewService(NewMemoryRepository(), blob.NewMemoryStore(), WithDuplicatePolicy(DuplicatePolicy{}))
+	for _, key := range []string{strings.Repeat("k", internal.MaxIdempotencyKeyLength+1), "tab\tkey", "schlüssel"} {
+		ctx := internal.WithIdempotencyKey(context.Background(), key)
+		if _, err := svc.Add(ctx, &internal.Document{Title: "Title"}); !errors.Is(err, util.ErrInvalidArgument) {
+			t.Errorf("Add with key %q returned %v, want %v", key, err, util.ErrInvalidArgument)
+		}
+	}
+}
+
+func TestPurgeIdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository().(*memoryRepository)
+	svc := NewService(repo, blob.NewMemoryStore(), WithDuplicatePolicy(DuplicatePolicy{}))
+	for _, key := range []string{"a", "b"} {
+		if _, err := svc.Add(internal.WithIdempotencyKey(ctx, key), &internal.Document{Title: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := PurgeIdempotencyKeys(ctx, repo); err != nil || n != 0 {
+		t.Errorf("PurgeIdempotencyKeys returned %d, %v, want 0", n, err)
+	}
+	expireIdempotencyKeys(repo)
+	if n, err := PurgeIdempotencyKeys(ctx, repo); err != nil || n != 2 {
+		t.Errorf("PurgeIdempotencyKeys after the TTL returned %d, %v, want 2", n, err)
+	}
+}
@@ -0,0 +1,1025 @@
+package database
+
+import (
+	"context"
+	"publisher/internal"
+	orm "publisher/internal/database"
+	"publisher/internal/util"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// memoryState holds every table of the memory and the file repository.
+type memoryState struct {
+	NextID    uint                     `json:"nextID"`
+	Documents map[string]*orm.Document `json:"documents"`
+	// Revisions are keyed by ticket and ordered by number
+	Revisions map[string][]orm.Revision `json:"revisions"`
+	// ContentIndex holds the searchable text of the content by ticket
+	ContentIndex map[string]string `json:"contentIndex"`
+	// Fingerprints are keyed by ticket
+	Fingerprints map[string]orm.Fingerprint `json:"fingerprints"`
+	// Events are ordered by ID, NextEventID is the ID of the next event
+	Events      []orm.Event `json:"events"`
+	NextEventID int64       `json:"nextEventID"`
+	// IdempotencyKeys are keyed by scope and key, see idempotencyID
+	IdempotencyKeys map[string]orm.IdempotencyKey `json:"idempotencyKeys"`
+	// WatermarkJobs are keyed by ticket
+	WatermarkJobs map[string]orm.WatermarkJob `json:"watermarkJobs"`
+	// Outbox holds the messages by ID, NextOutboxID is the ID of the next one
+	Outbox       map[int64]orm.OutboxMessage `json:"outbox"`
+	NextOutboxID int64                       `json:"nextOutboxID"`
+}
+
+func newMemoryState() *memoryState {
+	s := &memoryState{}
+	s.init()
+	return s
+}
+
+// init allocates the tables missing from a decoded state.
+func (s *memoryState) init() {
+	if s.Documents == nil {
+		s.Documents = map[string]*orm.Document{}
+	}
+	if s.Revisions == nil {
+		s.Revisions = map[string][]orm.Revision{}
+	}
+	if s.ContentIndex == nil {
+		s.ContentIndex = map[string]string{}
+	}
+	if s.Fingerprints == nil {
+		s.Fingerprints = map[string]orm.Fingerprint{}
+	}
+	if s.IdempotencyKeys == nil {
+		s.IdempotencyKeys = map[string]orm.IdempotencyKey{}
+	}
+	if s.WatermarkJobs == nil {
+		s.WatermarkJobs = map[string]orm.WatermarkJob{}
+	}
+	if s.Outbox == nil {
+		s.Outbox = map[int64]orm.OutboxMessage{}
+	}
+}
+
+type memoryRepository struct {
+	mu    sync.RWMutex
+	state *memoryState
+	// persist is called with the state after every committed transaction
+	persist func(state *memoryState) error
+}
+
+// NewMemoryRepository returns a thread-safe Repository keeping the documents
+// in memory, meant for tests and local runs without postgres.
+func NewMemoryRepository() Repository {
+	return &memoryRepository{state: newMemoryState()}
+}
+
+// Transaction holds the repository lock while fn runs, so transactions are
+// serialized. If fn fails the rows it touched are restored from the undo log
+// of the transaction.
+func (m *memoryRepository) Transaction(ctx context.Context, fn func(tx Repository) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx := newMemoryTx(m.state)
+	err := fn(tx)
+	if err == nil && m.persist != nil {
+		err = m.persist(m.state)
+	}
+	if err != nil {
+		tx.rollback()
+	}
+	return err
+}
+
+// view runs a read only fn with the shared lock held.
+func (m *memoryRepository) view(fn func(tx *memoryTx) error) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return fn(newMemoryTx(m.state))
+}
+
+func (m *memoryRepository) Create(ctx context.Context, row *orm.Document) error {
+	return m.Transaction(ctx, func(tx Repository) error { return tx.Create(ctx, row) })
+}
+
+func (m *memoryRepository) Find(ctx context.Context, ticketID string, includeDeleted bool) (*orm.Document, error) {
+	var row *orm.Document
+	err := m.view(func(tx *memoryTx) (err error) {
+		row, err = tx.Find(ctx, ticketID, includeDeleted)
+		return err
+	})
+	return row, err
+}
+
+func (m *memoryRepository) Save(ctx context.Context, row *orm.Document) error {
+	return m.Transaction(ctx, func(tx Repository) error { return tx.Save(ctx, row) })
+}
+
+func (m *memoryRepository) List(ctx context.Context, query internal.Query) (internal.Page, error) {
+	var page internal.Page
+	err := m.view(func(tx *memoryTx) (err error) {
+		page, err = tx.List(ctx, query)
+		return err
+	})
+	return page, err
+}
+
+func (m *memoryRepository) Search(ctx context.Context, query internal.SearchQuery) ([]internal.SearchResult, error) {
+	var results []internal.SearchResult
+	err := m.view(func(tx *memoryTx) (err error) {
+		results, err = tx.Search(ctx, query)
+		return err
+	})
+	return results, err
+}
+
+func (m *memoryRepository) Remove(ctx context.Context, ticketID string) error {
+	return m.Transaction(ctx, func(tx Repository) error { return tx.Remove(ctx, ticketID) })
+}
+
+func (m *memoryRepository) Restore(ctx context.Context, ticketID string) error {
+	return m.Transaction(ctx, func(tx Repository) error { return tx.Restore(ctx, ticketID) })
+}
+
+func (m *memoryRepository) Purge(ctx context.Context, ticketID string) error {
+	return m.Transaction(ctx, func(tx Repository) error { return tx.Purge(ctx, ticketID) })
+}
+
+func (m *memoryRepository) AddRevision(ctx context.Context, rev *orm.Revision) error {
+	return m.Transaction(ctx, func(tx Repository) error { return tx.AddRevision(ctx, rev) })
+}
+
+func (m *memoryRepository) Revisions(ctx context.Context, ticketID string) ([]orm.Revision, error) {
+	var revs []orm.Revision
+	err := m.view(func(tx *memoryTx) (err error) {
+		revs, err = tx.Revisions(ctx, ticketID)
+		return err
+	})
+	return revs, err
+}
+
+func (m *memoryRepository) FindRevision(ctx context.Context, ticketID string, number int64) (*orm.Revision, error) {
+	var rev *orm.Revision
+	err := m.view(func(tx *memoryTx) (err error) {
+		rev, err = tx.FindRevision(ctx, ticketID, number)
+		return err
+	})
+	return rev, err
+}
+
+func (m *memoryRepository) IndexContent(ctx context.Context, ticketID, text string) error {
+	return m.Transaction(ctx, func(tx Repository) error { return tx.IndexContent(ctx, ticketID, text) })
+}
+
+func (m *memoryRepository) ContentDigests(ctx context.Context) (map[string]bool, error) {
+	var digests map[string]bool
+	err := m.view(func(tx *memoryTx) (err error) {
+		digests, err = tx.ContentDigests(ctx)
+		return err
+	})
+	return digests, err
+}
+
+func (m *memoryRepository) SaveFingerprint(ctx context.Context, fp *orm.Fingerprint) error {
+	return m.Transaction(ctx, func(tx Repository) error { return tx.SaveFingerprint(ctx, fp) })
+}
+
+func (m *memoryRepository) FindFingerprint(ctx context.Context, ticketID string) (*orm.Fingerprint, error) {
+	var fp *orm.Fingerprint
+	err := m.view(func(tx *memoryTx) (err error) {
+		fp, err = tx.FindFingerprint(ctx, ticketID)
+		return err
+	})
+	return fp, err
+}
+
+func (m *memoryRepository) SimilarFingerprints(ctx context.Context, fp *orm.Fingerprint) ([]orm.Fingerprint, error) {
+	var fps []orm.Fingerprint
+	err := m.view(func(tx *memoryTx) (err error) {
+		fps, err = tx.SimilarFingerprints(ctx, fp)
+		return err
+	})
+	return fps, err
+}
+
+func (m *memoryRepository) MissingFingerprints(ctx context.Context, limit int) ([]orm.Document, error) {
+	var rows []orm.Document
+	err := m.view(func(tx *memoryTx) (err error) {
+		rows, err = tx.MissingFingerprints(ctx, limit)
+		return err
+	})
+	return rows, err
+}
+
+func (m *memoryRepository) AddEvent(ctx context.Context, ev *orm.Event) error {
+	return m.Transaction(ctx, func(tx Repository) error { return tx.AddEvent(ctx, ev) })
+}
+
+func (m *memoryRepository) Events(ctx context.Context, after int64, limit int) ([]orm.Event, error) {
+	var events []orm.Event
+	err := m.view(func(tx *memoryTx) (err error) {
+		events, err = tx.Events(ctx, after, limit)
+		return err
+	})
+	return events, err
+}
+
+func (m *memoryRepository) LastEventOffset(ctx context.Context) (int64, error) {
+	var offset int64
+	err := m.view(func(tx *memoryTx) (err error) {
+		offset, err = tx.LastEventOffset(ctx)
+		return err
+	})
+	return offset, err
+}
+
+func (m *memoryRepository) FirstEventOffset(ctx context.Context) (int64, error) {
+	var offset int64
+	err := m.view(func(tx *memoryTx) (err error) {
+		offset, err = tx.FirstEventOffset(ctx)
+		return err
+	})
+	return offset, err
+}
+
+func (m *memoryRepository) PurgeEvents(ctx context.Context, before time.Time) (int, error) {
+	var n int
+	err := m.Transaction(ctx, func(tx Repository) (err error) {
+		n, err = tx.PurgeEvents(ctx, before)
+		return err
+	})
+	return n, err
+}
+
+func (m *memoryRepository) FindWatermarkJob(ctx context.Context, ticketID string) (*orm.WatermarkJob, error) {
+	var job *orm.WatermarkJob
+	err := m.view(func(tx *memoryTx) error {
+		var err error
+		job, err = tx.FindWatermarkJob(ctx, ticketID)
+		return err
+	})
+	return job, err
+}
+
+func (m *memoryRepository) SaveWatermarkJob(ctx context.Context, job *orm.WatermarkJob) error {
+	return m.Transaction(ctx, func(tx Repository) error { return tx.SaveWatermarkJob(ctx, job) })
+}
+
+func (m *memoryRepository) AddOutboxMessage(ctx context.Context, msg *orm.OutboxMessage) error {
+	return m.Transaction(ctx, func(tx Repository) error { return tx.AddOutboxMessage(ctx, msg) })
+}
+
+func (m *memoryRepository) ClaimOutboxMessages(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]orm.OutboxMessage, error) {
+	// an idle relay must not rewrite the file of the file repository
+	due := false
+	m.view(func(tx *memoryTx) error {
+		for _, msg := range tx.state.Outbox {
+			if msg.Status == orm.OutboxPending && !msg.NextAttemptAt.After(now) {
+				due = true
+				break
+			}
+		}
+		return nil
+	})
+	if !due {
+		return nil, nil
+	}
+	var msgs []orm.OutboxMessage
+	err := m.Transaction(ctx, func(tx Repository) error {
+		var err error
+		msgs, err = tx.ClaimOutboxMessages(ctx, now, limit, lease)
+		return err
+	})
+	return msgs, err
+}
+
+func (m *memoryRepository) UpdateOutboxMessage(ctx context.Context, msg *orm.OutboxMessage) error {
+	return m.Transaction(ctx, func(tx Repository) error { return tx.UpdateOutboxMessage(ctx, msg) })
+}
+
+func (m *memoryRepository) PurgeOutboxMessages(ctx context.Context, before time.Time) (int, error) {
+	var n int
+	err := m.Transaction(ctx, func(tx Repository) error {
+		var err error
+		n, err = tx.PurgeOutboxMessages(ctx, before)
+		return err
+	})
+	return n, err
+}
+
+func (m *memoryRepository) FindIdempotencyKey(ctx context.Context, scope, key string) (*orm.IdempotencyKey, error) {
+	var row *orm.IdempotencyKey
+	err := m.view(func(tx *memoryTx) (err error) {
+		row, err = tx.FindIdempotencyKey(ctx, scope, key)
+		return err
+	})
+	return row, err
+}
+
+func (m *memoryRepository) CreateIdempotencyKey(ctx context.Context, row *orm.IdempotencyKey) (bool, error) {
+	var created bool
+	err := m.Transaction(ctx, func(tx Repository) (err error) {
+		created, err = tx.CreateIdempotencyKey(ctx, row)
+		return err
+	})
+	return created, err
+}
+
+func (m *memoryRepository) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	var n int
+	err := m.Transaction(ctx, func(tx Repository) (err error) {
+		n, err = tx.PurgeIdempotencyKeys(ctx, before)
+		return err
+	})
+	return n, err
+}
+
+func (m *memoryRepository) Ping(_ context.Context) error {
+	return nil
+}
+
+func (m *memoryRepository) Close() error {
+	return nil
+}
+
+// memoryTx works on the state directly, the caller holds the lock. Before a
+// row is changed its previous value is recorded in the undo log, so a failed
+// transaction costs the rows it touched rather than a copy of the state.
+type memoryTx struct {
+	state *memoryState
+	undo  []func()
+	// the counters only grow and the events are appended or replaced, never
+	// changed in place, their values at the start are enough to roll back
+	nextID                    uint
+	nextEventID, nextOutboxID int64
+	events                    []orm.Event
+}
+
+func newMemoryTx(state *memoryState) *memoryTx {
+	return &memoryTx{
+		state:        state,
+		nextID:       state.NextID,
+		nextEventID:  state.NextEventID,
+		nextOutboxID: state.NextOutboxID,
+		events:       state.Events,
+	}
+}
+
+// rollback undoes the changes of the transaction in reverse order.
+func (t *memoryTx) rollback() {
+	for i := len(t.undo) - 1; i >= 0; i-- {
+		t.undo[i]()
+	}
+	t.undo = nil
+	t.state.NextID = t.nextID
+	t.state.NextEventID = t.nextEventID
+	t.state.NextOutboxID = t.nextOutboxID
+	t.state.Events = t.events
+}
+
+// touchDocument records the document before it is created, changed or
+// deleted. The row is copied since Remove and Restore change it in place.
+func (t *memoryTx) touchDocument(ticketID string) {
+	row, ok := t.state.Documents[ticketID]
+	var before orm.Document
+	if ok {
+		before = *row
+	}
+	t.undo = append(t.undo, func() {
+		if ok {
+			t.state.Documents[ticketID] = &before
+		} else {
+			delete(t.state.Documents, ticketID)
+		}
+	})
+}
+
+// touchRevisions records the revisions of a ticket. Their capacity is capped
+// so the next append copies them instead of writing into the array recorded.
+func (t *memoryTx) touchRevisions(ticketID string) {
+	revs, ok := t.state.Revisions[ticketID]
+	if ok {
+		t.state.Revisions[ticketID] = revs[:len(revs):len(revs)]
+	}
+	t.undo = append(t.undo, func() {
+		if ok {
+			t.state.Revisions[ticketID] = revs
+		} else {
+			delete(t.state.Revisions, ticketID)
+		}
+	})
+}
+
+func (t *memoryTx) touchContentIndex(ticketID string) {
+	text, ok := t.state.ContentIndex[ticketID]
+	t.undo = append(t.undo, func() {
+		if ok {
+			t.state.ContentIndex[ticketID] = text
+		} else {
+			delete(t.state.ContentIndex, ticketID)
+		}
+	})
+}
+
+func (t *memoryTx) touchFingerprint(ticketID string) {
+	fp, ok := t.state.Fingerprints[ticketID]
+	t.undo = append(t.undo, func() {
+		if ok {
+			t.state.Fingerprints[ticketID] = fp
+		} else {
+			delete(t.state.Fingerprints, ticketID)
+		}
+	})
+}
+
+func (t *memoryTx) touchWatermarkJob(ticketID string) {
+	job, ok := t.state.WatermarkJobs[ticketID]
+	t.undo = append(t.undo, func() {
+		if ok {
+			t.state.WatermarkJobs[ticketID] = job
+		} else {
+			delete(t.state.WatermarkJobs, ticketID)
+		}
+	})
+}
+
+func (t *memoryTx) touchOutboxMessage(id int64) {
+	msg, ok := t.state.Outbox[id]
+	t.undo = append(t.undo, func() {
+		if ok {
+			t.state.Outbox[id] = msg
+		} else {
+			delete(t.state.Outbox, id)
+		}
+	})
+}
+
+func (t *memoryTx) touchIdempotencyKey(id string) {
+	row, ok := t.state.IdempotencyKeys[id]
+	t.undo = append(t.undo, func() {
+		if ok {
+			t.state.IdempotencyKeys[id] = row
+		} else {
+			delete(t.state.IdempotencyKeys, id)
+		}
+	})
+}
+
+func (t *memoryTx) Transaction(_ context.Context, fn func(tx Repository) error) error {
+	return fn(t)
+}
+
+func (t *memoryTx) Create(_ context.Context, row *orm.Document) error {
+	if _, ok := t.state.Documents[row.TicketID]; ok {
+		return util.ErrInvalidArgument
+	}
+	t.touchDocument(row.TicketID)
+	now := time.Now()
+	t.state.NextID++
+	row.ID = t.state.NextID
+	row.CreatedAt, row.UpdatedAt = now, now
+	copied := *row
+	t.state.Documents[row.TicketID] = &copied
+	return nil
+}
+
+func (t *memoryTx) Find(_ context.Context, ticketID string, includeDeleted bool) (*orm.Document, error) {
+	row, ok := t.state.Documents[ticketID]
+	if !ok || (row.DeletedAt.Valid && !includeDeleted) {
+		return nil, util.ErrUnknown
+	}
+	copied := *row
+	return &copied, nil
+}
+
+func (t *memoryTx) Save(_ context.Context, row *orm.Document) error {
+	if _, ok := t.state.Documents[row.TicketID]; !ok {
+		return util.ErrUnknown
+	}
+	t.touchDocument(row.TicketID)
+	row.UpdatedAt = time.Now()
+	copied := *row
+	t.state.Documents[row.TicketID] = &copied
+	return nil
+}
+
+func (t *memoryTx) List(_ context.Context, query internal.Query) (internal.Page, error) {
+	page := internal.Page{Documents: []internal.Document{}}
+	if err := internal.ValidateFilters(query.Filters); err != nil {
+		return page, err
+	}
+	keys := sortKeys(query.Filters)
+
+	var after func(row *orm.Document) bool
+	if query.Cursor != "" {
+		cursor, err := internal.DecodeCursor(query.Cursor, query.Filters)
+		if err != nil {
+			return page, err
+		}
+		if len(cursor.Values) != len(keys) {
+			return page, util.ErrInvalidArgument
+		}
+		last := &orm.Document{Model: gorm.Model{ID: cursor.ID}}
+		for i, k := range keys {
+			if err := setSortValue(last, k.key, cursor.Values[i]); err != nil {
+				return page, err
+			}
+		}
+		after = func(row *orm.Document) bool { return compareRows(row, last, keys) > 0 }
+	}
+
+	var rows []*orm.Document
+	for _, row := range t.state.Documents {
+		if row.DeletedAt.Valid && !query.IncludeDeleted {
+			continue
+		}
+		if !matchFilters(row, query.Filters) {
+			continue
+		}
+		page.Total++
+		if after == nil || after(row) {
+			rows = append(rows, row)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return compareRows(rows[i], rows[j], keys) < 0 })
+
+	if limit := query.Limit(); len(rows) > limit {
+		rows = rows[:limit]
+		page.NextCursor = nextCursor(rows[limit-1], keys, query.Filters)
+	}
+	for _, row := range rows {
+		page.Documents = append(page.Documents, row.ToInternal())
+	}
+	return page, nil
+}
+
+func (t *memoryTx) Search(_ context.Context, query internal.SearchQuery) ([]internal.SearchResult, error) {
+	entries := make([]indexedDocument, 0, len(t.state.Documents))
+	for _, row := range t.state.Documents {
+		if !row.DeletedAt.Valid {
+			doc := row.ToInternal()
+			doc.Content = t.state.ContentIndex[row.TicketID]
+			entries = append(entries, newIndexedDocument(int(row.ID), doc))
+		}
+	}
+	results, err := searchEntries(entries, query)
+	// like postgres only the index of the content is kept, not the content
+	for i := range results {
+		results[i].Document.Content = ""
+		delete(results[i].Highlights, internal.KeyContent)
+	}
+	return results, err
+}
+
+func (t *memoryTx) Remove(_ context.Context, ticketID string) error {
+	row, ok := t.state.Documents[ticketID]
+	if !ok || row.DeletedAt.Valid {
+		return util.ErrUnknown
+	}
+	t.touchDocument(ticketID)
+	row.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	return nil
+}
+
+func (t *memoryTx) Restore(_ context.Context, ticketID string) error {
+	row, ok := t.state.Documents[ticketID]
+	if !ok {
+		return util.ErrUnknown
+	}
+	t.touchDocument(ticketID)
+	row.DeletedAt = gorm.DeletedAt{}
+	return nil
+}
+
+func (t *memoryTx) Purge(_ context.Context, ticketID string) error {
+	if _, ok := t.state.Documents[ticketID]; !ok {
+		return util.ErrUnknown
+	}
+	t.touchDocument(ticketID)
+	t.touchRevisions(ticketID)
+	t.touchContentIndex(ticketID)
+	t.touchFingerprint(ticketID)
+	t.touchWatermarkJob(ticketID)
+	delete(t.state.Documents, ticketID)
+	delete(t.state.Revisions, ticketID)
+	delete(t.state.ContentIndex, ticketID)
+	delete(t.state.Fingerprints, ticketID)
+	delete(t.state.WatermarkJobs, ticketID)
+	return nil
+}
+
+func (t *memoryTx) IndexContent(_ context.Context, ticketID, text string) error {
+	if _, ok := t.state.Documents[ticketID]; !ok {
+		return util.ErrUnknown
+	}
+	t.touchContentIndex(ticketID)
+	t.state.ContentIndex[ticketID] = text
+	return nil
+}
+
+func (t *memoryTx) ContentDigests(_ context.Context) (map[string]bool, error) {
+	digests := map[string]bool{}
+	for _, row := range t.state.Documents {
+		if row.ContentDigest != "" {
+			digests[row.ContentDigest] = true
+		}
+	}
+	for _, revs := range t.state.Revisions {
+		for _, r := range revs {
+			if r.ContentDigest != "" {
+				digests[r.ContentDigest] = true
+			}
+		}
+	}
+	return digests, nil
+}
+
+func (t *memoryTx) SaveFingerprint(_ context.Context, fp *orm.Fingerprint) error {
+	if _, ok := t.state.Documents[fp.TicketID]; !ok {
+		return util.ErrUnknown
+	}
+	t.touchFingerprint(fp.TicketID)
+	t.state.Fingerprints[fp.TicketID] = *fp
+	return nil
+}
+
+func (t *memoryTx) FindFingerprint(_ context.Context, ticketID string) (*orm.Fingerprint, error) {
+	fp, ok := t.state.Fingerprints[ticketID]
+	if !ok {
+		return nil, util.ErrUnknown
+	}
+	return &fp, nil
+}
+
+func (t *memoryTx) SimilarFingerprints(_ context.Context, fp *orm.Fingerprint) ([]orm.Fingerprint, error) {
+	var fps []orm.Fingerprint
+	for id, other := range t.state.Fingerprints {
+		if id == fp.TicketID || t.state.Documents[id].DeletedAt.Valid {
+			continue
+		}
+		if other.ContentDigest == fp.ContentDigest || sharesBand(other.Bands, fp.Bands) {
+			fps = append(fps, other)
+		}
+	}
+	sort.Slice(fps, func(i, j int) bool { return fps[i].TicketID < fps[j].TicketID })
+	return fps, nil
+}
+
+// sharesBand reports whether a band of the same number has the same hash.
+func sharesBand(a, b []int64) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *memoryTx) MissingFingerprints(_ context.Context, limit int) ([]orm.Document, error) {
+	var rows []orm.Document
+	for id, row := range t.state.Documents {
+		if _, ok := t.state.Fingerprints[id]; !ok && row.ContentDigest != "" {
+			rows = append(rows, *row)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
+
+func (t *memoryTx) AddEvent(_ context.Context, ev *orm.Event) error {
+	if t.state.NextEventID == 0 {
+		t.state.NextEventID = 1
+	}
+	ev.ID = t.state.NextEventID
+	t.state.NextEventID++
+	if ev.CreatedAt.IsZero() {
+		ev.CreatedAt = time.Now()
+	}
+	t.state.Events = append(t.state.Events, *ev)
+	return nil
+}
+
+func (t *memoryTx) Events(_ context.Context, after int64, limit int) ([]orm.Event, error) {
+	events := t.state.Events
+	i := sort.Search(len(events), func(i int) bool { return events[i].ID > after })
+	events = events[i:]
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	// the caller must not see events appended later to the same array
+	return append([]orm.Event(nil), events...), nil
+}
+
+func (t *memoryTx) LastEventOffset(_ context.Context) (int64, error) {
+	// purged events keep their offsets
+	if t.state.NextEventID == 0 {
+		return 0, nil
+	}
+	return t.state.NextEventID - 1, nil
+}
+
+func (t *memoryTx) FirstEventOffset(_ context.Context) (int64, error) {
+	if len(t.state.Events) == 0 {
+		return 0, nil
+	}
+	return t.state.Events[0].ID, nil
+}
+
+func (t *memoryTx) PurgeEvents(_ context.Context, before time.Time) (int, error) {
+	events := t.state.Events
+	i := sort.Search(len(events), func(i int) bool { return !events[i].CreatedAt.Before(before) })
+	// the events of the transaction start stay intact for a rollback
+	t.state.Events = append([]orm.Event(nil), events[i:]...)
+	return i, nil
+}
+
+func (t *memoryTx) FindWatermarkJob(_ context.Context, ticketID string) (*orm.WatermarkJob, error) {
+	job, ok := t.state.WatermarkJobs[ticketID]
+	if !ok {
+		return nil, util.ErrUnknown
+	}
+	return &job, nil
+}
+
+func (t *memoryTx) SaveWatermarkJob(_ context.Context, job *orm.WatermarkJob) error {
+	if _, ok := t.state.Documents[job.TicketID]; !ok {
+		return util.ErrUnknown
+	}
+	now := time.Now()
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = now
+	}
+	job.UpdatedAt = now
+	t.touchWatermarkJob(job.TicketID)
+	t.state.WatermarkJobs[job.TicketID] = *job
+	return nil
+}
+
+func (t *memoryTx) AddOutboxMessage(_ context.Context, msg *orm.OutboxMessage) error {
+	if t.state.NextOutboxID == 0 {
+		t.state.NextOutboxID = 1
+	}
+	msg.ID = t.state.NextOutboxID
+	t.state.NextOutboxID++
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+	if msg.Status == "" {
+		msg.Status = orm.OutboxPending
+	}
+	t.touchOutboxMessage(msg.ID)
+	t.state.Outbox[msg.ID] = *msg
+	return nil
+}
+
+func (t *memoryTx) ClaimOutboxMessages(_ context.Context, now time.Time, limit int, lease time.Duration) ([]orm.OutboxMessage, error) {
+	var msgs []orm.OutboxMessage
+	for _, msg := range t.state.Outbox {
+		if msg.Status == orm.OutboxPending && !msg.NextAttemptAt.After(now) {
+			msgs = append(msgs, msg)
+		}
+	}
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].ID < msgs[j].ID })
+	if len(msgs) > limit {
+		msgs = msgs[:limit]
+	}
+	for i := range msgs {
+		msgs[i].Attempts++
+		msgs[i].NextAttemptAt = now.Add(lease)
+		t.touchOutboxMessage(msgs[i].ID)
+		t.state.Outbox[msgs[i].ID] = msgs[i]
+	}
+	return msgs, nil
+}
+
+func (t *memoryTx) UpdateOutboxMessage(_ context.Context, msg *orm.OutboxMessage) error {
+	stored, ok := t.state.Outbox[msg.ID]
+	if !ok || stored.Attempts != msg.Attempts || stored.Status != orm.OutboxPending {
+		return util.ErrConflict
+	}
+	stored.Status = msg.Status
+	stored.NextAttemptAt = msg.NextAttemptAt
+	stored.DeliveredAt = msg.DeliveredAt
+	stored.LastError = msg.LastError
+	t.touchOutboxMessage(msg.ID)
+	t.state.Outbox[msg.ID] = stored
+	return nil
+}
+
+func (t *memoryTx) PurgeOutboxMessages(_ context.Context, before time.Time) (int, error) {
+	n := 0
+	for id, msg := range t.state.Outbox {
+		done := msg.NextAttemptAt
+		if msg.DeliveredAt != nil {
+			done = *msg.DeliveredAt
+		}
+		if msg.Status != orm.OutboxPending && done.Before(before) {
+			t.touchOutboxMessage(id)
+			delete(t.state.Outbox, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// idempotencyID is the key of an idempotency key in the state.
+func idempotencyID(scope, key string) string {
+	return scope + "\x00" + key
+}
+
+func (t *memoryTx) FindIdempotencyKey(_ context.Context, scope, key string) (*orm.IdempotencyKey, error) {
+	row, ok := t.state.IdempotencyKeys[idempotencyID(scope, key)]
+	if !ok || !row.ExpiresAt.After(time.Now()) {
+		return nil, util.ErrUnknown
+	}
+	return &row, nil
+}
+
+func (t *memoryTx) CreateIdempotencyKey(_ context.Context, row *orm.IdempotencyKey) (bool, error) {
+	id := idempotencyID(row.Scope, row.Key)
+	if existing, ok := t.state.IdempotencyKeys[id]; ok && existing.ExpiresAt.After(row.CreatedAt) {
+		return false, nil
+	}
+	t.touchIdempotencyKey(id)
+	t.state.IdempotencyKeys[id] = *row
+	return true, nil
+}
+
+func (t *memoryTx) PurgeIdempotencyKeys(_ context.Context, before time.Time) (int, error) {
+	n := 0
+	for id, row := range t.state.IdempotencyKeys {
+		if !row.ExpiresAt.After(before) {
+			t.touchIdempotencyKey(id)
+			delete(t.state.IdempotencyKeys, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (t *memoryTx) AddRevision(_ context.Context, rev *orm.Revision) error {
+	if _, ok := t.state.Documents[rev.TicketID]; !ok {
+		return util.ErrUnknown
+	}
+	for _, r := range t.state.Revisions[rev.TicketID] {
+		if r.Number == rev.Number {
+			return util.ErrInvalidArgument
+		}
+	}
+	t.touchRevisions(rev.TicketID)
+	t.state.NextID++
+	rev.ID = t.state.NextID
+	rev.CreatedAt = time.Now()
+	revs := append(t.state.Revisions[rev.TicketID], *rev)
+	sort.Slice(revs, func(i, j int) bool { return revs[i].Number < revs[j].Number })
+	t.state.Revisions[rev.TicketID] = revs
+	return nil
+}
+
+func (t *memoryTx) Revisions(_ context.Context, ticketID string) ([]orm.Revision, error) {
+	return append([]orm.Revision(nil), t.state.Revisions[ticketID]...), nil
+}
+
+func (t *memoryTx) FindRevision(_ context.Context, ticketID string, number int64) (*orm.Revision, error) {
+	for _, r := range t.state.Revisions[ticketID] {
+		if r.Number == number {
+			return &r, nil
+		}
+	}
+	return nil, util.ErrUnknown
+}
+
+func (t *memoryTx) Ping(_ context.Context) error {
+	return nil
+}
+
+func (t *memoryTx) Close() error {
+	return nil
+}
+
+// matchFilters evaluates the filters the same way applyFilters does in SQL.
+func matchFilters(row *orm.Document, filters []internal.Filter) bool {
+	for _, f := range filters {
+		if !matchFilter(row, f) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchFilter(row *orm.Document, f internal.Filter) bool {
+	if f.IsGroup() {
+		if !matchFilters(row, f.All) {
+			return false
+		}
+		if len(f.Any) == 0 {
+			return true
+		}
+		for _, nested := range f.Any {
+			if matchFilter(row, nested) {
+				return true
+			}
+		}
+		return false
+	}
+
+	f = contentFilter(f)
+	value := sortValue(row, f.Key)
+	switch f.Operator() {
+	case "":
+		return true
+	case internal.OpEq:
+		return value == f.Value
+	case internal.OpNeq:
+		return value != f.Value
+	case internal.OpContains:
+		return strings.Contains(value, f.Value)
+	case internal.OpPrefix:
+		return strings.HasPrefix(value, f.Value)
+	case internal.OpIn:
+		for _, v := range f.Values {
+			if value == v {
+				return true
+			}
+		}
+		return false
+	case internal.OpRange:
+		t := timeValue(row, f.Key)
+		return (f.From == nil || !t.Before(*f.From)) && (f.To == nil || !t.After(*f.To))
+	}
+	return false
+}
+
+// compareRows orders the rows by the sort keys and finally by the row id.
+func compareRows(a, b *orm.Document, keys []sortKey) int {
+	for _, k := range keys {
+		var c int
+		if internal.IsTimeKey(k.key) {
+			ta, tb := timeValue(a, k.key), timeValue(b, k.key)
+			switch {
+			case ta.Before(tb):
+				c = -1
+			case ta.After(tb):
+				c = 1
+			}
+		} else {
+			c = strings.Compare(sortValue(a, k.key), sortValue(b, k.key))
+		}
+		if k.order == internal.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	switch {
+	case a.ID < b.ID:
+		return -1
+	case a.ID > b.ID:
+		return 1
+	}
+	return 0
+}
+
+func timeValue(row *orm.Document, key string) time.Time {
+	if key == internal.KeyUpdatedAt {
+		return row.UpdatedAt
+	}
+	return row.CreatedAt
+}
+
+// setSortValue is the inverse of sortValue, used to rebuild the last row of
+// a page from its cursor.
+func setSortValue(row *orm.Document, key, value string) error {
+	v, err := parseSortValue(key, value)
+	if err != nil {
+		return err
+	}
+	switch key {
+	case internal.KeyTicketID:
+		row.TicketID = value
+	case internal.KeyContentDigest:
+		row.ContentDigest = value
+	case internal.KeyTitle:
+		row.Title = value
+	case internal.KeyAuthor:
+		row.Author = value
+	case internal.KeyTopic:
+		row.Topic = value
+	case internal.KeyWatermark:
+		row.Watermark = value
+	case internal.KeyCreatedAt:
+		row.CreatedAt = v.(time.Time)
+	case internal.KeyUpdatedAt:
+		row.UpdatedAt = v.(time.Time)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"publisher/internal"
+	orm "publisher/internal/database"
+	"testing"
+	"time"
+)
+
+func TestMemoryTransactionRollback(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository().(*memoryRepository)
+	doc := orm.NewDocument("a", &internal.Document{Title: "Title", Author: "Author", Topic: "Topic"})
+	seed := func(tx Repository) error {
+		if err := tx.Create(ctx, doc); err != nil {
+			return err
+		}
+		if err := tx.AddRevision(ctx, orm.NewRevision(doc, "editor", nil)); err != nil {
+			return err
+		}
+		if err := tx.IndexContent(ctx, "a", "text"); err != nil {
+			return err
+		}
+		if err := tx.SaveWatermarkJob(ctx, &orm.WatermarkJob{TicketID: "a"}); err != nil {
+			return err
+		}
+		if _, err := tx.CreateIdempotencyKey(ctx, &orm.IdempotencyKey{Scope: "add", Key: "k", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			return err
+		}
+		if err := tx.AddOutboxMessage(ctx, &orm.OutboxMessage{}); err != nil {
+			return err
+		}
+		return tx.AddEvent(ctx, orm.NewEvent(internal.EventAdded, doc, "editor"))
+	}
+	if err := repo.Transaction(ctx, seed); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := json.Marshal(repo.state)
+
+	failed := errors.New("failed")
+	err := repo.Transaction(ctx, func(tx Repository) error {
+		row, err := tx.Find(ctx, "a", false)
+		if err != nil {
+			return err
+		}
+		row.Title = "Changed"
+		row.Version++
+		if err := tx.Save(ctx, row); err != nil {
+			return err
+		}
+		if err := tx.AddRevision(ctx, orm.NewRevision(row, "editor", []string{internal.KeyTitle})); err != nil {
+			return err
+		}
+		if err := tx.Remove(ctx, "a"); err != nil {
+			return err
+		}
+		if err := tx.Create(ctx, orm.NewDocument("b", &internal.Document{Title: "Other"})); err != nil {
+			return err
+		}
+		if err := tx.AddEvent(ctx, orm.NewEvent(internal.EventRemoved, row, "editor")); err != nil {
+			return err
+		}
+		if _, err := tx.PurgeEvents(ctx, time.Now().Add(time.Hour)); err != nil {
+			return err
+		}
+		if _, err := tx.PurgeIdempotencyKeys(ctx, time.Now().Add(2*time.Hour)); err != nil {
+			return err
+		}
+		if _, err := tx.ClaimOutboxMessages(ctx, time.Now(), 10, time.Minute); err != nil {
+			return err
+		}
+		if err := tx.Purge(ctx, "a"); err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("Transaction returned %v, want %v", err, failed)
+	}
+	if after, _ := json.Marshal(repo.state); string(after) != string(before) {
+		t.Errorf("state after rollback:\n%s\nwant:\n%s", after, before)
+	}
+
+	// the rows restored are not shared with the failed transaction
+	if err := repo.Remove(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if revs, _ := repo.Revisions(ctx, "a"); len(revs) != 1 {
+		t.Errorf("got %d revisions, want 1", len(revs))
+	}
+}
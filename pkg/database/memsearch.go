@@ -0,0 +1,369 @@
+package database
+
+import (
+	"context"
+	"math"
+	"publisher/internal"
+	"publisher/internal/util"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// searchWeights mirror the tsvector weights of the postgres index.
+var searchWeights = map[string]float64{
+	internal.KeyTitle:   1.0,
+	internal.KeyAuthor:  0.4,
+	internal.KeyTopic:   0.4,
+	internal.KeyContent: 0.2,
+}
+
+// snippetWords is the number of words shown around the first match of the content.
+const snippetWords = 20
+
+// MemorySearcher is an in-memory Searcher for tests and local runs without
+// postgres. It understands the same query syntax as the postgres searcher.
+type MemorySearcher struct {
+	mu   sync.RWMutex
+	seq  int
+	docs map[string]indexedDocument
+}
+
+type indexedDocument struct {
+	seq    int
+	doc    internal.Document
+	fields map[string][]token
+}
+
+type token struct {
+	text       string
+	start, end int
+}
+
+func NewMemorySearcher() *MemorySearcher {
+	return &MemorySearcher{docs: map[string]indexedDocument{}}
+}
+
+// Index adds or replaces the document in the index.
+func (m *MemorySearcher) Index(doc internal.Document) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seq := m.docs[doc.TicketID].seq
+	if seq == 0 {
+		m.seq++
+		seq = m.seq
+	}
+	m.docs[doc.TicketID] = newIndexedDocument(seq, doc)
+}
+
+// Remove drops the document of the ticket from the index.
+func (m *MemorySearcher) Remove(ticketID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.docs, ticketID)
+}
+
+func (m *MemorySearcher) Search(_ context.Context, query internal.SearchQuery) ([]internal.SearchResult, error) {
+	m.mu.RLock()
+	entries := make([]indexedDocument, 0, len(m.docs))
+	for _, entry := range m.docs {
+		entries = append(entries, entry)
+	}
+	m.mu.RUnlock()
+	return searchEntries(entries, query)
+}
+
+func newIndexedDocument(seq int, doc internal.Document) indexedDocument {
+	return indexedDocument{
+		seq: seq,
+		doc: doc,
+		fields: map[string][]token{
+			internal.KeyTitle:   tokenize(doc.Title),
+			internal.KeyAuthor:  tokenize(doc.Author),
+			internal.KeyTopic:   tokenize(doc.Topic),
+			internal.KeyContent: tokenize(doc.Content),
+		},
+	}
+}
+
+// searchEntries ranks the indexed documents matching the query, documents
+// of equal rank keep the order of their sequence numbers.
+func searchEntries(entries []indexedDocument, query internal.SearchQuery) ([]internal.SearchResult, error) {
+	alternatives := parseSearchQuery(query.Text)
+	if len(alternatives) == 0 {
+		return []internal.SearchResult{}, util.ErrInvalidArgument
+	}
+
+	type hit struct {
+		seq    int
+		result internal.SearchResult
+	}
+	var hits []hit
+	for _, entry := range entries {
+		var matched [][]string
+		for _, terms := range alternatives {
+			if terms.matches(entry.fields) {
+				matched = append(matched, terms.positive()...)
+			}
+		}
+		if matched == nil {
+			continue
+		}
+		hits = append(hits, hit{seq: entry.seq, result: internal.SearchResult{
+			Document:   entry.doc,
+			Rank:       rank(entry.fields, matched),
+			Highlights: highlights(entry.doc, entry.fields, matched),
+		}})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].result.Rank != hits[j].result.Rank {
+			return hits[i].result.Rank > hits[j].result.Rank
+		}
+		return hits[i].seq < hits[j].seq
+	})
+	if limit := query.MaxResults(); len(hits) > limit {
+		hits = hits[:limit]
+	}
+	results := make([]internal.SearchResult, 0, len(hits))
+	for _, h := range hits {
+		results = append(results, h.result)
+	}
+	return results, nil
+}
+
+// searchTerm is a word or a phrase of the query.
+type searchTerm struct {
+	words   []string
+	negated bool
+}
+
+// searchTerms must all match, the alternatives of a query are separated by "or".
+type searchTerms []searchTerm
+
+func (t searchTerms) matches(fields map[string][]token) bool {
+	positive := false
+	for _, term := range t {
+		found := false
+		for _, tokens := range fields {
+			if countPhrase(tokens, term.words) > 0 {
+				found = true
+				break
+			}
+		}
+		if found == term.negated {
+			return false
+		}
+		positive = positive || !term.negated
+	}
+	return positive
+}
+
+func (t searchTerms) positive() [][]string {
+	var phrases [][]string
+	for _, term := range t {
+		if !term.negated {
+			phrases = append(phrases, term.words)
+		}
+	}
+	return phrases
+}
+
+// parseSearchQuery parses the web search syntax of websearch_to_tsquery.
+func parseSearchQuery(text string) []searchTerms {
+	var (
+		alternatives []searchTerms
+		current      searchTerms
+	)
+	for len(text) > 0 {
+		text = strings.TrimLeftFunc(text, unicode.IsSpace)
+		if text == "" {
+			break
+		}
+		negated := false
+		if text[0] == '-' {
+			negated = true
+			text = text[1:]
+		}
+		var raw string
+		if strings.HasPrefix(text, `"`) {
+			end := strings.IndexByte(text[1:], '"')
+			if end < 0 {
+				raw, text = text[1:], ""
+			} else {
+				raw, text = text[1:end+1], text[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(text, unicode.IsSpace)
+			if end < 0 {
+				end = len(text)
+			}
+			raw, text = text[:end], text[end:]
+			if !negated && strings.EqualFold(raw, "or") {
+				if len(current) > 0 {
+					alternatives = append(alternatives, current)
+				}
+				current = nil
+				continue
+			}
+		}
+		var words []string
+		for _, t := range tokenize(raw) {
+			words = append(words, t.text)
+		}
+		if len(words) > 0 {
+			current = append(current, searchTerm{words: words, negated: negated})
+		}
+	}
+	if len(current) > 0 {
+		alternatives = append(alternatives, current)
+	}
+	return alternatives
+}
+
+// tokenize splits the text into lower cased words and keeps their offsets.
+func tokenize(text string) []token {
+	var (
+		tokens []token
+		start  = -1
+	)
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			tokens = append(tokens, token{text: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{text: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+// phraseAt reports whether the words occur in the tokens starting at i.
+func phraseAt(tokens []token, words []string, i int) bool {
+	if i+len(words) > len(tokens) {
+		return false
+	}
+	for j, w := range words {
+		if tokens[i+j].text != w {
+			return false
+		}
+	}
+	return true
+}
+
+func countPhrase(tokens []token, words []string) int {
+	count := 0
+	for i := range tokens {
+		if phraseAt(tokens, words, i) {
+			count++
+		}
+	}
+	return count
+}
+
+// rank weighs the occurrences of the phrases per field, long fields are
+// dampened so a short title match outranks a long content match.
+func rank(fields map[string][]token, phrases [][]string) float64 {
+	var r float64
+	for key, tokens := range fields {
+		if len(tokens) == 0 {
+			continue
+		}
+		occurrences := 0
+		for _, words := range phrases {
+			occurrences += countPhrase(tokens, words)
+		}
+		r += searchWeights[key] * float64(occurrences) / math.Log2(float64(len(tokens))+1)
+	}
+	return r
+}
+
+func highlights(doc internal.Document, fields map[string][]token, phrases [][]string) map[string]string {
+	texts := map[string]string{
+		internal.KeyTitle:   doc.Title,
+		internal.KeyAuthor:  doc.Author,
+		internal.KeyTopic:   doc.Topic,
+		internal.KeyContent: doc.Content,
+	}
+	result := map[string]string{}
+	for key, tokens := range fields {
+		marked := markPhrases(tokens, phrases)
+		if len(marked) == 0 {
+			continue
+		}
+		from, to := 0, len(tokens)
+		if key == internal.KeyContent {
+			// show a window of words around the first match only
+			from = marked[0] - snippetWords/2
+			if from < 0 {
+				from = 0
+			}
+			if to > from+snippetWords {
+				to = from + snippetWords
+			}
+		}
+		result[key] = highlight(texts[key], tokens, marked, from, to)
+	}
+	return result
+}
+
+// markPhrases returns the sorted indexes of the tokens belonging to a phrase.
+func markPhrases(tokens []token, phrases [][]string) []int {
+	marks := map[int]bool{}
+	for i := range tokens {
+		for _, words := range phrases {
+			if phraseAt(tokens, words, i) {
+				for j := range words {
+					marks[i+j] = true
+				}
+			}
+		}
+	}
+	indexes := make([]int, 0, len(marks))
+	for i := range marks {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// highlight renders the text of the tokens [from, to) with the marked tokens
+// wrapped in the highlight markers.
+func highlight(text string, tokens []token, marked []int, from, to int) string {
+	isMarked := map[int]bool{}
+	for _, i := range marked {
+		isMarked[i] = true
+	}
+	var b strings.Builder
+	start := 0
+	if from > 0 {
+		start = tokens[from].start
+		b.WriteString("...")
+	}
+	end := len(text)
+	if to < len(tokens) {
+		end = tokens[to-1].end
+	}
+	pos := start
+	for i := from; i < to; i++ {
+		if !isMarked[i] {
+			continue
+		}
+		b.WriteString(text[pos:tokens[i].start])
+		b.WriteString(internal.HighlightStart)
+		b.WriteString(text[tokens[i].start:tokens[i].end])
+		b.WriteString(internal.HighlightStop)
+		pos = tokens[i].end
+	}
+	b.WriteString(text[pos:end])
+	if end < len(text) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
@@ -0,0 +1,236 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"publisher/internal"
+	orm "publisher/internal/database"
+	"publisher/internal/util"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultRelayInterval is how often the relay looks for pending messages
+	// when it is idle.
+	DefaultRelayInterval = time.Second
+	// DefaultOutboxRetention is how long delivered and failed messages are
+	// kept by default.
+	DefaultOutboxRetention = 7 * 24 * time.Hour
+	// DefaultMaxAttempts is the number of deliveries of a message before it
+	// is given up as failed.
+	DefaultMaxAttempts = 10
+	// DefaultPublishTimeout limits a delivery of a message by default.
+	DefaultPublishTimeout = 10 * time.Second
+
+	// relayBatchSize is the number of messages claimed at once
+	relayBatchSize = 50
+	// relayLeaseMargin is added to the time needed to publish a batch, it
+	// covers recording the outcome of the deliveries
+	relayLeaseMargin = time.Minute
+	// maxRelayBackoff caps the delay between the attempts of a message
+	maxRelayBackoff = 10 * time.Minute
+)
+
+// Publisher delivers the events of the outbox to a consumer. An error
+// schedules another attempt, so a consumer may see an event more than once.
+type Publisher interface {
+	Publish(ctx context.Context, ev internal.Event) error
+}
+
+// recordOutbox adds the message reporting the event to the transaction, the
+// event must have been added before.
+func recordOutbox(ctx context.Context, tx Repository, ev *orm.Event) error {
+	msg, err := orm.NewOutboxMessage(ev)
+	if err != nil {
+		return err
+	}
+	return tx.AddOutboxMessage(ctx, msg)
+}
+
+// Relay publishes the messages of the outbox with at-least-once delivery: a
+// message is marked delivered only after the publisher accepted it, and a
+// message claimed by a relay which crashed is claimed again once its lease
+// ends. Messages are published in the order of their IDs, but a retried
+// message is passed by the ones after it.
+type Relay struct {
+	repo        Repository
+	publisher   Publisher
+	maxAttempts int
+	timeout     time.Duration
+}
+
+// NewRelay returns a relay publishing the outbox of repo, a message is given
+// up after maxAttempts deliveries, DefaultMaxAttempts if it is not positive.
+// A delivery is canceled after timeout, DefaultPublishTimeout if it is not
+// positive.
+func NewRelay(repo Repository, publisher Publisher, maxAttempts int, timeout time.Duration) *Relay {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	if timeout <= 0 {
+		timeout = DefaultPublishTimeout
+	}
+	return &Relay{repo: repo, publisher: publisher, maxAttempts: maxAttempts, timeout: timeout}
+}
+
+// lease returns how long a claimed batch is hidden from other relays, it
+// exceeds the time needed to publish the whole batch, so no other relay
+// claims a message while it is published.
+func (r *Relay) lease() time.Duration {
+	return relayBatchSize*r.timeout + relayLeaseMargin
+}
+
+// RelayStats counts the messages handled by RelayOnce.
+type RelayStats struct {
+	Delivered int
+	Retried   int
+	Failed    int
+}
+
+// RelayOnce claims one batch of pending messages and publishes them.
+func (r *Relay) RelayOnce(ctx context.Context) (RelayStats, error) {
+	var stats RelayStats
+	msgs, err := r.repo.ClaimOutboxMessages(ctx, time.Now(), relayBatchSize, r.lease())
+	if err != nil {
+		return stats, err
+	}
+	for i := range msgs {
+		msg := &msgs[i]
+		err := r.publish(ctx, msg)
+		if ctx.Err() != nil {
+			// the lease ends and the message is claimed again after a restart
+			return stats, ctx.Err()
+		}
+		now := time.Now()
+		var count *int
+		switch {
+		case err == nil:
+			msg.Status, msg.DeliveredAt, msg.LastError = orm.OutboxDelivered, &now, ""
+			count = &stats.Delivered
+		case msg.Attempts >= r.maxAttempts:
+			msg.Status, msg.NextAttemptAt, msg.LastError = orm.OutboxFailed, now, err.Error()
+			count = &stats.Failed
+			logger.Log("outbox", "relay", "message", msg.ID, "ticketID", msg.TicketID, "attempts", msg.Attempts, "err", err)
+		default:
+			msg.NextAttemptAt, msg.LastError = now.Add(relayBackoff(msg.Attempts)), err.Error()
+			count = &stats.Retried
+		}
+		err = r.repo.UpdateOutboxMessage(ctx, msg)
+		if errors.Is(err, util.ErrConflict) {
+			// the lease ended and another relay claimed the message, its
+			// outcome is recorded by that relay
+			logger.Log("outbox", "relay", "message", msg.ID, "ticketID", msg.TicketID, "msg", "lease lost")
+			continue
+		}
+		if err != nil {
+			return stats, err
+		}
+		*count++
+	}
+	return stats, nil
+}
+
+// publish delivers the message within the timeout of the relay.
+func (r *Relay) publish(ctx context.Context, msg *orm.OutboxMessage) error {
+	ev, err := msg.Event()
+	if err != nil {
+		return fmt.Errorf("decode message %d: %w", msg.ID, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.publisher.Publish(ctx, ev)
+}
+
+// Run relays the outbox until the context is canceled. A full batch is
+// followed by the next one at once, otherwise the relay waits for interval.
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultRelayInterval
+	}
+	for {
+		stats, err := r.RelayOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Log("outbox", "relay", "err", err)
+		}
+		if err == nil && stats.Delivered+stats.Retried+stats.Failed == relayBatchSize {
+			continue
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// relayBackoff returns the delay before the next attempt of a message which
+// failed attempts times, doubling from a second up to maxRelayBackoff.
+func relayBackoff(attempts int) time.Duration {
+	d := time.Second
+	for i := 1; i < attempts && d < maxRelayBackoff; i++ {
+		d *= 2
+	}
+	if d > maxRelayBackoff {
+		d = maxRelayBackoff
+	}
+	return d
+}
+
+// PurgeOutbox deletes the messages delivered or failed longer than the
+// retention ago and returns their number, pending messages are kept.
+func PurgeOutbox(ctx context.Context, repo Repository, retention time.Duration) (int, error) {
+	return repo.PurgeOutboxMessages(ctx, time.Now().Add(-retention))
+}
+
+// WebhookPublisher posts the events as JSON to a URL, any status but 2xx
+// is a failed delivery. The Idempotency-Key header carries the offset of the
+// event, so the consumer can detect redelivered events, and the
+// internal.EventSignatureHeader the signature of the body by the secret.
+type WebhookPublisher struct {
+	URL    string
+	Secret []byte
+	Client *http.Client
+}
+
+// NewWebhookPublisher returns a publisher posting to url events signed by
+// the secret, with the timeout per request.
+func NewWebhookPublisher(url string, secret []byte, timeout time.Duration) *WebhookPublisher {
+	return &WebhookPublisher{URL: url, Secret: secret, Client: &http.Client{Timeout: timeout}}
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, ev internal.Event) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Idempotency-Key", "event-"+strconv.FormatInt(ev.Offset, 10))
+	req.Header.Set(internal.EventSignatureHeader, internal.SignEvent(p.Secret, b))
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook responded %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"publisher/internal"
+	orm "publisher/internal/database"
+	"publisher/internal/util"
+	"testing"
+	"time"
+)
+
+// publisherFunc publishes the events by calling the function.
+type publisherFunc func(ctx context.Context, ev internal.Event) error
+
+func (f publisherFunc) Publish(ctx context.Context, ev internal.Event) error {
+	return f(ctx, ev)
+}
+
+// newTestOutbox returns a memory repository with one pending message.
+func newTestOutbox(t *testing.T) (*memoryRepository, *orm.OutboxMessage) {
+	t.Helper()
+	ctx := context.Background()
+	repo := NewMemoryRepository().(*memoryRepository)
+	ev := orm.NewEvent(internal.EventRemoved, orm.NewDocument("a", &internal.Document{Title: "Title"}), "editor")
+	if err := repo.AddEvent(ctx, ev); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := orm.NewOutboxMessage(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddOutboxMessage(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	return repo, msg
+}
+
+func TestRelayLease(t *testing.T) {
+	repo, msg := newTestOutbox(t)
+	timeout := 3 * time.Second
+	var published int
+	relay := NewRelay(repo, publisherFunc(func(ctx context.Context, ev internal.Event) error {
+		published++
+		// the batch stays claimed while all of it may be published
+		if lease := time.Until(repo.state.Outbox[msg.ID].NextAttemptAt); lease < relayBatchSize*timeout {
+			t.Errorf("message leased for %v, want at least %v", lease, relayBatchSize*timeout)
+		}
+		if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > timeout {
+			t.Errorf("delivery has deadline %v, %v, want within %v", deadline, ok, timeout)
+		}
+		return nil
+	}), 0, timeout)
+
+	stats, err := relay.RelayOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published != 1 || stats != (RelayStats{Delivered: 1}) {
+		t.Errorf("RelayOnce published %d messages with %+v", published, stats)
+	}
+	if stored := repo.state.Outbox[msg.ID]; stored.Status != orm.OutboxDelivered || stored.DeliveredAt == nil {
+		t.Errorf("message after the delivery: %+v", stored)
+	}
+}
+
+func TestRelayLostLease(t *testing.T) {
+	ctx := context.Background()
+	repo, msg := newTestOutbox(t)
+	failed := errors.New("the consumer is unavailable")
+	relay := NewRelay(repo, publisherFunc(func(ctx context.Context, ev internal.Event) error {
+		// the lease ends while the message is published, another relay
+		// claims and delivers it
+		claimed, err := repo.ClaimOutboxMessages(ctx, time.Now().Add(time.Hour), 10, time.Minute)
+		if err != nil || len(claimed) != 1 {
+			t.Fatalf("claim of the leased message = %+v, %v", claimed, err)
+		}
+		now := time.Now()
+		claimed[0].Status, claimed[0].DeliveredAt = orm.OutboxDelivered, &now
+		if err := repo.UpdateOutboxMessage(ctx, &claimed[0]); err != nil {
+			t.Fatal(err)
+		}
+		return failed
+	}), 0, 0)
+
+	stats, err := relay.RelayOnce(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (RelayStats{}) {
+		t.Errorf("RelayOnce counted %+v for a message whose lease was lost", stats)
+	}
+	stored := repo.state.Outbox[msg.ID]
+	if stored.Status != orm.OutboxDelivered || stored.Attempts != 2 || stored.LastError != "" {
+		t.Errorf("message after the stale relay: %+v", stored)
+	}
+}
+
+func TestUpdateOutboxMessageConflict(t *testing.T) {
+	ctx := context.Background()
+	repo, msg := newTestOutbox(t)
+	stale, err := repo.ClaimOutboxMessages(ctx, time.Now(), 10, 0)
+	if err != nil || len(stale) != 1 {
+		t.Fatalf("ClaimOutboxMessages = %+v, %v", stale, err)
+	}
+	fresh, err := repo.ClaimOutboxMessages(ctx, time.Now(), 10, time.Minute)
+	if err != nil || len(fresh) != 1 {
+		t.Fatalf("ClaimOutboxMessages = %+v, %v", fresh, err)
+	}
+	if err := repo.UpdateOutboxMessage(ctx, &stale[0]); !errors.Is(err, util.ErrConflict) {
+		t.Errorf("update of a message claimed again returned %v, want %v", err, util.ErrConflict)
+	}
+
+	fresh[0].Status = orm.OutboxFailed
+	if err := repo.UpdateOutboxMessage(ctx, &fresh[0]); err != nil {
+		t.Fatal(err)
+	}
+	fresh[0].Status = orm.OutboxPending
+	if err := repo.UpdateOutboxMessage(ctx, &fresh[0]); !errors.Is(err, util.ErrConflict) {
+		t.Errorf("update of a failed message returned %v, want %v", err, util.ErrConflict)
+	}
+	if stored := repo.state.Outbox[msg.ID]; stored.Status != orm.OutboxFailed {
+		t.Errorf("message after the updates: %+v", stored)
+	}
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"publisher/internal"
+	"publisher/internal/util"
+	"testing"
+)
+
+func addTitled(t *testing.T, svc Service, titles ...string) {
+	t.Helper()
+	for _, title := range titles {
+		if _, err := svc.Add(context.Background(), &internal.Document{Title: title, Author: "Author", Topic: "Topic"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGetPagesWithInserts(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		name    string
+		filters []internal.Filter
+	}{
+		{"row id", nil},
+		{"title", []internal.Filter{{Key: internal.KeyTitle}}},
+		{"title desc", []internal.Filter{{Key: internal.KeyTitle, Sort: internal.Desc}}},
+		{"created desc", []internal.Filter{{Key: internal.KeyCreatedAt, Sort: internal.Desc}}},
+		{"author and title", []internal.Filter{{Key: internal.KeyAuthor}, {Key: internal.KeyTitle, Sort: internal.Desc}}},
+	} {
+		svc := newTestService(t)
+		var want []string
+		for i := 0; i < 10; i++ {
+			want = append(want, fmt.Sprintf("m%02d", i))
+		}
+		addTitled(t, svc, want...)
+
+		seen := map[string]int{}
+		query := internal.Query{Filters: tt.filters, PageSize: 3}
+		for n := 0; ; n++ {
+			page, err := svc.Get(ctx, query)
+			if err != nil {
+				t.Fatalf("%s: Get page %d returned %v", tt.name, n, err)
+			}
+			if len(page.Documents) > query.PageSize {
+				t.Errorf("%s: page %d has %d documents, want at most %d", tt.name, n, len(page.Documents), query.PageSize)
+			}
+			for _, doc := range page.Documents {
+				seen[doc.Title]++
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			// documents sorted before and after the cursor are added between
+			// the pages
+			addTitled(t, svc, fmt.Sprintf("a%02d", n), fmt.Sprintf("z%02d", n))
+			query.Cursor = page.NextCursor
+		}
+		for _, title := range want {
+			if seen[title] != 1 {
+				t.Errorf("%s: %s was returned %d times, want once", tt.name, title, seen[title])
+			}
+		}
+		for title, n := range seen {
+			if n > 1 {
+				t.Errorf("%s: %s was returned %d times", tt.name, title, n)
+			}
+		}
+	}
+}
+
+func TestGetTotal(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	addTitled(t, svc, "a", "b", "c", "d", "e")
+
+	query := internal.Query{Filters: []internal.Filter{{Key: internal.KeyTitle, Op: internal.OpNeq, Value: "c"}}, PageSize: 2}
+	for n := 0; ; n++ {
+		page, err := svc.Get(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the total counts every match, not the rest after the cursor
+		if page.Total != 4 {
+			t.Errorf("page %d: total %d, want 4", n, page.Total)
+		}
+		if page.NextCursor == "" {
+			if n != 1 {
+				t.Errorf("got %d pages, want 2", n+1)
+			}
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func TestGetInvalidCursor(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	addTitled(t, svc, "a", "b", "c")
+
+	byTitle := []internal.Filter{{Key: internal.KeyTitle}}
+	byCreated := []internal.Filter{{Key: internal.KeyCreatedAt}}
+	page, err := svc.Get(ctx, internal.Query{Filters: byTitle, PageSize: 1})
+	if err != nil || page.NextCursor == "" {
+		t.Fatalf("Get returned %+v, %v", page, err)
+	}
+	encode := func(c internal.Cursor) string { return c.Encode() }
+	raw := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	for _, tt := range []struct {
+		name    string
+		filters []internal.Filter
+		cursor  string
+	}{
+		{"no base64", byTitle, "not a cursor!"},
+		{"no json", byTitle, raw("not json")},
+		{"other filters", []internal.Filter{{Key: internal.KeyAuthor}}, page.NextCursor},
+		{"no filters", nil, page.NextCursor},
+		{"tampered fingerprint", byTitle, encode(internal.Cursor{Values: []string{"a"}, ID: 1, Query: "0000000000000000"})},
+		{"too many values", byTitle, encode(internal.Cursor{Values: []string{"a", "b"}, ID: 1, Query: internal.Fingerprint(byTitle)})},
+		{"too few values", byTitle, encode(internal.Cursor{ID: 1, Query: internal.Fingerprint(byTitle)})},
+		{"invalid time", byCreated, encode(internal.Cursor{Values: []string{"yesterday"}, ID: 1, Query: internal.Fingerprint(byCreated)})},
+	} {
+		if _, err := svc.Get(ctx, internal.Query{Filters: tt.filters, PageSize: 1, Cursor: tt.cursor}); !errors.Is(err, util.ErrInvalidArgument) {
+			t.Errorf("%s: Get returned %v, want %v", tt.name, err, util.ErrInvalidArgument)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"publisher/internal"
+	orm "publisher/internal/database"
+	"time"
+)
+
+// Repository stores the documents of the database service. Methods return
+// util.ErrUnknown for tickets which do not exist and util.ErrInvalidArgument
+// for invalid filters or cursors.
+type Repository interface {
+	// Transaction runs fn with a repository bound to a single transaction,
+	// every change made through tx is rolled back if fn returns an error.
+	Transaction(ctx context.Context, fn func(tx Repository) error) error
+
+	Create(ctx context.Context, row *orm.Document) error
+	// Find returns the document of the ticket, inside a transaction the row
+	// stays locked until the transaction ends.
+	Find(ctx context.Context, ticketID string, includeDeleted bool) (*orm.Document, error)
+	// Save writes every column of a row returned by Find.
+	Save(ctx context.Context, row *orm.Document) error
+	List(ctx context.Context, query internal.Query) (internal.Page, error)
+	Search(ctx context.Context, query internal.SearchQuery) ([]internal.SearchResult, error)
+
+	// Remove marks the document as deleted.
+	Remove(ctx context.Context, ticketID string) error
+	// Restore clears the deleted mark, it is a no-op for active documents.
+	Restore(ctx context.Context, ticketID string) error
+	// Purge deletes the document permanently, together with its revisions
+	// and its watermark job.
+	Purge(ctx context.Context, ticketID string) error
+
+	// AddRevision stores a new revision, revisions are never changed afterwards.
+	AddRevision(ctx context.Context, rev *orm.Revision) error
+	// Revisions returns the revisions of the ticket ordered by number.
+	Revisions(ctx context.Context, ticketID string) ([]orm.Revision, error)
+	FindRevision(ctx context.Context, ticketID string, number int64) (*orm.Revision, error)
+
+	// IndexContent replaces the text the search matches against the content
+	// of the document, the content itself is kept in the blob store.
+	IndexContent(ctx context.Context, ticketID, text string) error
+	// ContentDigests returns the digests referenced by any document or revision.
+	ContentDigests(ctx context.Context) (map[string]bool, error)
+
+	// SaveFingerprint replaces the fingerprint of the content of the document.
+	SaveFingerprint(ctx context.Context, fp *orm.Fingerprint) error
+	// FindFingerprint returns the fingerprint of the document, util.ErrUnknown
+	// if it has none.
+	FindFingerprint(ctx context.Context, ticketID string) (*orm.Fingerprint, error)
+	// SimilarFingerprints returns the fingerprints of the other documents which
+	// are not removed and share the content digest or a band with fp.
+	SimilarFingerprints(ctx context.Context, fp *orm.Fingerprint) ([]orm.Fingerprint, error)
+	// MissingFingerprints returns up to limit documents which have content but
+	// no fingerprint, e.g. documents stored before fingerprints existed.
+	MissingFingerprints(ctx context.Context, limit int) ([]orm.Document, error)
+
+	// AddEvent appends the event and sets its ID to the offset of the event.
+	// Inside a transaction events are committed in the order of their offsets.
+	AddEvent(ctx context.Context, ev *orm.Event) error
+	// Events returns up to limit events with an offset greater than after,
+	// ordered by offset.
+	Events(ctx context.Context, after int64, limit int) ([]orm.Event, error)
+	// LastEventOffset returns the offset of the latest event, also if it was
+	// purged, 0 without events.
+	LastEventOffset(ctx context.Context) (int64, error)
+	// FirstEventOffset returns the offset of the oldest retained event, 0
+	// without events.
+	FirstEventOffset(ctx context.Context) (int64, error)
+	// PurgeEvents deletes the events created before the time and returns
+	// their number.
+	PurgeEvents(ctx context.Context, before time.Time) (int, error)
+
+	// FindWatermarkJob returns the watermark job of the ticket, util.ErrUnknown
+	// if no watermark was requested.
+	FindWatermarkJob(ctx context.Context, ticketID string) (*orm.WatermarkJob, error)
+	// SaveWatermarkJob creates or replaces the watermark job of the ticket.
+	SaveWatermarkJob(ctx context.Context, job *orm.WatermarkJob) error
+
+	// AddOutboxMessage stores a pending message, it is called in the
+	// transaction of the change the message reports.
+	AddOutboxMessage(ctx context.Context, msg *orm.OutboxMessage) error
+	// ClaimOutboxMessages returns up to limit pending messages due at now,
+	// ordered by ID. It counts an attempt for each and hides them from other
+	// claims until now+lease, so messages of a crashed relay are claimed
+	// again once the lease ends.
+	ClaimOutboxMessages(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]orm.OutboxMessage, error)
+	// UpdateOutboxMessage writes the delivery state of a claimed message. It
+	// returns util.ErrConflict if the message was claimed again or is no
+	// longer pending, e.g. after the lease ended.
+	UpdateOutboxMessage(ctx context.Context, msg *orm.OutboxMessage) error
+	// PurgeOutboxMessages deletes the messages delivered or failed before the
+	// time and returns their number.
+	PurgeOutboxMessages(ctx context.Context, before time.Time) (int, error)
+
+	// FindIdempotencyKey returns the key of the scope, expired keys are
+	// reported as util.ErrUnknown.
+	FindIdempotencyKey(ctx context.Context, scope, key string) (*orm.IdempotencyKey, error)
+	// CreateIdempotencyKey stores the key unless a key of the scope which has
+	// not expired exists, it reports whether the key was stored. Expired keys
+	// are replaced.
+	CreateIdempotencyKey(ctx context.Context, row *orm.IdempotencyKey) (bool, error)
+	// PurgeIdempotencyKeys deletes the keys which expired before the time and
+	// returns their number.
+	PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error)
+
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Names of the storage backends of the database node.
+const (
+	BackendPostgres = "postgres"
+	BackendMemory   = "memory"
+	BackendFile     = "file"
+)
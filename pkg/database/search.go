@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"publisher/internal"
+	orm "publisher/internal/database"
+	"publisher/internal/util"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Searcher ranks the documents by their relevance to a full text query.
+type Searcher interface {
+	Search(ctx context.Context, query internal.SearchQuery) ([]internal.SearchResult, error)
+}
+
+type postgresSearcher struct {
+	db *gorm.DB
+}
+
+// NewPostgresSearcher returns a Searcher backed by the tsvector index of the
+// documents table.
+func NewPostgresSearcher(db *gorm.DB) Searcher {
+	return &postgresSearcher{db: db}
+}
+
+// searchRow is a document row extended by the rank and the highlights.
+type searchRow struct {
+	orm.Document
+	Rank            float64
+	TitleHighlight  string
+	AuthorHighlight string
+	TopicHighlight  string
+}
+
+func (p *postgresSearcher) Search(ctx context.Context, query internal.SearchQuery) ([]internal.SearchResult, error) {
+	if strings.TrimSpace(query.Text) == "" {
+		return []internal.SearchResult{}, util.ErrInvalidArgument
+	}
+	fieldOptions := fmt.Sprintf("StartSel=%s, StopSel=%s, HighlightAll=true", internal.HighlightStart, internal.HighlightStop)
+
+	var rows []searchRow
+	err := p.db.WithContext(ctx).Raw(`SELECT documents.*,
+			ts_rank(search_vector, q) AS rank,
+			ts_headline('simple', title, q, @field) AS title_highlight,
+			ts_headline('simple', author, q, @field) AS author_highlight,
+			ts_headline('simple', topic, q, @field) AS topic_highlight
+		FROM documents, websearch_to_tsquery('simple', @text) AS q
+		WHERE documents.deleted_at IS NULL AND search_vector @@ q
+		ORDER BY rank DESC, documents.id
+		LIMIT @limit`,
+		map[string]interface{}{
+			"text":  query.Text,
+			"field": fieldOptions,
+			"limit": query.MaxResults(),
+		}).Scan(&rows).Error
+	if err != nil {
+		logger.Log("method", "Search", "err", err)
+		return []internal.SearchResult{}, err
+	}
+
+	results := make([]internal.SearchResult, 0, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		highlights := map[string]string{}
+		for key, h := range map[string]string{
+			internal.KeyTitle:  row.TitleHighlight,
+			internal.KeyAuthor: row.AuthorHighlight,
+			internal.KeyTopic:  row.TopicHighlight,
+		} {
+			if strings.Contains(h, internal.HighlightStart) {
+				highlights[key] = h
+			}
+		}
+		results = append(results, internal.SearchResult{
+			Document:   row.Document.ToInternal(),
+			Rank:       row.Rank,
+			Highlights: highlights,
+		})
+	}
+	return results, nil
+}
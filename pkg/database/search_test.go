@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"publisher/internal"
+	"publisher/internal/util"
+	"publisher/pkg/blob"
+	"strings"
+	"testing"
+)
+
+// newSearchService returns a service on a memory repository with the
+// documents, keyed by their titles.
+func newSearchService(t *testing.T, docs ...internal.Document) (Service, map[string]string) {
+	t.Helper()
+	svc := NewService(NewMemoryRepository(), blob.NewMemoryStore(), WithDuplicatePolicy(DuplicatePolicy{}))
+	titles := map[string]string{}
+	for i := range docs {
+		ticketID, err := svc.Add(context.Background(), &docs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		titles[ticketID] = docs[i].Title
+	}
+	return svc, titles
+}
+
+var searchDocuments = []internal.Document{
+	{Title: "Go Concurrency Patterns", Author: "Rob Pike", Topic: "programming", Content: "Goroutines and channels compose."},
+	{Title: "Cooking for Engineers", Author: "Julia Child", Topic: "food", Content: "Let the dough go for an hour, the patterns of concurrency in a kitchen are many."},
+	{Title: "Patterns of Enterprise Applications", Author: "Martin Fowler", Topic: "architecture", Content: "Layering and domain logic."},
+	{Title: "Removed", Author: "Nobody", Topic: "go", Content: "go go go"},
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	svc, titles := newSearchService(t, searchDocuments...)
+	for ticketID, title := range titles {
+		if title == "Removed" {
+			if _, err := svc.Remove(ctx, ticketID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, tt := range []struct {
+		query string
+		limit int
+		want  []string
+	}{
+		// a title match outranks a match of the content
+		{"go", 0, []string{"Go Concurrency Patterns", "Cooking for Engineers"}},
+		{"GO", 0, []string{"Go Concurrency Patterns", "Cooking for Engineers"}},
+		{"go", 1, []string{"Go Concurrency Patterns"}},
+		// the words of a phrase must follow each other
+		{`"concurrency patterns"`, 0, []string{"Go Concurrency Patterns"}},
+		{"concurrency patterns", 0, []string{"Go Concurrency Patterns", "Cooking for Engineers"}},
+		{"patterns -go", 0, []string{"Patterns of Enterprise Applications"}},
+		// equal ranks keep the order the documents were added in
+		{"fowler or pike", 0, []string{"Go Concurrency Patterns", "Patterns of Enterprise Applications"}},
+		{"domain logic", 0, []string{"Patterns of Enterprise Applications"}},
+		{"missing", 0, []string{}},
+		{"-go", 0, []string{}},
+	} {
+		results, err := svc.Search(ctx, internal.SearchQuery{Text: tt.query, Limit: tt.limit})
+		if err != nil {
+			t.Errorf("Search(%q): %v", tt.query, err)
+			continue
+		}
+		got := make([]string, 0, len(results))
+		for i, r := range results {
+			got = append(got, titles[r.Document.TicketID])
+			if i > 0 && r.Rank > results[i-1].Rank {
+				t.Errorf("Search(%q): result %d ranks %v above %v", tt.query, i, r.Rank, results[i-1].Rank)
+			}
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	for _, query := range []string{"", "   ", `""`, "-"} {
+		if _, err := svc.Search(ctx, internal.SearchQuery{Text: query}); !errors.Is(err, util.ErrInvalidArgument) {
+			t.Errorf("Search(%q) returned %v, want %v", query, err, util.ErrInvalidArgument)
+		}
+	}
+}
+
+func TestSearchHighlights(t *testing.T) {
+	svc, _ := newSearchService(t, searchDocuments[:3]...)
+	for _, tt := range []struct {
+		query string
+		want  map[string]string
+	}{
+		{"go", map[string]string{internal.KeyTitle: "<mark>Go</mark> Concurrency Patterns"}},
+		{`"concurrency patterns" pike`, map[string]string{
+			internal.KeyTitle:  "Go <mark>Concurrency</mark> <mark>Patterns</mark>",
+			internal.KeyAuthor: "Rob <mark>Pike</mark>",
+		}},
+		{"programming", map[string]string{internal.KeyTopic: "<mark>programming</mark>"}},
+	} {
+		results, err := svc.Search(context.Background(), internal.SearchQuery{Text: tt.query})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) == 0 {
+			t.Fatalf("Search(%q) found nothing", tt.query)
+		}
+		got := results[0].Highlights
+		if len(got) != len(tt.want) {
+			t.Errorf("Search(%q) highlights %q, want %q", tt.query, got, tt.want)
+		}
+		for key, want := range tt.want {
+			if got[key] != want {
+				t.Errorf("Search(%q) highlights %s as %q, want %q", tt.query, key, got[key], want)
+			}
+		}
+	}
+}
+
+func TestMemorySearcherSnippet(t *testing.T) {
+	words := make([]string, 100)
+	for i := range words {
+		words[i] = "word"
+	}
+	words[50] = "needle"
+	m := NewMemorySearcher()
+	m.Index(internal.Document{TicketID: "a", Title: "Title", Content: strings.Join(words, " ")})
+	m.Index(internal.Document{TicketID: "b", Title: "Title", Content: "A needle at the start."})
+
+	results, err := m.Search(context.Background(), internal.SearchQuery{Text: "needle"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Search found %d documents, want 2", len(results))
+	}
+	snippets := map[string]string{}
+	for _, r := range results {
+		snippets[r.Document.TicketID] = r.Highlights[internal.KeyContent]
+	}
+	// the snippet is a window of words around the match
+	want := "..." + strings.Repeat("word ", snippetWords/2) + "<mark>needle</mark>" + strings.Repeat(" word", snippetWords/2-1) + "..."
+	if snippets["a"] != want {
+		t.Errorf("snippet of a long content = %q, want %q", snippets["a"], want)
+	}
+	if want := "A <mark>needle</mark> at the start."; snippets["b"] != want {
+		t.Errorf("snippet of a short content = %q, want %q", snippets["b"], want)
+	}
+	// the shorter content ranks higher
+	if results[0].Document.TicketID != "b" {
+		t.Errorf("Search ranked %q first, want %q", results[0].Document.TicketID, "b")
+	}
+
+	m.Remove("b")
+	if results, _ := m.Search(context.Background(), internal.SearchQuery{Text: "needle"}); len(results) != 1 {
+		t.Errorf("Search after Remove found %d documents, want 1", len(results))
+	}
+}
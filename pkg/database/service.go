@@ -2,15 +2,49 @@ package database
 
 import (
 	"context"
+	"io"
 	"publisher/internal"
 )
 
 type Service interface {
 	Add(ctx context.Context, doc *internal.Document) (string, error)
-	// Get the list of all documents
-	Get(ctx context.Context, filters ...internal.Filter) ([]internal.Document, error)
+	// Get a page of the documents matching the query
+	Get(ctx context.Context, query internal.Query) (internal.Page, error)
+	// Search the documents by relevance to a full text query
+	Search(ctx context.Context, query internal.SearchQuery) ([]internal.SearchResult, error)
 	Update(ctx context.Context, ticketID string, doc *internal.Document) (int, error)
+	// Remove marks the document as deleted, it can be brought back by Restore
 	Remove(ctx context.Context, ticketID string) (int, error)
+	Restore(ctx context.Context, ticketID string) (int, error)
+	// Purge deletes the document permanently
+	Purge(ctx context.Context, ticketID string) (int, error)
+	// History lists every revision of the document, oldest first
+	History(ctx context.Context, ticketID string) ([]internal.Revision, error)
+	Revision(ctx context.Context, ticketID string, number int64) (internal.Revision, error)
+	// Diff compares two revisions of the document field by field
+	Diff(ctx context.Context, ticketID string, from, to int64) (internal.Diff, error)
+	// Rollback restores the fields of an earlier revision as a new revision
+	Rollback(ctx context.Context, ticketID string, number int64) (internal.Revision, error)
+	// Import adds the documents read from rows, rejected rows are reported in
+	// the result and do not stop the import. Nothing is stored in a dry run.
+	Import(ctx context.Context, rows internal.DocumentReader, dryRun bool) (internal.ImportResult, error)
+	// Export reads every document matching the query, the caller must close it
+	Export(ctx context.Context, query internal.Query) (internal.DocumentReadCloser, error)
+	// PutContent streams the content of the document into the blob store
+	PutContent(ctx context.Context, ticketID string, r io.Reader, version int64) (internal.Document, error)
+	// OpenContent streams a byte range of the content, the caller must close it
+	OpenContent(ctx context.Context, ticketID string, offset, length int64) (internal.Content, error)
+	// FindSimilar returns the documents whose content is at least threshold
+	// similar to the content of the ticket, most similar first
+	FindSimilar(ctx context.Context, ticketID string, threshold float64) ([]internal.SimilarDocument, error)
+	// Watch streams the events of the changed documents after query.After,
+	// it waits for new events until the reader is closed
+	Watch(ctx context.Context, query internal.WatchQuery) (internal.EventReader, error)
+	// WatermarkJob returns the state of the watermark requested for the ticket
+	WatermarkJob(ctx context.Context, ticketID string) (internal.WatermarkJob, error)
+	// TransitionWatermark moves the watermark job of the ticket to another
+	// status, util.ErrConflict if its current status does not allow it
+	TransitionWatermark(ctx context.Context, ticketID string, change internal.WatermarkTransition) (internal.WatermarkJob, error)
 	ServiceStatus(ctx context.Context) (int, error)
 
 	// Validate(ctx context.Context, doc *internal.Document) (bool, error)
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"publisher/internal"
+	orm "publisher/internal/database"
+	"publisher/internal/util"
+	"publisher/pkg/blob"
+	"publisher/pkg/fingerprint"
+	"sort"
+	"time"
+)
+
+// DefaultSimilarityThreshold is the threshold of FindSimilar if none is given.
+const DefaultSimilarityThreshold = 0.8
+
+// DuplicatePolicy decides what happens to submitted content which is similar
+// to the content of another document.
+type DuplicatePolicy struct {
+	// Threshold is the similarity from which content counts as duplicate,
+	// 0 disables the check
+	Threshold float64
+	// Reject rejects duplicates with util.ErrConflict instead of flagging them
+	Reject bool
+}
+
+// DefaultDuplicatePolicy flags content which is at least 90% similar.
+var DefaultDuplicatePolicy = DuplicatePolicy{Threshold: 0.9}
+
+// WithDuplicatePolicy sets how Add and PutContent treat content similar to
+// the content of another document.
+func WithDuplicatePolicy(policy DuplicatePolicy) Option {
+	return func(d *dbService) {
+		d.duplicates = policy
+	}
+}
+
+// FindSimilar returns the documents whose content is at least threshold
+// similar to the content of the ticket, most similar first. A threshold of
+// 0 uses DefaultSimilarityThreshold.
+func (d *dbService) FindSimilar(ctx context.Context, ticketID string, threshold float64) ([]internal.SimilarDocument, error) {
+	similar := []internal.SimilarDocument{}
+	if threshold < 0 || threshold > 1 {
+		return similar, util.ErrInvalidArgument
+	}
+	if threshold == 0 {
+		threshold = DefaultSimilarityThreshold
+	}
+	if _, err := d.repo.Find(ctx, ticketID, false); err != nil {
+		return similar, d.logError("FindSimilar", ticketID, err)
+	}
+	fp, err := d.repo.FindFingerprint(ctx, ticketID)
+	if errors.Is(err, util.ErrUnknown) {
+		// the document has no content
+		return similar, nil
+	}
+	if err != nil {
+		return similar, d.logError("FindSimilar", ticketID, err)
+	}
+	matches, err := d.similar(ctx, d.repo, fp, threshold)
+	if err != nil {
+		return similar, d.logError("FindSimilar", ticketID, err)
+	}
+	for _, m := range matches {
+		row, err := d.repo.Find(ctx, m.TicketID, false)
+		if errors.Is(err, util.ErrUnknown) {
+			// removed meanwhile
+			continue
+		}
+		if err != nil {
+			return similar, d.logError("FindSimilar", ticketID, err)
+		}
+		m.Title = row.Title
+		similar = append(similar, m)
+	}
+	return similar, nil
+}
+
+// similar returns the documents whose fingerprint is at least threshold
+// similar to fp, most similar first.
+func (d *dbService) similar(ctx context.Context, repo Repository, fp *orm.Fingerprint, threshold float64) ([]internal.SimilarDocument, error) {
+	if fp.ContentDigest == "" {
+		return nil, nil
+	}
+	var sig fingerprint.Signature
+	if err := sig.UnmarshalBinary(fp.Signature); err != nil {
+		return nil, err
+	}
+	candidates, err := repo.SimilarFingerprints(ctx, fp)
+	if err != nil {
+		return nil, err
+	}
+	var matches []internal.SimilarDocument
+	for _, c := range candidates {
+		m := internal.SimilarDocument{TicketID: c.TicketID, Exact: c.ContentDigest == fp.ContentDigest}
+		if m.Exact {
+			m.Similarity = 1
+		} else {
+			var other fingerprint.Signature
+			if err := other.UnmarshalBinary(c.Signature); err != nil {
+				return nil, err
+			}
+			m.Similarity = fingerprint.Similarity(sig, other)
+		}
+		if m.Similarity >= threshold {
+			matches = append(matches, m)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Similarity != matches[j].Similarity {
+			return matches[i].Similarity > matches[j].Similarity
+		}
+		return matches[i].TicketID < matches[j].TicketID
+	})
+	return matches, nil
+}
+
+// screen applies the duplicate policy to the content of the row, whose
+// fingerprint is fp. Duplicates are flagged by setting SimilarTo to the most
+// similar document, or rejected.
+func (d *dbService) screen(ctx context.Context, tx Repository, row *orm.Document, fp *orm.Fingerprint) error {
+	if d.duplicates.Threshold <= 0 || fp == nil {
+		return nil
+	}
+	matches, err := d.similar(ctx, tx, fp, d.duplicates.Threshold)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		row.SimilarTo, row.Similarity = "", 0
+		return nil
+	}
+	best := matches[0]
+	if d.duplicates.Reject {
+		return fmt.Errorf("%w: the content is %.0f%% similar to ticket %s", util.ErrConflict, 100*best.Similarity, best.TicketID)
+	}
+	row.SimilarTo, row.Similarity = best.TicketID, best.Similarity
+	return nil
+}
+
+// indexContent makes the content of the ticket searchable and stores its
+// fingerprint.
+func (d *dbService) indexContent(ctx context.Context, tx Repository, fp *orm.Fingerprint, text string) error {
+	if err := tx.IndexContent(ctx, fp.TicketID, text); err != nil {
+		return err
+	}
+	return tx.SaveFingerprint(ctx, fp)
+}
+
+// newFingerprint returns the fingerprint of the indexed text of a content.
+func newFingerprint(ticketID, digest, text string) *orm.Fingerprint {
+	sig := fingerprint.New(text)
+	b, _ := sig.MarshalBinary()
+	return &orm.Fingerprint{
+		TicketID:      ticketID,
+		ContentDigest: digest,
+		Signature:     b,
+		Bands:         sig.Bands(),
+		UpdatedAt:     time.Now(),
+	}
+}
+
+// IndexFingerprints stores the fingerprints missing for documents with
+// content, e.g. of documents stored before fingerprints existed. It returns
+// the number of indexed documents.
+func IndexFingerprints(ctx context.Context, repo Repository, blobs blob.Store) (int, error) {
+	d := &dbService{repo: repo, blobs: blobs}
+	indexed := 0
+	for {
+		rows, err := repo.MissingFingerprints(ctx, 100)
+		if err != nil || len(rows) == 0 {
+			return indexed, err
+		}
+		for _, row := range rows {
+			text, err := d.indexedContent(ctx, row.ContentDigest)
+			if errors.Is(err, blob.ErrNotFound) {
+				// an empty fingerprint keeps the document from being retried
+				logger.Log("method", "IndexFingerprints", "ticketID", row.TicketID, "err", err)
+				text = ""
+			} else if err != nil {
+				return indexed, err
+			}
+			if err := repo.SaveFingerprint(ctx, newFingerprint(row.TicketID, row.ContentDigest, text)); err != nil {
+				return indexed, err
+			}
+			indexed++
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"publisher/internal"
+	orm "publisher/internal/database"
+	"publisher/internal/util"
+	"publisher/pkg/blob"
+	"strings"
+	"testing"
+)
+
+// numberedWords returns a text of the numbered words from first to last.
+func numberedWords(first, last int) string {
+	w := make([]string, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		w = append(w, fmt.Sprintf("word%d", i))
+	}
+	return strings.Join(w, " ")
+}
+
+func TestScreen(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	svc := NewService(repo, blob.NewMemoryStore(), WithDuplicatePolicy(DuplicatePolicy{}))
+	original, err := svc.Add(ctx, &internal.Document{Title: "Original", Content: numberedWords(0, 199)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Add(ctx, &internal.Document{Title: "Unrelated", Content: numberedWords(1000, 1199)}); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		exact   = numberedWords(0, 199)
+		near    = numberedWords(0, 189) + " changed ending here"
+		distant = numberedWords(100, 299)
+	)
+	for _, tt := range []struct {
+		name       string
+		policy     DuplicatePolicy
+		content    string
+		similarTo  string
+		similarity float64
+		err        error
+	}{
+		{"exact", DuplicatePolicy{Threshold: 0.9}, exact, original, 1, nil},
+		{"near", DuplicatePolicy{Threshold: 0.9}, near, original, 0.9, nil},
+		{"distant", DuplicatePolicy{Threshold: 0.9}, distant, "", 0, nil},
+		{"low threshold", DuplicatePolicy{Threshold: 0.2}, distant, original, 0.2, nil},
+		// without a threshold the row is left as it is
+		{"disabled", DuplicatePolicy{}, exact, "stale", 0.5, nil},
+		{"reject exact", DuplicatePolicy{Threshold: 0.9, Reject: true}, exact, "", 0, util.ErrConflict},
+		{"reject near", DuplicatePolicy{Threshold: 0.9, Reject: true}, near, "", 0, util.ErrConflict},
+		{"reject distant", DuplicatePolicy{Threshold: 0.9, Reject: true}, distant, "", 0, nil},
+		{"reject disabled", DuplicatePolicy{Reject: true}, exact, "stale", 0.5, nil},
+	} {
+		d := NewService(repo, blob.NewMemoryStore(), WithDuplicatePolicy(tt.policy)).(*dbService)
+		ref, err := d.blobs.Put(ctx, strings.NewReader(tt.content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a stale flag is cleared when the content is no duplicate any more
+		row := &orm.Document{TicketID: "new", SimilarTo: "stale", Similarity: 0.5}
+		err = d.screen(ctx, repo, row, newFingerprint("new", ref.Digest, tt.content))
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: screen returned %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err != nil {
+			if !strings.Contains(err.Error(), original) {
+				t.Errorf("%s: screen returned %v, want the ticket %s named", tt.name, err, original)
+			}
+			continue
+		}
+		if row.SimilarTo != tt.similarTo || row.Similarity < tt.similarity || (tt.similarTo == "" && row.Similarity != 0) {
+			t.Errorf("%s: flagged %q with %.2f, want %q with at least %.2f", tt.name, row.SimilarTo, row.Similarity, tt.similarTo, tt.similarity)
+		}
+	}
+}
+
+func TestAddDuplicate(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	content := numberedWords(0, 199)
+	flagging := NewService(repo, blob.NewMemoryStore(), WithDuplicatePolicy(DuplicatePolicy{Threshold: 0.9}))
+	original, err := flagging.Add(ctx, &internal.Document{Title: "Original", Content: content})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copied, err := flagging.Add(ctx, &internal.Document{Title: "Copy", Content: content})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := flagging.Get(ctx, internal.Query{Filters: []internal.Filter{{Key: internal.KeyTicketID, Value: copied}}})
+	if err != nil || len(page.Documents) != 1 {
+		t.Fatalf("Get returned %+v, %v", page, err)
+	}
+	if doc := page.Documents[0]; doc.SimilarTo != original || doc.Similarity != 1 {
+		t.Errorf("copy flagged %q with %v, want %q with 1", doc.SimilarTo, doc.Similarity, original)
+	}
+
+	rejecting := NewService(repo, blob.NewMemoryStore(), WithDuplicatePolicy(DuplicatePolicy{Threshold: 0.9, Reject: true}))
+	if _, err := rejecting.Add(ctx, &internal.Document{Title: "Rejected", Content: content}); !errors.Is(err, util.ErrConflict) {
+		t.Errorf("Add of a duplicate returned %v, want %v", err, util.ErrConflict)
+	}
+	if n := countDocuments(t, rejecting); n != 2 {
+		t.Errorf("%d documents after the rejected Add, want 2", n)
+	}
+	if _, err := rejecting.Add(ctx, &internal.Document{Title: "Accepted", Content: numberedWords(500, 699)}); err != nil {
+		t.Errorf("Add of other content returned %v", err)
+	}
+}
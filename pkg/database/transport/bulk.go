@@ -0,0 +1,221 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"publisher/internal"
+	"publisher/internal/util"
+	"strconv"
+	"strings"
+)
+
+const (
+	contentTypeNDJSON = "application/x-ndjson"
+	contentTypeCSV    = "text/csv"
+)
+
+// maxLineSize bounds a single NDJSON row.
+const maxLineSize = 16 << 20
+
+// flushRows is the number of exported rows written between two flushes.
+const flushRows = 100
+
+// csvColumns are the columns of an export, imports also accept any subset.
+var csvColumns = []string{
+	internal.KeyTicketID,
+	internal.KeyTitle,
+	internal.KeyAuthor,
+	internal.KeyTopic,
+	internal.KeyContent,
+	internal.KeyWatermark,
+	"version",
+}
+
+// bulkFormat returns the format of the format query parameter or of the
+// content type header, NDJSON is the default.
+func bulkFormat(r *http.Request, header string) (string, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		switch format {
+		case internal.FormatNDJSON, internal.FormatCSV:
+			return format, nil
+		}
+		return "", util.ErrInvalidArgument
+	}
+	for _, v := range strings.Split(r.Header.Get(header), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(v))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case contentTypeCSV:
+			return internal.FormatCSV, nil
+		case contentTypeNDJSON:
+			return internal.FormatNDJSON, nil
+		}
+	}
+	return internal.FormatNDJSON, nil
+}
+
+func bulkContentType(format string) string {
+	if format == internal.FormatCSV {
+		return contentTypeCSV + "; charset=utf-8"
+	}
+	return contentTypeNDJSON
+}
+
+// newDocumentDecoder returns a reader over the rows of the body.
+func newDocumentDecoder(format string, body io.Reader) (internal.DocumentReader, error) {
+	if format == internal.FormatCSV {
+		return newCSVDecoder(body)
+	}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64<<10), maxLineSize)
+	return &ndjsonDecoder{scanner: scanner}, nil
+}
+
+type ndjsonDecoder struct {
+	scanner *bufio.Scanner
+}
+
+func (d *ndjsonDecoder) Next() (*internal.Document, error) {
+	for d.scanner.Scan() {
+		line := bytes.TrimSpace(d.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var doc internal.Document
+		if err := json.Unmarshal(line, &doc); err != nil {
+			return nil, &internal.RowError{Err: "invalid JSON: " + err.Error()}
+		}
+		return &doc, nil
+	}
+	if err := d.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+type csvDecoder struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+// newCSVDecoder reads the header row, which names the column of each field.
+func newCSVDecoder(body io.Reader) (*csvDecoder, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, util.ErrInvalidArgument
+	}
+	known := map[string]bool{}
+	for _, c := range csvColumns {
+		known[c] = true
+	}
+	for i, c := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(c, "\ufeff"))
+		if !known[header[i]] {
+			return nil, fmt.Errorf("%w: unknown column %q", util.ErrInvalidArgument, c)
+		}
+	}
+	return &csvDecoder{reader: reader, columns: header}, nil
+}
+
+func (d *csvDecoder) Next() (*internal.Document, error) {
+	record, err := d.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &internal.RowError{Err: parseErr.Err.Error()}
+		}
+		return nil, err
+	}
+	if len(record) != len(d.columns) {
+		return nil, &internal.RowError{Err: fmt.Sprintf("expected %d fields, got %d", len(d.columns), len(record))}
+	}
+	var doc internal.Document
+	for i, c := range d.columns {
+		switch c {
+		case internal.KeyTicketID:
+			doc.TicketID = record[i]
+		case internal.KeyTitle:
+			doc.Title = record[i]
+		case internal.KeyAuthor:
+			doc.Author = record[i]
+		case internal.KeyTopic:
+			doc.Topic = record[i]
+		case internal.KeyContent:
+			doc.Content = record[i]
+		case internal.KeyWatermark:
+			doc.Watermark = record[i]
+		}
+	}
+	return &doc, nil
+}
+
+// documentEncoder writes the rows of an export.
+type documentEncoder interface {
+	Encode(doc *internal.Document) error
+	Flush() error
+}
+
+func newDocumentEncoder(format string, w io.Writer) documentEncoder {
+	if format == internal.FormatCSV {
+		return &csvEncoder{writer: csv.NewWriter(w)}
+	}
+	return &ndjsonEncoder{encoder: json.NewEncoder(w)}
+}
+
+type ndjsonEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(doc *internal.Document) error {
+	return e.encoder.Encode(doc)
+}
+
+func (e *ndjsonEncoder) Flush() error {
+	return nil
+}
+
+type csvEncoder struct {
+	writer *csv.Writer
+	header bool
+}
+
+func (e *csvEncoder) Encode(doc *internal.Document) error {
+	if !e.header {
+		e.header = true
+		if err := e.writer.Write(csvColumns); err != nil {
+			return err
+		}
+	}
+	return e.writer.Write([]string{
+		doc.TicketID,
+		doc.Title,
+		doc.Author,
+		doc.Topic,
+		doc.Content,
+		doc.Watermark,
+		strconv.FormatInt(doc.Version, 10),
+	})
+}
+
+func (e *csvEncoder) Flush() error {
+	if !e.header {
+		// an empty export still names its columns
+		e.header = true
+		if err := e.writer.Write(csvColumns); err != nil {
+			return err
+		}
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
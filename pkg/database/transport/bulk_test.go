@@ -0,0 +1,117 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"publisher/internal"
+	"publisher/internal/util"
+	"publisher/pkg/blob"
+	"publisher/pkg/database"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportDecoders(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		format  string
+		body    string
+		titles  []string
+		rowErrs []internal.RowError
+	}{
+		{"ndjson", internal.FormatNDJSON,
+			`{"title":"a","content":"x"}` + "\n\n" + `  {"title":"b"}  ` + "\n",
+			[]string{"a", "b"}, nil},
+		{"ndjson invalid rows", internal.FormatNDJSON,
+			`{"title":"a"}` + "\n" + `{"title":` + "\n" + `{"author":"nobody"}` + "\n" + `[1]` + "\n" + `{"title":"e"}`,
+			[]string{"a", "e"}, []internal.RowError{
+				{Row: 2, Err: "invalid JSON: unexpected end of JSON input"},
+				{Row: 3, Err: util.ErrInvalidArgument.Error() + ": title or content is required"},
+				{Row: 4, Err: "invalid JSON: json: cannot unmarshal array into Go value of type internal.Document"},
+			}},
+		{"csv", internal.FormatCSV,
+			"\ufefftitle, author ,content\na,x,\"quoted, with comma\"\nb,y,\"multi\nline\"\n",
+			[]string{"a", "b"}, nil},
+		{"csv invalid rows", internal.FormatCSV,
+			"title,author\na,x\nb\nc,y,z\nd,\"bare\"quote\n,nobody\nf,z\n",
+			[]string{"a", "f"}, []internal.RowError{
+				{Row: 2, Err: "expected 2 fields, got 1"},
+				{Row: 3, Err: "expected 2 fields, got 3"},
+				{Row: 4, Err: `extraneous or missing " in quoted-field`},
+				{Row: 5, Err: util.ErrInvalidArgument.Error() + ": title or content is required"},
+			}},
+	} {
+		rows, err := newDocumentDecoder(tt.format, strings.NewReader(tt.body))
+		if err != nil {
+			t.Errorf("%s: newDocumentDecoder returned %v", tt.name, err)
+			continue
+		}
+		svc := database.NewService(database.NewMemoryRepository(), blob.NewMemoryStore())
+		result, err := svc.Import(context.Background(), rows, false)
+		if err != nil {
+			t.Errorf("%s: Import returned %v", tt.name, err)
+			continue
+		}
+		if want := len(tt.titles) + len(tt.rowErrs); result.Rows != want || result.Imported != len(tt.titles) {
+			t.Errorf("%s: imported %d of %d rows, want %d of %d", tt.name, result.Imported, result.Rows, len(tt.titles), want)
+		}
+		if !reflect.DeepEqual(result.Errors, tt.rowErrs) {
+			t.Errorf("%s: row errors %+v, want %+v", tt.name, result.Errors, tt.rowErrs)
+		}
+		page, _ := svc.Get(context.Background(), internal.Query{Filters: []internal.Filter{{Key: internal.KeyTitle}}})
+		var titles []string
+		for _, doc := range page.Documents {
+			titles = append(titles, doc.Title)
+		}
+		if !reflect.DeepEqual(titles, tt.titles) {
+			t.Errorf("%s: stored %q, want %q", tt.name, titles, tt.titles)
+		}
+	}
+}
+
+func TestCSVDecoderHeader(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		body string
+	}{
+		{"empty", ""},
+		{"unknown column", "title,publisher\na,b\n"},
+		{"invalid header", "\"title\n"},
+	} {
+		if _, err := newDocumentDecoder(internal.FormatCSV, strings.NewReader(tt.body)); !errors.Is(err, util.ErrInvalidArgument) {
+			t.Errorf("%s: newDocumentDecoder returned %v, want %v", tt.name, err, util.ErrInvalidArgument)
+		}
+	}
+	rows, err := newDocumentDecoder(internal.FormatCSV, strings.NewReader("ticketID,title,author,topic,content,watermark,version\n"))
+	if err != nil {
+		t.Fatalf("newDocumentDecoder of the export columns returned %v", err)
+	}
+	if doc, err := rows.Next(); doc != nil || err == nil {
+		t.Errorf("Next without rows returned %+v, %v, want EOF", doc, err)
+	}
+}
+
+func TestBulkFormat(t *testing.T) {
+	for _, tt := range []struct {
+		query, contentType string
+		format             string
+		err                error
+	}{
+		{"", "", internal.FormatNDJSON, nil},
+		{"", "text/csv; charset=utf-8", internal.FormatCSV, nil},
+		{"", "application/x-ndjson", internal.FormatNDJSON, nil},
+		{"", "text/plain, text/csv", internal.FormatCSV, nil},
+		{"csv", "application/x-ndjson", internal.FormatCSV, nil},
+		{"xml", "", "", util.ErrInvalidArgument},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/import?format="+tt.query, nil)
+		r.Header.Set("Content-Type", tt.contentType)
+		format, err := bulkFormat(r, "Content-Type")
+		if format != tt.format || err != tt.err {
+			t.Errorf("bulkFormat(%q, %q) returned %q, %v, want %q, %v", tt.query, tt.contentType, format, err, tt.format, tt.err)
+		}
+	}
+}
@@ -2,49 +2,152 @@ package transport
 
 import (
 	"context"
+	"errors"
+	"io"
+	"net/http"
 	"publisher/api/v1/pb/db"
 	"publisher/internal"
+	"publisher/internal/util"
 	"publisher/pkg/database/endpoints"
+	"strings"
 
+	"github.com/go-kit/kit/endpoint"
 	grpctransport "github.com/go-kit/kit/transport/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type grpcServer struct {
 	add           grpctransport.Handler
 	get           grpctransport.Handler
+	search        grpctransport.Handler
 	update        grpctransport.Handler
 	remove        grpctransport.Handler
+	restore       grpctransport.Handler
+	purge         grpctransport.Handler
+	history       grpctransport.Handler
+	revision      grpctransport.Handler
+	diff          grpctransport.Handler
+	rollback      grpctransport.Handler
+	findSimilar   grpctransport.Handler
+	watermarkJob  grpctransport.Handler
+	transition    grpctransport.Handler
 	serviceStatus grpctransport.Handler
+	// grpctransport does not support streams, the streaming RPCs call the
+	// endpoints directly.
+	bulkAdd     endpoint.Endpoint
+	export      endpoint.Endpoint
+	putContent  endpoint.Endpoint
+	openContent endpoint.Endpoint
+	watch       endpoint.Endpoint
+	adminToken  string
 	// forward compatible implementations.
 	db.UnimplementedDatabaseServer
 }
 
-func NewGRPCServer(ep endpoints.Set) db.DatabaseServer {
+// NewGRPCServer returns the gRPC server of the endpoints, adminToken is
+// checked as in NewHTTPHandler against the authorization metadata.
+func NewGRPCServer(ep endpoints.Set, adminToken string) db.DatabaseServer {
+	options := []grpctransport.ServerOption{
+		grpctransport.ServerBefore(editorFromGRPC, adminFromGRPC(adminToken)),
+	}
 	return &grpcServer{
+		adminToken:  adminToken,
+		bulkAdd:     ep.ImportEndpoint,
+		export:      ep.ExportEndpoint,
+		watch:       ep.WatchEndpoint,
+		putContent:  ep.PutContentEndpoint,
+		openContent: ep.OpenContentEndpoint,
 		add: grpctransport.NewServer(
 			ep.AddEndpoint,
 			decodeGRPCAddRequest,
-			decodeGRPCAddResponse,
+			encodeGRPCAddResponse,
+			options...,
 		),
 		get: grpctransport.NewServer(
 			ep.GetEndpoint,
 			decodeGRPCGetRequest,
-			decodeGRPCGetResponse,
+			encodeGRPCGetResponse,
+			options...,
+		),
+		search: grpctransport.NewServer(
+			ep.SearchEndpoint,
+			decodeGRPCSearchRequest,
+			encodeGRPCSearchResponse,
+			options...,
 		),
 		update: grpctransport.NewServer(
 			ep.UpdateEndpoint,
 			decodeGRPCUpdateRequest,
-			decodeGRPCUpdateResponse,
+			encodeGRPCUpdateResponse,
+			options...,
 		),
 		remove: grpctransport.NewServer(
 			ep.RemoveEndpoint,
 			decodeGRPCRemoveRequest,
-			decodeGRPCRemoveResponse,
+			encodeGRPCRemoveResponse,
+			options...,
+		),
+		restore: grpctransport.NewServer(
+			ep.RestoreEndpoint,
+			decodeGRPCRestoreRequest,
+			encodeGRPCRestoreResponse,
+			options...,
+		),
+		purge: grpctransport.NewServer(
+			ep.PurgeEndpoint,
+			decodeGRPCPurgeRequest,
+			encodeGRPCPurgeResponse,
+			options...,
+		),
+		history: grpctransport.NewServer(
+			ep.HistoryEndpoint,
+			decodeGRPCHistoryRequest,
+			encodeGRPCHistoryResponse,
+			options...,
+		),
+		revision: grpctransport.NewServer(
+			ep.RevisionEndpoint,
+			decodeGRPCRevisionRequest,
+			encodeGRPCRevisionResponse,
+			options...,
+		),
+		diff: grpctransport.NewServer(
+			ep.DiffEndpoint,
+			decodeGRPCDiffRequest,
+			encodeGRPCDiffResponse,
+			options...,
+		),
+		rollback: grpctransport.NewServer(
+			ep.RollbackEndpoint,
+			decodeGRPCRollbackRequest,
+			encodeGRPCRollbackResponse,
+			options...,
+		),
+		findSimilar: grpctransport.NewServer(
+			ep.FindSimilarEndpoint,
+			decodeGRPCFindSimilarRequest,
+			encodeGRPCFindSimilarResponse,
+			options...,
+		),
+		watermarkJob: grpctransport.NewServer(
+			ep.WatermarkJobEndpoint,
+			decodeGRPCWatermarkJobRequest,
+			encodeGRPCWatermarkJobResponse,
+			options...,
+		),
+		transition: grpctransport.NewServer(
+			ep.TransitionWatermarkEndpoint,
+			decodeGRPCTransitionWatermarkRequest,
+			encodeGRPCWatermarkJobResponse,
+			options...,
 		),
 		serviceStatus: grpctransport.NewServer(
 			ep.ServiceStatusEndpoint,
 			decodeGRPCServiceStatusRequest,
-			decodeGRPCServiceStatusResponse,
+			encodeGRPCServiceStatusResponse,
 		),
 	}
 }
@@ -66,7 +169,12 @@ func decodeGRPCAddRequest(_ context.Context, grpcReq interface{}) (interface{},
 		Topic:     req.Document.Topic,
 		Watermark: req.Document.Watermark,
 	}
-	return endpoints.AddRequest{Document: doc}, nil
+	return endpoints.AddRequest{Document: doc, IdempotencyKey: req.IdempotencyKey}, nil
+}
+
+func encodeGRPCAddResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.AddResponse)
+	return &db.AddReply{TicketID: resp.TicketID, Err: resp.Err}, nil
 }
 
 func decodeGRPCAddResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
@@ -76,6 +184,9 @@ func decodeGRPCAddResponse(_ context.Context, grpcReply interface{}) (interface{
 
 func (g *grpcServer) Get(ctx context.Context, r *db.GetRequest) (*db.GetReply, error) {
 	_, rep, err := g.get.ServeGRPC(ctx, r)
+	if err == util.ErrInvalidArgument {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -84,11 +195,103 @@ func (g *grpcServer) Get(ctx context.Context, r *db.GetRequest) (*db.GetReply, e
 
 func decodeGRPCGetRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
 	req := grpcReq.(*db.GetRequest)
+	filters := decodeGRPCFilters(req.Filters)
+	if err := internal.ValidateFilters(filters); err != nil {
+		return nil, err
+	}
+	return endpoints.GetRequest{
+		Filters:        filters,
+		PageSize:       int(req.PageSize),
+		Cursor:         req.Cursor,
+		IncludeDeleted: req.IncludeDeleted,
+	}, nil
+}
+
+func decodeGRPCFilters(fs []*db.GetRequest_Filters) []internal.Filter {
 	var filters []internal.Filter
-	for _, f := range req.Filters {
-		filters = append(filters, internal.Filter{Key: f.Key, Value: f.Value})
+	for _, f := range fs {
+		filter := internal.Filter{
+			Key:    f.Key,
+			Value:  f.Value,
+			Op:     internal.Operator(f.Op),
+			Values: f.Values,
+			Sort:   internal.SortOrder(f.Sort),
+			Any:    decodeGRPCFilters(f.Any),
+			All:    decodeGRPCFilters(f.All),
+		}
+		if f.From != nil {
+			from := f.From.AsTime()
+			filter.From = &from
+		}
+		if f.To != nil {
+			to := f.To.AsTime()
+			filter.To = &to
+		}
+		filters = append(filters, filter)
+	}
+	return filters
+}
+
+func encodeGRPCGetResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.GetResponse)
+	var docs []*db.Document
+	for _, d := range resp.Documents {
+		docs = append(docs, encodeGRPCDocument(d))
+	}
+	return &db.GetReply{Documents: docs, Err: resp.Err, NextCursor: resp.NextCursor, Total: resp.Total}, nil
+}
+
+func encodeGRPCDocument(d internal.Document) *db.Document {
+	doc := &db.Document{
+		TicketID:  d.TicketID,
+		Content:   d.Content,
+		Title:     d.Title,
+		Author:    d.Author,
+		Topic:     d.Topic,
+		Watermark: d.Watermark,
+		Version:   d.Version,
+
+		ContentDigest: d.ContentDigest,
+		ContentSize:   d.ContentSize,
+		SimilarTo:     d.SimilarTo,
+		Similarity:    d.Similarity,
+	}
+	if d.DeletedAt != nil {
+		doc.DeletedAt = timestamppb.New(*d.DeletedAt)
 	}
-	return endpoints.GetRequest{Filters: filters}, nil
+	return doc
+}
+
+func (g *grpcServer) Search(ctx context.Context, r *db.SearchRequest) (*db.SearchReply, error) {
+	_, rep, err := g.search.ServeGRPC(ctx, r)
+	if err == util.ErrInvalidArgument {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*db.SearchReply), nil
+}
+
+func decodeGRPCSearchRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*db.SearchRequest)
+	if strings.TrimSpace(req.Text) == "" {
+		return nil, util.ErrInvalidArgument
+	}
+	return endpoints.SearchRequest{Text: req.Text, Limit: int(req.Limit)}, nil
+}
+
+func encodeGRPCSearchResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.SearchResponse)
+	var results []*db.SearchResult
+	for _, r := range resp.Results {
+		results = append(results, &db.SearchResult{
+			Document:   encodeGRPCDocument(r.Document),
+			Rank:       r.Rank,
+			Highlights: r.Highlights,
+		})
+	}
+	return &db.SearchReply{Results: results, Err: resp.Err}, nil
 }
 
 func decodeGRPCGetResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
@@ -96,6 +299,8 @@ func decodeGRPCGetResponse(_ context.Context, grpcReply interface{}) (interface{
 	var docs []internal.Document
 	for _, d := range reply.Documents {
 		doc := internal.Document{
+			TicketID:  d.TicketID,
+			Version:   d.Version,
 			Content:   d.Content,
 			Title:     d.Title,
 			Author:    d.Author,
@@ -104,7 +309,7 @@ func decodeGRPCGetResponse(_ context.Context, grpcReply interface{}) (interface{
 		}
 		docs = append(docs, doc)
 	}
-	return endpoints.GetResponse{Documents: docs, Err: reply.Err}, nil
+	return endpoints.GetResponse{Documents: docs, NextCursor: reply.NextCursor, Total: reply.Total, Err: reply.Err}, nil
 }
 
 func (g *grpcServer) Update(ctx context.Context, r *db.UpdateRequest) (*db.UpdateReply, error) {
@@ -112,11 +317,15 @@ func (g *grpcServer) Update(ctx context.Context, r *db.UpdateRequest) (*db.Updat
 	if err != nil {
 		return nil, err
 	}
-	return rep.(*db.UpdateReply), nil
+	reply := rep.(*db.UpdateReply)
+	return reply, codeError(reply.Code, reply.Err)
 }
 
 func decodeGRPCUpdateRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
 	req := grpcReq.(*db.UpdateRequest)
+	if req.Document == nil {
+		return nil, util.ErrInvalidArgument
+	}
 	doc := &internal.Document{
 		Content:   req.Document.Content,
 		Title:     req.Document.Title,
@@ -124,12 +333,17 @@ func decodeGRPCUpdateRequest(_ context.Context, grpcReq interface{}) (interface{
 		Topic:     req.Document.Topic,
 		Watermark: req.Document.Watermark,
 	}
-	return endpoints.UpdateRequest{TicketID: req.TicketID, Document: doc}, nil
+	return endpoints.UpdateRequest{TicketID: req.TicketID, Document: doc, Version: req.Version}, nil
+}
+
+func encodeGRPCUpdateResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.UpdateResponse)
+	return &db.UpdateReply{Code: int64(resp.Code), Version: resp.Version, Err: resp.Err}, nil
 }
 
 func decodeGRPCUpdateResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
 	reply := grpcReply.(*db.UpdateReply)
-	return endpoints.UpdateResponse{Code: int(reply.Code), Err: reply.Err}, nil
+	return endpoints.UpdateResponse{Code: int(reply.Code), Version: reply.Version, Err: reply.Err}, nil
 }
 
 func (g *grpcServer) Remove(ctx context.Context, r *db.RemoveRequest) (*db.RemoveReply, error) {
@@ -137,7 +351,8 @@ func (g *grpcServer) Remove(ctx context.Context, r *db.RemoveRequest) (*db.Remov
 	if err != nil {
 		return nil, err
 	}
-	return rep.(*db.RemoveReply), nil
+	reply := rep.(*db.RemoveReply)
+	return reply, codeError(reply.Code, reply.Err)
 }
 
 func decodeGRPCRemoveRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
@@ -145,13 +360,505 @@ func decodeGRPCRemoveRequest(_ context.Context, grpcReq interface{}) (interface{
 	return endpoints.RemoveRequest{TicketID: req.TicketID}, nil
 }
 
+func encodeGRPCRemoveResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.RemoveResponse)
+	return &db.RemoveReply{Code: int64(resp.Code), Err: resp.Err}, nil
+}
+
 func decodeGRPCRemoveResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
 	reply := grpcReply.(*db.RemoveReply)
 	return endpoints.RemoveResponse{Code: int(reply.Code), Err: reply.Err}, nil
 }
 
+func (g *grpcServer) Restore(ctx context.Context, r *db.RestoreRequest) (*db.RestoreReply, error) {
+	_, rep, err := g.restore.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	reply := rep.(*db.RestoreReply)
+	return reply, codeError(reply.Code, reply.Err)
+}
+
+func decodeGRPCRestoreRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*db.RestoreRequest)
+	return endpoints.RestoreRequest{TicketID: req.TicketID}, nil
+}
+
+func encodeGRPCRestoreResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.RestoreResponse)
+	return &db.RestoreReply{Code: int64(resp.Code), Err: resp.Err}, nil
+}
+
+func (g *grpcServer) Purge(ctx context.Context, r *db.PurgeRequest) (*db.PurgeReply, error) {
+	_, rep, err := g.purge.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	reply := rep.(*db.PurgeReply)
+	return reply, codeError(reply.Code, reply.Err)
+}
+
+func decodeGRPCPurgeRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*db.PurgeRequest)
+	return endpoints.PurgeRequest{TicketID: req.TicketID}, nil
+}
+
+func encodeGRPCPurgeResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.PurgeResponse)
+	return &db.PurgeReply{Code: int64(resp.Code), Err: resp.Err}, nil
+}
+
+// editorMetadata is the gRPC counterpart of the EditorHeader.
+const editorMetadata = "x-editor"
+
+func editorFromGRPC(ctx context.Context, md metadata.MD) context.Context {
+	if values := md.Get(editorMetadata); len(values) > 0 && values[0] != "" {
+		return internal.WithEditor(ctx, values[0])
+	}
+	return ctx
+}
+
+// adminFromGRPC is the gRPC counterpart of adminFromHTTP.
+func adminFromGRPC(adminToken string) grpctransport.ServerRequestFunc {
+	return func(ctx context.Context, md metadata.MD) context.Context {
+		if values := md.Get("authorization"); len(values) > 0 && isAdminToken(adminToken, values[0]) {
+			return internal.WithAdmin(ctx)
+		}
+		return ctx
+	}
+}
+
+func (g *grpcServer) History(ctx context.Context, r *db.HistoryRequest) (*db.HistoryReply, error) {
+	_, rep, err := g.history.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	reply := rep.(*db.HistoryReply)
+	return reply, codeError(reply.Code, reply.Err)
+}
+
+func decodeGRPCHistoryRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*db.HistoryRequest)
+	return endpoints.HistoryRequest{TicketID: req.TicketID}, nil
+}
+
+func encodeGRPCHistoryResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.HistoryResponse)
+	var revisions []*db.Revision
+	for _, r := range resp.Revisions {
+		revisions = append(revisions, encodeGRPCRevision(r))
+	}
+	return &db.HistoryReply{Revisions: revisions, Code: int64(resp.Code), Err: resp.Err}, nil
+}
+
+func encodeGRPCRevision(r internal.Revision) *db.Revision {
+	return &db.Revision{
+		TicketID:   r.TicketID,
+		Number:     r.Number,
+		Editor:     r.Editor,
+		CreatedAt:  timestamppb.New(r.CreatedAt),
+		Changes:    r.Changes,
+		RollbackOf: r.RollbackOf,
+		Document:   encodeGRPCDocument(r.Document),
+	}
+}
+
+func (g *grpcServer) Revision(ctx context.Context, r *db.RevisionRequest) (*db.RevisionReply, error) {
+	_, rep, err := g.revision.ServeGRPC(ctx, r)
+	if err == util.ErrInvalidArgument {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	reply := rep.(*db.RevisionReply)
+	return reply, codeError(reply.Code, reply.Err)
+}
+
+func decodeGRPCRevisionRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*db.RevisionRequest)
+	if req.Number <= 0 {
+		return nil, util.ErrInvalidArgument
+	}
+	return endpoints.RevisionRequest{TicketID: req.TicketID, Number: req.Number}, nil
+}
+
+func encodeGRPCRevisionResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.RevisionResponse)
+	reply := &db.RevisionReply{Code: int64(resp.Code), Err: resp.Err}
+	if resp.Err == "" {
+		reply.Revision = encodeGRPCRevision(resp.Revision)
+	}
+	return reply, nil
+}
+
+func (g *grpcServer) Diff(ctx context.Context, r *db.DiffRequest) (*db.DiffReply, error) {
+	_, rep, err := g.diff.ServeGRPC(ctx, r)
+	if err == util.ErrInvalidArgument {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	reply := rep.(*db.DiffReply)
+	return reply, codeError(reply.Code, reply.Err)
+}
+
+func decodeGRPCDiffRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*db.DiffRequest)
+	if req.From <= 0 || req.To <= 0 {
+		return nil, util.ErrInvalidArgument
+	}
+	return endpoints.DiffRequest{TicketID: req.TicketID, From: req.From, To: req.To}, nil
+}
+
+func encodeGRPCDiffResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.DiffResponse)
+	var changes []*db.FieldChange
+	for _, c := range resp.Diff.Changes {
+		changes = append(changes, &db.FieldChange{Key: c.Key, From: c.From, To: c.To})
+	}
+	return &db.DiffReply{Changes: changes, Code: int64(resp.Code), Err: resp.Err}, nil
+}
+
+func (g *grpcServer) FindSimilar(ctx context.Context, r *db.FindSimilarRequest) (*db.FindSimilarReply, error) {
+	_, rep, err := g.findSimilar.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	reply := rep.(*db.FindSimilarReply)
+	return reply, codeError(reply.Code, reply.Err)
+}
+
+func decodeGRPCFindSimilarRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*db.FindSimilarRequest)
+	return endpoints.FindSimilarRequest{TicketID: req.TicketID, Threshold: req.Threshold}, nil
+}
+
+func encodeGRPCFindSimilarResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.FindSimilarResponse)
+	var docs []*db.SimilarDocument
+	for _, d := range resp.Documents {
+		docs = append(docs, &db.SimilarDocument{TicketID: d.TicketID, Title: d.Title, Similarity: d.Similarity, Exact: d.Exact})
+	}
+	return &db.FindSimilarReply{Documents: docs, Code: int64(resp.Code), Err: resp.Err}, nil
+}
+
+func (g *grpcServer) WatermarkJob(ctx context.Context, r *db.WatermarkJobRequest) (*db.WatermarkJobReply, error) {
+	_, rep, err := g.watermarkJob.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	reply := rep.(*db.WatermarkJobReply)
+	return reply, codeError(reply.Code, reply.Err)
+}
+
+func (g *grpcServer) TransitionWatermark(ctx context.Context, r *db.TransitionWatermarkRequest) (*db.WatermarkJobReply, error) {
+	_, rep, err := g.transition.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	reply := rep.(*db.WatermarkJobReply)
+	return reply, codeError(reply.Code, reply.Err)
+}
+
+func decodeGRPCWatermarkJobRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*db.WatermarkJobRequest)
+	return endpoints.WatermarkJobRequest{TicketID: req.TicketID}, nil
+}
+
+func decodeGRPCTransitionWatermarkRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*db.TransitionWatermarkRequest)
+	change := internal.WatermarkTransition{To: internal.Status(req.To), Mark: req.Mark, Reason: req.Reason, Retry: req.Retry}
+	return endpoints.TransitionWatermarkRequest{TicketID: req.TicketID, WatermarkTransition: change}, nil
+}
+
+func encodeGRPCWatermarkJobResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.WatermarkJobResponse)
+	job := &db.WatermarkJob{
+		TicketID: resp.Job.TicketID,
+		Status:   string(resp.Job.Status),
+		Mark:     resp.Job.Mark,
+		Reason:   resp.Job.Reason,
+	}
+	if !resp.Job.CreatedAt.IsZero() {
+		job.CreatedAt = timestamppb.New(resp.Job.CreatedAt)
+		job.UpdatedAt = timestamppb.New(resp.Job.UpdatedAt)
+	}
+	if resp.Job.StartedAt != nil {
+		job.StartedAt = timestamppb.New(*resp.Job.StartedAt)
+	}
+	if resp.Job.FinishedAt != nil {
+		job.FinishedAt = timestamppb.New(*resp.Job.FinishedAt)
+	}
+	return &db.WatermarkJobReply{Job: job, Code: int64(resp.Code), Err: resp.Err}, nil
+}
+
+func (g *grpcServer) Rollback(ctx context.Context, r *db.RollbackRequest) (*db.RollbackReply, error) {
+	_, rep, err := g.rollback.ServeGRPC(ctx, r)
+	if err == util.ErrInvalidArgument {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	reply := rep.(*db.RollbackReply)
+	return reply, codeError(reply.Code, reply.Err)
+}
+
+func decodeGRPCRollbackRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*db.RollbackRequest)
+	if req.Number <= 0 {
+		return nil, util.ErrInvalidArgument
+	}
+	return endpoints.RollbackRequest{TicketID: req.TicketID, Number: req.Number}, nil
+}
+
+func encodeGRPCRollbackResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.RollbackResponse)
+	reply := &db.RollbackReply{Code: int64(resp.Code), Err: resp.Err}
+	if resp.Err == "" {
+		reply.Revision = encodeGRPCRevision(resp.Revision)
+	}
+	return reply, nil
+}
+
+// BulkAdd imports the documents of the stream, the dry run flag is read from
+// the first message.
+func (g *grpcServer) BulkAdd(stream db.Database_BulkAddServer) error {
+	ctx := stream.Context()
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = editorFromGRPC(ctx, md)
+	}
+	first, err := stream.Recv()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	rows := &grpcDocumentReader{stream: stream, next: first}
+	resp, err := g.bulkAdd(ctx, endpoints.ImportRequest{Rows: rows, DryRun: first.GetDryRun()})
+	if err != nil {
+		return err
+	}
+	importResp := resp.(endpoints.ImportResponse)
+	if err := codeError(int64(importResp.Code), importResp.Err); err != nil {
+		return err
+	}
+	return stream.SendAndClose(encodeGRPCImportResult(importResp.Result))
+}
+
+// grpcDocumentReader reads the documents of a BulkAdd stream, next holds the
+// message received before the reader was created.
+type grpcDocumentReader struct {
+	stream db.Database_BulkAddServer
+	next   *db.BulkAddRequest
+}
+
+func (r *grpcDocumentReader) Next() (*internal.Document, error) {
+	req := r.next
+	r.next = nil
+	if req == nil {
+		var err error
+		if req, err = r.stream.Recv(); err != nil {
+			return nil, err
+		}
+	}
+	if req.Document == nil {
+		return nil, &internal.RowError{Err: "document is missing"}
+	}
+	return &internal.Document{
+		TicketID:  req.Document.TicketID,
+		Content:   req.Document.Content,
+		Title:     req.Document.Title,
+		Author:    req.Document.Author,
+		Topic:     req.Document.Topic,
+		Watermark: req.Document.Watermark,
+	}, nil
+}
+
+func encodeGRPCImportResult(result internal.ImportResult) *db.BulkAddReply {
+	reply := &db.BulkAddReply{
+		DryRun:    result.DryRun,
+		Rows:      int64(result.Rows),
+		Imported:  int64(result.Imported),
+		TicketIDs: result.TicketIDs,
+	}
+	for _, e := range result.Errors {
+		reply.Errors = append(reply.Errors, &db.RowError{Row: int64(e.Row), Err: e.Err})
+	}
+	return reply
+}
+
+func (g *grpcServer) Export(r *db.ExportRequest, stream db.Database_ExportServer) error {
+	filters := decodeGRPCFilters(r.Filters)
+	if err := internal.ValidateFilters(filters); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	ctx := stream.Context()
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = adminFromGRPC(g.adminToken)(ctx, md)
+	}
+	resp, err := g.export(ctx, endpoints.ExportRequest{Filters: filters, IncludeDeleted: r.IncludeDeleted})
+	if err != nil {
+		return err
+	}
+	exportResp := resp.(endpoints.ExportResponse)
+	if err := codeError(int64(exportResp.Code), exportResp.Err); err != nil {
+		return err
+	}
+	defer exportResp.Documents.Close()
+	for {
+		doc, err := exportResp.Documents.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if err := stream.Send(encodeGRPCDocument(*doc)); err != nil {
+			return err
+		}
+	}
+}
+
+// Watch sends the events of the changed documents until the client cancels
+// the call.
+func (g *grpcServer) Watch(r *db.WatchRequest, stream db.Database_WatchServer) error {
+	req := endpoints.WatchRequest{After: r.After, TicketID: r.TicketID}
+	for _, t := range r.Types {
+		req.Types = append(req.Types, internal.EventType(t))
+	}
+	resp, err := g.watch(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	watchResp := resp.(endpoints.WatchResponse)
+	if err := codeError(int64(watchResp.Code), watchResp.Err); err != nil {
+		return err
+	}
+	defer watchResp.Events.Close()
+	for {
+		ev, err := watchResp.Events.Next()
+		if err != nil {
+			if stream.Context().Err() != nil {
+				return status.FromContextError(stream.Context().Err()).Err()
+			}
+			if errors.Is(err, util.ErrOutOfRange) {
+				return status.Error(codes.OutOfRange, err.Error())
+			}
+			return status.Error(codes.Internal, err.Error())
+		}
+		if err := stream.Send(encodeGRPCEvent(ev)); err != nil {
+			return err
+		}
+	}
+}
+
+func encodeGRPCEvent(ev *internal.Event) *db.Event {
+	e := &db.Event{
+		Offset:   ev.Offset,
+		Type:     string(ev.Type),
+		TicketID: ev.TicketID,
+		Version:  ev.Version,
+		Editor:   ev.Editor,
+		Time:     timestamppb.New(ev.Time),
+	}
+	if ev.Document != nil {
+		e.Document = encodeGRPCDocument(*ev.Document)
+	}
+	return e
+}
+
+// PutContent streams the chunks of the stream into the content of the
+// document, ticketID and version are read from the first message.
+func (g *grpcServer) PutContent(stream db.Database_PutContentServer) error {
+	ctx := stream.Context()
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = editorFromGRPC(ctx, md)
+	}
+	first, err := stream.Recv()
+	if err == io.EOF || (err == nil && first.TicketID == "") {
+		return status.Error(codes.InvalidArgument, util.ErrInvalidArgument.Error())
+	}
+	if err != nil {
+		return err
+	}
+	body := &grpcChunkReader{stream: stream, chunk: first.Chunk}
+	resp, err := g.putContent(ctx, endpoints.PutContentRequest{TicketID: first.TicketID, Version: first.Version, Body: body})
+	if err != nil {
+		return err
+	}
+	putContentResp := resp.(endpoints.PutContentResponse)
+	if err := codeError(int64(putContentResp.Code), putContentResp.Err); err != nil {
+		return err
+	}
+	return stream.SendAndClose(&db.PutContentReply{
+		Document: encodeGRPCDocument(putContentResp.Document),
+		Code:     int64(putContentResp.Code),
+	})
+}
+
+// grpcChunkReader reads the chunks of a PutContent stream, chunk holds the
+// unread rest of the last received chunk.
+type grpcChunkReader struct {
+	stream db.Database_PutContentServer
+	chunk  []byte
+}
+
+func (r *grpcChunkReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.chunk = req.Chunk
+	}
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+// contentChunkSize is the size of the chunks sent by GetContent.
+const contentChunkSize = 32 << 10
+
+// GetContent streams a byte range of the content in chunks, the first chunk
+// carries digest and size of the whole content.
+func (g *grpcServer) GetContent(r *db.GetContentRequest, stream db.Database_GetContentServer) error {
+	resp, err := g.openContent(stream.Context(), endpoints.OpenContentRequest{TicketID: r.TicketID, Offset: r.Offset, Length: r.Length})
+	if err != nil {
+		return err
+	}
+	openContentResp := resp.(endpoints.OpenContentResponse)
+	if err := codeError(int64(openContentResp.Code), openContentResp.Err); err != nil {
+		return err
+	}
+	content := openContentResp.Content
+	defer content.Body.Close()
+	first := &db.ContentChunk{Offset: content.Offset, Digest: content.Digest, Size: content.Size}
+	buf := make([]byte, contentChunkSize)
+	for offset := content.Offset; ; {
+		n, err := io.ReadFull(content.Body, buf)
+		if n > 0 || first != nil {
+			chunk := &db.ContentChunk{Offset: offset}
+			if first != nil {
+				chunk, first = first, nil
+			}
+			chunk.Chunk = buf[:n]
+			if err := stream.Send(chunk); err != nil {
+				return err
+			}
+			offset += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+}
+
 func (g *grpcServer) ServiceStatus(ctx context.Context, r *db.ServiceStatusRequest) (*db.ServiceStatusReply, error) {
-	_, rep, err := g.get.ServeGRPC(ctx, r)
+	_, rep, err := g.serviceStatus.ServeGRPC(ctx, r)
 	if err != nil {
 		return nil, err
 	}
@@ -162,7 +869,33 @@ func decodeGRPCServiceStatusRequest(_ context.Context, _ interface{}) (interface
 	return endpoints.ServiceStatusRequest{}, nil
 }
 
+func encodeGRPCServiceStatusResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.ServiceStatusResponse)
+	return &db.ServiceStatusReply{Code: int64(resp.Code), Err: resp.Err}, nil
+}
+
 func decodeGRPCServiceStatusResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
 	reply := grpcReply.(*db.ServiceStatusReply)
 	return endpoints.ServiceStatusResponse{Code: int(reply.Code), Err: reply.Err}, nil
 }
+
+// codeError maps the HTTP status code returned by the service to a gRPC
+// status error, successful codes map to nil.
+func codeError(code int64, msg string) error {
+	switch code {
+	case http.StatusBadRequest:
+		return status.Error(codes.InvalidArgument, msg)
+	case http.StatusNotFound:
+		return status.Error(codes.NotFound, msg)
+	case http.StatusConflict:
+		return status.Error(codes.Aborted, msg)
+	case http.StatusRequestedRangeNotSatisfiable, http.StatusGone:
+		return status.Error(codes.OutOfRange, msg)
+	case http.StatusForbidden:
+		return status.Error(codes.PermissionDenied, msg)
+	}
+	if code >= http.StatusBadRequest {
+		return status.Error(codes.Internal, msg)
+	}
+	return nil
+}
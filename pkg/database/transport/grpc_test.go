@@ -0,0 +1,73 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"publisher/api/v1/pb/db"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCUpdateConflict(t *testing.T) {
+	ctx := context.Background()
+	ep, ticketID := newTestEndpoints(t)
+	server := NewGRPCServer(ep, "")
+
+	reply, err := server.Update(ctx, &db.UpdateRequest{TicketID: ticketID, Document: &db.Document{Title: "Alice"}, Version: 1})
+	if err != nil || reply.Version != 2 {
+		t.Fatalf("first update returned %+v, %v", reply, err)
+	}
+	reply, err = server.Update(ctx, &db.UpdateRequest{TicketID: ticketID, Document: &db.Document{Title: "Bob"}, Version: 1})
+	if status.Code(err) != codes.Aborted || reply.GetCode() != http.StatusConflict {
+		t.Errorf("stale update returned %+v, %v, want code %d and %v", reply, err, http.StatusConflict, codes.Aborted)
+	}
+	if _, err := server.Update(ctx, &db.UpdateRequest{TicketID: "unknown", Document: &db.Document{Title: "Bob"}}); status.Code(err) != codes.NotFound {
+		t.Errorf("update of an unknown ticket returned %v, want %v", err, codes.NotFound)
+	}
+}
+
+func TestCodeError(t *testing.T) {
+	for _, tt := range []struct {
+		code int64
+		want codes.Code
+	}{
+		{http.StatusOK, codes.OK},
+		{http.StatusBadRequest, codes.InvalidArgument},
+		{http.StatusNotFound, codes.NotFound},
+		{http.StatusConflict, codes.Aborted},
+		{http.StatusRequestedRangeNotSatisfiable, codes.OutOfRange},
+		{http.StatusGone, codes.OutOfRange},
+		{http.StatusForbidden, codes.PermissionDenied},
+		{http.StatusInternalServerError, codes.Internal},
+	} {
+		if got := status.Code(codeError(tt.code, "error")); got != tt.want {
+			t.Errorf("codeError(%d) returned %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestGRPCAdmin(t *testing.T) {
+	ep, ticketID := newTestEndpoints(t)
+	server := NewGRPCServer(ep, "secret")
+	admin := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer secret"))
+	other := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer other"))
+
+	if _, err := server.Remove(other, &db.RemoveRequest{TicketID: ticketID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Restore(other, &db.RestoreRequest{TicketID: ticketID}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("restore without the admin token returned %v, want %v", err, codes.PermissionDenied)
+	}
+	if _, err := server.Purge(context.Background(), &db.PurgeRequest{TicketID: ticketID}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("purge without metadata returned %v, want %v", err, codes.PermissionDenied)
+	}
+	if _, err := server.Restore(admin, &db.RestoreRequest{TicketID: ticketID}); err != nil {
+		t.Errorf("restore of an admin returned %v", err)
+	}
+	if _, err := server.Purge(admin, &db.PurgeRequest{TicketID: ticketID}); err != nil {
+		t.Errorf("purge of an admin returned %v", err)
+	}
+}
@@ -1,64 +1,256 @@
 package transport
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
+	"publisher/internal"
 	"publisher/internal/util"
+	"publisher/pkg/database"
 	"publisher/pkg/database/endpoints"
+	"strconv"
+	"strings"
 
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/go-kit/log"
 )
 
-func NewHTTPHandler(ep endpoints.Set) http.Handler {
+// NewHTTPHandler returns the HTTP handler of the endpoints. A request whose
+// bearer token is adminToken is made by an admin, an empty token makes no
+// request an admin.
+func NewHTTPHandler(ep endpoints.Set, adminToken string) http.Handler {
 	m := http.NewServeMux()
+	options := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(encodeError),
+		httptransport.ServerBefore(editorFromHTTP, adminFromHTTP(adminToken)),
+	}
 
 	m.Handle("/healthz", httptransport.NewServer(
 		ep.ServiceStatusEndpoint,
 		decodeHTTPServiceStatusRequest,
 		encodeResponse,
+		options...,
 	))
 
 	m.Handle("/add", httptransport.NewServer(
 		ep.AddEndpoint,
 		decodeHTTPAddRequest,
 		encodeResponse,
+		options...,
 	))
 
 	m.Handle("/get", httptransport.NewServer(
 		ep.GetEndpoint,
 		decodeHTTPGetRequest,
 		encodeResponse,
+		options...,
+	))
+
+	m.Handle("/search", httptransport.NewServer(
+		ep.SearchEndpoint,
+		decodeHTTPSearchRequest,
+		encodeResponse,
+		options...,
 	))
 
 	m.Handle("/update", httptransport.NewServer(
 		ep.UpdateEndpoint,
 		decodeHTTPUploadRequest,
 		encodeResponse,
+		options...,
 	))
 
 	m.Handle("/remove", httptransport.NewServer(
 		ep.RemoveEndpoint,
 		decodeHTTPRemoveRequest,
 		encodeResponse,
+		options...,
+	))
+
+	m.Handle("/restore", httptransport.NewServer(
+		ep.RestoreEndpoint,
+		decodeHTTPRestoreRequest,
+		encodeResponse,
+		options...,
+	))
+
+	m.Handle("/purge", httptransport.NewServer(
+		ep.PurgeEndpoint,
+		decodeHTTPPurgeRequest,
+		encodeResponse,
+		options...,
+	))
+
+	m.Handle("/history", httptransport.NewServer(
+		ep.HistoryEndpoint,
+		decodeHTTPHistoryRequest,
+		encodeResponse,
+		options...,
+	))
+
+	m.Handle("/revision", httptransport.NewServer(
+		ep.RevisionEndpoint,
+		decodeHTTPRevisionRequest,
+		encodeResponse,
+		options...,
+	))
+
+	m.Handle("/diff", httptransport.NewServer(
+		ep.DiffEndpoint,
+		decodeHTTPDiffRequest,
+		encodeResponse,
+		options...,
+	))
+
+	m.Handle("/similar", httptransport.NewServer(
+		ep.FindSimilarEndpoint,
+		decodeHTTPFindSimilarRequest,
+		encodeResponse,
+		options...,
+	))
+
+	m.Handle("/watch", methodHandler{
+		http.MethodGet: httptransport.NewServer(
+			ep.WatchEndpoint,
+			decodeHTTPWatchRequest,
+			encodeHTTPWatchResponse,
+			options...,
+		),
+	})
+
+	// the watermark node keeps the state of its jobs here
+	m.Handle("/watermark", methodHandler{
+		http.MethodGet: httptransport.NewServer(
+			ep.WatermarkJobEndpoint,
+			decodeHTTPWatermarkJobRequest,
+			encodeResponse,
+			options...,
+		),
+		http.MethodPost: httptransport.NewServer(
+			ep.TransitionWatermarkEndpoint,
+			decodeHTTPTransitionWatermarkRequest,
+			encodeResponse,
+			options...,
+		),
+	})
+
+	m.Handle("/rollback", httptransport.NewServer(
+		ep.RollbackEndpoint,
+		decodeHTTPRollbackRequest,
+		encodeResponse,
+		options...,
+	))
+
+	m.Handle("/import", httptransport.NewServer(
+		ep.ImportEndpoint,
+		decodeHTTPImportRequest,
+		encodeResponse,
+		options...,
 	))
 
+	m.Handle("/export", httptransport.NewServer(
+		ep.ExportEndpoint,
+		decodeHTTPExportRequest,
+		encodeHTTPExportResponse,
+		options...,
+	))
+
+	openContent := httptransport.NewServer(
+		ep.OpenContentEndpoint,
+		decodeHTTPOpenContentRequest,
+		encodeHTTPOpenContentResponse,
+		options...,
+	)
+	putContent := httptransport.NewServer(
+		ep.PutContentEndpoint,
+		decodeHTTPPutContentRequest,
+		encodeResponse,
+		options...,
+	)
+	m.Handle("/content", methodHandler{
+		http.MethodGet:  openContent,
+		http.MethodHead: openContent,
+		http.MethodPut:  putContent,
+		http.MethodPost: putContent,
+	})
+
 	return m
 }
 
+// methodHandler serves the handler registered for the method of the request.
+type methodHandler map[string]http.Handler
+
+func (m methodHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, ok := m[r.Method]
+	if !ok {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+// EditorHeader names the account making a change, it is recorded in the
+// revisions of the document.
+const EditorHeader = "X-Editor"
+
+func editorFromHTTP(ctx context.Context, r *http.Request) context.Context {
+	if editor := r.Header.Get(EditorHeader); editor != "" {
+		return internal.WithEditor(ctx, editor)
+	}
+	return ctx
+}
+
+func editorToHTTP(ctx context.Context, r *http.Request) context.Context {
+	if editor := internal.EditorFromContext(ctx); editor != "" {
+		r.Header.Set(EditorHeader, editor)
+	}
+	return ctx
+}
+
+// adminFromHTTP marks the context of a request carrying the admin token in
+// its Authorization header.
+func adminFromHTTP(adminToken string) httptransport.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		if isAdminToken(adminToken, r.Header.Get("Authorization")) {
+			return internal.WithAdmin(ctx)
+		}
+		return ctx
+	}
+}
+
+// isAdminToken tells whether the authorization is the bearer admin token.
+func isAdminToken(adminToken, authorization string) bool {
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	return adminToken != "" && token != authorization &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
 func decodeHTTPServiceStatusRequest(_ context.Context, _ *http.Request) (interface{}, error) {
 	var req endpoints.ServiceStatusRequest
 	return req, nil
 }
 
+// IdempotencyKeyHeader carries the idempotency key of an add request, it
+// takes precedence over the idempotencyKey field of the body.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
 func decodeHTTPAddRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var req endpoints.AddRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		return nil, err
 	}
+	if key := r.Header.Get(IdempotencyKeyHeader); key != "" {
+		req.IdempotencyKey = key
+	}
 	return req, nil
 }
 
@@ -72,6 +264,30 @@ func decodeHTTPGetRequest(_ context.Context, r *http.Request) (interface{}, erro
 	if err != nil {
 		return nil, err
 	}
+	if err := internal.ValidateFilters(req.Filters); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// decodeHTTPSearchRequest accepts a JSON body or the q and limit query parameters.
+func decodeHTTPSearchRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.SearchRequest
+	if r.ContentLength == 0 {
+		req.Text = r.URL.Query().Get("q")
+		if limit := r.URL.Query().Get("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil {
+				return nil, util.ErrInvalidArgument
+			}
+			req.Limit = n
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		return nil, util.ErrInvalidArgument
+	}
 	return req, nil
 }
 
@@ -81,9 +297,36 @@ func decodeHTTPUploadRequest(_ context.Context, r *http.Request) (interface{}, e
 	if err != nil {
 		return nil, err
 	}
+	if match := r.Header.Get("If-Match"); match != "" {
+		version, err := parseETag(match)
+		if err != nil {
+			return nil, err
+		}
+		if req.Version != 0 && version != 0 && req.Version != version {
+			return nil, util.ErrInvalidArgument
+		}
+		if version != 0 {
+			req.Version = version
+		}
+	}
 	return req, nil
 }
 
+// parseETag returns the document version of an If-Match header, the
+// wildcard matches every version and is returned as 0.
+func parseETag(match string) (int64, error) {
+	match = strings.TrimSpace(match)
+	if match == "*" {
+		return 0, nil
+	}
+	match = strings.TrimPrefix(match, "W/")
+	version, err := strconv.ParseInt(strings.Trim(match, `"`), 10, 64)
+	if err != nil || version <= 0 {
+		return 0, util.ErrInvalidArgument
+	}
+	return version, nil
+}
+
 func decodeHTTPRemoveRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var req endpoints.RemoveRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -93,21 +336,273 @@ func decodeHTTPRemoveRequest(_ context.Context, r *http.Request) (interface{}, e
 	return req, nil
 }
 
+func decodeHTTPRestoreRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.RestoreRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func decodeHTTPPurgeRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.PurgeRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func decodeHTTPHistoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.HistoryRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func decodeHTTPRevisionRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.RevisionRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Number <= 0 {
+		return nil, util.ErrInvalidArgument
+	}
+	return req, nil
+}
+
+// decodeHTTPFindSimilarRequest reads the request from the ticketID and
+// threshold query parameters of a GET request, or from the JSON body.
+func decodeHTTPFindSimilarRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.FindSimilarRequest
+	if r.Method == http.MethodGet {
+		req.TicketID = r.URL.Query().Get("ticketID")
+		if v := r.URL.Query().Get("threshold"); v != "" {
+			threshold, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, util.ErrInvalidArgument
+			}
+			req.Threshold = threshold
+		}
+		return req, nil
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func decodeHTTPWatermarkJobRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := endpoints.WatermarkJobRequest{TicketID: r.URL.Query().Get("ticketID")}
+	if req.TicketID == "" {
+		return nil, util.ErrInvalidArgument
+	}
+	return req, nil
+}
+
+func decodeHTTPTransitionWatermarkRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.TransitionWatermarkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, util.ErrInvalidArgument
+	}
+	if req.TicketID == "" {
+		return nil, util.ErrInvalidArgument
+	}
+	return req, nil
+}
+
+func decodeHTTPDiffRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.DiffRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return nil, err
+	}
+	if req.From <= 0 || req.To <= 0 {
+		return nil, util.ErrInvalidArgument
+	}
+	return req, nil
+}
+
+func decodeHTTPRollbackRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.RollbackRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Number <= 0 {
+		return nil, util.ErrInvalidArgument
+	}
+	return req, nil
+}
+
+// decodeHTTPImportRequest streams the rows of the body, the format is taken
+// from the format query parameter or the Content-Type. The dryRun query
+// parameter only validates the rows.
+func decodeHTTPImportRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.ImportRequest
+	format, err := bulkFormat(r, "Content-Type")
+	if err != nil {
+		return nil, err
+	}
+	if dryRun := r.URL.Query().Get("dryRun"); dryRun != "" {
+		if req.DryRun, err = strconv.ParseBool(dryRun); err != nil {
+			return nil, util.ErrInvalidArgument
+		}
+	}
+	req.Format = format
+	req.Rows, err = newDocumentDecoder(format, r.Body)
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// decodeHTTPExportRequest accepts the filters of a Get request as optional
+// body, the format is taken from the format query parameter or the Accept header.
+func decodeHTTPExportRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.ExportRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+	if err := internal.ValidateFilters(req.Filters); err != nil {
+		return nil, err
+	}
+	format, err := bulkFormat(r, "Accept")
+	if err != nil {
+		return nil, err
+	}
+	req.Format = format
+	return req, nil
+}
+
+// exportErrorTrailer carries the error of an export which failed after the
+// first rows were sent.
+const exportErrorTrailer = "X-Export-Error"
+
+func encodeHTTPExportResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(endpoints.ExportResponse)
+	if resp.Err != "" {
+		return encodeResponse(ctx, w, resp)
+	}
+	defer resp.Documents.Close()
+
+	w.Header().Set("Content-Type", bulkContentType(resp.Format))
+	w.Header().Set("Trailer", exportErrorTrailer)
+	enc := newDocumentEncoder(resp.Format, w)
+	flusher, _ := w.(http.Flusher)
+	for n := 1; ; n++ {
+		doc, err := resp.Documents.Next()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = enc.Encode(doc)
+		}
+		if err != nil {
+			logger.Log("method", "Export", "row", n, "err", err)
+			enc.Flush()
+			w.Header().Set(exportErrorTrailer, err.Error())
+			return nil
+		}
+		if n%flushRows == 0 {
+			if err := enc.Flush(); err != nil {
+				return nil
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+	return enc.Flush()
+}
+
+// decodeHTTPPutContentRequest streams the body as the new content of the
+// document named by the ticketID query parameter, If-Match carries the
+// expected version.
+func decodeHTTPPutContentRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := endpoints.PutContentRequest{TicketID: r.URL.Query().Get("ticketID"), Body: r.Body}
+	if req.TicketID == "" {
+		return nil, util.ErrInvalidArgument
+	}
+	if match := r.Header.Get("If-Match"); match != "" {
+		version, err := parseETag(match)
+		if err != nil {
+			return nil, err
+		}
+		req.Version = version
+	}
+	return req, nil
+}
+
+// decodeHTTPOpenContentRequest reads the ticketID query parameter and a
+// single byte range of the Range header. Suffix ranges and multiple ranges
+// are ignored and the whole content is sent, as the RFC allows.
+func decodeHTTPOpenContentRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := endpoints.OpenContentRequest{TicketID: r.URL.Query().Get("ticketID")}
+	if req.TicketID == "" {
+		return nil, util.ErrInvalidArgument
+	}
+	if offset, length, ok := util.ParseRange(r.Header.Get("Range")); ok {
+		req.Offset, req.Length = offset, length
+	}
+	return req, nil
+}
+
+// encodeHTTPOpenContentResponse streams the content, a part of it is sent as
+// 206 with its Content-Range. The digest of the whole content is the ETag.
+func encodeHTTPOpenContentResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(endpoints.OpenContentResponse)
+	if resp.Err != "" {
+		if resp.Code == http.StatusRequestedRangeNotSatisfiable {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", resp.Content.Size))
+		}
+		return encodeResponse(ctx, w, resp)
+	}
+	content := resp.Content
+	defer content.Body.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Length", strconv.FormatInt(content.Length, 10))
+	if content.Digest != "" {
+		w.Header().Set("ETag", strconv.Quote(content.Digest))
+	}
+	if content.Length < content.Size {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", content.Offset, content.Offset+content.Length-1, content.Size))
+		w.WriteHeader(http.StatusPartialContent)
+	}
+	_, err := io.Copy(w, content.Body)
+	return err
+}
+
 func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
 	if e, ok := response.(error); ok && e != nil {
 		encodeError(ctx, e, w)
 		return nil
 	}
-	return json.NewEncoder(w).Encode(response)
+	// honours the status code and headers of responses such as UpdateResponse
+	return httptransport.EncodeJSONResponse(ctx, w, response)
 }
 
 func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	switch err {
-	case util.ErrUnknown:
+	switch {
+	case errors.Is(err, util.ErrUnknown):
 		w.WriteHeader(http.StatusNotFound)
-	case util.ErrInvalidArgument:
+	case errors.Is(err, util.ErrInvalidArgument):
 		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, util.ErrConflict):
+		w.WriteHeader(http.StatusConflict)
+	case errors.Is(err, util.ErrOutOfRange):
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+	case errors.Is(err, util.ErrForbidden):
+		w.WriteHeader(http.StatusForbidden)
 	default:
 		w.WriteHeader(http.StatusInternalServerError)
 	}
@@ -122,3 +617,364 @@ func init() {
 	logger = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
 	logger = log.With(logger, "ts", log.DefaultTimestampUTC)
 }
+
+// NewHTTPClient returns a database.Service backed by the HTTP handler of a
+// remote database node, instance is its host:port or base URL.
+func NewHTTPClient(instance string) (database.Service, error) {
+	if !strings.HasPrefix(instance, "http") {
+		instance = "http://" + instance
+	}
+	u, err := url.Parse(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []httptransport.ClientOption{
+		httptransport.ClientBefore(editorToHTTP),
+	}
+
+	return &endpoints.Set{
+		AddEndpoint: httptransport.NewClient(
+			http.MethodPost, copyURL(u, "/add"), encodeHTTPRequest, decodeHTTPAddResponse, options...,
+		).Endpoint(),
+		GetEndpoint: httptransport.NewClient(
+			http.MethodPost, copyURL(u, "/get"), encodeHTTPRequest, decodeHTTPGetResponse, options...,
+		).Endpoint(),
+		SearchEndpoint: httptransport.NewClient(
+			http.MethodPost, copyURL(u, "/search"), encodeHTTPRequest, decodeHTTPSearchResponse, options...,
+		).Endpoint(),
+		UpdateEndpoint: httptransport.NewClient(
+			http.MethodPost, copyURL(u, "/update"), encodeHTTPRequest, decodeHTTPUpdateResponse, options...,
+		).Endpoint(),
+		RemoveEndpoint: httptransport.NewClient(
+			http.MethodPost, copyURL(u, "/remove"), encodeHTTPRequest, decodeHTTPRemoveResponse, options...,
+		).Endpoint(),
+		RestoreEndpoint: httptransport.NewClient(
+			http.MethodPost, copyURL(u, "/restore"), encodeHTTPRequest, decodeHTTPRestoreResponse, options...,
+		).Endpoint(),
+		PurgeEndpoint: httptransport.NewClient(
+			http.MethodPost, copyURL(u, "/purge"), encodeHTTPRequest, decodeHTTPPurgeResponse, options...,
+		).Endpoint(),
+		HistoryEndpoint: httptransport.NewClient(
+			http.MethodPost, copyURL(u, "/history"), encodeHTTPRequest, decodeHTTPHistoryResponse, options...,
+		).Endpoint(),
+		RevisionEndpoint: httptransport.NewClient(
+			http.MethodPost, copyURL(u, "/revision"), encodeHTTPRequest, decodeHTTPRevisionResponse, options...,
+		).Endpoint(),
+		DiffEndpoint: httptransport.NewClient(
+			http.MethodPost, copyURL(u, "/diff"), encodeHTTPRequest, decodeHTTPDiffResponse, options...,
+		).Endpoint(),
+		RollbackEndpoint: httptransport.NewClient(
+			http.MethodPost, copyURL(u, "/rollback"), encodeHTTPRequest, decodeHTTPRollbackResponse, options...,
+		).Endpoint(),
+		ImportEndpoint: httptransport.NewClient(
+			http.MethodPost, copyURL(u, "/import"), encodeHTTPImportRequest, decodeHTTPImportResponse, options...,
+		).Endpoint(),
+		ExportEndpoint: httptransport.NewClient(
+			http.MethodPost, copyURL(u, "/export"), encodeHTTPRequest, decodeHTTPExportResponse,
+			append(options, httptransport.BufferedStream(true))...,
+		).Endpoint(),
+		PutContentEndpoint: httptransport.NewClient(
+			http.MethodPut, copyURL(u, "/content"), encodeHTTPPutContentRequest, decodeHTTPPutContentResponse, options...,
+		).Endpoint(),
+		OpenContentEndpoint: httptransport.NewClient(
+			http.MethodGet, copyURL(u, "/content"), encodeHTTPOpenContentRequest, decodeHTTPOpenContentResponse,
+			append(options, httptransport.BufferedStream(true))...,
+		).Endpoint(),
+		FindSimilarEndpoint: httptransport.NewClient(
+			http.MethodPost, copyURL(u, "/similar"), encodeHTTPRequest, decodeHTTPFindSimilarResponse, options...,
+		).Endpoint(),
+		WatchEndpoint: httptransport.NewClient(
+			http.MethodGet, copyURL(u, "/watch"), encodeHTTPWatchRequest, decodeHTTPWatchResponse,
+			append(options, httptransport.BufferedStream(true))...,
+		).Endpoint(),
+		WatermarkJobEndpoint: httptransport.NewClient(
+			http.MethodGet, copyURL(u, "/watermark"), encodeHTTPWatermarkJobRequest, decodeHTTPWatermarkJobResponse, options...,
+		).Endpoint(),
+		TransitionWatermarkEndpoint: httptransport.NewClient(
+			http.MethodPost, copyURL(u, "/watermark"), encodeHTTPRequest, decodeHTTPWatermarkJobResponse, options...,
+		).Endpoint(),
+		ServiceStatusEndpoint: httptransport.NewClient(
+			http.MethodGet, copyURL(u, "/healthz"), encodeHTTPRequest, decodeHTTPServiceStatusResponse,
+		).Endpoint(),
+	}, nil
+}
+
+func copyURL(base *url.URL, path string) *url.URL {
+	next := *base
+	next.Path = strings.TrimSuffix(base.Path, "/") + path
+	return &next
+}
+
+// encodeHTTPRequest is the client side encoder which sends the request as JSON body.
+func encodeHTTPRequest(_ context.Context, r *http.Request, request interface{}) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(request); err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	r.Body = io.NopCloser(&buf)
+	return nil
+}
+
+// encodeHTTPImportRequest streams the rows as NDJSON body. A row the reader
+// fails to decode aborts the request, the server cannot report it per row.
+func encodeHTTPImportRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(endpoints.ImportRequest)
+	if req.Rows == nil {
+		return util.ErrInvalidArgument
+	}
+	q := r.URL.Query()
+	q.Set("format", internal.FormatNDJSON)
+	if req.DryRun {
+		q.Set("dryRun", "true")
+	}
+	r.URL.RawQuery = q.Encode()
+	r.Header.Set("Content-Type", contentTypeNDJSON)
+
+	pr, pw := io.Pipe()
+	go func() {
+		enc := json.NewEncoder(pw)
+		for {
+			doc, err := req.Rows.Next()
+			if err == io.EOF {
+				pw.Close()
+				return
+			}
+			if err == nil {
+				err = enc.Encode(doc)
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	r.Body = pr
+	return nil
+}
+
+// encodeHTTPPutContentRequest streams the content as request body.
+func encodeHTTPPutContentRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(endpoints.PutContentRequest)
+	if req.Body == nil {
+		return util.ErrInvalidArgument
+	}
+	q := r.URL.Query()
+	q.Set("ticketID", req.TicketID)
+	r.URL.RawQuery = q.Encode()
+	r.Header.Set("Content-Type", "application/octet-stream")
+	if req.Version != 0 {
+		r.Header.Set("If-Match", strconv.Quote(strconv.FormatInt(req.Version, 10)))
+	}
+	r.Body = io.NopCloser(req.Body)
+	return nil
+}
+
+func decodeHTTPPutContentResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp endpoints.PutContentResponse
+	err := decodeHTTPResponse(r, &resp)
+	return resp, err
+}
+
+func encodeHTTPWatermarkJobRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(endpoints.WatermarkJobRequest)
+	q := r.URL.Query()
+	q.Set("ticketID", req.TicketID)
+	r.URL.RawQuery = q.Encode()
+	return nil
+}
+
+func encodeHTTPOpenContentRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(endpoints.OpenContentRequest)
+	q := r.URL.Query()
+	q.Set("ticketID", req.TicketID)
+	r.URL.RawQuery = q.Encode()
+	switch {
+	case req.Length > 0:
+		r.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", req.Offset, req.Offset+req.Length-1))
+	case req.Offset > 0:
+		r.Header.Set("Range", fmt.Sprintf("bytes=%d-", req.Offset))
+	}
+	return nil
+}
+
+// decodeHTTPOpenContentResponse returns the streamed body as content body,
+// which stays open until it is closed.
+func decodeHTTPOpenContentResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK && r.StatusCode != http.StatusPartialContent {
+		defer r.Body.Close()
+		var resp endpoints.OpenContentResponse
+		err := decodeHTTPResponse(r, &resp)
+		return resp, err
+	}
+	content := internal.Content{
+		Digest: strings.Trim(r.Header.Get("ETag"), `"`),
+		Size:   r.ContentLength,
+		Length: r.ContentLength,
+		Body:   r.Body,
+	}
+	if r.StatusCode == http.StatusPartialContent {
+		var last int64
+		_, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &content.Offset, &last, &content.Size)
+		if err != nil {
+			r.Body.Close()
+			return nil, fmt.Errorf("invalid Content-Range: %w", err)
+		}
+		content.Length = last - content.Offset + 1
+	}
+	return endpoints.OpenContentResponse{Content: content, Code: r.StatusCode}, nil
+}
+
+func decodeHTTPImportResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp endpoints.ImportResponse
+	err := decodeHTTPResponse(r, &resp)
+	return resp, err
+}
+
+// decodeHTTPExportResponse returns a reader over the streamed body, which
+// stays open until the reader is closed.
+func decodeHTTPExportResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		defer r.Body.Close()
+		var resp endpoints.ExportResponse
+		err := decodeHTTPResponse(r, &resp)
+		return resp, err
+	}
+	format := internal.FormatNDJSON
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == contentTypeCSV {
+		format = internal.FormatCSV
+	}
+	rows, err := newDocumentDecoder(format, r.Body)
+	if err != nil {
+		r.Body.Close()
+		return nil, err
+	}
+	return endpoints.ExportResponse{Format: format, Documents: &exportBody{rows: rows, resp: r}}, nil
+}
+
+// exportBody reads the rows of an export response and reports the error
+// trailer of an export which failed on the server.
+type exportBody struct {
+	rows internal.DocumentReader
+	resp *http.Response
+}
+
+func (e *exportBody) Next() (*internal.Document, error) {
+	doc, err := e.rows.Next()
+	if err == io.EOF {
+		if msg := e.resp.Trailer.Get(exportErrorTrailer); msg != "" {
+			return nil, util.ParseError(msg)
+		}
+	}
+	return doc, err
+}
+
+func (e *exportBody) Close() error {
+	return e.resp.Body.Close()
+}
+
+func decodeHTTPAddResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp endpoints.AddResponse
+	err := decodeHTTPResponse(r, &resp)
+	return resp, err
+}
+
+func decodeHTTPGetResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp endpoints.GetResponse
+	err := decodeHTTPResponse(r, &resp)
+	return resp, err
+}
+
+func decodeHTTPSearchResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp endpoints.SearchResponse
+	err := decodeHTTPResponse(r, &resp)
+	return resp, err
+}
+
+func decodeHTTPUpdateResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp endpoints.UpdateResponse
+	err := decodeHTTPResponse(r, &resp)
+	return resp, err
+}
+
+func decodeHTTPRemoveResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp endpoints.RemoveResponse
+	err := decodeHTTPResponse(r, &resp)
+	return resp, err
+}
+
+func decodeHTTPRestoreResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp endpoints.RestoreResponse
+	err := decodeHTTPResponse(r, &resp)
+	return resp, err
+}
+
+func decodeHTTPPurgeResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp endpoints.PurgeResponse
+	err := decodeHTTPResponse(r, &resp)
+	return resp, err
+}
+
+func decodeHTTPHistoryResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp endpoints.HistoryResponse
+	err := decodeHTTPResponse(r, &resp)
+	return resp, err
+}
+
+func decodeHTTPRevisionResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp endpoints.RevisionResponse
+	err := decodeHTTPResponse(r, &resp)
+	return resp, err
+}
+
+func decodeHTTPFindSimilarResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp endpoints.FindSimilarResponse
+	err := decodeHTTPResponse(r, &resp)
+	return resp, err
+}
+
+func decodeHTTPWatermarkJobResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp endpoints.WatermarkJobResponse
+	err := decodeHTTPResponse(r, &resp)
+	return resp, err
+}
+
+func decodeHTTPDiffResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp endpoints.DiffResponse
+	err := decodeHTTPResponse(r, &resp)
+	return resp, err
+}
+
+func decodeHTTPRollbackResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp endpoints.RollbackResponse
+	err := decodeHTTPResponse(r, &resp)
+	return resp, err
+}
+
+func decodeHTTPServiceStatusResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp endpoints.ServiceStatusResponse
+	err := decodeHTTPResponse(r, &resp)
+	return resp, err
+}
+
+// decodeHTTPResponse decodes the JSON body into resp, error bodies written
+// by encodeError are returned as error.
+func decodeHTTPResponse(r *http.Response, resp interface{}) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if r.StatusCode >= http.StatusBadRequest {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			return util.ParseError(e.Error)
+		}
+	}
+	if err := json.Unmarshal(body, resp); err != nil {
+		return fmt.Errorf("%s: %w", r.Status, err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"publisher/internal"
+	"publisher/pkg/blob"
+	"publisher/pkg/database"
+	"publisher/pkg/database/endpoints"
+	"strings"
+	"testing"
+)
+
+// newTestEndpoints returns the endpoints of a service on a memory repository
+// holding a single document.
+func newTestEndpoints(t *testing.T) (endpoints.Set, string) {
+	t.Helper()
+	svc := database.NewService(database.NewMemoryRepository(), blob.NewMemoryStore())
+	ticketID, err := svc.Add(context.Background(), &internal.Document{Title: "Title", Author: "Author", Topic: "Topic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return endpoints.NewEndpointSet(svc), ticketID
+}
+
+func TestHTTPUpdateConflict(t *testing.T) {
+	ep, ticketID := newTestEndpoints(t)
+	handler := NewHTTPHandler(ep, "")
+	update := func(title, match string) *httptest.ResponseRecorder {
+		body := `{"ticketID":"` + ticketID + `","document":{"title":"` + title + `"}}`
+		r := httptest.NewRequest(http.MethodPost, "/update", strings.NewReader(body))
+		r.Header.Set("If-Match", match)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := update("Alice", `"1"`); w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("first update returned %d with ETag %s, want %d with ETag %s", w.Code, w.Header().Get("ETag"), http.StatusOK, `"2"`)
+	}
+	if w := update("Bob", `"1"`); w.Code != http.StatusConflict {
+		t.Errorf("stale update returned %d, want %d", w.Code, http.StatusConflict)
+	}
+	if w := update("Bob", "*"); w.Code != http.StatusOK {
+		t.Errorf("update of any version returned %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := update("Bob", "yesterday"); w.Code != http.StatusBadRequest {
+		t.Errorf("update with an invalid ETag returned %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHTTPAdmin(t *testing.T) {
+	ep, ticketID := newTestEndpoints(t)
+	handler := NewHTTPHandler(ep, "secret")
+	post := func(path, authorization string) int {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"ticketID":"`+ticketID+`"}`))
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := post("/remove", ""); code != http.StatusOK {
+		t.Fatalf("remove returned %d", code)
+	}
+	for _, tt := range []struct {
+		path, authorization string
+		code                int
+	}{
+		{"/restore", "", http.StatusForbidden},
+		{"/restore", "Bearer other", http.StatusForbidden},
+		{"/restore", "secret", http.StatusForbidden},
+		{"/purge", "", http.StatusForbidden},
+		{"/restore", "Bearer secret", http.StatusOK},
+		{"/purge", "Bearer secret", http.StatusOK},
+		{"/purge", "Bearer secret", http.StatusNotFound},
+	} {
+		if code := post(tt.path, tt.authorization); code != tt.code {
+			t.Errorf("%s with %q returned %d, want %d", tt.path, tt.authorization, code, tt.code)
+		}
+	}
+}
+
+func TestIsAdminToken(t *testing.T) {
+	for _, tt := range []struct {
+		adminToken, authorization string
+		want                      bool
+	}{
+		{"secret", "Bearer secret", true},
+		{"secret", "Bearer other", false},
+		{"secret", "secret", false},
+		{"secret", "", false},
+		// without a token nobody is an admin
+		{"", "Bearer ", false},
+		{"", "", false},
+	} {
+		if got := isAdminToken(tt.adminToken, tt.authorization); got != tt.want {
+			t.Errorf("isAdminToken(%q, %q) returned %v, want %v", tt.adminToken, tt.authorization, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"publisher/internal"
+	"publisher/internal/util"
+	"publisher/pkg/database/endpoints"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sseKeepAlive is how often a comment is sent on an idle event stream, so
+// proxies do not close the connection.
+const sseKeepAlive = 15 * time.Second
+
+// decodeHTTPWatchRequest reads the offset to resume after from the
+// Last-Event-ID header sent by reconnecting EventSource clients, or from the
+// after query parameter. ticketID and type (repeated or comma separated)
+// select the events.
+func decodeHTTPWatchRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	req := endpoints.WatchRequest{TicketID: q.Get("ticketID")}
+	after := r.Header.Get("Last-Event-ID")
+	if after == "" {
+		after = q.Get("after")
+	}
+	if after != "" {
+		offset, err := strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			return nil, util.ErrInvalidArgument
+		}
+		req.After = offset
+	}
+	for _, v := range q["type"] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				req.Types = append(req.Types, internal.EventType(t))
+			}
+		}
+	}
+	return req, nil
+}
+
+// encodeHTTPWatchResponse sends the events as Server-Sent Events until the
+// client disconnects. The id of an event is its offset.
+func encodeHTTPWatchResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(endpoints.WatchResponse)
+	if resp.Err != "" {
+		return encodeResponse(ctx, w, resp)
+	}
+	defer resp.Events.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// disables the response buffering of nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	flush()
+
+	type result struct {
+		ev  *internal.Event
+		err error
+	}
+	results := make(chan result)
+	go func() {
+		for {
+			ev, err := resp.Events.Next()
+			select {
+			case results <- result{ev, err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			flush()
+		case res := <-results:
+			if res.err != nil {
+				if ctx.Err() == nil {
+					logger.Log("method", "Watch", "err", res.err)
+					fmt.Fprintf(w, "event: error\ndata: %s\n\n", res.err)
+				}
+				return nil
+			}
+			data, err := json.Marshal(res.ev)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", res.ev.Offset, res.ev.Type, data); err != nil {
+				return nil
+			}
+			flush()
+		}
+	}
+}
+
+func encodeHTTPWatchRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(endpoints.WatchRequest)
+	q := r.URL.Query()
+	q.Set("after", strconv.FormatInt(req.After, 10))
+	if req.TicketID != "" {
+		q.Set("ticketID", req.TicketID)
+	}
+	for _, t := range req.Types {
+		q.Add("type", string(t))
+	}
+	r.URL.RawQuery = q.Encode()
+	r.Header.Set("Accept", "text/event-stream")
+	return nil
+}
+
+// decodeHTTPWatchResponse returns a reader over the event stream, which
+// stays open until the reader is closed.
+func decodeHTTPWatchResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		defer r.Body.Close()
+		var resp endpoints.WatchResponse
+		err := decodeHTTPResponse(r, &resp)
+		return resp, err
+	}
+	lines := bufio.NewScanner(r.Body)
+	lines.Buffer(nil, maxLineSize)
+	return endpoints.WatchResponse{Events: &sseReader{body: r.Body, lines: lines}}, nil
+}
+
+// sseReader parses the events of a Server-Sent Events stream.
+type sseReader struct {
+	body  io.ReadCloser
+	lines *bufio.Scanner
+}
+
+func (s *sseReader) Next() (*internal.Event, error) {
+	var typ, data string
+	for s.lines.Scan() {
+		line := s.lines.Text()
+		switch {
+		case line == "":
+			if data == "" {
+				continue
+			}
+			if typ == "error" {
+				return nil, util.ParseError(data)
+			}
+			var ev internal.Event
+			if err := json.Unmarshal([]byte(data), &ev); err != nil {
+				return nil, err
+			}
+			return &ev, nil
+		case strings.HasPrefix(line, "event:"):
+			typ = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+		}
+	}
+	if err := s.lines.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+func (s *sseReader) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"publisher/internal"
+	orm "publisher/internal/database"
+	"publisher/internal/util"
+	"strings"
+	"time"
+)
+
+// WatermarkJob returns the state of the watermark of the ticket,
+// util.ErrUnknown if no watermark was requested.
+func (d *dbService) WatermarkJob(ctx context.Context, ticketID string) (internal.WatermarkJob, error) {
+	job, err := d.repo.FindWatermarkJob(ctx, ticketID)
+	if err != nil {
+		return internal.WatermarkJob{TicketID: ticketID}, d.logError("WatermarkJob", ticketID, err)
+	}
+	return job.ToInternal(), nil
+}
+
+// TransitionWatermark moves the watermark job of the ticket to the status of
+// the transition. The document row stays locked meanwhile, so concurrent
+// transitions of a ticket are applied one after the other. A transition the
+// current status does not allow returns util.ErrConflict.
+func (d *dbService) TransitionWatermark(ctx context.Context, ticketID string, change internal.WatermarkTransition) (internal.WatermarkJob, error) {
+	if !internal.ValidStatus(change.To) {
+		return internal.WatermarkJob{TicketID: ticketID}, util.ErrInvalidArgument
+	}
+	if change.To == internal.Pending && !change.Retry && strings.TrimSpace(change.Mark) == "" {
+		return internal.WatermarkJob{TicketID: ticketID}, util.ErrInvalidArgument
+	}
+	var job *orm.WatermarkJob
+	err := d.repo.Transaction(ctx, func(tx Repository) error {
+		if _, err := tx.Find(ctx, ticketID, false); err != nil {
+			return err
+		}
+		var err error
+		job, err = tx.FindWatermarkJob(ctx, ticketID)
+		switch {
+		case errors.Is(err, util.ErrUnknown):
+			job = &orm.WatermarkJob{TicketID: ticketID}
+		case err != nil:
+			return err
+		}
+		from := internal.Status(job.Status)
+		if change.Retry {
+			if change.To != internal.Pending || !from.CanRetry() {
+				return fmt.Errorf("%w: the watermark is %s", util.ErrConflict, from)
+			}
+		} else if !from.CanTransition(change.To) {
+			if from == "" {
+				return fmt.Errorf("%w: no watermark was requested", util.ErrConflict)
+			}
+			return fmt.Errorf("%w: the watermark is %s", util.ErrConflict, from)
+		}
+		applyTransition(job, change, time.Now())
+		return tx.SaveWatermarkJob(ctx, job)
+	})
+	if err != nil {
+		return internal.WatermarkJob{TicketID: ticketID}, d.logError("TransitionWatermark", ticketID, err)
+	}
+	return job.ToInternal(), nil
+}
+
+// applyTransition sets the status of the job and the fields belonging to it,
+// a job requested again starts over while a retried one keeps its request.
+func applyTransition(job *orm.WatermarkJob, change internal.WatermarkTransition, now time.Time) {
+	job.Status = string(change.To)
+	job.UpdatedAt = now
+	switch change.To {
+	case internal.Pending:
+		if change.Retry {
+			job.Reason, job.StartedAt = change.Reason, nil
+			break
+		}
+		job.Mark, job.Reason = change.Mark, ""
+		job.CreatedAt, job.StartedAt, job.FinishedAt = now, nil, nil
+	case internal.Started:
+		job.StartedAt = &now
+	case internal.Finished:
+		job.Reason, job.FinishedAt = "", &now
+	case internal.Failed:
+		job.Reason = change.Reason
+		job.FinishedAt = &now
+	}
+}
@@ -0,0 +1,154 @@
+// Package fingerprint estimates the similarity of texts by MinHash signatures
+// of their word shingles, see https://en.wikipedia.org/wiki/MinHash.
+package fingerprint
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// NumHashes is the number of hash functions of a signature.
+	NumHashes = 128
+	// NumBands is the number of bands used to look up similar signatures,
+	// signatures sharing a band are candidates. With 2 hashes per band a
+	// pair with a similarity of 0.3 shares a band with a probability of
+	// 99.8%, so candidates above that similarity are rarely missed.
+	NumBands = 64
+	// ShingleSize is the number of words of a shingle.
+	ShingleSize = 3
+
+	rowsPerBand = NumHashes / NumBands
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Signature is the MinHash signature of a text, the share of equal values of
+// two signatures estimates the Jaccard similarity of their shingle sets.
+type Signature []uint64
+
+// seeds derive the hash functions of a signature from the shingle hash, they
+// must never change since signatures are stored.
+var seeds = func() [NumHashes]uint64 {
+	var s [NumHashes]uint64
+	x := uint64(0x5851f42d4c957f2d)
+	for i := range s {
+		x = splitmix64(x)
+		s[i] = x
+	}
+	return s
+}()
+
+// New returns the signature of the text, nil if it has no words. Case,
+// punctuation and whitespace are ignored.
+func New(text string) Signature {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return nil
+	}
+	sig := make(Signature, NumHashes)
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	n := len(words) - ShingleSize + 1
+	if n < 1 {
+		// a short text is a single shingle
+		n = 1
+	}
+	for i := 0; i < n; i++ {
+		end := i + ShingleSize
+		if end > len(words) {
+			end = len(words)
+		}
+		h := shingleHash(words[i:end])
+		for j, seed := range seeds {
+			if v := splitmix64(h ^ seed); v < sig[j] {
+				sig[j] = v
+			}
+		}
+	}
+	return sig
+}
+
+func shingleHash(words []string) uint64 {
+	h := fnv.New64a()
+	for _, w := range words {
+		h.Write([]byte(w))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// splitmix64 is the finalizer of the SplitMix64 generator, it spreads the
+// bits of x over the result.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// Similarity estimates the Jaccard similarity of the texts of the signatures,
+// it is 0 if either signature is empty.
+func Similarity(a, b Signature) float64 {
+	if len(a) != NumHashes || len(b) != NumHashes {
+		return 0
+	}
+	equal := 0
+	for i := range a {
+		if a[i] == b[i] {
+			equal++
+		}
+	}
+	return float64(equal) / NumHashes
+}
+
+// Bands returns the hashes of the bands of the signature by band number, nil
+// for an empty signature. Only hashes of the same band number are comparable.
+func (s Signature) Bands() []int64 {
+	if len(s) != NumHashes {
+		return nil
+	}
+	bands := make([]int64, NumBands)
+	var buf [8]byte
+	for i := range bands {
+		h := fnv.New64a()
+		for _, v := range s[i*rowsPerBand : (i+1)*rowsPerBand] {
+			binary.BigEndian.PutUint64(buf[:], v)
+			h.Write(buf[:])
+		}
+		bands[i] = int64(h.Sum64())
+	}
+	return bands
+}
+
+// MarshalBinary encodes the signature as big endian values.
+func (s Signature) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 8*len(s))
+	for i, v := range s {
+		binary.BigEndian.PutUint64(b[8*i:], v)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a signature encoded by MarshalBinary.
+func (s *Signature) UnmarshalBinary(b []byte) error {
+	if len(b) == 0 {
+		*s = nil
+		return nil
+	}
+	if len(b) != 8*NumHashes {
+		return ErrInvalidSignature
+	}
+	sig := make(Signature, NumHashes)
+	for i := range sig {
+		sig[i] = binary.BigEndian.Uint64(b[8*i:])
+	}
+	*s = sig
+	return nil
+}
@@ -0,0 +1,129 @@
+package fingerprint
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// words returns the text of the numbered words from first to last.
+func words(first, last int) string {
+	w := make([]string, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		w = append(w, fmt.Sprintf("w%d", i))
+	}
+	return strings.Join(w, " ")
+}
+
+// jaccard returns the exact similarity of the shingle sets of the texts.
+func jaccard(a, b string) float64 {
+	shingles := func(text string) map[string]bool {
+		w := strings.Fields(text)
+		set := map[string]bool{}
+		for i := 0; i+ShingleSize <= len(w); i++ {
+			set[strings.Join(w[i:i+ShingleSize], " ")] = true
+		}
+		return set
+	}
+	sa, sb := shingles(a), shingles(b)
+	shared := 0
+	for s := range sa {
+		if sb[s] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(sa)+len(sb)-shared)
+}
+
+func TestSimilarity(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		a, b string
+	}{
+		{"equal", words(0, 199), words(0, 199)},
+		{"disjoint", words(0, 199), words(200, 399)},
+		{"three quarters", words(0, 199), words(25, 224)},
+		{"half", words(0, 199), words(67, 266)},
+		{"quarter", words(0, 199), words(120, 319)},
+		{"contained", words(0, 199), words(0, 99)},
+	} {
+		want := jaccard(tt.a, tt.b)
+		got := Similarity(New(tt.a), New(tt.b))
+		// the standard error of 128 hashes is below 0.045
+		if math.Abs(got-want) > 0.1 {
+			t.Errorf("%s: Similarity returned %.3f, want %.3f", tt.name, got, want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	text := "The quick brown fox jumps over the lazy dog"
+	for _, tt := range []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{"case and punctuation", text, "the QUICK, brown fox; jumps over the lazy dog!", 1},
+		{"whitespace", text, "The  quick\nbrown\tfox jumps over the lazy dog", 1},
+		{"short", "two words", "Two, words.", 1},
+		{"order", "one two three", "three two one", 0},
+		{"empty", "", "", 0},
+		{"punctuation only", "...", text, 0},
+	} {
+		if got := Similarity(New(tt.a), New(tt.b)); got != tt.want {
+			t.Errorf("%s: Similarity returned %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if sig := New(" -- "); sig != nil {
+		t.Errorf("New of a text without words returned %v, want nil", sig)
+	}
+	if sig := New(text); len(sig) != NumHashes {
+		t.Errorf("New returned %d hashes, want %d", len(sig), NumHashes)
+	}
+}
+
+func TestSignatureMarshal(t *testing.T) {
+	sig := New(words(0, 50))
+	b, err := sig.MarshalBinary()
+	if err != nil || len(b) != 8*NumHashes {
+		t.Fatalf("MarshalBinary returned %d bytes, %v", len(b), err)
+	}
+	var decoded Signature
+	if err := decoded.UnmarshalBinary(b); err != nil || !reflect.DeepEqual(decoded, sig) {
+		t.Errorf("UnmarshalBinary returned %v, want the signature", err)
+	}
+
+	var empty Signature
+	if b, err := Signature(nil).MarshalBinary(); err != nil || len(b) != 0 {
+		t.Errorf("MarshalBinary of no signature returned %v, %v", b, err)
+	}
+	if err := empty.UnmarshalBinary(nil); err != nil || empty != nil {
+		t.Errorf("UnmarshalBinary of no bytes returned %v, %v", empty, err)
+	}
+	for _, n := range []int{8, 8*NumHashes - 1, 8*NumHashes + 8} {
+		if err := decoded.UnmarshalBinary(make([]byte, n)); err != ErrInvalidSignature {
+			t.Errorf("UnmarshalBinary of %d bytes returned %v, want %v", n, err, ErrInvalidSignature)
+		}
+	}
+}
+
+func TestBands(t *testing.T) {
+	a, b := New(words(0, 199)), New(words(10, 209))
+	if bands := a.Bands(); len(bands) != NumBands || !reflect.DeepEqual(bands, New(words(0, 199)).Bands()) {
+		t.Errorf("Bands returned %d bands, want %d equal for equal texts", len(bands), NumBands)
+	}
+	shared := 0
+	for i, band := range a.Bands() {
+		if b.Bands()[i] == band {
+			shared++
+		}
+	}
+	if shared == 0 {
+		t.Errorf("similar texts share no band")
+	}
+	if bands := Signature(nil).Bands(); bands != nil {
+		t.Errorf("Bands of no signature returned %v, want nil", bands)
+	}
+}
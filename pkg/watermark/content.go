@@ -0,0 +1,188 @@
+package watermark
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"publisher/internal"
+	"publisher/internal/util"
+	"publisher/pkg/watermark/epub"
+	"publisher/pkg/watermark/pdf"
+	"publisher/pkg/watermark/raster"
+	"publisher/pkg/watermark/text"
+	"unicode/utf8"
+)
+
+// maxMarkedContent limits the size of a content the mark is embedded into,
+// the content is held in memory meanwhile
+const maxMarkedContent = 64 << 20
+
+// Names of the encodings of images and PDF documents in a detection.
+const (
+	encodingDCT     = "dct"
+	encodingPDFInfo = "pdf-info"
+	encodingStamp   = "pdf-stamp"
+)
+
+// errUnsupportedContent is returned for a content the mark cannot be
+// embedded into, the document then only records the mark.
+var errUnsupportedContent = errors.New("the content cannot carry a watermark")
+
+// apply embeds the mark into the content of the document and records it. A
+// document changed meanwhile returns util.ErrConflict, so the job is retried
+// with the new content.
+func (p *Pool) apply(ctx context.Context, ticketID, mark string) error {
+	query := internal.Query{Filters: []internal.Filter{{Key: internal.KeyTicketID, Op: internal.OpEq, Value: ticketID}}, PageSize: 1}
+	page, err := p.db.Get(ctx, query)
+	if err != nil {
+		return err
+	}
+	if len(page.Documents) == 0 {
+		return util.ErrUnknown
+	}
+	doc := page.Documents[0]
+	version := doc.Version
+	if doc.ContentDigest != "" {
+		content, err := p.readContent(ctx, ticketID)
+		if err != nil {
+			return err
+		}
+		// a retried job finds the content marked already, marking it again
+		// would stack the visible overlays of an image
+		if found, err := detectContent(content); err == nil && found.Mark == mark {
+			_, err = p.db.Update(ctx, ticketID, &internal.Document{Watermark: mark, Version: version})
+			return err
+		}
+		marked, err := p.markContent(content, mark)
+		switch {
+		case errors.Is(err, errUnsupportedContent), errors.Is(err, text.ErrNoCapacity),
+			errors.Is(err, raster.ErrUnsupported), errors.Is(err, raster.ErrNoCapacity),
+			errors.Is(err, pdf.ErrUnsupported), errors.Is(err, pdf.ErrEncrypted),
+			errors.Is(err, epub.ErrUnsupported), errors.Is(err, epub.ErrEncrypted):
+			logger.Log("method", "apply", "ticketID", ticketID, "msg", "the mark is only recorded", "err", err)
+		case err != nil:
+			return err
+		default:
+			updated, err := p.db.PutContent(ctx, ticketID, bytes.NewReader(marked), version)
+			if err != nil {
+				return err
+			}
+			version = updated.Version
+		}
+	}
+	_, err = p.db.Update(ctx, ticketID, &internal.Document{Watermark: mark, Version: version})
+	return err
+}
+
+// readContent reads the whole content of the document, errUnsupportedContent
+// if it exceeds maxMarkedContent.
+func (p *Pool) readContent(ctx context.Context, ticketID string) ([]byte, error) {
+	content, err := p.db.OpenContent(ctx, ticketID, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Body.Close()
+	if content.Size > maxMarkedContent {
+		return nil, errUnsupportedContent
+	}
+	return io.ReadAll(io.LimitReader(content.Body, maxMarkedContent))
+}
+
+// contentKind sniffs the type of the content, it is "image" for a PNG or
+// JPEG image, "pdf" for a PDF document, "epub" for a ZIP archive, which is
+// taken for an e-book, "text" for UTF-8 text and empty otherwise.
+func contentKind(content []byte) string {
+	switch http.DetectContentType(content) {
+	case "image/png", "image/jpeg":
+		return "image"
+	case "application/pdf":
+		return "pdf"
+	case "application/zip":
+		return "epub"
+	}
+	if utf8.Valid(content) {
+		return "text"
+	}
+	return ""
+}
+
+// detectContent recovers the mark from a suspect content, util.ErrUnknown if
+// it carries none.
+func detectContent(content []byte) (internal.Detection, error) {
+	detection := internal.Detection{Encodings: []string{}}
+	switch contentKind(content) {
+	case "image":
+		res, err := raster.Extract(content)
+		if errors.Is(err, raster.ErrNoMark) {
+			return detection, fmt.Errorf("%w: %v", util.ErrUnknown, err)
+		}
+		if err != nil {
+			return detection, fmt.Errorf("%w: %v", util.ErrInvalidArgument, err)
+		}
+		detection.Mark, detection.Confidence, detection.Frames = res.Mark, res.Confidence, res.Frames
+		detection.Encodings = append(detection.Encodings, encodingDCT)
+	case "pdf":
+		res, err := pdf.Extract(content)
+		if errors.Is(err, pdf.ErrNoMark) {
+			return detection, fmt.Errorf("%w: %v", util.ErrUnknown, err)
+		}
+		if err != nil {
+			return detection, fmt.Errorf("%w: %v", util.ErrInvalidArgument, err)
+		}
+		// the document information holds the mark once, Frames counts the
+		// stamped pages
+		detection.Mark, detection.Confidence, detection.Frames = res.Mark, 1, res.Stamped
+		detection.Encodings = append(detection.Encodings, encodingPDFInfo)
+		if res.Stamped > 0 {
+			detection.Encodings = append(detection.Encodings, encodingStamp)
+		}
+	case "epub":
+		res, err := epub.Extract(content)
+		if errors.Is(err, epub.ErrNoMark) {
+			return detection, fmt.Errorf("%w: %v", util.ErrUnknown, err)
+		}
+		if err != nil {
+			return detection, fmt.Errorf("%w: %v", util.ErrInvalidArgument, err)
+		}
+		detection.Mark, detection.Confidence, detection.Frames = res.Mark, res.Confidence, res.Frames
+		detection.Encodings = append(detection.Encodings, res.Encodings...)
+	case "text":
+		res, err := text.Extract(string(content))
+		if errors.Is(err, text.ErrNoMark) {
+			return detection, fmt.Errorf("%w: %v", util.ErrUnknown, err)
+		}
+		if err != nil {
+			return detection, err
+		}
+		detection.Mark, detection.Confidence, detection.Frames = res.Mark, res.Confidence, res.Frames
+		for _, e := range res.Encodings {
+			detection.Encodings = append(detection.Encodings, string(e))
+		}
+	default:
+		return detection, fmt.Errorf("%w: %v", util.ErrUnknown, errUnsupportedContent)
+	}
+	return detection, nil
+}
+
+// markContent returns the content carrying the mark, the type of the
+// content selects how the mark is embedded.
+func (p *Pool) markContent(content []byte, mark string) ([]byte, error) {
+	switch contentKind(content) {
+	case "image":
+		return raster.Embed(content, mark, p.image)
+	case "pdf":
+		return pdf.Embed(content, mark, p.pdf)
+	case "epub":
+		return epub.Embed(content, mark, p.epub)
+	case "text":
+		marked, err := text.Embed(string(content), mark, p.text)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(marked), nil
+	}
+	return nil, errUnsupportedContent
+}
@@ -3,8 +3,11 @@ package endpoints
 import (
 	"context"
 	"errors"
+	"io"
+	"net/http"
 	"os"
 	"publisher/internal"
+	"publisher/internal/util"
 	"publisher/pkg/watermark"
 
 	"github.com/go-kit/kit/endpoint"
@@ -15,39 +18,70 @@ var logger log.Logger
 
 type Set struct {
 	GetEndpoint           endpoint.Endpoint
+	SearchEndpoint        endpoint.Endpoint
 	AddDocumentEndpoint   endpoint.Endpoint
 	StatusEndpoint        endpoint.Endpoint
 	ServiceStatusEndpoint endpoint.Endpoint
 	WatermarkEndpoint     endpoint.Endpoint
+	// UploadDocumentEndpoint, ResumeUploadEndpoint and DownloadDocumentEndpoint
+	// stream the content, their transports pass the bodies as readers.
+	UploadDocumentEndpoint   endpoint.Endpoint
+	ResumeUploadEndpoint     endpoint.Endpoint
+	UploadStateEndpoint      endpoint.Endpoint
+	DownloadDocumentEndpoint endpoint.Endpoint
+	HandleEventEndpoint      endpoint.Endpoint
+	DetectEndpoint           endpoint.Endpoint
 }
 
 func NewEndpointSet(s watermark.Service) Set {
 	return Set{
 		GetEndpoint:           MakeGetEndpoint(s),
+		SearchEndpoint:        MakeSearchEndpoint(s),
 		AddDocumentEndpoint:   MakeAddDocumentEndpoint(s),
 		StatusEndpoint:        MakeStatusEndpoint(s),
 		ServiceStatusEndpoint: MakeServiceStatusEndpoint(s),
 		WatermarkEndpoint:     MakeWatermarkEndpoint(s),
+
+		UploadDocumentEndpoint:   MakeUploadDocumentEndpoint(s),
+		ResumeUploadEndpoint:     MakeResumeUploadEndpoint(s),
+		UploadStateEndpoint:      MakeUploadStateEndpoint(s),
+		DownloadDocumentEndpoint: MakeDownloadDocumentEndpoint(s),
+		HandleEventEndpoint:      MakeHandleEventEndpoint(s),
+		DetectEndpoint:           MakeDetectEndpoint(s),
 	}
 }
 
 func MakeGetEndpoint(s watermark.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(GetRequest)
-		docs, err := s.Get(ctx, req.Filters...)
+		page, err := s.Get(ctx, internal.Query{Filters: req.Filters, PageSize: req.PageSize, Cursor: req.Cursor})
 		if err != nil {
-			return GetResponse{docs, err.Error()}, nil
+			return GetResponse{Documents: page.Documents, Err: err.Error()}, nil
 		}
-		return GetResponse{docs, ""}, nil
+		return GetResponse{Documents: page.Documents, NextCursor: page.NextCursor, Total: page.Total, Err: ""}, nil
+	}
+}
+
+func MakeSearchEndpoint(s watermark.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SearchRequest)
+		results, err := s.Search(ctx, internal.SearchQuery{Text: req.Text, Limit: req.Limit})
+		if err != nil {
+			return SearchResponse{Results: results, Err: err.Error()}, nil
+		}
+		return SearchResponse{Results: results, Err: ""}, nil
 	}
 }
 
 func MakeAddDocumentEndpoint(s watermark.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(AddDocumentRequest)
+		if req.IdempotencyKey != "" {
+			ctx = internal.WithIdempotencyKey(ctx, req.IdempotencyKey)
+		}
 		ticketID, err := s.AddDocument(ctx, req.Document)
 		if err != nil {
-			return AddDocumentResponse{TicketID: ticketID, Err: err.Error()}, nil
+			return AddDocumentResponse{TicketID: ticketID, Code: errorCode(err), Err: err.Error()}, nil
 		}
 		return AddDocumentResponse{TicketID: ticketID, Err: ""}, nil
 	}
@@ -56,11 +90,11 @@ func MakeAddDocumentEndpoint(s watermark.Service) endpoint.Endpoint {
 func MakeStatusEndpoint(s watermark.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(StatusRequest)
-		status, err := s.Status(ctx, req.TicketID)
+		job, err := s.Status(ctx, req.TicketID)
 		if err != nil {
-			return StatusResponse{Status: status, Err: err.Error()}, nil
+			return StatusResponse{WatermarkJob: job, Code: errorCode(err), Err: err.Error()}, nil
 		}
-		return StatusResponse{Status: status, Err: ""}, nil
+		return StatusResponse{WatermarkJob: job, Err: ""}, nil
 	}
 }
 
@@ -86,40 +120,135 @@ func MakeWatermarkEndpoint(s watermark.Service) endpoint.Endpoint {
 	}
 }
 
-func (s *Set) Get(ctx context.Context, filters ...internal.Filter) ([]internal.Document, error) {
-	resp, err := s.GetEndpoint(ctx, GetRequest{Filters: filters})
+func MakeUploadDocumentEndpoint(s watermark.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UploadDocumentRequest)
+		upload, err := s.UploadDocument(ctx, req.Document, req.Size, req.Final, req.Body)
+		if err != nil {
+			return UploadResponse{Upload: upload, Code: errorCode(err), Err: err.Error()}, nil
+		}
+		return UploadResponse{Upload: upload, Code: http.StatusCreated, Err: ""}, nil
+	}
+}
+
+func MakeResumeUploadEndpoint(s watermark.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ResumeUploadRequest)
+		upload, err := s.ResumeUpload(ctx, req.TicketID, req.Offset, req.Size, req.Final, req.Body)
+		if err != nil {
+			return UploadResponse{Upload: upload, Code: errorCode(err), Err: err.Error()}, nil
+		}
+		return UploadResponse{Upload: upload, Code: http.StatusOK, Err: ""}, nil
+	}
+}
+
+func MakeUploadStateEndpoint(s watermark.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UploadStateRequest)
+		upload, err := s.UploadState(ctx, req.TicketID)
+		if err != nil {
+			return UploadResponse{Upload: upload, Code: errorCode(err), Err: err.Error()}, nil
+		}
+		return UploadResponse{Upload: upload, Code: http.StatusOK, Err: ""}, nil
+	}
+}
+
+func MakeDownloadDocumentEndpoint(s watermark.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(DownloadDocumentRequest)
+		content, err := s.DownloadDocument(ctx, req.TicketID, req.Offset, req.Length)
+		if err != nil {
+			return DownloadDocumentResponse{Content: content, Code: errorCode(err), Err: err.Error()}, nil
+		}
+		return DownloadDocumentResponse{Content: content, Code: http.StatusOK, Err: ""}, nil
+	}
+}
+
+func MakeHandleEventEndpoint(s watermark.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(HandleEventRequest)
+		if err := s.HandleEvent(ctx, req.Event); err != nil {
+			return HandleEventResponse{Code: errorCode(err), Err: err.Error()}, nil
+		}
+		return HandleEventResponse{Err: ""}, nil
+	}
+}
+
+func MakeDetectEndpoint(s watermark.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(DetectRequest)
+		detection, err := s.Detect(ctx, req.Body)
+		if err != nil {
+			return DetectResponse{Detection: detection, Code: errorCode(err), Err: err.Error()}, nil
+		}
+		return DetectResponse{Detection: detection, Err: ""}, nil
+	}
+}
+
+// errorCode maps the errors of the service to HTTP status codes.
+func errorCode(err error) int {
+	switch {
+	case errors.Is(err, util.ErrUnknown):
+		return http.StatusNotFound
+	case errors.Is(err, util.ErrInvalidArgument):
+		return http.StatusBadRequest
+	case errors.Is(err, util.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, util.ErrOutOfRange):
+		return http.StatusRequestedRangeNotSatisfiable
+	case errors.Is(err, util.ErrForbidden):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (s *Set) Get(ctx context.Context, query internal.Query) (internal.Page, error) {
+	resp, err := s.GetEndpoint(ctx, GetRequest{Filters: query.Filters, PageSize: query.PageSize, Cursor: query.Cursor})
 	if err != nil {
-		return []internal.Document{}, err
+		return internal.Page{Documents: []internal.Document{}}, err
 	}
 	getResp := resp.(GetResponse)
 	if getResp.Err != "" {
-		return []internal.Document{}, errors.New(getResp.Err)
+		return internal.Page{Documents: []internal.Document{}}, errors.New(getResp.Err)
+	}
+	return internal.Page{Documents: getResp.Documents, NextCursor: getResp.NextCursor, Total: getResp.Total}, nil
+}
+
+func (s *Set) Search(ctx context.Context, query internal.SearchQuery) ([]internal.SearchResult, error) {
+	resp, err := s.SearchEndpoint(ctx, SearchRequest{Text: query.Text, Limit: query.Limit})
+	if err != nil {
+		return []internal.SearchResult{}, err
+	}
+	searchResp := resp.(SearchResponse)
+	if searchResp.Err != "" {
+		return []internal.SearchResult{}, errors.New(searchResp.Err)
 	}
-	return getResp.Documents, nil
+	return searchResp.Results, nil
 }
 
 func (s *Set) AddDocument(ctx context.Context, doc *internal.Document) (string, error) {
-	resp, err := s.AddDocumentEndpoint(ctx, AddDocumentRequest{Document: doc})
+	resp, err := s.AddDocumentEndpoint(ctx, AddDocumentRequest{Document: doc, IdempotencyKey: internal.IdempotencyKeyFromContext(ctx)})
 	if err != nil {
 		return "", err
 	}
 	addResp := resp.(AddDocumentResponse)
 	if addResp.Err != "" {
-		return "", errors.New(addResp.Err)
+		return "", util.ParseError(addResp.Err)
 	}
 	return addResp.TicketID, nil
 }
 
-func (s *Set) Status(ctx context.Context, ticketID string) (internal.Status, error) {
+func (s *Set) Status(ctx context.Context, ticketID string) (internal.WatermarkJob, error) {
 	resp, err := s.StatusEndpoint(ctx, StatusRequest{TicketID: ticketID})
 	if err != nil {
-		return internal.Failed, err
+		return internal.WatermarkJob{TicketID: ticketID}, err
 	}
 	stsResp := resp.(StatusResponse)
 	if stsResp.Err != "" {
-		return internal.Failed, errors.New(stsResp.Err)
+		return stsResp.WatermarkJob, util.ParseError(stsResp.Err)
 	}
-	return stsResp.Status, nil
+	return stsResp.WatermarkJob, nil
 }
 
 func (s *Set) ServiceStatus(ctx context.Context) (int, error) {
@@ -141,12 +270,84 @@ func (s *Set) Watermark(ctx context.Context, ticketID, mark string) (int, error)
 		return wResp.Code, err
 	}
 	if wResp.Err != "" {
-		return wResp.Code, errors.New(wResp.Err)
+		return wResp.Code, util.ParseError(wResp.Err)
 	}
 
 	return wResp.Code, nil
 }
 
+func (s *Set) UploadDocument(ctx context.Context, doc *internal.Document, size int64, final bool, r io.Reader) (internal.Upload, error) {
+	resp, err := s.UploadDocumentEndpoint(ctx, UploadDocumentRequest{Document: doc, Size: size, Final: final, Body: r})
+	if err != nil {
+		return internal.Upload{}, err
+	}
+	uploadResp := resp.(UploadResponse)
+	if uploadResp.Err != "" {
+		return uploadResp.Upload, util.ParseError(uploadResp.Err)
+	}
+	return uploadResp.Upload, nil
+}
+
+func (s *Set) ResumeUpload(ctx context.Context, ticketID string, offset, size int64, final bool, r io.Reader) (internal.Upload, error) {
+	resp, err := s.ResumeUploadEndpoint(ctx, ResumeUploadRequest{TicketID: ticketID, Offset: offset, Size: size, Final: final, Body: r})
+	if err != nil {
+		return internal.Upload{TicketID: ticketID}, err
+	}
+	uploadResp := resp.(UploadResponse)
+	if uploadResp.Err != "" {
+		return uploadResp.Upload, util.ParseError(uploadResp.Err)
+	}
+	return uploadResp.Upload, nil
+}
+
+func (s *Set) UploadState(ctx context.Context, ticketID string) (internal.Upload, error) {
+	resp, err := s.UploadStateEndpoint(ctx, UploadStateRequest{TicketID: ticketID})
+	if err != nil {
+		return internal.Upload{TicketID: ticketID}, err
+	}
+	uploadResp := resp.(UploadResponse)
+	if uploadResp.Err != "" {
+		return uploadResp.Upload, util.ParseError(uploadResp.Err)
+	}
+	return uploadResp.Upload, nil
+}
+
+func (s *Set) DownloadDocument(ctx context.Context, ticketID string, offset, length int64) (internal.Content, error) {
+	resp, err := s.DownloadDocumentEndpoint(ctx, DownloadDocumentRequest{TicketID: ticketID, Offset: offset, Length: length})
+	if err != nil {
+		return internal.Content{}, err
+	}
+	downloadResp := resp.(DownloadDocumentResponse)
+	if downloadResp.Err != "" {
+		return downloadResp.Content, util.ParseError(downloadResp.Err)
+	}
+	return downloadResp.Content, nil
+}
+
+func (s *Set) HandleEvent(ctx context.Context, ev internal.Event) error {
+	resp, err := s.HandleEventEndpoint(ctx, HandleEventRequest{Event: ev})
+	if err != nil {
+		return err
+	}
+	eventResp := resp.(HandleEventResponse)
+	if eventResp.Err != "" {
+		return util.ParseError(eventResp.Err)
+	}
+	return nil
+}
+
+func (s *Set) Detect(ctx context.Context, r io.Reader) (internal.Detection, error) {
+	resp, err := s.DetectEndpoint(ctx, DetectRequest{Body: r})
+	if err != nil {
+		return internal.Detection{Encodings: []string{}}, err
+	}
+	detectResp := resp.(DetectResponse)
+	if detectResp.Err != "" {
+		return detectResp.Detection, util.ParseError(detectResp.Err)
+	}
+	return detectResp.Detection, nil
+}
+
 func init() {
 	logger = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
 	logger = log.With(logger, "ts", log.DefaultTimestampUTC)
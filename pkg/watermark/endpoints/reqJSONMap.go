@@ -1,28 +1,57 @@
 package endpoints
 
-import "publisher/internal"
+import (
+	"io"
+	"net/http"
+	"publisher/internal"
+	"strconv"
+)
 
 type GetRequest struct {
-	Filters []internal.Filter `json:"filters,omitempty"`
+	Filters  []internal.Filter `json:"filters,omitempty"`
+	PageSize int               `json:"pageSize,omitempty"`
+	Cursor   string            `json:"cursor,omitempty"`
 }
 
 type GetResponse struct {
-	Documents []internal.Document `json:"documents"`
-	Err       string              `json:"err,omitempty"`
+	Documents  []internal.Document `json:"documents"`
+	NextCursor string              `json:"nextCursor,omitempty"`
+	Total      int64               `json:"total"`
+	Err        string              `json:"err,omitempty"`
+}
+
+type SearchRequest struct {
+	Text  string `json:"text"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+type SearchResponse struct {
+	Results []internal.SearchResult `json:"results"`
+	Err     string                  `json:"err,omitempty"`
 }
 
 type StatusRequest struct {
 	TicketID string `json:"ticketID"`
 }
 
+// StatusResponse carries the fields of the watermark job, an unknown ticket
+// or a ticket without a job is reported as 404.
 type StatusResponse struct {
-	Status internal.Status `json:"status"`
-	Err    string          `json:"err,omitempty"`
+	internal.WatermarkJob
+	Code int    `json:"code,omitempty"`
+	Err  string `json:"err,omitempty"`
+}
+
+func (r StatusResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
 }
 
 type WatermarkRequest struct {
 	TicketID string `json:"ticketID"`
-	Mark     string `json:"json:mark"`
+	Mark     string `json:"mark"`
 }
 
 type WatermarkResponse struct {
@@ -30,18 +59,148 @@ type WatermarkResponse struct {
 	Err  string `json:"err"`
 }
 
+func (r WatermarkResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
 type AddDocumentRequest struct {
 	Document *internal.Document `json:"document"`
+	// IdempotencyKey makes retries of the request return the first ticket
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
 }
 
 type AddDocumentResponse struct {
 	TicketID string `json:"ticketID"`
+	Code     int    `json:"code,omitempty"`
 	Err      string `json:"err,omitempty"`
 }
 
+func (r AddDocumentResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
+type UploadDocumentRequest struct {
+	Document *internal.Document `json:"document"`
+	// Size of the content, 0 if unknown
+	Size int64 `json:"size,omitempty"`
+	// Final marks Body as the whole content
+	Final bool `json:"final,omitempty"`
+	// Body is streamed from the request instead of being encoded as JSON,
+	// nil starts an upload whose content is sent by ResumeUpload
+	Body io.Reader `json:"-"`
+}
+
+type ResumeUploadRequest struct {
+	TicketID string `json:"ticketID"`
+	// Offset is the offset of the first byte of Body
+	Offset int64 `json:"offset"`
+	// Size announces the size of an upload started with an unknown size
+	Size int64 `json:"size,omitempty"`
+	// Final marks Body as the last chunk of the content
+	Final bool      `json:"final,omitempty"`
+	Body  io.Reader `json:"-"`
+}
+
+type UploadStateRequest struct {
+	TicketID string `json:"ticketID"`
+}
+
+// UploadResponse is the response of UploadDocument, ResumeUpload and
+// UploadState.
+type UploadResponse struct {
+	Upload internal.Upload `json:"upload"`
+	Code   int             `json:"code"`
+	Err    string          `json:"err,omitempty"`
+}
+
+func (r UploadResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
+// Headers returns the offset to resume the upload at.
+func (r UploadResponse) Headers() http.Header {
+	h := http.Header{}
+	if r.Upload.TicketID != "" {
+		h.Set("Upload-Offset", strconv.FormatInt(r.Upload.Offset, 10))
+	}
+	if r.Upload.Size != 0 {
+		h.Set("Upload-Length", strconv.FormatInt(r.Upload.Size, 10))
+	}
+	return h
+}
+
+type DownloadDocumentRequest struct {
+	TicketID string `json:"ticketID"`
+	Offset   int64  `json:"offset"`
+	// Length of 0 reads to the end of the content
+	Length int64 `json:"length"`
+}
+
+type DownloadDocumentResponse struct {
+	// Content.Body is streamed as response body, the receiver must close it
+	Content internal.Content `json:"content"`
+	Code    int              `json:"code"`
+	Err     string           `json:"err,omitempty"`
+}
+
+func (r DownloadDocumentResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
 type ServiceStatusRequest struct{}
 
 type ServiceStatusResponse struct {
 	Code int    `json:"status"`
 	Err  string `json:"err,omitempty"`
 }
+
+// HandleEventRequest is an event of the database node, the request body is
+// the event itself.
+type HandleEventRequest struct {
+	Event internal.Event
+}
+
+type HandleEventResponse struct {
+	Code int    `json:"code,omitempty"`
+	Err  string `json:"err,omitempty"`
+}
+
+func (r HandleEventResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
+
+// DetectRequest carries the suspect content, the transports pass it as a
+// reader.
+type DetectRequest struct {
+	Body io.Reader `json:"-"`
+}
+
+// DetectResponse carries the recovered watermark, a content without a
+// watermark is reported as 404.
+type DetectResponse struct {
+	internal.Detection
+	Code int    `json:"code,omitempty"`
+	Err  string `json:"err,omitempty"`
+}
+
+func (r DetectResponse) StatusCode() int {
+	if r.Code == 0 {
+		return http.StatusOK
+	}
+	return r.Code
+}
@@ -0,0 +1,346 @@
+// Package epub watermarks EPUB e-books. The mark is embedded invisibly into
+// the text of every chapter and recorded in the metadata of the package
+// document, a colophon page printing it may be added to the end of the book.
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"publisher/pkg/watermark/text"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// MediaType is the content of the mimetype file of an EPUB.
+	MediaType = "application/epub+zip"
+	// DefaultColophon is the text of the colophon page, {mark} stands for the
+	// mark.
+	DefaultColophon = "This copy is licensed to {mark}."
+	// EncodingMetadata names the mark of the package document in a Result.
+	EncodingMetadata = "opf-meta"
+)
+
+const (
+	// metaName is the name of the meta element holding the mark
+	metaName = "publisher-watermark"
+	// colophonID and colophonFile name the colophon in the manifest and in
+	// the directory of the package document
+	colophonID   = "publisher-watermark"
+	colophonFile = "publisher-watermark.xhtml"
+	// maxFileSize limits the size of a file of the archive once unpacked
+	maxFileSize = 64 << 20
+)
+
+var (
+	// ErrInvalidMark is returned for an empty or too long mark.
+	ErrInvalidMark = text.ErrInvalidMark
+	// ErrUnsupported is returned for a content which is no readable EPUB.
+	ErrUnsupported = errors.New("the content is not a supported EPUB")
+	// ErrEncrypted is returned for an e-book whose chapters are encrypted.
+	ErrEncrypted = errors.New("the EPUB is encrypted")
+	// ErrNoCapacity is returned if no chapter has text enough to carry the
+	// mark and no colophon is added.
+	ErrNoCapacity = text.ErrNoCapacity
+	// ErrNoMark is returned if the e-book carries no mark.
+	ErrNoMark = errors.New("no watermark was found")
+)
+
+// Options configure how a mark is embedded.
+type Options struct {
+	// Text configures the invisible mark of the chapters
+	Text text.Options
+	// Colophon is the text of a page added to the end of the book, {mark}
+	// replaced by the mark. An empty text adds no page.
+	Colophon string
+}
+
+// DefaultOptions embeds the mark invisibly without a colophon.
+func DefaultOptions() Options {
+	return Options{Text: text.DefaultOptions()}
+}
+
+// book is an unpacked EPUB.
+type book struct {
+	zip     *zip.Reader
+	files   map[string]*zip.File
+	opfPath string
+	opf     []byte
+	pkg     packageDoc
+}
+
+// open reads the container and the package document of the e-book.
+func open(content []byte) (*book, error) {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	b := &book{zip: zr, files: map[string]*zip.File{}}
+	for _, f := range zr.File {
+		b.files[f.Name] = f
+	}
+	mimetype, err := b.read("mimetype")
+	if err != nil || strings.TrimSpace(string(mimetype)) != MediaType {
+		return nil, fmt.Errorf("%w: the archive is no EPUB", ErrUnsupported)
+	}
+	c, err := b.read("META-INF/container.xml")
+	if err != nil {
+		return nil, err
+	}
+	if b.opfPath, err = rootfile(c); err != nil {
+		return nil, err
+	}
+	if b.opf, err = b.read(b.opfPath); err != nil {
+		return nil, err
+	}
+	if err := xml.Unmarshal(b.opf, &b.pkg); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	return b, nil
+}
+
+// read unpacks a file of the archive.
+func (b *book) read(name string) ([]byte, error) {
+	f, ok := b.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is missing", ErrUnsupported, name)
+	}
+	if f.UncompressedSize64 > maxFileSize {
+		return nil, fmt.Errorf("%w: %s is too large", ErrUnsupported, name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if len(data) > maxFileSize {
+		return nil, fmt.Errorf("%w: %s is too large", ErrUnsupported, name)
+	}
+	return data, nil
+}
+
+// encryption is META-INF/encryption.xml, which lists the encrypted files.
+type encryption struct {
+	URIs []struct {
+		URI string `xml:"URI,attr"`
+	} `xml:"EncryptedData>CipherData>CipherReference"`
+}
+
+// encrypted tells whether one of the chapters is encrypted, fonts are often
+// obfuscated that way and are no matter.
+func (b *book) encrypted(chapters []string) (bool, error) {
+	if _, ok := b.files["META-INF/encryption.xml"]; !ok {
+		return false, nil
+	}
+	data, err := b.read("META-INF/encryption.xml")
+	if err != nil {
+		return false, err
+	}
+	var e encryption
+	if err := xml.Unmarshal(data, &e); err != nil {
+		return false, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	for _, ref := range e.URIs {
+		for _, c := range chapters {
+			if resolve("", ref.URI) == c {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// Embed returns the e-book carrying the mark in the text of every chapter
+// with enough text and in its metadata. A mark embedded before is replaced.
+func Embed(content []byte, mark string, opts Options) ([]byte, error) {
+	if mark == "" || len(mark) > text.MaxMarkLength {
+		return nil, ErrInvalidMark
+	}
+	b, err := open(content)
+	if err != nil {
+		return nil, err
+	}
+	chapters := b.pkg.chapters(b.opfPath)
+	if encrypted, err := b.encrypted(chapters); err != nil {
+		return nil, err
+	} else if encrypted {
+		return nil, ErrEncrypted
+	}
+
+	changed := map[string][]byte{}
+	for _, c := range chapters {
+		if _, ok := changed[c]; ok {
+			continue
+		}
+		doc, err := b.read(c)
+		if err != nil {
+			return nil, err
+		}
+		marked, err := markChapter(doc, mark, opts.Text)
+		if errors.Is(err, text.ErrNoCapacity) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		changed[c] = marked
+	}
+	if len(changed) == 0 && opts.Colophon == "" {
+		return nil, ErrNoCapacity
+	}
+
+	colophonPath := resolve(b.opfPath, colophonFile)
+	colophonHref := ""
+	if opts.Colophon != "" {
+		colophonHref = colophonFile
+		changed[colophonPath] = colophonPage(b.pkg.Version, strings.ReplaceAll(opts.Colophon, "{mark}", mark))
+	}
+	now := time.Now()
+	if changed[b.opfPath], err = updatePackage(b.opf, mark, colophonHref, now); err != nil {
+		return nil, err
+	}
+	removed := ""
+	if colophonHref == "" {
+		removed = colophonPath
+	}
+	return b.write(changed, removed, now)
+}
+
+// write packs the e-book again with the changed files, the files added are
+// appended. The mimetype comes first and uncompressed, as EPUB requires.
+func (b *book) write(changed map[string][]byte, removed string, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	mimetype := []byte(MediaType)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "mimetype",
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(mimetype),
+		CompressedSize64:   uint64(len(mimetype)),
+		UncompressedSize64: uint64(len(mimetype)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(mimetype); err != nil {
+		return nil, err
+	}
+
+	put := func(name string, data []byte, modified time.Time) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	for _, f := range b.zip.File {
+		if f.Name == "mimetype" || f.Name == removed {
+			continue
+		}
+		data, ok := changed[f.Name]
+		if !ok {
+			if err := zw.Copy(f); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := put(f.Name, data, f.Modified); err != nil {
+			return nil, err
+		}
+		delete(changed, f.Name)
+	}
+	added := make([]string, 0, len(changed))
+	for name := range changed {
+		added = append(added, name)
+	}
+	sort.Strings(added)
+	for _, name := range added {
+		if err := put(name, changed[name], now); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// colophonPage returns the XHTML document of the colophon, in XHTML 1.1 for
+// an EPUB 2.
+func colophonPage(version, s string) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	if strings.HasPrefix(version, "2") {
+		b.WriteString(`<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.1//EN" "http://www.w3.org/TR/xhtml11/DTD/xhtml11.dtd">` + "\n")
+		b.WriteString(`<html xmlns="http://www.w3.org/1999/xhtml">` + "\n")
+		b.WriteString("<head><title>Colophon</title></head>\n")
+		b.WriteString(`<body><div class="colophon"><p>` + escape(s) + "</p></div></body>\n")
+	} else {
+		b.WriteString("<!DOCTYPE html>\n")
+		b.WriteString(`<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">` + "\n")
+		b.WriteString("<head><title>Colophon</title></head>\n")
+		b.WriteString(`<body><section epub:type="colophon"><p>` + escape(s) + "</p></section></body>\n")
+	}
+	b.WriteString("</html>\n")
+	return b.Bytes()
+}
+
+// Result is a mark found in an e-book.
+type Result struct {
+	Mark string
+	// Confidence is the share of the frames found which carry the mark,
+	// from 0 to 1, it is 1 for a mark found in the metadata only
+	Confidence float64
+	// Frames counts the copies of the mark found in the chapters
+	Frames int
+	// Encodings lists the text encodings the mark was found in and
+	// EncodingMetadata if the package document records it
+	Encodings []string
+}
+
+// Extract returns the mark carried by the chapters, or recorded in the
+// metadata if the chapters carry none. It returns ErrNoMark if there is
+// none.
+func Extract(content []byte) (Result, error) {
+	b, err := open(content)
+	if err != nil {
+		return Result{}, err
+	}
+	var joined []string
+	for _, c := range b.pkg.chapters(b.opfPath) {
+		doc, err := b.read(c)
+		if err != nil {
+			return Result{}, err
+		}
+		joined = append(joined, chapterMark(doc))
+	}
+	meta := b.pkg.mark()
+	res, err := text.Extract(strings.Join(joined, string(separator)))
+	switch {
+	case err == nil:
+		result := Result{Mark: res.Mark, Confidence: res.Confidence, Frames: res.Frames}
+		for _, e := range res.Encodings {
+			result.Encodings = append(result.Encodings, string(e))
+		}
+		if meta == res.Mark {
+			result.Encodings = append(result.Encodings, EncodingMetadata)
+		}
+		return result, nil
+	case errors.Is(err, text.ErrNoMark) && meta != "":
+		return Result{Mark: meta, Confidence: 1, Encodings: []string{EncodingMetadata}}, nil
+	case errors.Is(err, text.ErrNoMark):
+		return Result{}, ErrNoMark
+	}
+	return Result{}, err
+}
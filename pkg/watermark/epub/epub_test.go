@@ -0,0 +1,237 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"publisher/pkg/watermark/text"
+	"strings"
+	"testing"
+)
+
+const (
+	containerDoc = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`
+	opfDoc = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Книга</dc:title></metadata>
+  <manifest><item id="ch1" href="ch1.xhtml" media-type="application/xhtml+xml"/></manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`
+)
+
+// emptyChapter has no text in its body.
+const emptyChapter = `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Chapter</title></head><body><p/></body></html>`
+
+// chapter returns an XHTML chapter of n paragraphs with markup and entities.
+func chapter(n int) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Chapter</title></head><body>` + "\n")
+	for i := 0; i < n; i++ {
+		b.WriteString("<p>The quick brown fox <em>jumps</em> over the lazy dog &amp; runs away.</p>\n")
+	}
+	b.WriteString("</body></html>\n")
+	return b.String()
+}
+
+// cyrillicChapter has no-break spaces, written out and as entity, and
+// Russian words made of Latin look-alikes only.
+var cyrillicChapter = func() string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Глава</title></head><body>` + "\n")
+	for i := 0; i < 30; i++ {
+		b.WriteString("<p>Я и ты, а он с ней... о море у 10\u00a0км, <em>сказал</em> он&nbsp;тихо &amp; ушёл.</p>\n")
+	}
+	b.WriteString("</body></html>\n")
+	return b.String()
+}()
+
+func buildBook(t *testing.T, chapter string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct{ name, data string }{
+		{"mimetype", MediaType},
+		{"META-INF/container.xml", containerDoc},
+		{"OEBPS/content.opf", opfDoc},
+		{"OEBPS/ch1.xhtml", chapter},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readFile(t *testing.T, content []byte, name string) string {
+	t.Helper()
+	b, err := open(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := b.read(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(doc)
+}
+
+func readChapter(t *testing.T, content []byte) string {
+	t.Helper()
+	return readFile(t, content, "OEBPS/ch1.xhtml")
+}
+
+func TestEmbedExtract(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Colophon = DefaultColophon
+	marked, err := Embed(buildBook(t, chapter(30)), "reader@example.com", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Extract(marked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Mark != "reader@example.com" || res.Frames == 0 || res.Confidence != 1 {
+		t.Errorf("Extract = %+v", res)
+	}
+	if last := res.Encodings[len(res.Encodings)-1]; last != EncodingMetadata {
+		t.Errorf("Extract found the mark in %v, want %s last", res.Encodings, EncodingMetadata)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(marked), int64(len(marked)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first := zr.File[0]; first.Name != "mimetype" || first.Method != zip.Store {
+		t.Errorf("the first file is %s with method %d, want an uncompressed mimetype", first.Name, first.Method)
+	}
+	if colophon := readFile(t, marked, "OEBPS/"+colophonFile); !strings.Contains(colophon, "This copy is licensed to reader@example.com.") {
+		t.Errorf("colophon page:\n%s", colophon)
+	}
+	if opf := readFile(t, marked, "OEBPS/content.opf"); !strings.Contains(opf, colophonFile) || !strings.Contains(opf, metaName) {
+		t.Errorf("package document:\n%s", opf)
+	}
+	// the markup of the chapter is kept
+	if got := readChapter(t, marked); strings.Count(got, "<em>jumps</em>") != 30 || strings.Count(got, "&amp;") != 30 {
+		t.Errorf("marked chapter:\n%s", got)
+	}
+}
+
+func TestExtractMetadata(t *testing.T) {
+	// a chapter without text carries no frame, the colophon and metadata
+	// carry the mark
+	opts := DefaultOptions()
+	opts.Colophon = DefaultColophon
+	marked, err := Embed(buildBook(t, emptyChapter), "reader", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Extract(marked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Mark != "reader" || res.Frames != 0 || len(res.Encodings) != 1 || res.Encodings[0] != EncodingMetadata {
+		t.Errorf("Extract = %+v", res)
+	}
+}
+
+func TestEmbedErrors(t *testing.T) {
+	book := buildBook(t, chapter(30))
+	for _, tt := range []struct {
+		name    string
+		content []byte
+		mark    string
+		err     error
+	}{
+		{"empty mark", book, "", ErrInvalidMark},
+		{"not an epub", []byte("plain text"), "reader", ErrUnsupported},
+		{"no text", buildBook(t, emptyChapter), "reader", ErrNoCapacity},
+	} {
+		if _, err := Embed(tt.content, tt.mark, DefaultOptions()); !errors.Is(err, tt.err) {
+			t.Errorf("%s: Embed returned %v, want %v", tt.name, err, tt.err)
+		}
+	}
+	if _, err := Extract(book); !errors.Is(err, ErrNoMark) {
+		t.Errorf("Extract of an unmarked book returned %v, want %v", err, ErrNoMark)
+	}
+}
+
+// unmarked drops the zero-width characters and turns the no-break spaces
+// into spaces, the runes a mark may change.
+func unmarked(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\u200b', '\u200c':
+			return -1
+		case '\u00a0':
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+func TestEmbedKeepsCyrillicChapter(t *testing.T) {
+	book := buildBook(t, cyrillicChapter)
+	opts := DefaultOptions()
+	opts.Text.Encodings = append(opts.Text.Encodings, text.Homoglyph)
+	marked, err := Embed(book, "reader", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Extract(marked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Mark != "reader" || res.Frames == 0 {
+		t.Errorf("Extract = %+v", res)
+	}
+
+	chapter := readChapter(t, marked)
+	if chapter == cyrillicChapter {
+		t.Fatal("the chapter carries no mark")
+	}
+	// only spaces and zero-width characters carry the mark, no letter and
+	// no markup changed
+	if unmarked(chapter) != unmarked(cyrillicChapter) {
+		t.Errorf("the marked chapter differs in more than the mark:\n%s", chapter)
+	}
+	// stripping the mark returns the text with its no-break spaces
+	if got, want := text.Strip(chapterMark([]byte(chapter))), chapterMark([]byte(cyrillicChapter)); got != want {
+		t.Errorf("stripped text of the chapter:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestEmbedReplacesMark(t *testing.T) {
+	first, err := Embed(buildBook(t, cyrillicChapter), "first", DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Embed(first, "second", DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Extract(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Mark != "second" || res.Confidence != 1 {
+		t.Errorf("Extract = %+v", res)
+	}
+	chapter := readChapter(t, second)
+	if got, want := text.Strip(chapterMark([]byte(chapter))), chapterMark([]byte(cyrillicChapter)); got != want {
+		t.Errorf("stripped text of the chapter marked twice:\n%q\nwant:\n%q", got, want)
+	}
+}
@@ -0,0 +1,149 @@
+package epub
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// container is META-INF/container.xml, which locates the package document.
+type container struct {
+	Rootfiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// packageDoc is the part of the package document, the OPF file, the mark
+// needs.
+type packageDoc struct {
+	Version  string `xml:"version,attr"`
+	Manifest []struct {
+		ID        string `xml:"id,attr"`
+		Href      string `xml:"href,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+	Metas []struct {
+		Name    string `xml:"name,attr"`
+		Content string `xml:"content,attr"`
+	} `xml:"metadata>meta"`
+}
+
+// rootfile returns the path of the package document.
+func rootfile(b []byte) (string, error) {
+	var c container
+	if err := xml.Unmarshal(b, &c); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	for _, r := range c.Rootfiles {
+		if r.MediaType == "" || r.MediaType == "application/oebps-package+xml" {
+			return r.FullPath, nil
+		}
+	}
+	return "", fmt.Errorf("%w: the container names no package document", ErrUnsupported)
+}
+
+// chapters returns the paths in the archive of the XHTML documents of the
+// spine in reading order, the colophon of a previous mark left out.
+func (p *packageDoc) chapters(opfPath string) []string {
+	items := map[string]string{}
+	for _, item := range p.Manifest {
+		if item.MediaType == "application/xhtml+xml" && item.ID != colophonID {
+			items[item.ID] = item.Href
+		}
+	}
+	var chapters []string
+	for _, ref := range p.Spine {
+		if href, ok := items[ref.IDRef]; ok {
+			chapters = append(chapters, resolve(opfPath, href))
+		}
+	}
+	return chapters
+}
+
+// mark returns the mark recorded in the metadata.
+func (p *packageDoc) mark() string {
+	for _, m := range p.Metas {
+		if m.Name == metaName {
+			return m.Content
+		}
+	}
+	return ""
+}
+
+// resolve returns the path in the archive of an href of the package
+// document.
+func resolve(opfPath, href string) string {
+	if i := strings.IndexByte(href, '#'); i >= 0 {
+		href = href[:i]
+	}
+	if u, err := url.PathUnescape(href); err == nil {
+		href = u
+	}
+	return path.Join(path.Dir(opfPath), href)
+}
+
+var (
+	metaElement  = regexp.MustCompile(`\s*<(?:[\w-]+:)?meta\b[^>]*\bname=["']` + metaName + `["'][^>]*>(?:\s*</(?:[\w-]+:)?meta>)?`)
+	colophonItem = regexp.MustCompile(`\s*<(?:[\w-]+:)?item\b[^>]*\bid=["']` + colophonID + `["'][^>]*>(?:\s*</(?:[\w-]+:)?item>)?`)
+	colophonRef  = regexp.MustCompile(`\s*<(?:[\w-]+:)?itemref\b[^>]*\bidref=["']` + colophonID + `["'][^>]*>(?:\s*</(?:[\w-]+:)?itemref>)?`)
+	modified     = regexp.MustCompile(`(<(?:[\w-]+:)?meta\b[^>]*\bproperty=["']dcterms:modified["'][^>]*>)[^<]*(</(?:[\w-]+:)?meta>)`)
+	metadataEnd  = regexp.MustCompile(`</([\w-]+:)?metadata\s*>`)
+	manifestEnd  = regexp.MustCompile(`</([\w-]+:)?manifest\s*>`)
+	spineEnd     = regexp.MustCompile(`</([\w-]+:)?spine\s*>`)
+)
+
+// updatePackage records the mark in the metadata of the package document and
+// adds the colophon to the manifest and the end of the spine. The document
+// is edited in place, so everything else stays as it was.
+func updatePackage(opf []byte, mark, colophonHref string, now time.Time) ([]byte, error) {
+	s := string(opf)
+	s = metaElement.ReplaceAllString(s, "")
+	s = colophonItem.ReplaceAllString(s, "")
+	s = colophonRef.ReplaceAllString(s, "")
+	s = modified.ReplaceAllString(s, "${1}"+now.UTC().Format("2006-01-02T15:04:05Z")+"${2}")
+
+	var err error
+	s, err = insertBefore(s, metadataEnd, `<meta name="`+metaName+`" content="`+escape(mark)+`"/>`)
+	if err != nil {
+		return nil, err
+	}
+	if colophonHref != "" {
+		s, err = insertBefore(s, manifestEnd, `<item id="`+colophonID+`" href="`+escape(colophonHref)+`" media-type="application/xhtml+xml"/>`)
+		if err != nil {
+			return nil, err
+		}
+		s, err = insertBefore(s, spineEnd, `<itemref idref="`+colophonID+`"/>`)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return []byte(s), nil
+}
+
+// insertBefore inserts the element before the closing tag, the element
+// takes the namespace prefix of the tag.
+func insertBefore(s string, end *regexp.Regexp, element string) (string, error) {
+	loc := end.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return "", fmt.Errorf("%w: the package document is incomplete", ErrUnsupported)
+	}
+	prefix := ""
+	if loc[2] >= 0 {
+		prefix = s[loc[2]:loc[3]]
+	}
+	return s[:loc[0]] + "  <" + prefix + element[1:] + "\n  " + s[loc[0]:], nil
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,196 @@
+package epub
+
+import (
+	"bytes"
+	"publisher/pkg/watermark/text"
+	"regexp"
+	"strings"
+)
+
+var (
+	bodyStart = regexp.MustCompile(`(?i)<(?:[\w-]+:)?body\b(?:[^>"']|"[^"]*"|'[^']*')*>`)
+	bodyEnd   = regexp.MustCompile(`(?i)</(?:[\w-]+:)?body\s*>`)
+	entity    = regexp.MustCompile(`&(?:#[0-9]+|#x[0-9a-fA-F]+|[A-Za-z][A-Za-z0-9]*);`)
+	// rawElements find the end of the elements whose content is no text
+	rawElements = map[string]*regexp.Regexp{
+		"script": regexp.MustCompile(`(?i)</(?:[\w-]+:)?script\s*>`),
+		"style":  regexp.MustCompile(`(?i)</(?:[\w-]+:)?style\s*>`),
+	}
+)
+
+const (
+	// placeholder stands for an entity reference while the text is marked,
+	// so its letters carry no bits
+	placeholder = '\ufffc'
+	// separator joins the text nodes, so the words of two nodes stay apart
+	separator = '\n'
+)
+
+// textNodes returns the byte ranges of the text of the body, leaving out
+// scripts, styles, comments and CDATA sections.
+func textNodes(doc []byte) [][2]int {
+	start := bodyStart.FindIndex(doc)
+	if start == nil {
+		return nil
+	}
+	end := len(doc)
+	if loc := bodyEnd.FindAllIndex(doc, -1); len(loc) > 0 && loc[len(loc)-1][0] >= start[1] {
+		end = loc[len(loc)-1][0]
+	}
+	var nodes [][2]int
+	for i := start[1]; i < end; {
+		if doc[i] != '<' {
+			j := bytes.IndexByte(doc[i:end], '<')
+			if j < 0 {
+				j = end - i
+			}
+			nodes = append(nodes, [2]int{i, i + j})
+			i += j
+			continue
+		}
+		i = skipMarkup(doc, i, end)
+	}
+	return nodes
+}
+
+// skipMarkup returns the end of the markup at i, a script or style element
+// is skipped as a whole.
+func skipMarkup(doc []byte, i, end int) int {
+	rest := doc[i:end]
+	for _, delims := range [][2]string{{"<!--", "-->"}, {"<![CDATA[", "]]>"}, {"<?", "?>"}, {"<!", ">"}} {
+		if bytes.HasPrefix(rest, []byte(delims[0])) {
+			j := bytes.Index(rest, []byte(delims[1]))
+			if j < 0 {
+				return end
+			}
+			return i + j + len(delims[1])
+		}
+	}
+	j := tagEnd(rest)
+	if j < 0 {
+		return end
+	}
+	name := strings.ToLower(string(rest[1:j]))
+	if strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") {
+		return i + j + 1
+	}
+	if k := strings.IndexAny(name, " \t\r\n"); k >= 0 {
+		name = name[:k]
+	}
+	if k := strings.LastIndexByte(name, ':'); k >= 0 {
+		name = name[k+1:]
+	}
+	closing, ok := rawElements[name]
+	if !ok {
+		return i + j + 1
+	}
+	if loc := closing.FindIndex(doc[i+j+1 : end]); loc != nil {
+		return i + j + 1 + loc[1]
+	}
+	return end
+}
+
+// tagEnd returns the index of the '>' closing the tag, a quoted attribute
+// value may contain one. It returns -1 for an unterminated tag.
+func tagEnd(tag []byte) int {
+	var quote byte
+	for j := 1; j < len(tag); j++ {
+		switch c := tag[j]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return j
+		}
+	}
+	return -1
+}
+
+// chapterText joins the text nodes. The returned entities hold the
+// references replaced by a placeholder and the boundaries the positions of
+// the separators, both in runes.
+func chapterText(doc []byte, nodes [][2]int) (runes []rune, entities map[int]string, boundaries map[int]bool) {
+	entities, boundaries = map[int]string{}, map[int]bool{}
+	for n, node := range nodes {
+		if n > 0 {
+			boundaries[len(runes)] = true
+			runes = append(runes, separator)
+		}
+		s := string(doc[node[0]:node[1]])
+		last := 0
+		for _, loc := range entity.FindAllStringIndex(s, -1) {
+			runes = append(runes, []rune(s[last:loc[0]])...)
+			entities[len(runes)] = s[loc[0]:loc[1]]
+			runes = append(runes, placeholder)
+			last = loc[1]
+		}
+		runes = append(runes, []rune(s[last:])...)
+	}
+	return runes, entities, boundaries
+}
+
+// markChapter embeds the mark into the text nodes of the XHTML document,
+// replacing the frames of a previous mark. The rest of the text is kept as
+// it is. It returns text.ErrNoCapacity if the chapter has too little text.
+func markChapter(doc []byte, mark string, opts text.Options) ([]byte, error) {
+	nodes := textNodes(doc)
+	if len(nodes) == 0 {
+		return nil, text.ErrNoCapacity
+	}
+	// the frames of a previous mark are removed by Embed itself, they may
+	// span several nodes
+	runes, entities, boundaries := chapterText(doc, nodes)
+	marked, err := text.Embed(string(runes), mark, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Embed only replaces runes and inserts or removes zero-width ones, the
+	// marked text is split into the nodes again along the other runes of the
+	// original
+	texts := make([]strings.Builder, len(nodes))
+	n, p := 0, 0
+	for _, r := range marked {
+		if zeroWidth(r) || p >= len(runes) {
+			texts[n].WriteRune(r)
+			continue
+		}
+		for p < len(runes) && zeroWidth(runes[p]) {
+			p++
+		}
+		switch {
+		case boundaries[p]:
+			n++
+		case entities[p] != "":
+			texts[n].WriteString(entities[p])
+		default:
+			texts[n].WriteRune(r)
+		}
+		p++
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for i, node := range nodes {
+		out.Write(doc[last:node[0]])
+		out.WriteString(texts[i].String())
+		last = node[1]
+	}
+	out.Write(doc[last:])
+	return out.Bytes(), nil
+}
+
+// chapterMark returns the text of the chapter prepared for text.Extract.
+func chapterMark(doc []byte) string {
+	runes, _, _ := chapterText(doc, textNodes(doc))
+	return string(runes)
+}
+
+// zeroWidth reports whether r is one of the characters of a zero-width
+// frame.
+func zeroWidth(r rune) bool {
+	return r == '\u200b' || r == '\u200c'
+}
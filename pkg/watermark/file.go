@@ -0,0 +1,158 @@
+package watermark
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	orm "publisher/internal/database"
+	"publisher/internal/util"
+	"sync"
+	"time"
+)
+
+// fileQueueState is the content of the queue file, the entries are ordered
+// by ID.
+type fileQueueState struct {
+	NextID  int64
+	Entries []orm.WatermarkQueueEntry
+}
+
+type fileQueue struct {
+	path  string
+	mu    sync.Mutex
+	state fileQueueState
+}
+
+// NewFileQueue returns a queue which is kept in memory and written to a
+// single JSON file after every change, it serves a single node. The file is
+// created if it does not exist yet.
+func NewFileQueue(path string) (Queue, error) {
+	q := &fileQueue{path: path}
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(b, &q.state); err != nil {
+			return nil, err
+		}
+	}
+	return q, q.write(q.state)
+}
+
+func (q *fileQueue) Push(_ context.Context, ticketID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, e := range q.state.Entries {
+		if e.TicketID == ticketID && e.Status == orm.QueueQueued {
+			return nil
+		}
+	}
+	state := q.clone()
+	now := time.Now()
+	state.NextID++
+	state.Entries = append(state.Entries, orm.WatermarkQueueEntry{
+		ID:         state.NextID,
+		TicketID:   ticketID,
+		Status:     orm.QueueQueued,
+		VisibleAt:  now,
+		EnqueuedAt: now,
+	})
+	return q.commit(state)
+}
+
+func (q *fileQueue) Claim(_ context.Context, visibility time.Duration) (*orm.WatermarkQueueEntry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	next := -1
+	for i, e := range q.state.Entries {
+		if e.Status != orm.QueueQueued || e.VisibleAt.After(now) {
+			continue
+		}
+		if next < 0 || e.VisibleAt.Before(q.state.Entries[next].VisibleAt) {
+			next = i
+		}
+	}
+	if next < 0 {
+		// an idle queue does not rewrite the file
+		return nil, nil
+	}
+	state := q.clone()
+	entry := &state.Entries[next]
+	entry.Attempts++
+	entry.VisibleAt = now.Add(visibility)
+	claimed := *entry
+	if err := q.commit(state); err != nil {
+		return nil, err
+	}
+	return &claimed, nil
+}
+
+func (q *fileQueue) Ack(_ context.Context, entry *orm.WatermarkQueueEntry) error {
+	return q.update(entry, func(state *fileQueueState, i int) {
+		state.Entries = append(state.Entries[:i], state.Entries[i+1:]...)
+	})
+}
+
+func (q *fileQueue) Release(_ context.Context, entry *orm.WatermarkQueueEntry, delay time.Duration, reason string) error {
+	return q.update(entry, func(state *fileQueueState, i int) {
+		state.Entries[i].VisibleAt = time.Now().Add(delay)
+		state.Entries[i].LastError = reason
+	})
+}
+
+func (q *fileQueue) DeadLetter(_ context.Context, entry *orm.WatermarkQueueEntry, reason string) error {
+	return q.update(entry, func(state *fileQueueState, i int) {
+		state.Entries[i].Status = orm.QueueDead
+		state.Entries[i].LastError = reason
+	})
+}
+
+func (q *fileQueue) Close() error {
+	return nil
+}
+
+// update applies fn to the claimed entry as long as it was not claimed
+// again.
+func (q *fileQueue) update(entry *orm.WatermarkQueueEntry, fn func(state *fileQueueState, i int)) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, e := range q.state.Entries {
+		if e.ID != entry.ID {
+			continue
+		}
+		if e.Attempts != entry.Attempts || e.Status != orm.QueueQueued {
+			break
+		}
+		state := q.clone()
+		fn(&state, i)
+		return q.commit(state)
+	}
+	return errLeaseLost
+}
+
+// clone copies the state, so a change is only kept once it was written.
+func (q *fileQueue) clone() fileQueueState {
+	entries := make([]orm.WatermarkQueueEntry, len(q.state.Entries))
+	copy(entries, q.state.Entries)
+	return fileQueueState{NextID: q.state.NextID, Entries: entries}
+}
+
+func (q *fileQueue) commit(state fileQueueState) error {
+	if err := q.write(state); err != nil {
+		return err
+	}
+	q.state = state
+	return nil
+}
+
+func (q *fileQueue) write(state fileQueueState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(q.path, b)
+}
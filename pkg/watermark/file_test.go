@@ -0,0 +1,139 @@
+package watermark
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	orm "publisher/internal/database"
+	"publisher/internal/util"
+	"testing"
+	"time"
+)
+
+func newTestQueue(t *testing.T) (Queue, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "queue.json")
+	q, err := NewFileQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q, path
+}
+
+func mustClaim(t *testing.T, q Queue, visibility time.Duration) *orm.WatermarkQueueEntry {
+	t.Helper()
+	entry, err := q.Claim(context.Background(), visibility)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry == nil {
+		t.Fatal("Claim returned no entry")
+	}
+	return entry
+}
+
+func mustBeEmpty(t *testing.T, q Queue) {
+	t.Helper()
+	entry, err := q.Claim(context.Background(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry != nil {
+		t.Fatalf("Claim returned %+v, want no entry", entry)
+	}
+}
+
+func TestFileQueuePushOnce(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newTestQueue(t)
+	for _, ticketID := range []string{"a", "b", "a"} {
+		if err := q.Push(ctx, ticketID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, second := mustClaim(t, q, time.Minute), mustClaim(t, q, time.Minute)
+	if first.TicketID != "a" || second.TicketID != "b" {
+		t.Errorf("claimed %s and %s, want a and b", first.TicketID, second.TicketID)
+	}
+	mustBeEmpty(t, q)
+}
+
+func TestFileQueueRedelivery(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newTestQueue(t)
+	if err := q.Push(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	first := mustClaim(t, q, 20*time.Millisecond)
+	if first.Attempts != 1 {
+		t.Errorf("first claim counts %d attempts, want 1", first.Attempts)
+	}
+	// the entry is hidden until its visibility timeout ends
+	mustBeEmpty(t, q)
+	time.Sleep(30 * time.Millisecond)
+	second := mustClaim(t, q, time.Minute)
+	if second.ID != first.ID || second.Attempts != 2 {
+		t.Errorf("redelivered entry %d with %d attempts, want %d with 2", second.ID, second.Attempts, first.ID)
+	}
+	// the worker whose visibility timeout ended lost its lease
+	for name, settle := range map[string]func() error{
+		"Ack":        func() error { return q.Ack(ctx, first) },
+		"Release":    func() error { return q.Release(ctx, first, 0, "late") },
+		"DeadLetter": func() error { return q.DeadLetter(ctx, first, "late") },
+	} {
+		if err := settle(); !errors.Is(err, util.ErrConflict) {
+			t.Errorf("%s with a lost lease returned %v, want %v", name, err, util.ErrConflict)
+		}
+	}
+	if err := q.Ack(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+	mustBeEmpty(t, q)
+}
+
+func TestFileQueueRelease(t *testing.T) {
+	ctx := context.Background()
+	q, path := newTestQueue(t)
+	if err := q.Push(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Release(ctx, mustClaim(t, q, time.Minute), 20*time.Millisecond, "failed"); err != nil {
+		t.Fatal(err)
+	}
+	mustBeEmpty(t, q)
+	time.Sleep(30 * time.Millisecond)
+
+	// the entry survives a restart
+	reopened, err := NewFileQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := mustClaim(t, reopened, time.Minute)
+	if entry.Attempts != 2 || entry.LastError != "failed" {
+		t.Errorf("released entry has %d attempts and error %q, want 2 and %q", entry.Attempts, entry.LastError, "failed")
+	}
+}
+
+func TestFileQueueDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newTestQueue(t)
+	if err := q.Push(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.DeadLetter(ctx, mustClaim(t, q, 0), "given up"); err != nil {
+		t.Fatal(err)
+	}
+	mustBeEmpty(t, q)
+	state := q.(*fileQueue).state
+	if len(state.Entries) != 1 || state.Entries[0].Status != orm.QueueDead || state.Entries[0].LastError != "given up" {
+		t.Errorf("entries after DeadLetter: %+v", state.Entries)
+	}
+
+	// a dead entry does not keep the ticket from being queued again
+	if err := q.Push(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if entry := mustClaim(t, q, time.Minute); entry.Attempts != 1 {
+		t.Errorf("entry queued again counts %d attempts, want 1", entry.Attempts)
+	}
+}
@@ -0,0 +1,210 @@
+// Package frame encodes watermarks as error corrected bit sequences. A frame
+// carries the mark as the sync word, then the length of the mark, the mark
+// and a CRC-8 of both, every byte Hamming(7,4) coded. The frames are repeated
+// throughout a content, a frame is found by its sync word and checked by its
+// CRC.
+package frame
+
+import "sort"
+
+var syncWord = []byte{1, 1, 1, 0, 1, 1, 0, 1, 0, 0, 1, 0, 0, 0, 1, 1}
+
+const (
+	// codedByte is the number of bits of a Hamming(7,4) coded byte
+	codedByte = 14
+	// syncTolerance is the number of flipped bits a sync word may have
+	syncTolerance = 1
+)
+
+// MaxMarkLength is the length of the longest mark in bytes.
+const MaxMarkLength = 255
+
+// Len returns the number of bits of the frame of a mark of n bytes.
+func Len(n int) int {
+	return len(syncWord) + codedByte*(n+2)
+}
+
+// Encode returns the frame of the mark, which is at most MaxMarkLength
+// bytes. Each bit is a byte of 0 or 1.
+func Encode(mark []byte) []byte {
+	payload := make([]byte, 0, len(mark)+2)
+	payload = append(payload, byte(len(mark)))
+	payload = append(payload, mark...)
+	payload = append(payload, crc8(payload))
+
+	bits := make([]byte, 0, Len(len(mark)))
+	bits = append(bits, syncWord...)
+	for _, b := range payload {
+		bits = append(bits, hammingEncode(b>>4)...)
+		bits = append(bits, hammingEncode(b&0x0f)...)
+	}
+	return bits
+}
+
+// Scan is the outcome of decoding the frames of a bit stream.
+type Scan struct {
+	// Marks counts the valid frames per mark
+	Marks map[string]int
+	// Candidates counts the sync words followed by a frame, valid or not
+	Candidates int
+	// Spans holds the start and end of every valid frame in the bit stream,
+	// a mark recovered by the majority vote has none
+	Spans [][2]int
+}
+
+// Decode finds and decodes the frames of the bit stream. If no frame
+// is valid, the damaged frames of the most frequent length are combined by a
+// bitwise majority vote, which recovers marks no single copy carries intact.
+func Decode(bits []byte) Scan {
+	s := Scan{Marks: map[string]int{}}
+	damaged := map[int][]int{}
+	for i := 0; i+len(syncWord)+codedByte <= len(bits); {
+		if distance(bits[i:i+len(syncWord)], syncWord) > syncTolerance {
+			i++
+			continue
+		}
+		n := int(decodeByte(bits[i+len(syncWord):]))
+		end := i + Len(n)
+		if end > len(bits) {
+			i++
+			continue
+		}
+		s.Candidates++
+		if mark, ok := decodePayload(bits[i+len(syncWord) : end]); ok {
+			s.Marks[string(mark)]++
+			s.Spans = append(s.Spans, [2]int{i, end})
+			i = end
+			continue
+		}
+		damaged[n] = append(damaged[n], i)
+		i++
+	}
+	if len(s.Marks) > 0 || len(damaged) == 0 {
+		return s
+	}
+
+	lengths := make([]int, 0, len(damaged))
+	for n := range damaged {
+		lengths = append(lengths, n)
+	}
+	sort.Slice(lengths, func(i, j int) bool {
+		a, b := lengths[i], lengths[j]
+		return len(damaged[a]) > len(damaged[b]) || len(damaged[a]) == len(damaged[b]) && a < b
+	})
+	n := lengths[0]
+	if len(damaged[n]) < 3 {
+		// a vote needs a majority
+		return s
+	}
+	votes := make([]int, Len(n)-len(syncWord))
+	for _, start := range damaged[n] {
+		for j, b := range bits[start+len(syncWord) : start+Len(n)] {
+			votes[j] += int(b)
+		}
+	}
+	payload := make([]byte, len(votes))
+	for j, v := range votes {
+		if 2*v > len(damaged[n]) {
+			payload[j] = 1
+		}
+	}
+	if mark, ok := decodePayload(payload); ok {
+		s.Marks[string(mark)]++
+	}
+	return s
+}
+
+// decodePayload decodes the coded length, mark and CRC following a sync
+// word.
+func decodePayload(bits []byte) ([]byte, bool) {
+	payload := make([]byte, len(bits)/codedByte)
+	for i := range payload {
+		payload[i] = decodeByte(bits[i*codedByte:])
+	}
+	if len(payload) < 2 || int(payload[0]) != len(payload)-2 {
+		return nil, false
+	}
+	last := len(payload) - 1
+	if crc8(payload[:last]) != payload[last] {
+		return nil, false
+	}
+	return payload[1:last], true
+}
+
+func decodeByte(bits []byte) byte {
+	return hammingDecode(bits[:7])<<4 | hammingDecode(bits[7:codedByte])
+}
+
+// hammingEncode returns the Hamming(7,4) code of the nibble, which corrects
+// one flipped bit out of seven.
+func hammingEncode(n byte) []byte {
+	d1, d2, d3, d4 := n>>3&1, n>>2&1, n>>1&1, n&1
+	return []byte{d1 ^ d2 ^ d4, d1 ^ d3 ^ d4, d1, d2 ^ d3 ^ d4, d2, d3, d4}
+}
+
+func hammingDecode(bits []byte) byte {
+	b := make([]byte, 8)
+	copy(b[1:], bits)
+	syndrome := (b[1] ^ b[3] ^ b[5] ^ b[7]) | (b[2]^b[3]^b[6]^b[7])<<1 | (b[4]^b[5]^b[6]^b[7])<<2
+	if syndrome != 0 {
+		b[syndrome] ^= 1
+	}
+	return b[3]<<3 | b[5]<<2 | b[6]<<1 | b[7]
+}
+
+// crc8 returns the CRC-8 of b with the polynomial x^8+x^2+x+1.
+func crc8(b []byte) byte {
+	var crc byte
+	for _, c := range b {
+		crc ^= c
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func distance(a, b []byte) int {
+	d := 0
+	for i := range a {
+		if a[i] != b[i] {
+			d++
+		}
+	}
+	return d
+}
+
+// Vote returns the mark of most valid frames of the scans, ok is false if no
+// scan found a valid frame. The confidence is the share of the candidate
+// frames which carry the mark, from 0 to 1.
+func Vote(scans ...Scan) (mark string, frames int, confidence float64, ok bool) {
+	votes := map[string]int{}
+	candidates := 0
+	for _, s := range scans {
+		for mark, n := range s.Marks {
+			votes[mark] += n
+		}
+		candidates += s.Candidates
+	}
+	if len(votes) == 0 {
+		return "", 0, 0, false
+	}
+	marks := make([]string, 0, len(votes))
+	for mark := range votes {
+		marks = append(marks, mark)
+	}
+	sort.Slice(marks, func(i, j int) bool {
+		a, b := marks[i], marks[j]
+		return votes[a] > votes[b] || votes[a] == votes[b] && a < b
+	})
+	mark, frames = marks[0], votes[marks[0]]
+	if candidates < frames {
+		// a mark recovered by a vote has no sync word of its own
+		candidates = frames
+	}
+	return mark, frames, float64(frames) / float64(candidates), true
+}
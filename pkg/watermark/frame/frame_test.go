@@ -0,0 +1,119 @@
+package frame
+
+import (
+	"strings"
+	"testing"
+)
+
+var marks = []string{"a", "reader@example.com", "читатель", strings.Repeat("x", MaxMarkLength)}
+
+func TestEncodeDecode(t *testing.T) {
+	for _, mark := range marks {
+		bits := Encode([]byte(mark))
+		if len(bits) != Len(len(mark)) {
+			t.Errorf("Encode(%q) has %d bits, want %d", mark, len(bits), Len(len(mark)))
+		}
+		// the frames are found among other bits
+		stream := append(append([]byte{0, 1, 1, 0}, bits...), bits...)
+		s := Decode(append(stream, 1, 0))
+		if s.Marks[mark] != 2 || len(s.Marks) != 1 || s.Candidates != 2 {
+			t.Errorf("Decode of two frames of %q = %+v", mark, s)
+		}
+		want := [][2]int{{4, 4 + len(bits)}, {4 + len(bits), 4 + 2*len(bits)}}
+		if len(s.Spans) != 2 || s.Spans[0] != want[0] || s.Spans[1] != want[1] {
+			t.Errorf("Decode of two frames of %q has spans %v, want %v", mark, s.Spans, want)
+		}
+	}
+}
+
+func TestDecodeCorrectsFlippedBit(t *testing.T) {
+	bits := Encode([]byte("reader"))
+	for i := range bits {
+		damaged := append([]byte(nil), bits...)
+		damaged[i] ^= 1
+		if s := Decode(damaged); s.Marks["reader"] != 1 {
+			t.Errorf("Decode with bit %d flipped = %+v", i, s)
+		}
+	}
+}
+
+func TestHamming(t *testing.T) {
+	for n := byte(0); n < 16; n++ {
+		code := hammingEncode(n)
+		if got := hammingDecode(code); got != n {
+			t.Errorf("hammingDecode(hammingEncode(%d)) = %d", n, got)
+		}
+		for i := range code {
+			damaged := append([]byte(nil), code...)
+			damaged[i] ^= 1
+			if got := hammingDecode(damaged); got != n {
+				t.Errorf("hammingDecode of %d with bit %d flipped = %d", n, i, got)
+			}
+		}
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	bits := Encode([]byte("reader"))
+	wrongCRC := append([]byte(nil), bits...)
+	// two flipped bits of one Hamming block cannot be corrected
+	last := len(wrongCRC) - codedByte
+	wrongCRC[last] ^= 1
+	wrongCRC[last+1] ^= 1
+
+	for _, tt := range []struct {
+		name       string
+		bits       []byte
+		candidates int
+	}{
+		{"empty", nil, 0},
+		{"no sync word", make([]byte, len(bits)), 0},
+		{"truncated", bits[:len(bits)-1], 0},
+		{"sync word only", bits[:len(syncWord)+codedByte], 0},
+		{"wrong crc", wrongCRC, 1},
+	} {
+		s := Decode(tt.bits)
+		if len(s.Marks) != 0 || len(s.Spans) != 0 || s.Candidates != tt.candidates {
+			t.Errorf("%s: Decode = %+v, want no mark and %d candidates", tt.name, s, tt.candidates)
+		}
+		if _, _, _, ok := Vote(s); ok {
+			t.Errorf("%s: Vote found a mark", tt.name)
+		}
+	}
+}
+
+func TestDecodeMajorityVote(t *testing.T) {
+	bits := Encode([]byte("reader"))
+	var stream []byte
+	// every copy has an uncorrectable block, each a different one
+	for n := 0; n < 3; n++ {
+		damaged := append([]byte(nil), bits...)
+		block := len(syncWord) + codedByte*(1+n)
+		damaged[block] ^= 1
+		damaged[block+1] ^= 1
+		stream = append(stream, damaged...)
+	}
+	s := Decode(stream)
+	if s.Marks["reader"] != 1 || len(s.Spans) != 0 {
+		t.Errorf("Decode of damaged copies = %+v", s)
+	}
+
+	// two copies are no majority
+	if s := Decode(stream[:2*len(bits)]); len(s.Marks) != 0 {
+		t.Errorf("Decode of two damaged copies = %+v", s)
+	}
+}
+
+func TestVote(t *testing.T) {
+	mark, frames, confidence, ok := Vote(
+		Scan{Marks: map[string]int{"a": 2, "b": 1}, Candidates: 4},
+		Scan{Marks: map[string]int{"a": 1}, Candidates: 2},
+	)
+	if !ok || mark != "a" || frames != 3 || confidence != 0.5 {
+		t.Errorf("Vote = %q, %d, %v, %v", mark, frames, confidence, ok)
+	}
+	// a mark recovered by the majority vote has no candidates of its own
+	if _, _, confidence, _ := Vote(Scan{Marks: map[string]int{"a": 1}}); confidence != 1 {
+		t.Errorf("Vote of a recovered mark has confidence %v, want 1", confidence)
+	}
+}
@@ -0,0 +1,41 @@
+package pdf
+
+// helveticaWidths are the widths of the printable ASCII characters of
+// Helvetica in thousandths of the font size, from the space on.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0 to ?
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // @ to O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // P to _
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // ` to o
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // p to ~
+}
+
+// encodeText encodes the text in WinAnsiEncoding, which matches Latin-1 for
+// the printable characters. Other characters become a question mark.
+func encodeText(text string) String {
+	var s String
+	for _, r := range text {
+		switch {
+		case r >= ' ' && r <= '~', r >= 0xa0 && r <= 0xff:
+			s = append(s, byte(r))
+		default:
+			s = append(s, '?')
+		}
+	}
+	return s
+}
+
+// textWidth returns the width of the encoded text in units of the font size,
+// the accented letters are taken as wide as an average letter.
+func textWidth(s String) float64 {
+	var w int
+	for _, c := range s {
+		if c >= ' ' && c <= '~' {
+			w += helveticaWidths[c-' ']
+		} else {
+			w += 556
+		}
+	}
+	return float64(w) / 1000
+}
@@ -0,0 +1,189 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// The objects of a document are nil, bool, int64, float64, Name, String,
+// Array, Dict, Ref and *Stream.
+type (
+	// Name is a PDF name without the leading slash.
+	Name string
+	// String is the raw bytes of a PDF string.
+	String []byte
+	// Array is a PDF array.
+	Array []interface{}
+	// Dict is a PDF dictionary.
+	Dict map[Name]interface{}
+)
+
+// Ref refers to an indirect object.
+type Ref struct {
+	Num, Gen int
+}
+
+// Stream is a dictionary followed by data, which is encoded by the filters
+// of the dictionary.
+type Stream struct {
+	Dict Dict
+	Data []byte
+}
+
+// textString encodes s as a PDF text string, in UTF-16 if it is not ASCII.
+func textString(s string) String {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return String(s)
+	}
+	b := []byte{0xfe, 0xff}
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u>>8), byte(u))
+	}
+	return String(b)
+}
+
+// text decodes a PDF text string, the bytes without a UTF-16 byte order mark
+// are read as Latin-1, which matches PDFDocEncoding for the printable
+// characters.
+func (s String) text() string {
+	if len(s) >= 2 && s[0] == 0xfe && s[1] == 0xff {
+		u := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			u = append(u, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+	r := make([]rune, len(s))
+	for i, c := range s {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+// clone copies the dictionary, the values are shared.
+func (d Dict) clone() Dict {
+	c := make(Dict, len(d)+2)
+	for k, v := range d {
+		c[k] = v
+	}
+	return c
+}
+
+// writeObject appends the PDF syntax of the object to b, the keys of the
+// dictionaries are sorted so the output is stable.
+func writeObject(b *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case int:
+		b.WriteString(strconv.Itoa(v))
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		b.WriteString(formatNumber(v))
+	case Name:
+		writeName(b, v)
+	case String:
+		writeString(b, v)
+	case Ref:
+		fmt.Fprintf(b, "%d %d R", v.Num, v.Gen)
+	case Array:
+		b.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			writeObject(b, e)
+		}
+		b.WriteByte(']')
+	case Dict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		b.WriteString("<<")
+		for _, k := range keys {
+			writeName(b, Name(k))
+			b.WriteByte(' ')
+			writeObject(b, v[Name(k)])
+		}
+		b.WriteString(">>")
+	case *Stream:
+		d := v.Dict.clone()
+		d["Length"] = len(v.Data)
+		writeObject(b, d)
+		b.WriteString("\nstream\n")
+		b.Write(v.Data)
+		b.WriteString("\nendstream")
+	default:
+		panic(fmt.Sprintf("pdf: cannot write %T", v))
+	}
+}
+
+// formatNumber writes a real without an exponent and with at most four
+// decimals.
+func formatNumber(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 4, 64)
+	s = trimZeros(s)
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+func trimZeros(s string) string {
+	if !strings.Contains(s, ".") {
+		return s
+	}
+	for s[len(s)-1] == '0' {
+		s = s[:len(s)-1]
+	}
+	if s[len(s)-1] == '.' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+func writeName(b *bytes.Buffer, n Name) {
+	b.WriteByte('/')
+	for i := 0; i < len(n); i++ {
+		c := n[i]
+		if c <= ' ' || c >= 0x7f || c == '#' || isDelimiter(c) {
+			fmt.Fprintf(b, "#%02x", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+}
+
+func writeString(b *bytes.Buffer, s String) {
+	b.WriteByte('(')
+	for _, c := range s {
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(')')
+}
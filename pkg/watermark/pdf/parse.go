@@ -0,0 +1,323 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// errSyntax is returned for a malformed object.
+var errSyntax = errors.New("pdf: syntax error")
+
+// lexer reads the objects of a document from pos on.
+type lexer struct {
+	b   []byte
+	pos int
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// skipSpace skips the white space and the comments.
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.b) {
+		switch c := l.b[l.pos]; {
+		case isSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.b) && l.b[l.pos] != '\n' && l.b[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// keyword reads a run of regular characters, e.g. obj or a number.
+func (l *lexer) keyword() string {
+	l.skipSpace()
+	start := l.pos
+	for l.pos < len(l.b) && !isSpace(l.b[l.pos]) && !isDelimiter(l.b[l.pos]) {
+		l.pos++
+	}
+	return string(l.b[start:l.pos])
+}
+
+// expect reads the keyword kw.
+func (l *lexer) expect(kw string) error {
+	if got := l.keyword(); got != kw {
+		return fmt.Errorf("%w: %q instead of %q at %d", errSyntax, got, kw, l.pos)
+	}
+	return nil
+}
+
+// integer reads a non negative integer.
+func (l *lexer) integer() (int64, error) {
+	kw := l.keyword()
+	n, err := strconv.ParseInt(kw, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: %q is no integer at %d", errSyntax, kw, l.pos)
+	}
+	return n, nil
+}
+
+// object reads a direct object or a reference.
+func (l *lexer) object() (interface{}, error) {
+	l.skipSpace()
+	if l.pos >= len(l.b) {
+		return nil, fmt.Errorf("%w: unexpected end of data", errSyntax)
+	}
+	switch c := l.b[l.pos]; c {
+	case '/':
+		return l.name(), nil
+	case '(':
+		return l.literalString()
+	case '<':
+		if l.pos+1 < len(l.b) && l.b[l.pos+1] == '<' {
+			return l.dict()
+		}
+		return l.hexString()
+	case '[':
+		return l.array()
+	}
+	start := l.pos
+	kw := l.keyword()
+	switch kw {
+	case "":
+		return nil, fmt.Errorf("%w: unexpected %q at %d", errSyntax, l.b[l.pos], l.pos)
+	case "null":
+		return nil, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if n, err := strconv.ParseInt(kw, 10, 64); err == nil {
+		// a number followed by a generation and R is a reference
+		after := l.pos
+		if gen, err := l.integer(); err == nil && n >= 0 && l.keyword() == "R" {
+			return Ref{Num: int(n), Gen: int(gen)}, nil
+		}
+		l.pos = after
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(kw, 64); err == nil {
+		return f, nil
+	}
+	l.pos = start
+	return nil, fmt.Errorf("%w: unexpected %q at %d", errSyntax, kw, start)
+}
+
+func (l *lexer) name() Name {
+	l.pos++ // the slash
+	var n []byte
+	for l.pos < len(l.b) && !isSpace(l.b[l.pos]) && !isDelimiter(l.b[l.pos]) {
+		c := l.b[l.pos]
+		if c == '#' && l.pos+2 < len(l.b) {
+			if v, err := strconv.ParseUint(string(l.b[l.pos+1:l.pos+3]), 16, 8); err == nil {
+				n = append(n, byte(v))
+				l.pos += 3
+				continue
+			}
+		}
+		n = append(n, c)
+		l.pos++
+	}
+	return Name(n)
+}
+
+func (l *lexer) literalString() (String, error) {
+	l.pos++ // the opening parenthesis
+	var s []byte
+	depth := 1
+	for l.pos < len(l.b) {
+		c := l.b[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return String(s), nil
+			}
+		case '\\':
+			if l.pos >= len(l.b) {
+				break
+			}
+			c = l.b[l.pos]
+			l.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// a line continuation
+				if l.pos < len(l.b) && l.b[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				v := int(c - '0')
+				for i := 0; i < 2 && l.pos < len(l.b) && l.b[l.pos] >= '0' && l.b[l.pos] <= '7'; i++ {
+					v = v*8 + int(l.b[l.pos]-'0')
+					l.pos++
+				}
+				c = byte(v)
+			}
+		}
+		s = append(s, c)
+	}
+	return nil, fmt.Errorf("%w: unterminated string", errSyntax)
+}
+
+func (l *lexer) hexString() (String, error) {
+	l.pos++ // the opening angle bracket
+	var digits []byte
+	for l.pos < len(l.b) {
+		c := l.b[l.pos]
+		l.pos++
+		switch {
+		case c == '>':
+			if len(digits)%2 == 1 {
+				digits = append(digits, '0')
+			}
+			s := make(String, len(digits)/2)
+			for i := range s {
+				v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+				s[i] = byte(v)
+			}
+			return s, nil
+		case isSpace(c):
+		case bytes.IndexByte([]byte("0123456789abcdefABCDEF"), c) >= 0:
+			digits = append(digits, c)
+		default:
+			return nil, fmt.Errorf("%w: %q in a hex string", errSyntax, c)
+		}
+	}
+	return nil, fmt.Errorf("%w: unterminated hex string", errSyntax)
+}
+
+func (l *lexer) array() (Array, error) {
+	l.pos++ // the opening bracket
+	a := Array{}
+	for {
+		l.skipSpace()
+		if l.pos < len(l.b) && l.b[l.pos] == ']' {
+			l.pos++
+			return a, nil
+		}
+		v, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+}
+
+func (l *lexer) dict() (Dict, error) {
+	l.pos += 2 // the opening angle brackets
+	d := Dict{}
+	for {
+		l.skipSpace()
+		if bytes.HasPrefix(l.b[l.pos:], []byte(">>")) {
+			l.pos += 2
+			return d, nil
+		}
+		if l.pos >= len(l.b) || l.b[l.pos] != '/' {
+			return nil, fmt.Errorf("%w: dictionary key expected at %d", errSyntax, l.pos)
+		}
+		key := l.name()
+		v, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			d[key] = v
+		}
+	}
+}
+
+// indirect reads the object num at pos on, the length of a stream is
+// resolved through length.
+func (l *lexer) indirect(num int, length func(interface{}) (int64, bool)) (interface{}, error) {
+	n, err := l.integer()
+	if err != nil {
+		return nil, err
+	}
+	if int(n) != num {
+		return nil, fmt.Errorf("%w: object %d found instead of %d", errSyntax, n, num)
+	}
+	if _, err := l.integer(); err != nil {
+		return nil, err
+	}
+	if err := l.expect("obj"); err != nil {
+		return nil, err
+	}
+	v, err := l.object()
+	if err != nil {
+		return nil, err
+	}
+	d, ok := v.(Dict)
+	if !ok {
+		return v, nil
+	}
+	after := l.pos
+	if l.keyword() != "stream" {
+		l.pos = after
+		return d, nil
+	}
+	// the data starts after the end of the line of the keyword
+	if l.pos < len(l.b) && l.b[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos < len(l.b) && l.b[l.pos] == '\n' {
+		l.pos++
+	}
+	start := l.pos
+	if n, ok := length(d["Length"]); ok && start+int(n) <= len(l.b) {
+		end := start + int(n)
+		rest := lexer{b: l.b, pos: end}
+		if rest.keyword() == "endstream" {
+			l.pos = rest.pos
+			return &Stream{Dict: d, Data: l.b[start:end]}, nil
+		}
+	}
+	// the length is wrong, the data ends before the keyword
+	i := bytes.Index(l.b[start:], []byte("endstream"))
+	if i < 0 {
+		return nil, fmt.Errorf("%w: unterminated stream", errSyntax)
+	}
+	end := start + i
+	if end > start && l.b[end-1] == '\n' {
+		end--
+	}
+	if end > start && l.b[end-1] == '\r' {
+		end--
+	}
+	l.pos = start + i + len("endstream")
+	return &Stream{Dict: d, Data: l.b[start:end]}, nil
+}
@@ -0,0 +1,219 @@
+// Package pdf stamps PDF documents. Every page gets a diagonal text
+// watermark and the document information records the mark. The stamped
+// document is written anew as a whole, so no earlier revision without the
+// stamp can be cut out of it, and a later stamp replaces the earlier one.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"math"
+	"publisher/pkg/watermark/frame"
+)
+
+// InfoKey is the key of the document information holding the mark.
+const InfoKey = "Watermark"
+
+// stampKey marks the content streams of a stamp, so a later stamp can
+// replace them.
+const stampKey = "PublisherWatermark"
+
+// Names of the stamp in the resources of a page.
+const (
+	fontName  = "PublisherWatermarkFont"
+	stateName = "PublisherWatermarkState"
+)
+
+var (
+	// ErrInvalidMark is returned for an empty or too long mark.
+	ErrInvalidMark = errors.New("the mark must have 1 to 255 bytes")
+	// ErrUnsupported is returned for a content which is no readable PDF
+	// document.
+	ErrUnsupported = errors.New("the content is not a supported PDF document")
+	// ErrEncrypted is returned for an encrypted document, which cannot be
+	// changed without its password.
+	ErrEncrypted = errors.New("the PDF document is encrypted")
+	// ErrNoMark is returned if the document records no mark.
+	ErrNoMark = errors.New("no watermark was found")
+)
+
+// Options configure the stamp.
+type Options struct {
+	// Text is drawn on every page, the mark if it is empty
+	Text string
+	// Opacity from 0, invisible, to 1, opaque
+	Opacity float64
+	// Gray is the color of the text from 0, black, to 1, white
+	Gray float64
+}
+
+// DefaultOptions draw the mark in light gray.
+func DefaultOptions() Options {
+	return Options{Opacity: 0.3, Gray: 0.5}
+}
+
+// Embed returns the document stamped with the mark. A previous stamp is
+// removed from the pages, the document information keeps only the latest
+// mark.
+func Embed(content []byte, mark string, opts Options) ([]byte, error) {
+	if mark == "" || len(mark) > frame.MaxMarkLength {
+		return nil, ErrInvalidMark
+	}
+	r, err := newReader(content)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := r.pages()
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("%w: the document has no pages", ErrUnsupported)
+	}
+
+	u := newUpdate(r)
+	opacity := math.Max(0, math.Min(1, opts.Opacity))
+	font := u.add(Dict{"Type": Name("Font"), "Subtype": Name("Type1"), "BaseFont": Name("Helvetica"), "Encoding": Name("WinAnsiEncoding")})
+	state := u.add(Dict{"Type": Name("ExtGState"), "ca": opacity, "CA": opacity})
+	// the page content is enclosed in q and Q, so the stamp is drawn in the
+	// default graphics state whatever the content leaves behind
+	open := u.add(&Stream{Dict: Dict{stampKey: true}, Data: []byte("q\n")})
+	text := opts.Text
+	if text == "" {
+		text = mark
+	}
+	for _, p := range pages {
+		contents := Array{open}
+		for _, c := range r.contents(p.dict) {
+			if !r.isStamp(c) {
+				contents = append(contents, c)
+			}
+		}
+		data, err := compress(stampContent(text, p.box, opts.Gray))
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, u.add(&Stream{Dict: Dict{stampKey: true, "Filter": Name("FlateDecode")}, Data: data}))
+
+		d := p.dict.clone()
+		d["Contents"] = contents
+		resources := p.resources.clone()
+		resources["Font"] = r.withEntry(resources["Font"], fontName, font)
+		resources["ExtGState"] = r.withEntry(resources["ExtGState"], stateName, state)
+		d["Resources"] = resources
+		u.set(p.ref, d)
+	}
+
+	info := r.dict(r.trailer["Info"]).clone()
+	info[InfoKey] = textString(mark)
+	if ref, ok := r.trailer["Info"].(Ref); ok {
+		u.set(ref, info)
+	} else {
+		r.trailer["Info"] = u.add(info)
+	}
+	return u.bytes(), nil
+}
+
+// Result is the mark found in a document.
+type Result struct {
+	Mark string
+	// Pages counts the pages of the document, Stamped those carrying a
+	// stamp
+	Pages   int
+	Stamped int
+}
+
+// Extract returns the mark recorded in the document information, ErrNoMark
+// if there is none.
+func Extract(content []byte) (Result, error) {
+	r, err := newReader(content)
+	if err != nil {
+		return Result{}, err
+	}
+	mark, _ := r.resolve(r.dict(r.trailer["Info"])[InfoKey]).(String)
+	if len(mark) == 0 {
+		return Result{}, ErrNoMark
+	}
+	res := Result{Mark: mark.text()}
+	pages, err := r.pages()
+	if err != nil {
+		return Result{}, err
+	}
+	res.Pages = len(pages)
+	for _, p := range pages {
+		for _, c := range r.contents(p.dict) {
+			if r.isStamp(c) {
+				res.Stamped++
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+// contents returns the references of the content streams of a page.
+func (r *reader) contents(page Dict) Array {
+	switch c := page["Contents"].(type) {
+	case Ref:
+		if a, ok := r.resolve(c).(Array); ok {
+			return a
+		}
+		return Array{c}
+	case Array:
+		return c
+	}
+	return nil
+}
+
+// isStamp tells whether the content stream is part of a stamp.
+func (r *reader) isStamp(v interface{}) bool {
+	s, ok := r.resolve(v).(*Stream)
+	return ok && s.Dict[stampKey] == true
+}
+
+// withEntry returns a copy of the resource dictionary v with the entry
+// added.
+func (r *reader) withEntry(v interface{}, name Name, ref Ref) Dict {
+	d := r.dict(v).clone()
+	d[name] = ref
+	return d
+}
+
+// stampContent draws the text along the diagonal of the box, from the lower
+// left to the upper right corner, as large as fits.
+func stampContent(text string, box [4]float64, gray float64) []byte {
+	x0, x1 := math.Min(box[0], box[2]), math.Max(box[0], box[2])
+	y0, y1 := math.Min(box[1], box[3]), math.Max(box[1], box[3])
+	w, h := x1-x0, y1-y0
+	s := encodeText(text)
+	width := textWidth(s)
+	size := 0.7 * math.Hypot(w, h) / math.Max(width, 1e-3)
+	size = math.Min(size, math.Min(w, h)/4)
+	angle := math.Atan2(h, w)
+	cos, sin := math.Cos(angle), math.Sin(angle)
+	gray = math.Max(0, math.Min(1, gray))
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Q\nq\n/%s gs\n%s g\nBT\n/%s %s Tf\n", stateName, formatNumber(gray), fontName, formatNumber(size))
+	fmt.Fprintf(&b, "%s %s %s %s %s %s Tm\n", formatNumber(cos), formatNumber(sin), formatNumber(-sin), formatNumber(cos),
+		formatNumber(x0+w/2), formatNumber(y0+h/2))
+	// center the text, the cap height of Helvetica is 0.718 of its size
+	fmt.Fprintf(&b, "%s %s Td\n", formatNumber(-width*size/2), formatNumber(-0.718*size/2))
+	writeString(&b, s)
+	b.WriteString(" Tj\nET\nQ\n")
+	return b.Bytes()
+}
+
+func compress(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
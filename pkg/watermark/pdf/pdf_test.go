@@ -0,0 +1,379 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// document builds PDF documents for the tests. The pages share their
+// resources and media box through the page tree.
+type document struct {
+	pages int
+	// streams compresses the catalog and the page tree into an object stream
+	// indexed by a cross-reference stream
+	streams bool
+	// trailer adds entries to the trailer
+	trailer string
+}
+
+func (d document) build(t *testing.T) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n%\xe2\xe3\xcf\xd3\n")
+	offsets := map[int]int{}
+	write := func(num int, body string) {
+		offsets[num] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", num, body)
+	}
+	stream := func(num int, dict string, data []byte) {
+		offsets[num] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n<<%s /Length %d>>\nstream\n", num, dict, len(data))
+		b.Write(data)
+		b.WriteString("\nendstream\nendobj\n")
+	}
+
+	// 1 catalog, 2 page tree, 3 font, 4 info, 5... pages and their contents
+	var kids []string
+	compressed := map[int]string{
+		1: "<</Type /Catalog /Pages 2 0 R>>",
+	}
+	for i := 0; i < d.pages; i++ {
+		page, content := 5+2*i, 6+2*i
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+		compressed[page] = fmt.Sprintf("<</Type /Page /Parent 2 0 R /Contents %d 0 R>>", content)
+		// the content leaves a graphics state open
+		stream(content, "", []byte(fmt.Sprintf("q 1 0 0 1 10 10 cm BT /F1 12 Tf 72 720 Td (Page %d) Tj ET", i+1)))
+	}
+	compressed[2] = fmt.Sprintf("<</Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 595 842] /Resources <</Font <</F1 3 0 R>>>>>>",
+		strings.Join(kids, " "), d.pages)
+	write(3, "<</Type /Font /Subtype /Type1 /BaseFont /Times-Roman>>")
+	write(4, "<</Title (Print \\(proof\\)) /Producer (test)>>")
+
+	size := 5 + 2*d.pages
+	if !d.streams {
+		for num, body := range compressed {
+			write(num, body)
+		}
+		start := b.Len()
+		fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f\r\n", size)
+		for num := 1; num < size; num++ {
+			fmt.Fprintf(&b, "%010d 00000 n\r\n", offsets[num])
+		}
+		fmt.Fprintf(&b, "trailer\n<</Size %d /Root 1 0 R /Info 4 0 R%s>>\nstartxref\n%d\n%%%%EOF\n", size, d.trailer, start)
+		return b.Bytes()
+	}
+
+	// the object stream is object size, the cross-reference stream size+1
+	var header, body bytes.Buffer
+	var nums []int
+	for num := range compressed {
+		nums = append(nums, num)
+	}
+	for i := 0; i < len(nums); i++ {
+		for j := i + 1; j < len(nums); j++ {
+			if nums[j] < nums[i] {
+				nums[i], nums[j] = nums[j], nums[i]
+			}
+		}
+	}
+	index := map[int]int{}
+	for i, num := range nums {
+		index[num] = i
+		fmt.Fprintf(&header, "%d %d ", num, body.Len())
+		body.WriteString(compressed[num] + "\n")
+	}
+	objStm := size
+	stream(objStm, fmt.Sprintf("/Type /ObjStm /N %d /First %d /Filter /FlateDecode", len(nums), header.Len()),
+		deflate(t, append(header.Bytes(), body.Bytes()...)))
+
+	// the rows of the cross-reference stream are predicted from the row
+	// above, as most writers do
+	xref := size + 1
+	offsets[xref] = b.Len()
+	var rows, prev []byte
+	prev = make([]byte, 6)
+	for num := 0; num < size+2; num++ {
+		var row []byte
+		switch {
+		case num == 0:
+			row = []byte{0, 0, 0, 0, 0xff, 0xff}
+		case compressed[num] != "":
+			row = []byte{2, 0, 0, 0, byte(objStm), byte(index[num])}
+		default:
+			row = []byte{1, 0, byte(offsets[num] >> 16), byte(offsets[num] >> 8), byte(offsets[num]), 0}
+		}
+		rows = append(rows, 2)
+		for i := range row {
+			rows = append(rows, row[i]-prev[i])
+		}
+		prev = row
+	}
+	stream(xref, fmt.Sprintf("/Type /XRef /Size %d /W [1 4 1] /Root 1 0 R /Info 4 0 R /Filter /FlateDecode /DecodeParms <</Predictor 12 /Columns 6>>%s",
+		size+2, d.trailer), deflate(t, rows))
+	fmt.Fprintf(&b, "startxref\n%d\n%%%%EOF\n", offsets[xref])
+	return b.Bytes()
+}
+
+func deflate(t *testing.T, data []byte) []byte {
+	t.Helper()
+	b, err := compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// checkStamped re-parses the stamped document and checks that every page
+// carries one stamp of the text besides its original content. The stamped
+// document has a single revision, the original cannot be cut out of it.
+func checkStamped(t *testing.T, original, stamped []byte, mark, text string, pages int) {
+	t.Helper()
+	if bytes.Contains(stamped, original) {
+		t.Fatal("the original document can be cut out of the stamped one")
+	}
+	if n := bytes.Count(stamped, []byte("%%EOF")); n != 1 {
+		t.Errorf("the stamped document has %d revisions, want 1", n)
+	}
+	r, err := newReader(stamped)
+	if err != nil {
+		t.Fatalf("re-parsing the stamped document: %v", err)
+	}
+	ps, err := r.pages()
+	if err != nil {
+		t.Fatalf("reading the pages: %v", err)
+	}
+	if len(ps) != pages {
+		t.Fatalf("got %d pages, want %d", len(ps), pages)
+	}
+	for i, p := range ps {
+		var stamps, originals int
+		var drawn string
+		for _, ref := range r.contents(p.dict) {
+			s, ok := r.resolve(ref).(*Stream)
+			if !ok {
+				t.Fatalf("page %d: content %v is no stream", i+1, ref)
+			}
+			data, err := decode(s)
+			if err != nil {
+				t.Fatalf("page %d: decoding the content: %v", i+1, err)
+			}
+			if r.isStamp(ref) {
+				stamps++
+				drawn += string(data)
+				continue
+			}
+			if !strings.Contains(string(data), fmt.Sprintf("(Page %d) Tj", i+1)) {
+				t.Errorf("page %d: unexpected content %q", i+1, data)
+			}
+			originals++
+		}
+		if stamps != 2 || originals != 1 {
+			t.Errorf("page %d: got %d stamp and %d original streams, want 2 and 1", i+1, stamps, originals)
+		}
+		var want bytes.Buffer
+		writeString(&want, encodeText(text))
+		if !strings.Contains(drawn, want.String()+" Tj") {
+			t.Errorf("page %d: the stamp %q does not draw %s", i+1, drawn, want.String())
+		}
+		fonts := r.dict(p.resources["Font"])
+		if _, ok := fonts["F1"]; !ok {
+			t.Errorf("page %d: the inherited font was lost: %v", i+1, fonts)
+		}
+		if font := r.dict(fonts[fontName]); font["BaseFont"] != Name("Helvetica") {
+			t.Errorf("page %d: got stamp font %v", i+1, font)
+		}
+		if _, ok := r.dict(p.resources["ExtGState"])[stateName]; !ok {
+			t.Errorf("page %d: the stamp graphics state is missing", i+1)
+		}
+	}
+	info := r.dict(r.trailer["Info"])
+	if title, _ := info["Title"].(String); string(title) != "Print (proof)" {
+		t.Errorf("got title %q, the document information was not kept", title)
+	}
+	res, err := Extract(stamped)
+	if err != nil {
+		t.Fatalf("extracting the mark: %v", err)
+	}
+	if want := (Result{Mark: mark, Pages: pages, Stamped: pages}); res != want {
+		t.Errorf("got %+v, want %+v", res, want)
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  document
+		mark string
+		opts Options
+		text string
+	}{
+		{name: "xref table", doc: document{pages: 3}, mark: "buyer-42@example.com", text: "buyer-42@example.com"},
+		{name: "xref stream", doc: document{pages: 2, streams: true}, mark: "buyer-42@example.com", text: "buyer-42@example.com"},
+		{name: "text", doc: document{pages: 1}, mark: "ticket-7", opts: Options{Text: "PROOF (ticket-7)", Opacity: 0.5}, text: "PROOF (ticket-7)"},
+		{name: "unicode mark", doc: document{pages: 1}, mark: "Käufer 王", text: "Käufer 王"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := tt.doc.build(t)
+			opts := tt.opts
+			if opts == (Options{}) {
+				opts = DefaultOptions()
+			}
+			stamped, err := Embed(original, tt.mark, opts)
+			if err != nil {
+				t.Fatalf("Embed: %v", err)
+			}
+			checkStamped(t, original, stamped, tt.mark, tt.text, tt.doc.pages)
+
+			r, err := newReader(stamped)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.startxref == 0 || r.xrefStream {
+				t.Error("the stamped document has no valid cross-reference table")
+			}
+			if _, ok := r.trailer["Prev"]; ok {
+				t.Error("the stamped document refers to an earlier cross-reference section")
+			}
+			for num, e := range r.xref {
+				if e.stream > 0 {
+					t.Errorf("object %d is compressed in object stream %d", num, e.stream)
+				}
+			}
+		})
+	}
+}
+
+func TestEmbedReplacesStamp(t *testing.T) {
+	for _, streams := range []bool{false, true} {
+		original := document{pages: 2, streams: streams}.build(t)
+		first, err := Embed(original, "first-buyer", DefaultOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := Embed(first, "second-buyer", DefaultOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkStamped(t, first, second, "second-buyer", "second-buyer", 2)
+		if bytes.Contains(second, []byte("first-buyer")) {
+			t.Error("the document still records the first mark")
+		}
+		// the opening stream and one stamp per page, the streams of the
+		// first stamp are gone
+		if n := bytes.Count(second, []byte("/"+stampKey+" true")); n != 3 {
+			t.Errorf("the document has %d stamp streams, want 3", n)
+		}
+	}
+}
+
+func TestEmbedBrokenXref(t *testing.T) {
+	for _, streams := range []bool{false, true} {
+		original := document{pages: 2, streams: streams}.build(t)
+		i := bytes.LastIndex(original, []byte("startxref\n"))
+		broken := append(append([]byte(nil), original[:i]...), "startxref\n17\n%%EOF\n"...)
+		stamped, err := Embed(broken, "buyer", DefaultOptions())
+		if err != nil {
+			t.Fatalf("Embed: %v", err)
+		}
+		checkStamped(t, broken, stamped, "buyer", "buyer", 2)
+
+		// the new table lists every object, the document reads without
+		// scanning
+		r, err := newReader(stamped)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.startxref == 0 {
+			t.Error("the stamped document was scanned, its cross-reference section is incomplete")
+		}
+	}
+}
+
+func TestEmbedErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		mark    string
+		err     error
+	}{
+		{name: "not a pdf", content: []byte("plain text"), mark: "buyer", err: ErrUnsupported},
+		{name: "garbage", content: []byte("%PDF-1.4\nnothing here\n"), mark: "buyer", err: ErrUnsupported},
+		{name: "encrypted", content: document{pages: 1, trailer: " /Encrypt 3 0 R"}.build(t), mark: "buyer", err: ErrEncrypted},
+		{name: "empty mark", content: document{pages: 1}.build(t), mark: "", err: ErrInvalidMark},
+		{name: "long mark", content: document{pages: 1}.build(t), mark: strings.Repeat("x", 256), err: ErrInvalidMark},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Embed(tt.content, tt.mark, DefaultOptions()); !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestExtractNoMark(t *testing.T) {
+	if _, err := Extract(document{pages: 1}.build(t)); !errors.Is(err, ErrNoMark) {
+		t.Errorf("got %v, want %v", err, ErrNoMark)
+	}
+}
+
+func TestParseObject(t *testing.T) {
+	tests := []struct {
+		in   string
+		want interface{}
+	}{
+		{in: "null", want: nil},
+		{in: "true", want: true},
+		{in: "-12", want: int64(-12)},
+		{in: "+.5", want: 0.5},
+		{in: "/A#20B", want: Name("A B")},
+		{in: `(a\(b\) \\ \101\n(nested))`, want: String("a(b) \\ A\n(nested)")},
+		{in: "(line\\\ncontinued)", want: String("linecontinued")},
+		{in: "<48 65 6c6C6>", want: String("Hell`")},
+		{in: "12 0 R", want: Ref{Num: 12}},
+		{in: "[1 2 0 R /N (s)]", want: Array{int64(1), Ref{Num: 2}, Name("N"), String("s")}},
+		{in: "<</A 1 % comment\n/B <</C [3.5]>> /D null>>", want: Dict{"A": int64(1), "B": Dict{"C": Array{3.5}}}},
+	}
+	for _, tt := range tests {
+		l := lexer{b: []byte(tt.in)}
+		got, err := l.object()
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %#v, want %#v", tt.in, got, tt.want)
+		}
+
+		// writing the object reads back the same
+		var b bytes.Buffer
+		writeObject(&b, got)
+		l = lexer{b: b.Bytes()}
+		if again, err := l.object(); err != nil || !reflect.DeepEqual(again, tt.want) {
+			t.Errorf("%q: wrote %q, read back %#v, %v", tt.in, b.String(), again, err)
+		}
+	}
+}
+
+func TestDecodePredictor(t *testing.T) {
+	// two rows of three bytes, predicted by Sub and Up
+	rows := []byte{1, 1, 1, 1, 2, 1, 1, 1}
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	zw.Write(rows)
+	zw.Close()
+	s := &Stream{Dict: Dict{"Filter": Name("FlateDecode"), "DecodeParms": Dict{"Predictor": int64(12), "Columns": int64(3)}}, Data: b.Bytes()}
+	got, err := decode(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{1, 2, 3, 2, 3, 4}; !bytes.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,572 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// maxDepth limits the nesting of references followed and of the page tree,
+// a malformed document may contain cycles.
+const maxDepth = 64
+
+// entry locates an object, either at an offset of the file or inside an
+// object stream.
+type entry struct {
+	free   bool
+	offset int64
+	gen    int
+	// stream is the object stream holding the object, index its position
+	// in the stream
+	stream int
+	index  int
+}
+
+// reader reads the objects of a document through its cross-reference
+// sections.
+type reader struct {
+	data    []byte
+	xref    map[int]entry
+	trailer Dict
+	// startxref is the offset of the newest cross-reference section, zero if
+	// the sections were broken and the objects were found by scanning
+	startxref int64
+	// xrefStream tells whether the newest section is a stream
+	xrefStream bool
+	objects    map[int]interface{}
+	streams    map[int]*objectStream
+}
+
+// objectStream holds the objects compressed together.
+type objectStream struct {
+	data []byte
+	// nums and offsets list the objects in the stream
+	nums    []int
+	offsets []int
+}
+
+// newReader reads the cross-reference sections of the document, a document
+// whose sections are broken is scanned for its objects instead.
+func newReader(data []byte) (*reader, error) {
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	if !bytes.Contains(head, []byte("%PDF-")) {
+		return nil, ErrUnsupported
+	}
+	r := &reader{data: data, objects: map[int]interface{}{}, streams: map[int]*objectStream{}}
+	if err := r.readXref(); err != nil {
+		if err := r.scan(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+		}
+	}
+	if _, ok := r.trailer["Encrypt"]; ok {
+		return nil, ErrEncrypted
+	}
+	if _, ok := r.trailer["Root"].(Ref); !ok {
+		return nil, fmt.Errorf("%w: the document has no catalog", ErrUnsupported)
+	}
+	return r, nil
+}
+
+// readXref reads the chain of cross-reference sections from the newest on.
+// The sections are read newest first, an object keeps the entry found
+// first.
+func (r *reader) readXref() error {
+	i := bytes.LastIndex(r.data, []byte("startxref"))
+	if i < 0 {
+		return errors.New("no startxref")
+	}
+	l := lexer{b: r.data, pos: i + len("startxref")}
+	start, err := l.integer()
+	if err != nil {
+		return err
+	}
+	r.xref = map[int]entry{}
+	seen := map[int64]bool{}
+	for offset := start; ; {
+		if seen[offset] || offset <= 0 || offset >= int64(len(r.data)) {
+			return fmt.Errorf("invalid cross-reference offset %d", offset)
+		}
+		seen[offset] = true
+		trailer, stream, err := r.readSection(offset)
+		if err != nil {
+			return err
+		}
+		if r.trailer == nil {
+			r.trailer, r.startxref, r.xrefStream = trailer, start, stream
+		}
+		// a hybrid file keeps the compressed objects in an additional stream
+		if stm, ok := trailer["XRefStm"].(int64); ok && !seen[stm] {
+			seen[stm] = true
+			if _, _, err := r.readSection(stm); err != nil {
+				return err
+			}
+		}
+		prev, ok := trailer["Prev"].(int64)
+		if !ok {
+			return nil
+		}
+		offset = prev
+	}
+}
+
+// readSection reads a cross-reference table or stream at offset and returns
+// its trailer.
+func (r *reader) readSection(offset int64) (Dict, bool, error) {
+	l := lexer{b: r.data, pos: int(offset)}
+	l.skipSpace()
+	if !bytes.HasPrefix(r.data[l.pos:], []byte("xref")) {
+		trailer, err := r.readXrefStream(&l)
+		return trailer, true, err
+	}
+	l.pos += len("xref")
+	for {
+		l.skipSpace()
+		if bytes.HasPrefix(r.data[l.pos:], []byte("trailer")) {
+			l.pos += len("trailer")
+			v, err := l.object()
+			if err != nil {
+				return nil, false, err
+			}
+			trailer, ok := v.(Dict)
+			if !ok {
+				return nil, false, fmt.Errorf("%w: the trailer is no dictionary", errSyntax)
+			}
+			return trailer, false, nil
+		}
+		first, err := l.integer()
+		if err != nil {
+			return nil, false, err
+		}
+		count, err := l.integer()
+		if err != nil {
+			return nil, false, err
+		}
+		for i := int64(0); i < count; i++ {
+			off, err := l.integer()
+			if err != nil {
+				return nil, false, err
+			}
+			gen, err := l.integer()
+			if err != nil {
+				return nil, false, err
+			}
+			kind := l.keyword()
+			if kind != "n" && kind != "f" {
+				return nil, false, fmt.Errorf("%w: cross-reference entry %q", errSyntax, kind)
+			}
+			r.add(int(first+i), entry{free: kind == "f", offset: off, gen: int(gen)})
+		}
+	}
+}
+
+// readXrefStream reads the cross-reference stream at the lexer.
+func (r *reader) readXrefStream(l *lexer) (Dict, error) {
+	start := l.pos
+	num, err := l.integer()
+	if err != nil {
+		return nil, err
+	}
+	l.pos = start
+	v, err := l.indirect(int(num), r.length)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := v.(*Stream)
+	if !ok || s.Dict["Type"] != Name("XRef") {
+		return nil, fmt.Errorf("%w: no cross-reference stream at %d", errSyntax, start)
+	}
+	data, err := decode(s)
+	if err != nil {
+		return nil, err
+	}
+	var w [3]int
+	widths, _ := s.Dict["W"].(Array)
+	for i := 0; i < 3 && i < len(widths); i++ {
+		n, _ := widths[i].(int64)
+		w[i] = int(n)
+	}
+	size := w[0] + w[1] + w[2]
+	if size == 0 || len(widths) != 3 {
+		return nil, fmt.Errorf("%w: invalid cross-reference widths", errSyntax)
+	}
+	index, ok := s.Dict["Index"].(Array)
+	if !ok {
+		n, _ := s.Dict["Size"].(int64)
+		index = Array{int64(0), n}
+	}
+	field := func(b []byte, def int64) int64 {
+		if len(b) == 0 {
+			return def
+		}
+		var v int64
+		for _, c := range b {
+			v = v<<8 | int64(c)
+		}
+		return v
+	}
+	for i := 0; i+1 < len(index); i += 2 {
+		first, _ := index[i].(int64)
+		count, _ := index[i+1].(int64)
+		for j := int64(0); j < count; j++ {
+			if len(data) < size {
+				return nil, fmt.Errorf("%w: short cross-reference stream", errSyntax)
+			}
+			row := data[:size]
+			data = data[size:]
+			kind := field(row[:w[0]], 1)
+			a, b := field(row[w[0]:w[0]+w[1]], 0), field(row[w[0]+w[1]:], 0)
+			switch kind {
+			case 0:
+				r.add(int(first+j), entry{free: true})
+			case 1:
+				r.add(int(first+j), entry{offset: a, gen: int(b)})
+			case 2:
+				r.add(int(first+j), entry{stream: int(a), index: int(b)})
+			}
+		}
+	}
+	return s.Dict, nil
+}
+
+func (r *reader) add(num int, e entry) {
+	if _, ok := r.xref[num]; !ok {
+		r.xref[num] = e
+	}
+}
+
+var objectHeader = regexp.MustCompile(`(\d+)[ \t\r\n\f\x00]+(\d+)[ \t\r\n\f\x00]+obj\b`)
+
+// scan finds the objects of a document with broken cross-reference
+// sections, a later object replaces an earlier one of the same number.
+func (r *reader) scan() error {
+	r.xref, r.trailer, r.startxref, r.xrefStream = map[int]entry{}, nil, 0, false
+	r.objects, r.streams = map[int]interface{}{}, map[int]*objectStream{}
+	for _, m := range objectHeader.FindAllSubmatchIndex(r.data, -1) {
+		if m[0] > 0 && !isSpace(r.data[m[0]-1]) && !isDelimiter(r.data[m[0]-1]) {
+			continue
+		}
+		num, _ := strconv.Atoi(string(r.data[m[2]:m[3]]))
+		gen, _ := strconv.Atoi(string(r.data[m[4]:m[5]]))
+		r.xref[num] = entry{offset: int64(m[0]), gen: gen}
+	}
+	if len(r.xref) == 0 {
+		return errors.New("no objects found")
+	}
+	// the objects compressed into object streams are found by reading them
+	for num := range r.xref {
+		s, ok := r.resolve(Ref{Num: num}).(*Stream)
+		if !ok || s.Dict["Type"] != Name("ObjStm") {
+			continue
+		}
+		if objs, err := r.objectStream(num); err == nil {
+			for i := range objs.offsets {
+				if n := objs.nums[i]; n != num {
+					r.add(n, entry{stream: num, index: i})
+				}
+			}
+		}
+	}
+	if i := bytes.LastIndex(r.data, []byte("trailer")); i >= 0 {
+		l := lexer{b: r.data, pos: i + len("trailer")}
+		l.skipSpace()
+		if d, err := l.dict(); err == nil {
+			r.trailer = d
+		}
+	}
+	if r.trailer == nil {
+		// the newest cross-reference stream is the trailer of a document
+		// without tables
+		var newest int64
+		r.trailer = Dict{}
+		for num, e := range r.xref {
+			if s, ok := r.resolve(Ref{Num: num}).(*Stream); ok && s.Dict["Type"] == Name("XRef") && e.offset > newest {
+				newest, r.trailer = e.offset, s.Dict.clone()
+			}
+		}
+	}
+	if _, ok := r.trailer["Root"]; !ok {
+		for num := range r.xref {
+			if d, ok := r.resolve(Ref{Num: num}).(Dict); ok && d["Type"] == Name("Catalog") {
+				r.trailer["Root"] = Ref{Num: num}
+				break
+			}
+		}
+	}
+	delete(r.trailer, "Prev")
+	delete(r.trailer, "XRefStm")
+	return nil
+}
+
+// length resolves the length of a stream.
+func (r *reader) length(v interface{}) (int64, bool) {
+	n, ok := r.resolve(v).(int64)
+	return n, ok
+}
+
+// object returns the indirect object num, nil if it is missing or
+// malformed.
+func (r *reader) object(num int) interface{} {
+	if v, ok := r.objects[num]; ok {
+		return v
+	}
+	r.objects[num] = nil // breaks a cycle through the length of a stream
+	e, ok := r.xref[num]
+	if !ok || e.free {
+		return nil
+	}
+	var v interface{}
+	if e.stream > 0 {
+		objs, err := r.objectStream(e.stream)
+		if err != nil || e.index >= len(objs.offsets) {
+			return nil
+		}
+		l := lexer{b: objs.data, pos: objs.offsets[e.index]}
+		v, _ = l.object()
+	} else if e.offset > 0 && e.offset < int64(len(r.data)) {
+		l := lexer{b: r.data, pos: int(e.offset)}
+		v, _ = l.indirect(num, r.length)
+	}
+	r.objects[num] = v
+	return v
+}
+
+// objectStream decodes the object stream num.
+func (r *reader) objectStream(num int) (*objectStream, error) {
+	if objs, ok := r.streams[num]; ok {
+		return objs, nil
+	}
+	s, ok := r.object(num).(*Stream)
+	if !ok {
+		return nil, fmt.Errorf("%w: object stream %d is missing", errSyntax, num)
+	}
+	data, err := decode(s)
+	if err != nil {
+		return nil, err
+	}
+	n, _ := s.Dict["N"].(int64)
+	first, _ := s.Dict["First"].(int64)
+	objs := &objectStream{data: data}
+	l := lexer{b: data}
+	for i := int64(0); i < n; i++ {
+		objNum, err := l.integer()
+		if err != nil {
+			return nil, err
+		}
+		off, err := l.integer()
+		if err != nil {
+			return nil, err
+		}
+		objs.nums = append(objs.nums, int(objNum))
+		objs.offsets = append(objs.offsets, int(first+off))
+	}
+	r.streams[num] = objs
+	return objs, nil
+}
+
+// resolve follows the references to a direct object.
+func (r *reader) resolve(v interface{}) interface{} {
+	for i := 0; i < maxDepth; i++ {
+		ref, ok := v.(Ref)
+		if !ok {
+			return v
+		}
+		v = r.object(ref.Num)
+	}
+	return nil
+}
+
+// dict resolves v to a dictionary, nil if it is none.
+func (r *reader) dict(v interface{}) Dict {
+	d, _ := r.resolve(v).(Dict)
+	return d
+}
+
+// decode returns the decoded data of a stream, only the Flate filter is
+// supported.
+func decode(s *Stream) ([]byte, error) {
+	filters, params := s.Dict["Filter"], s.Dict["DecodeParms"]
+	if name, ok := filters.(Name); ok {
+		filters, params = Array{name}, Array{params}
+	}
+	list, _ := filters.(Array)
+	paramList, _ := params.(Array)
+	data := s.Data
+	for i, f := range list {
+		if f != Name("FlateDecode") && f != Name("Fl") {
+			return nil, fmt.Errorf("%w: unsupported filter %v", ErrUnsupported, f)
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := io.ReadAll(zr)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, err
+		}
+		data = decoded
+		if i < len(paramList) {
+			if p, ok := paramList[i].(Dict); ok {
+				if data, err = unpredict(data, p); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return data, nil
+}
+
+// unpredict reverses the PNG predictors applied before the compression.
+func unpredict(data []byte, params Dict) ([]byte, error) {
+	predictor, _ := params["Predictor"].(int64)
+	if predictor < 10 {
+		if predictor > 1 {
+			return nil, fmt.Errorf("%w: TIFF predictor", ErrUnsupported)
+		}
+		return data, nil
+	}
+	columns, ok := params["Columns"].(int64)
+	if !ok {
+		columns = 1
+	}
+	colors, ok := params["Colors"].(int64)
+	if !ok {
+		colors = 1
+	}
+	bits, ok := params["BitsPerComponent"].(int64)
+	if !ok {
+		bits = 8
+	}
+	bpp := int((colors*bits + 7) / 8)
+	rowLen := int((columns*colors*bits + 7) / 8)
+	var out []byte
+	prev := make([]byte, rowLen)
+	for len(data) >= rowLen+1 {
+		kind, row := data[0], append([]byte(nil), data[1:rowLen+1]...)
+		data = data[rowLen+1:]
+		for i := range row {
+			var left, upLeft byte
+			if i >= bpp {
+				left, upLeft = row[i-bpp], prev[i-bpp]
+			}
+			up := prev[i]
+			switch kind {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// page is a leaf of the page tree with its inherited attributes resolved.
+type page struct {
+	ref       Ref
+	dict      Dict
+	resources Dict
+	box       [4]float64
+}
+
+// pages returns the pages of the document in order.
+func (r *reader) pages() ([]page, error) {
+	catalog := r.dict(r.trailer["Root"])
+	if catalog == nil {
+		return nil, fmt.Errorf("%w: the catalog is missing", ErrUnsupported)
+	}
+	root, ok := catalog["Pages"].(Ref)
+	if !ok {
+		return nil, fmt.Errorf("%w: the page tree is missing", ErrUnsupported)
+	}
+	var pages []page
+	seen := map[int]bool{}
+	var walk func(ref Ref, inherited Dict, depth int) error
+	walk = func(ref Ref, inherited Dict, depth int) error {
+		if seen[ref.Num] || depth > maxDepth {
+			return fmt.Errorf("%w: the page tree has a cycle", ErrUnsupported)
+		}
+		seen[ref.Num] = true
+		node := r.dict(ref)
+		if node == nil {
+			return fmt.Errorf("%w: page tree node %d is missing", ErrUnsupported, ref.Num)
+		}
+		attrs := inherited.clone()
+		for _, k := range []Name{"Resources", "MediaBox", "CropBox"} {
+			if v, ok := node[k]; ok {
+				attrs[k] = v
+			}
+		}
+		kids, isNode := r.resolve(node["Kids"]).(Array)
+		if !isNode || node["Type"] == Name("Page") {
+			p := page{ref: ref, dict: node, resources: r.dict(attrs["Resources"]), box: [4]float64{0, 0, 612, 792}}
+			box, ok := r.resolve(attrs["CropBox"]).(Array)
+			if !ok {
+				box, _ = r.resolve(attrs["MediaBox"]).(Array)
+			}
+			if len(box) == 4 {
+				for i, v := range box {
+					p.box[i] = r.number(v)
+				}
+			}
+			pages = append(pages, p)
+			return nil
+		}
+		for _, kid := range kids {
+			kidRef, ok := kid.(Ref)
+			if !ok {
+				continue
+			}
+			if err := walk(kidRef, attrs, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root, Dict{}, 0); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// number resolves v to a number.
+func (r *reader) number(v interface{}) float64 {
+	switch n := r.resolve(v).(type) {
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,169 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// update collects the changed and new objects of a document, which is then
+// written anew as a whole.
+type update struct {
+	r       *reader
+	next    int
+	objects map[int]interface{}
+	gens    map[int]int
+}
+
+func newUpdate(r *reader) *update {
+	u := &update{r: r, objects: map[int]interface{}{}, gens: map[int]int{}}
+	if size, ok := r.trailer["Size"].(int64); ok {
+		u.next = int(size)
+	}
+	for num := range r.xref {
+		if num >= u.next {
+			u.next = num + 1
+		}
+	}
+	if u.next == 0 {
+		u.next = 1
+	}
+	return u
+}
+
+// add appends a new object and returns its reference.
+func (u *update) add(v interface{}) Ref {
+	ref := Ref{Num: u.next}
+	u.next++
+	u.objects[ref.Num] = v
+	return ref
+}
+
+// set replaces the object ref.
+func (u *update) set(ref Ref, v interface{}) {
+	u.objects[ref.Num] = v
+	u.gens[ref.Num] = ref.Gen
+}
+
+// bytes returns the document written as a full save of the objects
+// reachable from the trailer. Nothing of the original file is copied, so
+// neither an earlier revision nor the objects of a replaced stamp remain in
+// it. Compressed objects are written as plain objects, indexed by a
+// cross-reference table.
+func (u *update) bytes() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%%PDF-%s\n%%\xe2\xe3\xcf\xd3\n", version(u.r.data))
+
+	trailer := Dict{}
+	for _, k := range []Name{"Root", "Info", "ID"} {
+		if v, ok := u.r.trailer[k]; ok {
+			trailer[k] = v
+		}
+	}
+	entries := map[int]entry{0: {free: true, gen: 65535}}
+	for _, num := range u.reachable(trailer) {
+		gen, ok := u.gens[num]
+		if !ok {
+			gen = u.r.xref[num].gen
+		}
+		entries[num] = entry{offset: int64(b.Len()), gen: gen}
+		fmt.Fprintf(&b, "%d %d obj\n", num, gen)
+		writeObject(&b, u.object(num))
+		b.WriteString("\nendobj\n")
+	}
+
+	start := b.Len()
+	b.WriteString("xref\n")
+	for _, section := range sections(entries) {
+		fmt.Fprintf(&b, "%d %d\n", section[0], len(section))
+		for _, num := range section {
+			e := entries[num]
+			kind := 'n'
+			if e.free {
+				kind = 'f'
+			}
+			fmt.Fprintf(&b, "%010d %05d %c\r\n", e.offset, e.gen, kind)
+		}
+	}
+	trailer["Size"] = u.next
+	b.WriteString("trailer\n")
+	writeObject(&b, trailer)
+	fmt.Fprintf(&b, "\nstartxref\n%d\n%%%%EOF\n", start)
+	return b.Bytes()
+}
+
+// object returns the object num, changed or as read.
+func (u *update) object(num int) interface{} {
+	if v, ok := u.objects[num]; ok {
+		return v
+	}
+	return u.r.object(num)
+}
+
+// reachable returns the numbers of the objects the trailer refers to,
+// directly or through other objects, in ascending order. The length of a
+// stream is written directly, so an indirect length is left out.
+func (u *update) reachable(trailer Dict) []int {
+	var nums []int
+	seen := map[int]bool{}
+	stack := []interface{}{trailer}
+	for len(stack) > 0 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch v := v.(type) {
+		case Ref:
+			if obj := u.object(v.Num); !seen[v.Num] && obj != nil {
+				seen[v.Num] = true
+				nums = append(nums, v.Num)
+				stack = append(stack, obj)
+			}
+		case Array:
+			stack = append(stack, v...)
+		case Dict:
+			for _, e := range v {
+				stack = append(stack, e)
+			}
+		case *Stream:
+			for k, e := range v.Dict {
+				if k != "Length" {
+					stack = append(stack, e)
+				}
+			}
+		}
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+var versionHeader = regexp.MustCompile(`%PDF-(\d\.\d+)`)
+
+// version returns the version in the header of the document, at least 1.4
+// which added the transparency of the stamp.
+func version(data []byte) string {
+	if len(data) > 1024 {
+		data = data[:1024]
+	}
+	m := versionHeader.FindSubmatch(data)
+	if m == nil || string(m[1]) < "1.4" {
+		return "1.4"
+	}
+	return string(m[1])
+}
+
+// sections groups the object numbers into runs of consecutive numbers.
+func sections(entries map[int]entry) [][]int {
+	nums := make([]int, 0, len(entries))
+	for num := range entries {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	var runs [][]int
+	for i, num := range nums {
+		if i == 0 || num != nums[i-1]+1 {
+			runs = append(runs, nil)
+		}
+		runs[len(runs)-1] = append(runs[len(runs)-1], num)
+	}
+	return runs
+}
@@ -0,0 +1,325 @@
+package watermark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"publisher/internal"
+	orm "publisher/internal/database"
+	"publisher/internal/util"
+	"publisher/pkg/database"
+	"publisher/pkg/watermark/epub"
+	"publisher/pkg/watermark/pdf"
+	"publisher/pkg/watermark/raster"
+	"publisher/pkg/watermark/text"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultWorkers is the number of jobs run at once by default.
+	DefaultWorkers = 4
+	// DefaultVisibilityTimeout is how long a job may run by default before
+	// its queue entry is handed out again.
+	DefaultVisibilityTimeout = 5 * time.Minute
+	// DefaultMaxAttempts is the number of runs of a job by default before it
+	// fails and is dead-lettered.
+	DefaultMaxAttempts = 5
+	// DefaultDrainTimeout is how long a shut down pool waits for the running
+	// jobs by default.
+	DefaultDrainTimeout = 30 * time.Second
+
+	// failTimeout limits recording the outcome of a job, which must succeed
+	// even if the job was canceled
+	failTimeout = 10 * time.Second
+	// pollInterval is how often an idle worker looks for jobs queued by
+	// other nodes or becoming visible again
+	pollInterval = time.Second
+	// maxRetryBackoff caps the delay between the runs of a job
+	maxRetryBackoff = 5 * time.Minute
+)
+
+// ErrPoolClosed is returned for jobs enqueued after the pool was shut down.
+var ErrPoolClosed = errors.New("the watermark workers are shut down")
+
+// Pool runs the watermark jobs in the background with a fixed number of
+// workers. A job is enqueued once its ticket is Pending, a worker moves it
+// through Started and InProgress to Finished. A failed run is retried with
+// backoff, the job fails once it ran out of attempts.
+type Pool struct {
+	db          database.Service
+	queue       Queue
+	workers     int
+	visibility  time.Duration
+	maxAttempts int
+	text        text.Options
+	image       raster.Options
+	pdf         pdf.Options
+	epub        epub.Options
+	// wake tells the idle workers that a job was enqueued
+	wake   chan struct{}
+	mu     sync.RWMutex
+	closed bool
+}
+
+// PoolOption configures the worker pool.
+type PoolOption func(p *Pool)
+
+// WithWorkers sets the number of jobs run at once, the default is
+// DefaultWorkers.
+func WithWorkers(n int) PoolOption {
+	return func(p *Pool) {
+		if n > 0 {
+			p.workers = n
+		}
+	}
+}
+
+// WithVisibilityTimeout sets how long a job may run, the queue hands the job
+// out again once it passed, e.g. after a crash. The default is
+// DefaultVisibilityTimeout.
+func WithVisibilityTimeout(d time.Duration) PoolOption {
+	return func(p *Pool) {
+		if d > 0 {
+			p.visibility = d
+		}
+	}
+}
+
+// WithMaxAttempts sets the number of runs of a job before it fails, the
+// default is DefaultMaxAttempts.
+func WithMaxAttempts(n int) PoolOption {
+	return func(p *Pool) {
+		if n > 0 {
+			p.maxAttempts = n
+		}
+	}
+}
+
+// WithTextOptions sets how the mark is embedded into text content, the
+// default is text.DefaultOptions.
+func WithTextOptions(opts text.Options) PoolOption {
+	return func(p *Pool) {
+		p.text = opts
+	}
+}
+
+// WithImageOptions sets how the mark is embedded into PNG and JPEG images,
+// the default is raster.DefaultOptions.
+func WithImageOptions(opts raster.Options) PoolOption {
+	return func(p *Pool) {
+		p.image = opts
+	}
+}
+
+// WithPDFOptions sets how PDF documents are stamped, the default is
+// pdf.DefaultOptions.
+func WithPDFOptions(opts pdf.Options) PoolOption {
+	return func(p *Pool) {
+		p.pdf = opts
+	}
+}
+
+// WithEPUBOptions sets how the mark is embedded into EPUB e-books, the
+// default is epub.DefaultOptions.
+func WithEPUBOptions(opts epub.Options) PoolOption {
+	return func(p *Pool) {
+		p.epub = opts
+	}
+}
+
+// NewPool returns a pool running the jobs of queue, the watermarks are
+// applied through db.
+func NewPool(db database.Service, queue Queue, options ...PoolOption) *Pool {
+	p := &Pool{
+		db:          db,
+		queue:       queue,
+		workers:     DefaultWorkers,
+		visibility:  DefaultVisibilityTimeout,
+		maxAttempts: DefaultMaxAttempts,
+		text:        text.DefaultOptions(),
+		image:       raster.DefaultOptions(),
+		pdf:         pdf.DefaultOptions(),
+		epub:        epub.DefaultOptions(),
+	}
+	for _, option := range options {
+		option(p)
+	}
+	p.wake = make(chan struct{}, p.workers)
+	return p
+}
+
+// Enqueue queues the pending job of the ticket.
+func (p *Pool) Enqueue(ctx context.Context, ticketID string) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrPoolClosed
+	}
+	if err := p.queue.Push(ctx, ticketID); err != nil {
+		return err
+	}
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run starts the workers and blocks until the context is canceled. The pool
+// then claims no more jobs and waits for the running ones for up to drain,
+// after which they are canceled. The queued and the canceled jobs are run
+// after the next start.
+func (p *Pool) Run(ctx context.Context, drain time.Duration) {
+	jobCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx, jobCtx)
+		}()
+	}
+
+	<-ctx.Done()
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(drain)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		logger.Log("pool", "drain", "msg", "canceling the running watermark jobs")
+		cancel()
+		<-done
+	}
+}
+
+// work claims and runs jobs until the context is canceled, jobCtx is passed
+// on to the jobs.
+func (p *Pool) work(ctx, jobCtx context.Context) {
+	for ctx.Err() == nil {
+		entry, err := p.queue.Claim(ctx, p.visibility)
+		if err != nil && ctx.Err() == nil {
+			logger.Log("pool", "claim", "err", err)
+		}
+		if entry != nil {
+			p.process(jobCtx, entry)
+			continue
+		}
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-ctx.Done():
+		case <-p.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// process runs the claimed job and settles its queue entry: a completed job
+// is removed, a failed one is released for another attempt or dead-lettered,
+// a job canceled by the shutdown is released at once.
+func (p *Pool) process(ctx context.Context, entry *orm.WatermarkQueueEntry) {
+	// the job ends before the queue hands it out again
+	runCtx, cancel := context.WithTimeout(ctx, p.visibility)
+	err := p.run(runCtx, entry)
+	cancel()
+
+	settleCtx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+	switch {
+	case err == nil:
+		err = p.queue.Ack(settleCtx, entry)
+	case ctx.Err() != nil:
+		p.retry(settleCtx, entry.TicketID, "interrupted by the shutdown")
+		err = p.queue.Release(settleCtx, entry, 0, ctx.Err().Error())
+	case entry.Attempts >= p.maxAttempts:
+		logger.Log("method", "process", "ticketID", entry.TicketID, "attempts", entry.Attempts, "err", err)
+		p.fail(entry.TicketID, err)
+		err = p.queue.DeadLetter(settleCtx, entry, err.Error())
+	default:
+		p.retry(settleCtx, entry.TicketID, fmt.Sprintf("attempt %d: %v", entry.Attempts, err))
+		err = p.queue.Release(settleCtx, entry, retryBackoff(entry.Attempts), err.Error())
+	}
+	if err != nil {
+		logger.Log("method", "process", "ticketID", entry.TicketID, "err", err)
+	}
+}
+
+// run moves the job of the ticket through the statuses and applies the
+// watermark. A job started by an interrupted run is run again, a job which
+// is no longer pending is skipped.
+func (p *Pool) run(ctx context.Context, entry *orm.WatermarkQueueEntry) error {
+	ticketID := entry.TicketID
+	job, err := p.db.WatermarkJob(ctx, ticketID)
+	if errors.Is(err, util.ErrUnknown) {
+		// the document was removed meanwhile
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if job.Status.CanRetry() {
+		reason := fmt.Sprintf("attempt %d: the previous run was interrupted", entry.Attempts-1)
+		job, err = p.db.TransitionWatermark(ctx, ticketID, internal.WatermarkTransition{To: internal.Pending, Retry: true, Reason: reason})
+		if err != nil {
+			return err
+		}
+	}
+	if job.Status != internal.Pending {
+		return nil
+	}
+	_, err = p.db.TransitionWatermark(ctx, ticketID, internal.WatermarkTransition{To: internal.Started})
+	if err == nil {
+		_, err = p.db.TransitionWatermark(ctx, ticketID, internal.WatermarkTransition{To: internal.InProgress})
+	}
+	if err == nil {
+		err = p.apply(ctx, ticketID, job.Mark)
+	}
+	if err == nil {
+		_, err = p.db.TransitionWatermark(ctx, ticketID, internal.WatermarkTransition{To: internal.Finished})
+	}
+	return err
+}
+
+// retry moves the job of a failed or interrupted run back to Pending, a job
+// which did not start yet stays as it is.
+func (p *Pool) retry(ctx context.Context, ticketID, reason string) {
+	change := internal.WatermarkTransition{To: internal.Pending, Retry: true, Reason: reason}
+	if _, err := p.db.TransitionWatermark(ctx, ticketID, change); err != nil && !errors.Is(err, util.ErrConflict) {
+		logger.Log("method", "retry", "ticketID", ticketID, "err", err)
+	}
+}
+
+// fail records the error as the reason of the failed job, also when the job
+// was canceled.
+func (p *Pool) fail(ticketID string, cause error) {
+	ctx, cancel := context.WithTimeout(context.Background(), failTimeout)
+	defer cancel()
+	change := internal.WatermarkTransition{To: internal.Failed, Reason: cause.Error()}
+	if _, err := p.db.TransitionWatermark(ctx, ticketID, change); err != nil && !errors.Is(err, util.ErrConflict) {
+		logger.Log("method", "fail", "ticketID", ticketID, "err", err)
+	}
+}
+
+// retryBackoff returns the delay before the next run of a job which ran
+// attempts times, doubling from a second up to maxRetryBackoff.
+func retryBackoff(attempts int) time.Duration {
+	d := time.Second
+	for i := 1; i < attempts && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	if d > maxRetryBackoff {
+		d = maxRetryBackoff
+	}
+	return d
+}
@@ -0,0 +1,193 @@
+package watermark
+
+import (
+	"context"
+	"errors"
+	"publisher/internal"
+	orm "publisher/internal/database"
+	"publisher/pkg/blob"
+	"publisher/pkg/database"
+	"testing"
+	"time"
+)
+
+// flakyDB fails the first failures updates of a document.
+type flakyDB struct {
+	database.Service
+	failures int
+}
+
+var errFlaky = errors.New("the database is unavailable")
+
+func (f *flakyDB) Update(ctx context.Context, ticketID string, doc *internal.Document) (int, error) {
+	if f.failures > 0 {
+		f.failures--
+		return 0, errFlaky
+	}
+	return f.Service.Update(ctx, ticketID, doc)
+}
+
+// newTestPool returns a pool on a memory database with a document whose
+// watermark is requested and queued.
+func newTestPool(t *testing.T, failures int, options ...PoolOption) (*Pool, *flakyDB, Queue, string) {
+	t.Helper()
+	ctx := context.Background()
+	db := &flakyDB{Service: database.NewService(database.NewMemoryRepository(), blob.NewMemoryStore()), failures: failures}
+	ticketID, err := db.Add(ctx, &internal.Document{Title: "Title", Author: "Author", Topic: "Topic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.TransitionWatermark(ctx, ticketID, internal.WatermarkTransition{To: internal.Pending, Mark: "reader"}); err != nil {
+		t.Fatal(err)
+	}
+	q, _ := newTestQueue(t)
+	p := NewPool(db, q, options...)
+	if err := p.Enqueue(ctx, ticketID); err != nil {
+		t.Fatal(err)
+	}
+	return p, db, q, ticketID
+}
+
+func queueEntries(q Queue) []orm.WatermarkQueueEntry {
+	return q.(*fileQueue).state.Entries
+}
+
+func TestPoolProcess(t *testing.T) {
+	ctx := context.Background()
+	p, db, q, ticketID := newTestPool(t, 0)
+	p.process(ctx, mustClaim(t, q, time.Minute))
+
+	job, err := db.WatermarkJob(ctx, ticketID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != internal.Finished || job.StartedAt == nil || job.FinishedAt == nil {
+		t.Errorf("job after the run: %+v", job)
+	}
+	if entries := queueEntries(q); len(entries) != 0 {
+		t.Errorf("queue after the run: %+v", entries)
+	}
+}
+
+func TestPoolRetry(t *testing.T) {
+	ctx := context.Background()
+	p, db, q, ticketID := newTestPool(t, 2)
+	for attempt := 1; attempt <= 2; attempt++ {
+		entry := mustClaim(t, q, time.Minute)
+		claimed := time.Now()
+		p.process(ctx, entry)
+
+		job, err := db.WatermarkJob(ctx, ticketID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != internal.Pending || job.Mark != "reader" || job.Reason == "" {
+			t.Errorf("attempt %d: job after the failed run: %+v", attempt, job)
+		}
+		entries := queueEntries(q)
+		if len(entries) != 1 {
+			t.Fatalf("attempt %d: queue after the failed run: %+v", attempt, entries)
+		}
+		// the entry is released with backoff and the error of the run
+		if delay := entries[0].VisibleAt.Sub(claimed); delay < retryBackoff(attempt) || delay > retryBackoff(attempt)+time.Second {
+			t.Errorf("attempt %d: entry visible after %v, want %v", attempt, delay, retryBackoff(attempt))
+		}
+		if entries[0].LastError != errFlaky.Error() {
+			t.Errorf("attempt %d: entry error %q, want %q", attempt, entries[0].LastError, errFlaky.Error())
+		}
+		q.(*fileQueue).state.Entries[0].VisibleAt = time.Now()
+	}
+
+	p.process(ctx, mustClaim(t, q, time.Minute))
+	if job, _ := db.WatermarkJob(ctx, ticketID); job.Status != internal.Finished {
+		t.Errorf("job after the third run: %+v", job)
+	}
+	if entries := queueEntries(q); len(entries) != 0 {
+		t.Errorf("queue after the third run: %+v", entries)
+	}
+}
+
+func TestPoolDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	p, db, q, ticketID := newTestPool(t, 2, WithMaxAttempts(2))
+	p.process(ctx, mustClaim(t, q, time.Minute))
+	q.(*fileQueue).state.Entries[0].VisibleAt = time.Now()
+	p.process(ctx, mustClaim(t, q, time.Minute))
+
+	job, err := db.WatermarkJob(ctx, ticketID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != internal.Failed || job.Reason != errFlaky.Error() || job.FinishedAt == nil {
+		t.Errorf("job after the last attempt: %+v", job)
+	}
+	entries := queueEntries(q)
+	if len(entries) != 1 || entries[0].Status != orm.QueueDead || entries[0].Attempts != 2 {
+		t.Errorf("queue after the last attempt: %+v", entries)
+	}
+	mustBeEmpty(t, q)
+}
+
+func TestPoolRunsInterruptedJob(t *testing.T) {
+	ctx := context.Background()
+	p, db, q, ticketID := newTestPool(t, 0)
+	// a worker crashed after starting the job, the queue hands it out again
+	// once the visibility timeout ended
+	if _, err := db.TransitionWatermark(ctx, ticketID, internal.WatermarkTransition{To: internal.Started}); err != nil {
+		t.Fatal(err)
+	}
+	mustClaim(t, q, 0)
+	p.process(ctx, mustClaim(t, q, time.Minute))
+
+	if job, _ := db.WatermarkJob(ctx, ticketID); job.Status != internal.Finished {
+		t.Errorf("job after the second run: %+v", job)
+	}
+}
+
+func TestPoolRun(t *testing.T) {
+	p, db, _, ticketID := newTestPool(t, 1, WithWorkers(2))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx, time.Second)
+		close(done)
+	}()
+	// the failed first run is retried after a second
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := db.WatermarkJob(context.Background(), ticketID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == internal.Finished {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job not finished: %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if err := p.Enqueue(context.Background(), ticketID); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Enqueue after the shutdown returned %v, want %v", err, ErrPoolClosed)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	for _, tt := range []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{9, 256 * time.Second},
+		{10, maxRetryBackoff},
+		{100, maxRetryBackoff},
+	} {
+		if got := retryBackoff(tt.attempts); got != tt.want {
+			t.Errorf("retryBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
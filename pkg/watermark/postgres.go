@@ -0,0 +1,92 @@
+package watermark
+
+import (
+	"context"
+	orm "publisher/internal/database"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type postgresQueue struct {
+	db *gorm.DB
+}
+
+// NewPostgresQueue returns a queue kept in the watermark_queue table, the
+// nodes sharing the database share the queue.
+func NewPostgresQueue(db *gorm.DB) Queue {
+	return &postgresQueue{db: db}
+}
+
+func (q *postgresQueue) Push(ctx context.Context, ticketID string) error {
+	now := time.Now()
+	entry := &orm.WatermarkQueueEntry{TicketID: ticketID, Status: orm.QueueQueued, VisibleAt: now, EnqueuedAt: now}
+	// the partial unique index keeps a single queued entry per ticket
+	return q.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "ticket_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Eq{Column: "status", Value: orm.QueueQueued}}},
+		DoNothing:   true,
+	}).Create(entry).Error
+}
+
+func (q *postgresQueue) Claim(ctx context.Context, visibility time.Duration) (*orm.WatermarkQueueEntry, error) {
+	// SKIP LOCKED lets the workers of several nodes claim distinct entries
+	now := time.Now()
+	var entries []orm.WatermarkQueueEntry
+	err := q.db.WithContext(ctx).Raw(`UPDATE watermark_queue SET attempts = attempts + 1, visible_at = ?
+		WHERE id = (
+			SELECT id FROM watermark_queue WHERE status = ? AND visible_at <= ?
+			ORDER BY visible_at, id LIMIT 1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, now.Add(visibility), orm.QueueQueued, now).Scan(&entries).Error
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+func (q *postgresQueue) Ack(ctx context.Context, entry *orm.WatermarkQueueEntry) error {
+	res := q.claimed(ctx, entry).Delete(&orm.WatermarkQueueEntry{})
+	return leaseResult(res)
+}
+
+func (q *postgresQueue) Release(ctx context.Context, entry *orm.WatermarkQueueEntry, delay time.Duration, reason string) error {
+	res := q.claimed(ctx, entry).Updates(map[string]interface{}{
+		"visible_at": time.Now().Add(delay),
+		"last_error": reason,
+	})
+	return leaseResult(res)
+}
+
+func (q *postgresQueue) DeadLetter(ctx context.Context, entry *orm.WatermarkQueueEntry, reason string) error {
+	res := q.claimed(ctx, entry).Updates(map[string]interface{}{
+		"status":     orm.QueueDead,
+		"last_error": reason,
+	})
+	return leaseResult(res)
+}
+
+// claimed selects the entry as long as it was not claimed again.
+func (q *postgresQueue) claimed(ctx context.Context, entry *orm.WatermarkQueueEntry) *gorm.DB {
+	return q.db.WithContext(ctx).Model(&orm.WatermarkQueueEntry{}).
+		Where("id = ? AND attempts = ? AND status = ?", entry.ID, entry.Attempts, orm.QueueQueued)
+}
+
+func leaseResult(res *gorm.DB) error {
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errLeaseLost
+	}
+	return nil
+}
+
+func (q *postgresQueue) Close() error {
+	sqlDB, err := q.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
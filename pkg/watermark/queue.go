@@ -0,0 +1,41 @@
+package watermark
+
+import (
+	"context"
+	"fmt"
+	orm "publisher/internal/database"
+	"publisher/internal/util"
+	"time"
+)
+
+// Names of the queue backends.
+const (
+	QueueFile     = "file"
+	QueuePostgres = "postgres"
+)
+
+// errLeaseLost is returned for an entry whose visibility timeout ended, it was
+// claimed again or dead-lettered meanwhile.
+var errLeaseLost = fmt.Errorf("%w: the queued job was claimed again", util.ErrConflict)
+
+// Queue keeps the watermark jobs waiting for a worker, so they survive a
+// restart of the node. A claimed entry is hidden for the visibility timeout
+// and handed out again if it is neither acknowledged nor released until then,
+// a job may thus run more than once.
+type Queue interface {
+	// Push queues the job of the ticket, a ticket already queued is kept
+	// once.
+	Push(ctx context.Context, ticketID string) error
+	// Claim hides the next visible entry for the visibility timeout and
+	// counts the attempt, it returns nil if no entry is visible.
+	Claim(ctx context.Context, visibility time.Duration) (*orm.WatermarkQueueEntry, error)
+	// Ack removes the entry of a completed job.
+	Ack(ctx context.Context, entry *orm.WatermarkQueueEntry) error
+	// Release makes the entry visible again after the delay and records the
+	// error of the attempt.
+	Release(ctx context.Context, entry *orm.WatermarkQueueEntry, delay time.Duration, reason string) error
+	// DeadLetter keeps the entry of a job given up for inspection, it is not
+	// claimed any more.
+	DeadLetter(ctx context.Context, entry *orm.WatermarkQueueEntry, reason string) error
+	Close() error
+}
@@ -0,0 +1,123 @@
+package raster
+
+import (
+	"image"
+	"math"
+)
+
+// The invisible mark is kept in the luminance of the 8x8 blocks JPEG
+// compresses. Each block carries a bit in the order of two mid frequency
+// coefficients of its discrete cosine transform, which survives the
+// quantization of a re-encoding but not cropping or scaling.
+const blockSize = 8
+
+// the coefficients compared, as (u, v) frequencies
+var coefficients = [2][2]int{{2, 3}, {3, 2}}
+
+// basis holds the 8x8 orthonormal DCT basis of the two coefficients, so a
+// change of a coefficient by d changes the pixel (x, y) by d*basis[i][y][x].
+var basis = func() [2][blockSize][blockSize]float64 {
+	var b [2][blockSize][blockSize]float64
+	for i, c := range coefficients {
+		u, v := c[0], c[1]
+		for y := 0; y < blockSize; y++ {
+			for x := 0; x < blockSize; x++ {
+				b[i][y][x] = 0.25 *
+					math.Cos(float64(2*x+1)*float64(u)*math.Pi/16) *
+					math.Cos(float64(2*y+1)*float64(v)*math.Pi/16)
+			}
+		}
+	}
+	return b
+}()
+
+// blocks returns the number of whole blocks of the image.
+func blocks(b image.Rectangle) int {
+	return (b.Dx() / blockSize) * (b.Dy() / blockSize)
+}
+
+// blockOrigin returns the top left pixel of the k-th block in row order.
+func blockOrigin(b image.Rectangle, k int) image.Point {
+	cols := b.Dx() / blockSize
+	return image.Pt(b.Min.X+(k%cols)*blockSize, b.Min.Y+(k/cols)*blockSize)
+}
+
+// luminance returns the Y of the pixel as JPEG computes it.
+func luminance(img *image.RGBA, x, y int) float64 {
+	i := img.PixOffset(x, y)
+	return 0.299*float64(img.Pix[i]) + 0.587*float64(img.Pix[i+1]) + 0.114*float64(img.Pix[i+2])
+}
+
+// project returns the two coefficients of the block at p.
+func project(img *image.RGBA, p image.Point) (c0, c1 float64) {
+	for y := 0; y < blockSize; y++ {
+		for x := 0; x < blockSize; x++ {
+			l := luminance(img, p.X+x, p.Y+y)
+			c0 += l * basis[0][y][x]
+			c1 += l * basis[1][y][x]
+		}
+	}
+	return c0, c1
+}
+
+// embedDCT repeats the bits over the blocks of the image, the coefficients
+// of a block differ by at least strength afterwards. It changes nothing if
+// the blocks are too few for the bits.
+func embedDCT(img *image.RGBA, bits []byte, strength float64) bool {
+	n := blocks(img.Bounds())
+	if n < len(bits) {
+		return false
+	}
+	for k := 0; k < n; k++ {
+		p := blockOrigin(img.Bounds(), k)
+		c0, c1 := project(img, p)
+		diff := c0 - c1
+		var shift float64
+		if bits[k%len(bits)] == 1 {
+			if diff >= strength {
+				continue
+			}
+			shift = (strength - diff) / 2
+		} else {
+			if diff <= -strength {
+				continue
+			}
+			shift = -(strength + diff) / 2
+		}
+		// c0 rises and c1 falls by shift, the chroma is kept by changing the
+		// three channels alike
+		for y := 0; y < blockSize; y++ {
+			for x := 0; x < blockSize; x++ {
+				delta := shift * (basis[0][y][x] - basis[1][y][x])
+				i := img.PixOffset(p.X+x, p.Y+y)
+				for c := 0; c < 3; c++ {
+					img.Pix[i+c] = clamp(float64(img.Pix[i+c]) + delta)
+				}
+			}
+		}
+	}
+	return true
+}
+
+// extractDCT reads the bits of all blocks of the image.
+func extractDCT(img *image.RGBA) []byte {
+	n := blocks(img.Bounds())
+	bits := make([]byte, n)
+	for k := 0; k < n; k++ {
+		c0, c1 := project(img, blockOrigin(img.Bounds(), k))
+		if c0 > c1 {
+			bits[k] = 1
+		}
+	}
+	return bits
+}
+
+func clamp(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	}
+	return uint8(v + 0.5)
+}
@@ -0,0 +1,181 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Position places a single visible overlay on the image.
+type Position string
+
+const (
+	Center      Position = "center"
+	TopLeft     Position = "top-left"
+	TopRight    Position = "top-right"
+	BottomLeft  Position = "bottom-left"
+	BottomRight Position = "bottom-right"
+)
+
+// ValidPosition reports whether p is one of the positions.
+func ValidPosition(p Position) bool {
+	switch p {
+	case Center, TopLeft, TopRight, BottomLeft, BottomRight:
+		return true
+	}
+	return false
+}
+
+// Overlay is a visible watermark drawn over the image, either a text or a
+// logo.
+type Overlay struct {
+	// Text is drawn if no logo is set, the mark if it is empty
+	Text string
+	Logo image.Image
+	// Color of the text
+	Color color.Color
+	// Opacity from 0, invisible, to 1, opaque
+	Opacity float64
+	// Rotation in degrees, counterclockwise
+	Rotation float64
+	// Scale is the width of the overlay before its rotation relative to the
+	// width of the image
+	Scale float64
+	// Tile repeats the overlay over the whole image, Position is then
+	// ignored
+	Tile     bool
+	Position Position
+}
+
+// DefaultOverlay draws the mark diagonally across the image.
+func DefaultOverlay() *Overlay {
+	return &Overlay{
+		Color:    color.RGBA{R: 128, G: 128, B: 128, A: 255},
+		Opacity:  0.3,
+		Rotation: 30,
+		Scale:    0.6,
+		Position: Center,
+	}
+}
+
+// stamp returns the overlay rendered for an image of the given width, it is
+// transparent outside the text or logo.
+func (o *Overlay) stamp(mark string, width int) image.Image {
+	scale := o.Scale
+	if scale <= 0 || scale > 1 {
+		scale = DefaultOverlay().Scale
+	}
+	target := int(float64(width) * scale)
+	if target < 1 {
+		target = 1
+	}
+	var img image.Image
+	if o.Logo != nil {
+		img = resize(o.Logo, target)
+	} else {
+		s := o.Text
+		if s == "" {
+			s = mark
+		}
+		c := o.Color
+		if c == nil {
+			c = DefaultOverlay().Color
+		}
+		img = renderText(s, target/(len([]rune(s))*glyphWidth), c)
+	}
+	if o.Rotation != 0 {
+		img = rotate(img, o.Rotation)
+	}
+	return img
+}
+
+// draw draws the overlay onto dst.
+func (o *Overlay) draw(dst draw.Image, mark string) {
+	bounds := dst.Bounds()
+	stamp := o.stamp(mark, bounds.Dx())
+	size := stamp.Bounds().Size()
+	opacity := math.Max(0, math.Min(1, o.Opacity))
+	mask := image.NewUniform(color.Alpha{A: uint8(opacity*255 + 0.5)})
+	at := func(p image.Point) {
+		r := image.Rectangle{Min: p, Max: p.Add(size)}
+		draw.DrawMask(dst, r, stamp, stamp.Bounds().Min, mask, image.Point{}, draw.Over)
+	}
+	if o.Tile {
+		// half a stamp of space between the tiles
+		step := image.Pt(size.X+size.X/2+1, size.Y+size.Y/2+1)
+		for y := bounds.Min.Y - step.Y/2; y < bounds.Max.Y; y += step.Y {
+			for x := bounds.Min.X - step.X/2; x < bounds.Max.X; x += step.X {
+				at(image.Pt(x, y))
+			}
+		}
+		return
+	}
+	margin := min(bounds.Dx(), bounds.Dy()) / 50
+	p := image.Pt(bounds.Min.X+(bounds.Dx()-size.X)/2, bounds.Min.Y+(bounds.Dy()-size.Y)/2)
+	switch o.Position {
+	case TopLeft:
+		p = image.Pt(bounds.Min.X+margin, bounds.Min.Y+margin)
+	case TopRight:
+		p = image.Pt(bounds.Max.X-margin-size.X, bounds.Min.Y+margin)
+	case BottomLeft:
+		p = image.Pt(bounds.Min.X+margin, bounds.Max.Y-margin-size.Y)
+	case BottomRight:
+		p = image.Pt(bounds.Max.X-margin-size.X, bounds.Max.Y-margin-size.Y)
+	}
+	at(p)
+}
+
+// resize scales the image to the width keeping its aspect ratio, picking the
+// nearest pixel.
+func resize(src image.Image, width int) image.Image {
+	b := src.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return src
+	}
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			dst.Set(x, y, src.At(b.Min.X+x*b.Dx()/width, b.Min.Y+y*b.Dy()/height))
+		}
+	}
+	return dst
+}
+
+// rotate turns the image counterclockwise by the angle in degrees around its
+// center, the result is large enough to hold the rotated image.
+func rotate(src image.Image, degrees float64) image.Image {
+	b := src.Bounds()
+	rad := degrees * math.Pi / 180
+	sin, cos := math.Sin(rad), math.Cos(rad)
+	w, h := float64(b.Dx()), float64(b.Dy())
+	rw := int(math.Ceil(math.Abs(w*cos) + math.Abs(h*sin)))
+	rh := int(math.Ceil(math.Abs(w*sin) + math.Abs(h*cos)))
+	dst := image.NewRGBA(image.Rect(0, 0, rw, rh))
+	cx, cy := w/2, h/2
+	rcx, rcy := float64(rw)/2, float64(rh)/2
+	for y := 0; y < rh; y++ {
+		for x := 0; x < rw; x++ {
+			// map the destination pixel back into the source, y points down
+			dx, dy := float64(x)+0.5-rcx, float64(y)+0.5-rcy
+			sx := dx*cos - dy*sin + cx
+			sy := dx*sin + dy*cos + cy
+			if sx < 0 || sy < 0 || sx >= w || sy >= h {
+				continue
+			}
+			dst.Set(x, y, src.At(b.Min.X+int(sx), b.Min.Y+int(sy)))
+		}
+	}
+	return dst
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
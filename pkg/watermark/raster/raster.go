@@ -0,0 +1,148 @@
+// Package raster watermarks PNG and JPEG images. A visible overlay draws a
+// text or a logo over the image, an invisible mark is embedded into the
+// frequency domain of the luminance and survives a re-encoding of the image.
+package raster
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"publisher/pkg/watermark/frame"
+)
+
+// Formats of the images, as returned by image.Decode.
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+)
+
+const (
+	// DefaultStrength is the difference of the coefficients carrying a bit
+	// by default, larger values survive stronger compression but become
+	// visible.
+	DefaultStrength = 20
+	// DefaultQuality is the quality of the JPEG images written by default.
+	DefaultQuality = 92
+)
+
+var (
+	// ErrInvalidMark is returned for an empty or too long mark.
+	ErrInvalidMark = errors.New("the mark must have 1 to 255 bytes")
+	// ErrUnsupported is returned for a content which is neither a PNG nor a
+	// JPEG image.
+	ErrUnsupported = errors.New("the content is not a PNG or JPEG image")
+	// ErrNoCapacity is returned if the invisible mark cannot be recovered
+	// from the image, which is too small or too transparent, or if neither
+	// an overlay nor an invisible mark is requested.
+	ErrNoCapacity = errors.New("the image cannot carry the mark")
+	// ErrNoMark is returned if no mark was found in the image.
+	ErrNoMark = errors.New("no watermark was found")
+)
+
+// Options configure how a mark is embedded.
+type Options struct {
+	// Overlay is drawn over the image, nil draws none
+	Overlay *Overlay
+	// Invisible embeds the mark into the frequency domain
+	Invisible bool
+	// Strength of the invisible mark, DefaultStrength if not positive
+	Strength float64
+	// Quality of a JPEG image, DefaultQuality if not positive
+	Quality int
+}
+
+// DefaultOptions draws the mark diagonally over the image and embeds it
+// invisibly.
+func DefaultOptions() Options {
+	return Options{Overlay: DefaultOverlay(), Invisible: true, Strength: DefaultStrength, Quality: DefaultQuality}
+}
+
+// Embed returns the image carrying the mark, encoded in the format of the
+// content. The overlay is drawn before the invisible mark is embedded. The
+// invisible mark is read back from the encoded image, the image is not
+// returned if it lost the mark.
+func Embed(content []byte, mark string, opts Options) ([]byte, error) {
+	if mark == "" || len(mark) > frame.MaxMarkLength {
+		return nil, ErrInvalidMark
+	}
+	if opts.Overlay == nil && !opts.Invisible {
+		return nil, ErrNoCapacity
+	}
+	img, format, err := decode(content)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Overlay != nil {
+		opts.Overlay.draw(img, mark)
+	}
+	if opts.Invisible {
+		strength := opts.Strength
+		if strength <= 0 {
+			strength = DefaultStrength
+		}
+		if !embedDCT(img, frame.Encode([]byte(mark)), strength) {
+			return nil, ErrNoCapacity
+		}
+	}
+
+	var b bytes.Buffer
+	if format == FormatJPEG {
+		quality := opts.Quality
+		if quality <= 0 || quality > 100 {
+			quality = DefaultQuality
+		}
+		err = jpeg.Encode(&b, img, &jpeg.Options{Quality: quality})
+	} else {
+		err = png.Encode(&b, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the color of a transparent pixel is not kept, a block of transparent
+	// pixels loses its bit
+	if opts.Invisible {
+		if res, err := Extract(b.Bytes()); err != nil || res.Mark != mark {
+			return nil, ErrNoCapacity
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// Result is a mark found in an image.
+type Result struct {
+	Mark string
+	// Confidence is the share of the frames found which carry the mark,
+	// from 0 to 1
+	Confidence float64
+	// Frames counts the copies of the mark found
+	Frames int
+}
+
+// Extract returns the invisible mark of the image, ErrNoMark if there is
+// none.
+func Extract(content []byte) (Result, error) {
+	img, _, err := decode(content)
+	if err != nil {
+		return Result{}, err
+	}
+	mark, frames, confidence, ok := frame.Vote(frame.Decode(extractDCT(img)))
+	if !ok {
+		return Result{}, ErrNoMark
+	}
+	return Result{Mark: mark, Confidence: confidence, Frames: frames}, nil
+}
+
+// decode reads a PNG or JPEG image into an RGBA image.
+func decode(content []byte) (*image.RGBA, string, error) {
+	src, format, err := image.Decode(bytes.NewReader(content))
+	if err != nil || (format != FormatPNG && format != FormatJPEG) {
+		return nil, format, ErrUnsupported
+	}
+	b := src.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(img, img.Bounds(), src, b.Min, draw.Src)
+	return img, format, nil
+}
@@ -0,0 +1,105 @@
+package raster
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// picture returns an opaque gradient of w by h pixels whose first
+// transparent rows are transparent.
+func picture(w, h, transparent int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{R: uint8(x), G: uint8(y), B: uint8(x + y), A: 255}
+			if y < transparent {
+				c.A = 0
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestEmbedExtract(t *testing.T) {
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, picture(256, 256, 0), &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string][]byte{
+		"png":             encodePNG(t, picture(256, 256, 0)),
+		"jpeg":            jpg.Bytes(),
+		"transparent top": encodePNG(t, picture(256, 256, 64)),
+	} {
+		marked, err := Embed(content, "reader@example.com", DefaultOptions())
+		if err != nil {
+			t.Fatalf("%s: Embed: %v", name, err)
+		}
+		res, err := Extract(marked)
+		if err != nil {
+			t.Fatalf("%s: Extract: %v", name, err)
+		}
+		if res.Mark != "reader@example.com" {
+			t.Errorf("%s: Extract = %+v", name, res)
+		}
+	}
+}
+
+func TestEmbedNoCapacity(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		content []byte
+		opts    Options
+	}{
+		{"transparent", encodePNG(t, picture(256, 256, 256)), DefaultOptions()},
+		{"mostly transparent", encodePNG(t, picture(256, 256, 248)), DefaultOptions()},
+		{"small", encodePNG(t, picture(16, 16, 0)), DefaultOptions()},
+		{"nothing requested", encodePNG(t, picture(256, 256, 0)), Options{}},
+	} {
+		if _, err := Embed(tt.content, "reader@example.com", tt.opts); !errors.Is(err, ErrNoCapacity) {
+			t.Errorf("%s: Embed returned %v, want %v", tt.name, err, ErrNoCapacity)
+		}
+	}
+
+	// an overlay alone needs no capacity
+	opts := DefaultOptions()
+	opts.Invisible = false
+	if _, err := Embed(encodePNG(t, picture(256, 256, 256)), "reader@example.com", opts); err != nil {
+		t.Errorf("Embed of an overlay on a transparent image: %v", err)
+	}
+}
+
+func TestEmbedErrors(t *testing.T) {
+	content := encodePNG(t, picture(64, 64, 0))
+	for _, tt := range []struct {
+		name    string
+		content []byte
+		mark    string
+		err     error
+	}{
+		{"empty mark", content, "", ErrInvalidMark},
+		{"long mark", content, string(make([]byte, 256)), ErrInvalidMark},
+		{"not an image", []byte("plain text"), "reader", ErrUnsupported},
+	} {
+		if _, err := Embed(tt.content, tt.mark, DefaultOptions()); !errors.Is(err, tt.err) {
+			t.Errorf("%s: Embed returned %v, want %v", tt.name, err, tt.err)
+		}
+	}
+	if _, err := Extract(encodePNG(t, picture(256, 256, 0))); !errors.Is(err, ErrNoMark) {
+		t.Errorf("Extract of an unmarked image returned %v, want %v", err, ErrNoMark)
+	}
+}
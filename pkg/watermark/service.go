@@ -2,14 +2,42 @@ package watermark
 
 import (
 	"context"
+	"io"
 	"publisher/internal"
 )
 
 type Service interface {
-	// Get the list of all documents
-	Get(ctx context.Context, filters ...internal.Filter) ([]internal.Document, error)
-	Status(ctx context.Context, ticketID string) (internal.Status, error)
+	// Get a page of the documents matching the query
+	Get(ctx context.Context, query internal.Query) (internal.Page, error)
+	// Search the documents by relevance to a full text query
+	Search(ctx context.Context, query internal.SearchQuery) ([]internal.SearchResult, error)
+	// Status returns the state of the watermark requested for the ticket
+	Status(ctx context.Context, ticketID string) (internal.WatermarkJob, error)
+	// Watermark queues the job setting the watermark of the document, it
+	// fails with util.ErrConflict while the watermark of the ticket is being
+	// applied or once it was applied
 	Watermark(ctx context.Context, ticketID string, mark string) (int, error)
+	// Detect recovers the watermark from a suspect content, which may be a
+	// part of a document or reformatted, and looks up the documents carrying
+	// it. A content without a watermark returns util.ErrUnknown.
+	Detect(ctx context.Context, r io.Reader) (internal.Detection, error)
 	AddDocument(ctx context.Context, doc *internal.Document) (string, error)
+	// UploadDocument stores the document and starts the upload of its
+	// content of the given size, 0 if unknown. r may be nil to send the
+	// content by ResumeUpload, final marks r as the whole content.
+	UploadDocument(ctx context.Context, doc *internal.Document, size int64, final bool, r io.Reader) (internal.Upload, error)
+	// ResumeUpload appends the content read from r at offset, which must be
+	// the offset received so far. size announces the size of an upload
+	// started with an unknown size, such an upload stays open until its size
+	// is announced or final marks r as its last chunk.
+	ResumeUpload(ctx context.Context, ticketID string, offset, size int64, final bool, r io.Reader) (internal.Upload, error)
+	// UploadState returns the state of the upload.
+	UploadState(ctx context.Context, ticketID string) (internal.Upload, error)
+	// DownloadDocument streams a byte range of the content, the caller must close it
+	DownloadDocument(ctx context.Context, ticketID string, offset, length int64) (internal.Content, error)
 	ServiceStatus(ctx context.Context) (int, error)
+	// HandleEvent reacts to a change of a document reported by the database
+	// node. Events are delivered at least once, handling one again is
+	// harmless.
+	HandleEvent(ctx context.Context, ev internal.Event) error
 }
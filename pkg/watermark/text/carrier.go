@@ -0,0 +1,225 @@
+package text
+
+import (
+	"publisher/pkg/watermark/frame"
+	"unicode"
+)
+
+// The zero-width characters carrying the bits of a frame.
+const (
+	zeroBit = '\u200b' // zero-width space
+	oneBit  = '\u200c' // zero-width non-joiner
+)
+
+const noBreakSpace = '\u00a0'
+
+// toCyrillic maps the Latin letters to their Cyrillic look-alikes.
+var toCyrillic = map[rune]rune{
+	'a': '\u0430', 'c': '\u0441', 'e': '\u0435', 'o': '\u043e', 'p': '\u0440', 'x': '\u0445', 'y': '\u0443',
+	'A': '\u0410', 'B': '\u0412', 'C': '\u0421', 'E': '\u0415', 'H': '\u041d', 'K': '\u041a', 'M': '\u041c',
+	'O': '\u041e', 'P': '\u0420', 'T': '\u0422', 'X': '\u0425',
+}
+
+var fromCyrillic = func() map[rune]rune {
+	m := make(map[rune]rune, len(toCyrillic))
+	for latin, cyrillic := range toCyrillic {
+		m[cyrillic] = latin
+	}
+	return m
+}()
+
+// embedZeroWidth inserts the frame after the first word and then after
+// every interval words, or at the end of a text of a single word.
+func embedZeroWidth(runes []rune, bits []byte, interval int) ([]rune, bool) {
+	if len(runes) == 0 {
+		return runes, false
+	}
+	coded := make([]rune, len(bits))
+	for i, b := range bits {
+		coded[i] = zeroBit
+		if b == 1 {
+			coded[i] = oneBit
+		}
+	}
+	out := make([]rune, 0, len(runes)+len(coded)*(1+len(runes)/(interval*4)))
+	words := 0
+	for i, r := range runes {
+		out = append(out, r)
+		if unicode.IsSpace(r) || i+1 == len(runes) || !unicode.IsSpace(runes[i+1]) {
+			continue
+		}
+		if words%interval == 0 {
+			out = append(out, coded...)
+		}
+		words++
+	}
+	if words == 0 {
+		out = append(out, coded...)
+	}
+	return out, true
+}
+
+// stripZeroWidth removes the zero-width characters of the valid frames.
+func stripZeroWidth(runes []rune) []rune {
+	var positions []int
+	for i, r := range runes {
+		if r == zeroBit || r == oneBit {
+			positions = append(positions, i)
+		}
+	}
+	spans := frame.Decode(zeroWidthBits(runes)).Spans
+	if len(spans) == 0 {
+		return runes
+	}
+	removed := map[int]bool{}
+	for _, span := range spans {
+		for k := span[0]; k < span[1]; k++ {
+			removed[positions[k]] = true
+		}
+	}
+	stripped := make([]rune, 0, len(runes)-len(removed))
+	for i, r := range runes {
+		if !removed[i] {
+			stripped = append(stripped, r)
+		}
+	}
+	return stripped
+}
+
+func zeroWidthBits(runes []rune) []byte {
+	var bits []byte
+	for _, r := range runes {
+		switch r {
+		case zeroBit:
+			bits = append(bits, 0)
+		case oneBit:
+			bits = append(bits, 1)
+		}
+	}
+	return bits
+}
+
+// spaceCarriers returns the positions of the single spaces and no-break
+// spaces between two words.
+func spaceCarriers(runes []rune) []int {
+	var carriers []int
+	for i := 1; i+1 < len(runes); i++ {
+		if (runes[i] == ' ' || runes[i] == noBreakSpace) && !unicode.IsSpace(runes[i-1]) && !unicode.IsSpace(runes[i+1]) {
+			carriers = append(carriers, i)
+		}
+	}
+	return carriers
+}
+
+func spaceBit(r rune) byte {
+	if r == noBreakSpace {
+		return 1
+	}
+	return 0
+}
+
+func setSpace(r rune, bit byte) rune {
+	if bit == 1 {
+		return noBreakSpace
+	}
+	return ' '
+}
+
+// letterCarriers returns the positions of the letters with a look-alike in
+// the words written in Latin letters. A word is Latin if it has an ASCII
+// letter without a look-alike, which a mark never replaces, and no other
+// letters than ASCII ones and look-alikes. Cyrillic words thus carry no bits,
+// also those made of look-alikes only.
+func letterCarriers(runes []rune) []int {
+	var carriers []int
+	for start := 0; start < len(runes); {
+		if !unicode.IsLetter(runes[start]) {
+			start++
+			continue
+		}
+		end, ascii, foreign := start, false, false
+		for ; end < len(runes) && unicode.IsLetter(runes[end]); end++ {
+			r := runes[end]
+			switch {
+			case r <= unicode.MaxASCII && toCyrillic[r] == 0:
+				ascii = true
+			case r > unicode.MaxASCII && fromCyrillic[r] == 0:
+				foreign = true
+			}
+		}
+		if ascii && !foreign {
+			for i := start; i < end; i++ {
+				if toCyrillic[runes[i]] != 0 || fromCyrillic[runes[i]] != 0 {
+					carriers = append(carriers, i)
+				}
+			}
+		}
+		start = end
+	}
+	return carriers
+}
+
+func letterBit(r rune) byte {
+	if fromCyrillic[r] != 0 {
+		return 1
+	}
+	return 0
+}
+
+func setLetter(r rune, bit byte) rune {
+	if latin, ok := fromCyrillic[r]; ok {
+		r = latin
+	}
+	if bit == 1 {
+		return toCyrillic[r]
+	}
+	return r
+}
+
+// embedCarriers repeats the whole frame over the carriers. A frame is only
+// written over carriers which carry no bit yet, so the no-break spaces and
+// look-alikes of the text are never part of a frame and are kept by Strip.
+// It changes nothing if no whole frame fits.
+func embedCarriers(runes []rune, carriers []int, bits []byte, bit func(rune) byte, set func(rune, byte) rune) bool {
+	embedded := false
+	for k := 0; k+len(bits) <= len(carriers); {
+		if j := lastSet(runes, carriers[k:k+len(bits)], bit); j >= 0 {
+			k += j + 1
+			continue
+		}
+		for j, b := range bits {
+			runes[carriers[k+j]] = set(runes[carriers[k+j]], b)
+		}
+		k += len(bits)
+		embedded = true
+	}
+	return embedded
+}
+
+// lastSet returns the index of the last of the carriers whose bit is 1, or
+// -1 if there is none.
+func lastSet(runes []rune, carriers []int, bit func(rune) byte) int {
+	for j := len(carriers) - 1; j >= 0; j-- {
+		if bit(runes[carriers[j]]) == 1 {
+			return j
+		}
+	}
+	return -1
+}
+
+// undoCarriers resets the carriers of the valid frames.
+func undoCarriers(runes []rune, carriers []int, bit func(rune) byte, unset func(rune) rune) {
+	for _, span := range frame.Decode(carrierBits(runes, carriers, bit)).Spans {
+		for _, i := range carriers[span[0]:span[1]] {
+			runes[i] = unset(runes[i])
+		}
+	}
+}
+
+func carrierBits(runes []rune, carriers []int, bit func(rune) byte) []byte {
+	bits := make([]byte, len(carriers))
+	for k, i := range carriers {
+		bits[k] = bit(runes[i])
+	}
+	return bits
+}
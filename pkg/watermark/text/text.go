@@ -0,0 +1,170 @@
+// Package text embeds watermarks invisibly into plain text. The mark is
+// framed, error corrected and repeated throughout the text, so it survives
+// when the text is shortened, partially reformatted or stripped of metadata.
+package text
+
+import (
+	"errors"
+	"publisher/pkg/watermark/frame"
+	"strings"
+	"unicode/utf8"
+)
+
+// Encoding is a way of hiding the bits of a mark in a text.
+type Encoding string
+
+const (
+	// ZeroWidth inserts runs of zero-width spaces and non-joiners after
+	// words, they are invisible but removed by some editors.
+	ZeroWidth Encoding = "zero-width"
+	// Whitespace replaces single spaces between words by no-break spaces,
+	// which look alike but keep the two words on one line.
+	Whitespace Encoding = "whitespace"
+	// Homoglyph replaces Latin letters by their Cyrillic look-alikes, which
+	// survive reformatting but no longer match a search for the word.
+	Homoglyph Encoding = "homoglyph"
+)
+
+const (
+	// MaxMarkLength is the length of the longest mark in bytes.
+	MaxMarkLength = frame.MaxMarkLength
+	// DefaultInterval is the number of words between two zero-width frames
+	// by default.
+	DefaultInterval = 200
+)
+
+var (
+	// ErrInvalidMark is returned for an empty or too long mark.
+	ErrInvalidMark = errors.New("the mark must have 1 to 255 bytes")
+	// ErrNoCapacity is returned if the text is too short to carry the mark.
+	ErrNoCapacity = errors.New("the text is too short to carry the mark")
+	// ErrNoMark is returned if no mark was found in the text.
+	ErrNoMark = errors.New("no watermark was found")
+)
+
+// ValidEncoding reports whether e is one of the encodings.
+func ValidEncoding(e Encoding) bool {
+	switch e {
+	case ZeroWidth, Whitespace, Homoglyph:
+		return true
+	}
+	return false
+}
+
+// Options configure how a mark is embedded.
+type Options struct {
+	// Encodings carry the mark, each one on its own. The encodings whose
+	// carriers in the text are too few for a single frame are skipped.
+	Encodings []Encoding
+	// Interval is the number of words between two zero-width frames
+	Interval int
+}
+
+// DefaultOptions embeds the mark with zero-width characters and whitespace,
+// which keep the text searchable.
+func DefaultOptions() Options {
+	return Options{Encodings: []Encoding{ZeroWidth, Whitespace}, Interval: DefaultInterval}
+}
+
+// Embed returns the text carrying the mark. The frames of a mark embedded
+// before in one of the encodings are removed first, so the text carries a
+// single mark. The rest of the text stays as it is, so Strip returns it.
+func Embed(text, mark string, opts Options) (string, error) {
+	if mark == "" || len(mark) > MaxMarkLength {
+		return text, ErrInvalidMark
+	}
+	if !utf8.ValidString(text) {
+		return text, ErrNoCapacity
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	bits := frame.Encode([]byte(mark))
+	runes := strip([]rune(text), opts.Encodings)
+	embedded := false
+	// the zero-width frames are inserted last, they would shift the
+	// carriers of the other encodings otherwise
+	for _, e := range []Encoding{Whitespace, Homoglyph, ZeroWidth} {
+		if !hasEncoding(opts.Encodings, e) {
+			continue
+		}
+		var ok bool
+		switch e {
+		case Whitespace:
+			ok = embedCarriers(runes, spaceCarriers(runes), bits, spaceBit, setSpace)
+		case Homoglyph:
+			ok = embedCarriers(runes, letterCarriers(runes), bits, letterBit, setLetter)
+		case ZeroWidth:
+			runes, ok = embedZeroWidth(runes, bits, opts.Interval)
+		}
+		embedded = embedded || ok
+	}
+	if !embedded {
+		return text, ErrNoCapacity
+	}
+	return string(runes), nil
+}
+
+// Result is a mark found in a text.
+type Result struct {
+	Mark string `json:"mark"`
+	// Confidence is the share of the frames found which carry the mark,
+	// from 0 to 1
+	Confidence float64 `json:"confidence"`
+	// Frames counts the copies of the mark found
+	Frames int `json:"frames"`
+	// Encodings lists the encodings the mark was found in
+	Encodings []Encoding `json:"encodings"`
+}
+
+// Extract returns the mark carried by the text, ErrNoMark if there is none.
+// The frames of all encodings vote, the mark of most frames wins.
+func Extract(text string) (Result, error) {
+	runes := []rune(strings.ToValidUTF8(text, ""))
+	scans := map[Encoding]frame.Scan{
+		ZeroWidth:  frame.Decode(zeroWidthBits(runes)),
+		Whitespace: frame.Decode(carrierBits(runes, spaceCarriers(runes), spaceBit)),
+		Homoglyph:  frame.Decode(carrierBits(runes, letterCarriers(runes), letterBit)),
+	}
+	mark, frames, confidence, ok := frame.Vote(scans[ZeroWidth], scans[Whitespace], scans[Homoglyph])
+	if !ok {
+		return Result{}, ErrNoMark
+	}
+	res := Result{Mark: mark, Confidence: confidence, Frames: frames, Encodings: []Encoding{}}
+	for _, e := range []Encoding{ZeroWidth, Whitespace, Homoglyph} {
+		if scans[e].Marks[mark] > 0 {
+			res.Encodings = append(res.Encodings, e)
+		}
+	}
+	return res, nil
+}
+
+// Strip removes the marks of all encodings from the text. Only the complete
+// frames found are undone, no-break spaces, Cyrillic letters and zero-width
+// characters belonging to no frame are kept.
+func Strip(text string) string {
+	return string(strip([]rune(text), []Encoding{ZeroWidth, Whitespace, Homoglyph}))
+}
+
+// strip undoes the frames of the encodings found in the runes.
+func strip(runes []rune, encodings []Encoding) []rune {
+	if hasEncoding(encodings, Whitespace) {
+		undoCarriers(runes, spaceCarriers(runes), spaceBit, func(rune) rune { return ' ' })
+	}
+	if hasEncoding(encodings, Homoglyph) {
+		undoCarriers(runes, letterCarriers(runes), letterBit, func(r rune) rune { return setLetter(r, 0) })
+	}
+	if hasEncoding(encodings, ZeroWidth) {
+		runes = stripZeroWidth(runes)
+	}
+	return runes
+}
+
+func hasEncoding(encodings []Encoding, e Encoding) bool {
+	for _, x := range encodings {
+		if x == e {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package text
+
+import (
+	"strings"
+	"testing"
+)
+
+var allEncodings = Options{Encodings: []Encoding{ZeroWidth, Whitespace, Homoglyph}, Interval: 20}
+
+// repeat joins n copies of s, so the text has room for several frames.
+func repeat(s string, n int) string {
+	return strings.TrimSpace(strings.Repeat(s+" ", n))
+}
+
+func TestStripEmbed(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		text string
+		opts Options
+	}{
+		{"latin", repeat("The quick brown fox jumps over the lazy dog.", 40), allEncodings},
+		{"cyrillic", repeat("Я и ты, а он с ней... о море у", 40), DefaultOptions()},
+		{"cyrillic all encodings", repeat("Я и ты, а он с ней... о море у", 40), allEncodings},
+		{"mixed", repeat("Он сказал: the quick brown fox, а она ответила: Hello.", 40), allEncodings},
+		{"no-break spaces", repeat("It is 10\u00a0km to Mr.\u00a0Smith, he\u00a0said twice.", 40), allEncodings},
+		{"look-alikes and zero-width", repeat("A T\u043ekyo night, می\u200cخواهم this \u200bword.", 40), allEncodings},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			marked, err := Embed(tt.text, "reader@example.com", tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if marked == tt.text {
+				t.Fatal("Embed changed nothing")
+			}
+			if got := Strip(marked); got != tt.text {
+				t.Errorf("Strip(Embed(x)) = %q, want %q", got, tt.text)
+			}
+			res, err := Extract(marked)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Mark != "reader@example.com" || res.Confidence != 1 {
+				t.Errorf("Extract = %+v", res)
+			}
+		})
+	}
+}
+
+func TestEmbedKeepsCyrillic(t *testing.T) {
+	text := repeat("Я и ты, а он с ней... о море у", 40)
+	marked, err := Embed(text, "reader", allEncodings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the words of the text are unchanged, only spaces and zero-width
+	// characters carry the mark
+	want := strings.Fields(text)
+	got := strings.FieldsFunc(marked, func(r rune) bool { return r == ' ' || r == noBreakSpace })
+	if len(got) != len(want) {
+		t.Fatalf("marked text has %d words, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if word := strings.NewReplacer("\u200b", "", "\u200c", "").Replace(got[i]); word != want[i] {
+			t.Fatalf("word %d is %q, want %q", i, word, want[i])
+		}
+	}
+}
+
+func TestEmbedReplacesMark(t *testing.T) {
+	text := repeat("The quick brown fox jumps over the lazy dog.", 40)
+	first, err := Embed(text, "first", allEncodings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Embed(first, "second", allEncodings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Strip(second); got != text {
+		t.Errorf("Strip of a text marked twice = %q, want %q", got, text)
+	}
+	res, err := Extract(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Mark != "second" || res.Confidence != 1 {
+		t.Errorf("Extract = %+v", res)
+	}
+}
+
+func TestEncodings(t *testing.T) {
+	text := repeat("The quick brown fox jumps over the lazy dog.", 40)
+	for _, e := range []Encoding{ZeroWidth, Whitespace, Homoglyph} {
+		t.Run(string(e), func(t *testing.T) {
+			marked, err := Embed(text, "reader", Options{Encodings: []Encoding{e}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := Extract(marked)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Mark != "reader" || len(res.Encodings) != 1 || res.Encodings[0] != e {
+				t.Errorf("Extract = %+v", res)
+			}
+			if got := Strip(marked); got != text {
+				t.Errorf("Strip(Embed(x)) = %q, want %q", got, text)
+			}
+		})
+	}
+}
+
+func TestExtractDamaged(t *testing.T) {
+	text := repeat("The quick brown fox jumps over the lazy dog.", 40)
+	marked, err := Embed(text, "reader", Options{Encodings: []Encoding{Whitespace}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a no-break space lost by reformatting is corrected
+	i := strings.IndexRune(marked, noBreakSpace)
+	damaged := marked[:i] + " " + marked[i+len(string(noBreakSpace)):]
+	if res, err := Extract(damaged); err != nil || res.Mark != "reader" {
+		t.Errorf("Extract of a damaged text = %+v, %v", res, err)
+	}
+	// a truncated text keeps the whole frames
+	if res, err := Extract(damaged[:len(damaged)/2]); err != nil || res.Mark != "reader" {
+		t.Errorf("Extract of a truncated text = %+v, %v", res, err)
+	}
+}
+
+func TestEmbedErrors(t *testing.T) {
+	text := repeat("The quick brown fox jumps over the lazy dog.", 40)
+	for _, tt := range []struct {
+		name string
+		text string
+		mark string
+		opts Options
+		err  error
+	}{
+		{"empty mark", text, "", DefaultOptions(), ErrInvalidMark},
+		{"long mark", text, strings.Repeat("x", MaxMarkLength+1), DefaultOptions(), ErrInvalidMark},
+		{"invalid utf-8", text + "\xff", "reader", DefaultOptions(), ErrNoCapacity},
+		{"empty text", "", "reader", DefaultOptions(), ErrNoCapacity},
+		{"few spaces", "The quick brown fox.", "reader", Options{Encodings: []Encoding{Whitespace}}, ErrNoCapacity},
+		{"cyrillic homoglyph", repeat("Я и ты, а он с ней... о море у", 40), "reader", Options{Encodings: []Encoding{Homoglyph}}, ErrNoCapacity},
+		{"no encoding", text, "reader", Options{}, ErrNoCapacity},
+	} {
+		got, err := Embed(tt.text, tt.mark, tt.opts)
+		if err != tt.err {
+			t.Errorf("%s: Embed returned %v, want %v", tt.name, err, tt.err)
+		}
+		if got != tt.text {
+			t.Errorf("%s: Embed changed the text", tt.name)
+		}
+	}
+
+	// the zero-width frame fits after a single word
+	marked, err := Embed("Word", "reader", DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := Extract(marked); err != nil || res.Mark != "reader" {
+		t.Errorf("Extract of a single word = %+v, %v", res, err)
+	}
+}
+
+func TestExtractNoMark(t *testing.T) {
+	for _, text := range []string{"", "The quick brown fox.", repeat("It is 10 km to Mr. Smith.", 40)} {
+		if res, err := Extract(text); err != ErrNoMark {
+			t.Errorf("Extract(%q) = %+v, %v, want %v", text, res, err, ErrNoMark)
+		}
+		if got := Strip(text); got != text {
+			t.Errorf("Strip(%q) = %q", text, got)
+		}
+	}
+}
+
+func TestValidEncoding(t *testing.T) {
+	for e, want := range map[Encoding]bool{ZeroWidth: true, Whitespace: true, Homoglyph: true, "": false, "unicode": false} {
+		if got := ValidEncoding(e); got != want {
+			t.Errorf("ValidEncoding(%q) = %v, want %v", e, got, want)
+		}
+	}
+}
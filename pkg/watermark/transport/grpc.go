@@ -1,37 +1,64 @@
 package transport
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"net/http"
 	"publisher/internal"
+	"publisher/internal/util"
 	"publisher/pkg/watermark/endpoints"
+	"strings"
 
 	"publisher/api/v1/pb/watermark"
 
+	"github.com/go-kit/kit/endpoint"
 	grpctransport "github.com/go-kit/kit/transport/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type grpcServer struct {
 	get           grpctransport.Handler
+	search        grpctransport.Handler
 	status        grpctransport.Handler
 	addDocument   grpctransport.Handler
 	watermark     grpctransport.Handler
 	serviceStatus grpctransport.Handler
+	detect        grpctransport.Handler
+	// grpctransport does not support streams, the streaming RPCs call the
+	// endpoints directly.
+	uploadDocument   endpoint.Endpoint
+	resumeUpload     endpoint.Endpoint
+	uploadState      endpoint.Endpoint
+	downloadDocument endpoint.Endpoint
 	// forward compatible implementations.
 	watermark.UnimplementedWatermarkServer
 }
 
 func NewGRPCServer(ep endpoints.Set) watermark.WatermarkServer {
 	return &grpcServer{
-		get:           grpctransport.NewServer(ep.GetEndpoint, decodeGRPCGetRequest, decodeGRPCGetResponse),
-		status:        grpctransport.NewServer(ep.StatusEndpoint, decodeGRPCStatusRequest, decodeGRPCStatusResponse),
+		get:           grpctransport.NewServer(ep.GetEndpoint, decodeGRPCGetRequest, encodeGRPCGetResponse),
+		search:        grpctransport.NewServer(ep.SearchEndpoint, decodeGRPCSearchRequest, encodeGRPCSearchResponse),
+		status:        grpctransport.NewServer(ep.StatusEndpoint, decodeGRPCStatusRequest, encodeGRPCStatusResponse),
 		addDocument:   grpctransport.NewServer(ep.AddDocumentEndpoint, decodeGRPCAddDocumentRequest, decodeGRPCAddDocumentResponse),
-		watermark:     grpctransport.NewServer(ep.WatermarkEndpoint, decodeGRPCWatermarkRequest, decodeGRPCWatermarkResponse),
+		watermark:     grpctransport.NewServer(ep.WatermarkEndpoint, decodeGRPCWatermarkRequest, encodeGRPCWatermarkResponse),
 		serviceStatus: grpctransport.NewServer(ep.ServiceStatusEndpoint, decodeGRPCServiceStatusRequest, decodeGRPCServiceStatusResponse),
+		detect:        grpctransport.NewServer(ep.DetectEndpoint, decodeGRPCDetectRequest, encodeGRPCDetectResponse),
+
+		uploadDocument:   ep.UploadDocumentEndpoint,
+		resumeUpload:     ep.ResumeUploadEndpoint,
+		uploadState:      ep.UploadStateEndpoint,
+		downloadDocument: ep.DownloadDocumentEndpoint,
 	}
 }
 
 func (g *grpcServer) Get(ctx context.Context, r *watermark.GetRequest) (*watermark.GetReply, error) {
 	_, req, err := g.get.ServeGRPC(ctx, r)
+	if err == util.ErrInvalidArgument {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -40,11 +67,12 @@ func (g *grpcServer) Get(ctx context.Context, r *watermark.GetRequest) (*waterma
 }
 
 func (g *grpcServer) Status(ctx context.Context, r *watermark.StatusRequest) (*watermark.StatusReply, error) {
-	_, req, err := g.status.ServeGRPC(ctx, r)
+	_, rep, err := g.status.ServeGRPC(ctx, r)
 	if err != nil {
 		return nil, err
 	}
-	return req.(*watermark.StatusReply), nil
+	reply := rep.(*watermark.StatusReply)
+	return reply, codeError(reply.Code, reply.Err)
 }
 
 func (g *grpcServer) AddDocument(ctx context.Context, r *watermark.AddDocumentRequest) (*watermark.AddDocumentReply, error) {
@@ -56,11 +84,12 @@ func (g *grpcServer) AddDocument(ctx context.Context, r *watermark.AddDocumentRe
 }
 
 func (g *grpcServer) Watermark(ctx context.Context, r *watermark.WatermarkRequest) (*watermark.WatermarkReply, error) {
-	_, req, err := g.watermark.ServeGRPC(ctx, r)
+	_, rep, err := g.watermark.ServeGRPC(ctx, r)
 	if err != nil {
 		return nil, err
 	}
-	return req.(*watermark.WatermarkReply), nil
+	reply := rep.(*watermark.WatermarkReply)
+	return reply, codeError(reply.Code, reply.Err)
 }
 
 func (g *grpcServer) ServiceStatus(ctx context.Context, r *watermark.ServiceStatusRequest) (*watermark.ServiceStatusReply, error) {
@@ -71,13 +100,105 @@ func (g *grpcServer) ServiceStatus(ctx context.Context, r *watermark.ServiceStat
 	return req.(*watermark.ServiceStatusReply), nil
 }
 
+func (g *grpcServer) Detect(ctx context.Context, r *watermark.DetectRequest) (*watermark.DetectReply, error) {
+	_, rep, err := g.detect.ServeGRPC(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	reply := rep.(*watermark.DetectReply)
+	return reply, codeError(reply.Code, reply.Err)
+}
+
 func decodeGRPCGetRequest(_ context.Context, grpcRequest interface{}) (interface{}, error) {
 	req := grpcRequest.(*watermark.GetRequest)
+	filters := decodeGRPCFilters(req.Filters)
+	if err := internal.ValidateFilters(filters); err != nil {
+		return nil, err
+	}
+	return endpoints.GetRequest{Filters: filters, PageSize: int(req.PageSize), Cursor: req.Cursor}, nil
+}
+
+func decodeGRPCFilters(fs []*watermark.GetRequest_Filters) []internal.Filter {
 	var filters []internal.Filter
-	for _, f := range req.Filters {
-		filters = append(filters, internal.Filter{Key: f.Key, Value: f.Value})
+	for _, f := range fs {
+		filter := internal.Filter{
+			Key:    f.Key,
+			Value:  f.Value,
+			Op:     internal.Operator(f.Op),
+			Values: f.Values,
+			Sort:   internal.SortOrder(f.Sort),
+			Any:    decodeGRPCFilters(f.Any),
+			All:    decodeGRPCFilters(f.All),
+		}
+		if f.From != nil {
+			from := f.From.AsTime()
+			filter.From = &from
+		}
+		if f.To != nil {
+			to := f.To.AsTime()
+			filter.To = &to
+		}
+		filters = append(filters, filter)
+	}
+	return filters
+}
+
+func encodeGRPCGetResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.GetResponse)
+	var docs []*watermark.Document
+	for _, d := range resp.Documents {
+		docs = append(docs, encodeGRPCDocument(d))
+	}
+	return &watermark.GetReply{Documents: docs, Err: resp.Err, NextCursor: resp.NextCursor, Total: resp.Total}, nil
+}
+
+func encodeGRPCDocument(d internal.Document) *watermark.Document {
+	return &watermark.Document{
+		TicketID:  d.TicketID,
+		Content:   d.Content,
+		Title:     d.Title,
+		Author:    d.Author,
+		Topic:     d.Topic,
+		Watermark: d.Watermark,
+		Version:   d.Version,
+
+		ContentDigest: d.ContentDigest,
+		ContentSize:   d.ContentSize,
+		SimilarTo:     d.SimilarTo,
+		Similarity:    d.Similarity,
+	}
+}
+
+func (g *grpcServer) Search(ctx context.Context, r *watermark.SearchRequest) (*watermark.SearchReply, error) {
+	_, rep, err := g.search.ServeGRPC(ctx, r)
+	if err == util.ErrInvalidArgument {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rep.(*watermark.SearchReply), nil
+}
+
+func decodeGRPCSearchRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*watermark.SearchRequest)
+	if strings.TrimSpace(req.Text) == "" {
+		return nil, util.ErrInvalidArgument
 	}
-	return endpoints.GetRequest{Filters: filters}, nil
+	return endpoints.SearchRequest{Text: req.Text, Limit: int(req.Limit)}, nil
+}
+
+func encodeGRPCSearchResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.SearchResponse)
+	var results []*watermark.SearchResult
+	for _, r := range resp.Results {
+		results = append(results, &watermark.SearchResult{
+			Document:   encodeGRPCDocument(r.Document),
+			Rank:       r.Rank,
+			Highlights: r.Highlights,
+		})
+	}
+	return &watermark.SearchReply{Results: results, Err: resp.Err}, nil
 }
 
 func decodeGRPCGetResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
@@ -85,6 +206,8 @@ func decodeGRPCGetResponse(_ context.Context, grpcReply interface{}) (interface{
 	var docs []internal.Document
 	for _, d := range reply.Documents {
 		doc := internal.Document{
+			TicketID:  d.TicketID,
+			Version:   d.Version,
 			Title:     d.Title,
 			Content:   d.Content,
 			Author:    d.Author,
@@ -93,7 +216,7 @@ func decodeGRPCGetResponse(_ context.Context, grpcReply interface{}) (interface{
 		}
 		docs = append(docs, doc)
 	}
-	return endpoints.GetResponse{Documents: docs, Err: reply.Err}, nil
+	return endpoints.GetResponse{Documents: docs, NextCursor: reply.NextCursor, Total: reply.Total, Err: reply.Err}, nil
 }
 
 func decodeGRPCStatusRequest(_ context.Context, grpcRequest interface{}) (interface{}, error) {
@@ -101,9 +224,36 @@ func decodeGRPCStatusRequest(_ context.Context, grpcRequest interface{}) (interf
 	return endpoints.StatusRequest{TicketID: req.TicketID}, nil
 }
 
-func decodeGRPCStatusResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
-	reply := grpcReply.(*watermark.StatusReply)
-	return endpoints.StatusResponse{Status: internal.Status(reply.Status), Err: reply.Err}, nil
+// statusEnum maps the job statuses to the enum of StatusReply.
+var statusEnum = map[internal.Status]watermark.StatusReply_Status{
+	internal.Pending:    watermark.StatusReply_PENDING,
+	internal.Started:    watermark.StatusReply_STARTED,
+	internal.InProgress: watermark.StatusReply_IN_PROGRESS,
+	internal.Finished:   watermark.StatusReply_FINISHED,
+	internal.Failed:     watermark.StatusReply_FAILED,
+}
+
+func encodeGRPCStatusResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.StatusResponse)
+	reply := &watermark.StatusReply{
+		Status:   statusEnum[resp.Status],
+		TicketID: resp.TicketID,
+		Mark:     resp.Mark,
+		Reason:   resp.Reason,
+		Code:     int64(resp.Code),
+		Err:      resp.Err,
+	}
+	if !resp.CreatedAt.IsZero() {
+		reply.CreatedAt = timestamppb.New(resp.CreatedAt)
+		reply.UpdatedAt = timestamppb.New(resp.UpdatedAt)
+	}
+	if resp.StartedAt != nil {
+		reply.StartedAt = timestamppb.New(*resp.StartedAt)
+	}
+	if resp.FinishedAt != nil {
+		reply.FinishedAt = timestamppb.New(*resp.FinishedAt)
+	}
+	return reply, nil
 }
 
 func decodeGRPCAddDocumentRequest(_ context.Context, grpcRequest interface{}) (interface{}, error) {
@@ -115,7 +265,7 @@ func decodeGRPCAddDocumentRequest(_ context.Context, grpcRequest interface{}) (i
 		Topic:     req.Document.Topic,
 		Watermark: req.Document.Watermark,
 	}
-	return endpoints.AddDocumentRequest{Document: doc}, nil
+	return endpoints.AddDocumentRequest{Document: doc, IdempotencyKey: req.IdempotencyKey}, nil
 }
 
 func decodeGRPCAddDocumentResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
@@ -128,9 +278,28 @@ func decodeGRPCWatermarkRequest(_ context.Context, grpcRequest interface{}) (int
 	return endpoints.WatermarkRequest{TicketID: req.TicketID, Mark: req.Mark}, nil
 }
 
-func decodeGRPCWatermarkResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
-	req := grpcReply.(*watermark.WatermarkReply)
-	return endpoints.WatermarkResponse{Code: int(req.Code), Err: req.Err}, nil
+func encodeGRPCWatermarkResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.WatermarkResponse)
+	return &watermark.WatermarkReply{Code: int64(resp.Code), Err: resp.Err}, nil
+}
+
+func decodeGRPCDetectRequest(_ context.Context, grpcRequest interface{}) (interface{}, error) {
+	req := grpcRequest.(*watermark.DetectRequest)
+	return endpoints.DetectRequest{Body: bytes.NewReader(req.Content)}, nil
+}
+
+func encodeGRPCDetectResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoints.DetectResponse)
+	return &watermark.DetectReply{
+		Mark:       resp.Mark,
+		Confidence: resp.Confidence,
+		Frames:     int64(resp.Frames),
+		Encodings:  resp.Encodings,
+		TicketID:   resp.TicketID,
+		Candidates: resp.Candidates,
+		Code:       int64(resp.Code),
+		Err:        resp.Err,
+	}, nil
 }
 
 func decodeGRPCServiceStatusRequest(_ context.Context, grpcRequest interface{}) (interface{}, error) {
@@ -141,3 +310,149 @@ func decodeGRPCServiceStatusResponse(_ context.Context, grpcReply interface{}) (
 	req := grpcReply.(*watermark.ServiceStatusReply)
 	return endpoints.ServiceStatusResponse{Code: int(req.Code), Err: req.Err}, nil
 }
+
+// UploadDocument streams the chunks into an upload, the first message starts
+// the upload with its document or resumes the upload of its ticketID. An
+// offset of -1 only returns the state of the upload. A message with final
+// set ends the content, the upload of an unknown size is then completed.
+func (g *grpcServer) UploadDocument(stream watermark.Watermark_UploadDocumentServer) error {
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, util.ErrInvalidArgument.Error())
+	}
+	if err != nil {
+		return err
+	}
+	body := &grpcChunkReader{stream: stream, chunk: first.Chunk, final: first.Final}
+
+	var resp interface{}
+	switch {
+	case first.Document != nil:
+		doc := &internal.Document{
+			Title:     first.Document.Title,
+			Author:    first.Document.Author,
+			Topic:     first.Document.Topic,
+			Watermark: first.Document.Watermark,
+		}
+		resp, err = g.uploadDocument(stream.Context(), endpoints.UploadDocumentRequest{Document: doc, Size: first.Size, Body: body})
+	case first.Offset == -1:
+		resp, err = g.uploadState(stream.Context(), endpoints.UploadStateRequest{TicketID: first.TicketID})
+	default:
+		req := endpoints.ResumeUploadRequest{TicketID: first.TicketID, Offset: first.Offset, Size: first.Size, Body: body}
+		resp, err = g.resumeUpload(stream.Context(), req)
+	}
+	if err != nil {
+		return err
+	}
+	uploadResp := resp.(endpoints.UploadResponse)
+	if err := codeError(int64(uploadResp.Code), uploadResp.Err); err != nil {
+		return err
+	}
+	upload := uploadResp.Upload
+	if body.final && !upload.Complete {
+		// the final flag is only known once the stream was read
+		req := endpoints.ResumeUploadRequest{TicketID: upload.TicketID, Offset: upload.Offset, Final: true, Body: strings.NewReader("")}
+		if resp, err = g.resumeUpload(stream.Context(), req); err != nil {
+			return err
+		}
+		uploadResp = resp.(endpoints.UploadResponse)
+		if err := codeError(int64(uploadResp.Code), uploadResp.Err); err != nil {
+			return err
+		}
+		upload = uploadResp.Upload
+	}
+	reply := &watermark.UploadDocumentReply{
+		TicketID: upload.TicketID,
+		Size:     upload.Size,
+		Offset:   upload.Offset,
+		Complete: upload.Complete,
+	}
+	if upload.Document != nil {
+		reply.Document = encodeGRPCDocument(*upload.Document)
+	}
+	return stream.SendAndClose(reply)
+}
+
+// grpcChunkReader reads the chunks of an UploadDocument stream, chunk holds
+// the unread rest of the last received chunk and final is set once a message
+// marked final was received.
+type grpcChunkReader struct {
+	stream watermark.Watermark_UploadDocumentServer
+	chunk  []byte
+	final  bool
+}
+
+func (r *grpcChunkReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.chunk, r.final = req.Chunk, r.final || req.Final
+	}
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+// downloadChunkSize is the size of the chunks sent by DownloadDocument.
+const downloadChunkSize = 32 << 10
+
+// DownloadDocument streams a byte range of the content in chunks, the first
+// chunk carries digest and size of the whole content.
+func (g *grpcServer) DownloadDocument(r *watermark.DownloadDocumentRequest, stream watermark.Watermark_DownloadDocumentServer) error {
+	req := endpoints.DownloadDocumentRequest{TicketID: r.TicketID, Offset: r.Offset, Length: r.Length}
+	resp, err := g.downloadDocument(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	downloadResp := resp.(endpoints.DownloadDocumentResponse)
+	if err := codeError(int64(downloadResp.Code), downloadResp.Err); err != nil {
+		return err
+	}
+	content := downloadResp.Content
+	defer content.Body.Close()
+	first := &watermark.DocumentChunk{Offset: content.Offset, Digest: content.Digest, Size: content.Size}
+	buf := make([]byte, downloadChunkSize)
+	for offset := content.Offset; ; {
+		n, err := io.ReadFull(content.Body, buf)
+		if n > 0 || first != nil {
+			chunk := &watermark.DocumentChunk{Offset: offset}
+			if first != nil {
+				chunk, first = first, nil
+			}
+			chunk.Chunk = buf[:n]
+			if err := stream.Send(chunk); err != nil {
+				return err
+			}
+			offset += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+}
+
+// codeError maps the HTTP status code returned by the service to a gRPC
+// status error, successful codes map to nil.
+func codeError(code int64, msg string) error {
+	switch code {
+	case http.StatusBadRequest:
+		return status.Error(codes.InvalidArgument, msg)
+	case http.StatusNotFound:
+		return status.Error(codes.NotFound, msg)
+	case http.StatusConflict:
+		return status.Error(codes.Aborted, msg)
+	case http.StatusRequestedRangeNotSatisfiable:
+		return status.Error(codes.OutOfRange, msg)
+	case http.StatusServiceUnavailable:
+		return status.Error(codes.Unavailable, msg)
+	}
+	if code >= http.StatusBadRequest {
+		return status.Error(codes.Internal, msg)
+	}
+	return nil
+}
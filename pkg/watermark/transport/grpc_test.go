@@ -0,0 +1,152 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"publisher/api/v1/pb/watermark"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestClient serves the endpoints on an in-memory connection.
+func newTestClient(t *testing.T) watermark.WatermarkClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	watermark.RegisterWatermarkServer(server, NewGRPCServer(newTestEndpoints(t)))
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return watermark.NewWatermarkClient(conn)
+}
+
+// upload sends the requests on an UploadDocument stream.
+func upload(ctx context.Context, client watermark.WatermarkClient, reqs ...*watermark.UploadDocumentRequest) (*watermark.UploadDocumentReply, error) {
+	stream, err := client.UploadDocument(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, req := range reqs {
+		if err := stream.Send(req); err != nil {
+			return nil, err
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+func TestGRPCUploadDownload(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+	content := bytes.Repeat([]byte("0123456789"), downloadChunkSize/10+1)
+	size := int64(len(content))
+
+	reply, err := upload(ctx, client,
+		&watermark.UploadDocumentRequest{Document: &watermark.Document{Title: "Title"}, Size: size, Chunk: content[:3]},
+		&watermark.UploadDocumentRequest{Chunk: content[3:100]})
+	if err != nil || reply.TicketID == "" || reply.Offset != 100 || reply.Complete {
+		t.Fatalf("UploadDocument returned %+v, %v, want 100 bytes", reply, err)
+	}
+	ticketID := reply.TicketID
+
+	if _, err := upload(ctx, client, &watermark.UploadDocumentRequest{TicketID: ticketID, Offset: 50, Chunk: content[50:]}); status.Code(err) != codes.Aborted {
+		t.Errorf("UploadDocument at the wrong offset returned %v, want %v", err, codes.Aborted)
+	}
+	reply, err = upload(ctx, client,
+		&watermark.UploadDocumentRequest{TicketID: ticketID, Offset: 100},
+		&watermark.UploadDocumentRequest{Chunk: content[100:]})
+	if err != nil || !reply.Complete || reply.Offset != size || reply.Document.GetContentSize() != size {
+		t.Fatalf("UploadDocument of the rest returned %+v, %v, want it complete", reply, err)
+	}
+	reply, err = upload(ctx, client, &watermark.UploadDocumentRequest{TicketID: ticketID, Offset: -1})
+	if err != nil || !reply.Complete || reply.Offset != size {
+		t.Errorf("UploadDocument at offset -1 returned %+v, %v, want the state of the complete upload", reply, err)
+	}
+	if _, err := upload(ctx, client); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("UploadDocument without messages returned %v, want %v", err, codes.InvalidArgument)
+	}
+
+	for _, tt := range []struct {
+		offset, length int64
+		chunks         int
+	}{
+		{0, 0, 2},
+		{5, 10, 1},
+		{size - 3, 0, 1},
+	} {
+		stream, err := client.DownloadDocument(ctx, &watermark.DownloadDocumentRequest{TicketID: ticketID, Offset: tt.offset, Length: tt.length})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []byte
+		chunks := 0
+		for {
+			chunk, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("DownloadDocument(%d, %d) returned %v", tt.offset, tt.length, err)
+			}
+			if chunk.Offset != tt.offset+int64(len(got)) {
+				t.Errorf("DownloadDocument(%d, %d) sent a chunk at %d after %d bytes", tt.offset, tt.length, chunk.Offset, len(got))
+			}
+			if chunks == 0 && (chunk.Size != size || chunk.Digest != reply.Document.GetContentDigest()) {
+				t.Errorf("DownloadDocument(%d, %d) started with %d bytes of %s", tt.offset, tt.length, chunk.Size, chunk.Digest)
+			}
+			got = append(got, chunk.Chunk...)
+			chunks++
+		}
+		want := content[tt.offset:]
+		if tt.length != 0 {
+			want = want[:tt.length]
+		}
+		if !bytes.Equal(got, want) || chunks != tt.chunks {
+			t.Errorf("DownloadDocument(%d, %d) returned %d bytes in %d chunks, want %d in %d", tt.offset, tt.length, len(got), chunks, len(want), tt.chunks)
+		}
+	}
+
+	stream, err := client.DownloadDocument(ctx, &watermark.DownloadDocumentRequest{TicketID: ticketID, Offset: size})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.OutOfRange {
+		t.Errorf("DownloadDocument past the end returned %v, want %v", err, codes.OutOfRange)
+	}
+}
+
+func TestGRPCUploadUnknownSize(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	reply, err := upload(ctx, client,
+		&watermark.UploadDocumentRequest{Document: &watermark.Document{Title: "Title"}, Chunk: []byte("0123")},
+		&watermark.UploadDocumentRequest{Chunk: []byte("45")})
+	if err != nil || reply.Offset != 6 || reply.Complete {
+		t.Fatalf("UploadDocument without final returned %+v, %v, want it open at 6", reply, err)
+	}
+	reply, err = upload(ctx, client,
+		&watermark.UploadDocumentRequest{TicketID: reply.TicketID, Offset: 6, Chunk: []byte("6")},
+		&watermark.UploadDocumentRequest{Final: true})
+	if err != nil || !reply.Complete || reply.Document.GetContentSize() != 7 {
+		t.Errorf("UploadDocument with final returned %+v, %v, want it complete", reply, err)
+	}
+
+	// the final flag may come with the document
+	reply, err = upload(ctx, client, &watermark.UploadDocumentRequest{Document: &watermark.Document{Title: "Title"}, Chunk: []byte("whole"), Final: true})
+	if err != nil || !reply.Complete || reply.Offset != 5 {
+		t.Errorf("UploadDocument of a single final message returned %+v, %v, want it complete", reply, err)
+	}
+}
@@ -3,10 +3,17 @@ package transport
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"os"
+	"publisher/internal"
 	"publisher/internal/util"
 	"publisher/pkg/watermark/endpoints"
+	"strconv"
+	"strings"
 
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/go-kit/log"
@@ -14,42 +21,127 @@ import (
 
 var logger log.Logger
 
-func NewHttpHandler(ep endpoints.Set) http.Handler {
+// maxEventSize limits the body of an event posted by the database node
+const maxEventSize = 1 << 20
+
+// NewHttpHandler returns the routes of the watermark service, the events
+// posted to /events must be signed by eventSecret.
+func NewHttpHandler(ep endpoints.Set, eventSecret []byte) http.Handler {
 	m := http.NewServeMux()
+	options := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(encodeError),
+	}
 
 	m.Handle("/get", httptransport.NewServer(
 		ep.GetEndpoint,
 		decodeHTTPGetRequest,
 		encodeResponse,
+		options...,
+	))
+
+	m.Handle("/search", httptransport.NewServer(
+		ep.SearchEndpoint,
+		decodeHTTPSearchRequest,
+		encodeResponse,
+		options...,
 	))
 
 	m.Handle("/healthz", httptransport.NewServer(
 		ep.ServiceStatusEndpoint,
 		decodeHTTPServiceStatusRequest,
 		encodeResponse,
+		options...,
 	))
 
 	m.Handle("/status", httptransport.NewServer(
 		ep.StatusEndpoint,
 		decodeHTTPStatusRequest,
 		encodeResponse,
+		options...,
 	))
 
 	m.Handle("/addDocument", httptransport.NewServer(
 		ep.AddDocumentEndpoint,
 		decodeHTTPAddDocumentRequest,
 		encodeResponse,
+		options...,
 	))
 
 	m.Handle("/watermark", httptransport.NewServer(
 		ep.WatermarkEndpoint,
 		decodeHTTPWatermarkRequest,
 		encodeResponse,
+		options...,
 	))
 
+	uploadState := httptransport.NewServer(
+		ep.UploadStateEndpoint,
+		decodeHTTPUploadStateRequest,
+		encodeResponse,
+		options...,
+	)
+	m.Handle("/upload", methodHandler{
+		http.MethodPost: httptransport.NewServer(
+			ep.UploadDocumentEndpoint,
+			decodeHTTPUploadDocumentRequest,
+			encodeResponse,
+			options...,
+		),
+		http.MethodPatch: httptransport.NewServer(
+			ep.ResumeUploadEndpoint,
+			decodeHTTPResumeUploadRequest,
+			encodeResponse,
+			options...,
+		),
+		http.MethodHead: uploadState,
+		http.MethodGet:  uploadState,
+	})
+
+	download := httptransport.NewServer(
+		ep.DownloadDocumentEndpoint,
+		decodeHTTPDownloadDocumentRequest,
+		encodeHTTPDownloadDocumentResponse,
+		options...,
+	)
+	m.Handle("/download", methodHandler{
+		http.MethodGet:  download,
+		http.MethodHead: download,
+	})
+
+	// the database node posts the changes of the documents
+	m.Handle("/events", methodHandler{
+		http.MethodPost: httptransport.NewServer(
+			ep.HandleEventEndpoint,
+			decodeHTTPHandleEventRequest(eventSecret),
+			encodeResponse,
+			options...,
+		),
+	})
+
+	m.Handle("/detect", methodHandler{
+		http.MethodPost: httptransport.NewServer(
+			ep.DetectEndpoint,
+			decodeHTTPDetectRequest,
+			encodeResponse,
+			options...,
+		),
+	})
+
 	return m
 }
 
+// methodHandler serves the handler registered for the method of the request.
+type methodHandler map[string]http.Handler
+
+func (m methodHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, ok := m[r.Method]
+	if !ok {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
 func decodeHTTPGetRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var req endpoints.GetRequest
 	if r.ContentLength == 0 {
@@ -59,6 +151,30 @@ func decodeHTTPGetRequest(_ context.Context, r *http.Request) (interface{}, erro
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return nil, err
 	}
+	if err := internal.ValidateFilters(req.Filters); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// decodeHTTPSearchRequest accepts a JSON body or the q and limit query parameters.
+func decodeHTTPSearchRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.SearchRequest
+	if r.ContentLength == 0 {
+		req.Text = r.URL.Query().Get("q")
+		if limit := r.URL.Query().Get("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil {
+				return nil, util.ErrInvalidArgument
+			}
+			req.Limit = n
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		return nil, util.ErrInvalidArgument
+	}
 	return req, nil
 }
 
@@ -78,14 +194,192 @@ func decodeHTTPWatermarkRequest(_ context.Context, r *http.Request) (interface{}
 	return req, nil
 }
 
+// IdempotencyKeyHeader carries the idempotency key of an add request, it
+// takes precedence over the idempotencyKey field of the body.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
 func decodeHTTPAddDocumentRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var req endpoints.AddDocumentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return nil, err
 	}
+	if key := r.Header.Get(IdempotencyKeyHeader); key != "" {
+		req.IdempotencyKey = key
+	}
 	return req, nil
 }
 
+// decodeHTTPUploadDocumentRequest starts an upload. A JSON body only carries
+// the document and the size of the content, which is then sent by PATCH. A
+// multipart/form-data body carries the document as JSON in the "document"
+// part followed by the content in the "content" part, which is streamed as
+// the whole content. Upload-Length announces the size of the content.
+func decodeHTTPUploadDocumentRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoints.UploadDocumentRequest
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, util.ErrInvalidArgument
+		}
+	} else {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			return nil, util.ErrInvalidArgument
+		}
+		for req.Body == nil {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, util.ErrInvalidArgument
+			}
+			switch part.FormName() {
+			case "document":
+				if err := json.NewDecoder(part).Decode(&req.Document); err != nil {
+					return nil, util.ErrInvalidArgument
+				}
+			case "content":
+				// the following parts are not read
+				req.Body, req.Final = part, true
+			}
+		}
+		if req.Body == nil {
+			req.Body, req.Final = strings.NewReader(""), true
+		}
+	}
+	if length := r.Header.Get("Upload-Length"); length != "" {
+		size, err := strconv.ParseInt(length, 10, 64)
+		if err != nil || size < 0 {
+			return nil, util.ErrInvalidArgument
+		}
+		req.Size = size
+	}
+	if req.Document == nil {
+		return nil, util.ErrInvalidArgument
+	}
+	return req, nil
+}
+
+// decodeHTTPResumeUploadRequest appends the body of a PATCH request at the
+// Upload-Offset of the upload named by the ticketID query parameter, the
+// body may be sent chunked. Upload-Length announces the size of an upload
+// started with an unknown size, "Upload-Complete: ?1" marks the body as its
+// last chunk.
+func decodeHTTPResumeUploadRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := endpoints.ResumeUploadRequest{TicketID: r.URL.Query().Get("ticketID")}
+	if req.TicketID == "" {
+		return nil, util.ErrInvalidArgument
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return nil, util.ErrInvalidArgument
+	}
+	req.Offset, req.Body = offset, r.Body
+	if length := r.Header.Get("Upload-Length"); length != "" {
+		if req.Size, err = strconv.ParseInt(length, 10, 64); err != nil || req.Size < 0 {
+			return nil, util.ErrInvalidArgument
+		}
+	}
+	switch r.Header.Get("Upload-Complete") {
+	case "", "?0":
+	case "?1":
+		req.Final = true
+	default:
+		return nil, util.ErrInvalidArgument
+	}
+	return req, nil
+}
+
+// decodeHTTPUploadStateRequest reads the ticketID query parameter of a HEAD
+// or GET request for the state of an upload.
+func decodeHTTPUploadStateRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := endpoints.UploadStateRequest{TicketID: r.URL.Query().Get("ticketID")}
+	if req.TicketID == "" {
+		return nil, util.ErrInvalidArgument
+	}
+	return req, nil
+}
+
+// decodeHTTPDownloadDocumentRequest reads the ticketID query parameter and a
+// single byte range of the Range header, other ranges send the whole content.
+func decodeHTTPDownloadDocumentRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := endpoints.DownloadDocumentRequest{TicketID: r.URL.Query().Get("ticketID")}
+	if req.TicketID == "" {
+		return nil, util.ErrInvalidArgument
+	}
+	if offset, length, ok := util.ParseRange(r.Header.Get("Range")); ok {
+		req.Offset, req.Length = offset, length
+	}
+	return req, nil
+}
+
+// encodeHTTPDownloadDocumentResponse streams the content, a part of it is
+// sent as 206 with its Content-Range.
+func encodeHTTPDownloadDocumentResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(endpoints.DownloadDocumentResponse)
+	if resp.Err != "" {
+		if resp.Code == http.StatusRequestedRangeNotSatisfiable {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", resp.Content.Size))
+		}
+		return encodeResponse(ctx, w, resp)
+	}
+	content := resp.Content
+	defer content.Body.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Length", strconv.FormatInt(content.Length, 10))
+	if content.Digest != "" {
+		w.Header().Set("ETag", strconv.Quote(content.Digest))
+	}
+	if content.Length < content.Size {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", content.Offset, content.Offset+content.Length-1, content.Size))
+		w.WriteHeader(http.StatusPartialContent)
+	}
+	_, err := io.Copy(w, content.Body)
+	return err
+}
+
+// decodeHTTPHandleEventRequest returns the decoder of the events posted by
+// the database node, an event whose body is not signed by the secret is
+// refused with util.ErrForbidden.
+func decodeHTTPHandleEventRequest(secret []byte) httptransport.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxEventSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(body) > maxEventSize {
+			return nil, util.ErrInvalidArgument
+		}
+		if !internal.ValidEventSignature(secret, body, r.Header.Get(internal.EventSignatureHeader)) {
+			return nil, util.ErrForbidden
+		}
+		var req endpoints.HandleEventRequest
+		if err := json.Unmarshal(body, &req.Event); err != nil {
+			return nil, util.ErrInvalidArgument
+		}
+		return req, nil
+	}
+}
+
+// decodeHTTPDetectRequest reads the suspect content from the body, a JSON
+// body carries it in the "content" field.
+func decodeHTTPDetectRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return endpoints.DetectRequest{Body: r.Body}, nil
+	}
+	var body struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, util.ErrInvalidArgument
+	}
+	return endpoints.DetectRequest{Body: strings.NewReader(body.Content)}, nil
+}
+
 func decodeHTTPServiceStatusRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var req endpoints.ServiceStatusRequest
 	return req, nil
@@ -96,16 +390,21 @@ func encodeResponse(ctx context.Context, w http.ResponseWriter, response interfa
 		encodeError(ctx, err, w)
 		return nil
 	}
-	return json.NewEncoder(w).Encode(response)
+	// honours the status code and headers of responses such as UploadResponse
+	return httptransport.EncodeJSONResponse(ctx, w, response)
 }
 
 func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	switch err {
-	case util.ErrUnknown:
+	switch {
+	case errors.Is(err, util.ErrUnknown):
 		w.WriteHeader(http.StatusNotFound)
-	case util.ErrInvalidArgument:
+	case errors.Is(err, util.ErrInvalidArgument):
 		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, util.ErrConflict):
+		w.WriteHeader(http.StatusConflict)
+	case errors.Is(err, util.ErrForbidden):
+		w.WriteHeader(http.StatusForbidden)
 	default:
 		w.WriteHeader(http.StatusInternalServerError)
 	}
@@ -0,0 +1,218 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"publisher/internal"
+	"publisher/internal/util"
+	"publisher/pkg/blob"
+	"publisher/pkg/database"
+	"publisher/pkg/watermark"
+	"publisher/pkg/watermark/endpoints"
+	"strings"
+	"testing"
+)
+
+func newTestEndpoints(t *testing.T) endpoints.Set {
+	t.Helper()
+	db := database.NewService(database.NewMemoryRepository(), blob.NewMemoryStore())
+	q, err := watermark.NewFileQueue(filepath.Join(t.TempDir(), "queue.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := watermark.NewService(db, t.TempDir(), watermark.NewPool(db, q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return endpoints.NewEndpointSet(svc)
+}
+
+// serve sends the request to the handler and decodes an upload response.
+func serve(t *testing.T, h http.Handler, r *http.Request) (*httptest.ResponseRecorder, endpoints.UploadResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var resp endpoints.UploadResponse
+	if r.Method != http.MethodHead && strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s %s returned %q: %v", r.Method, r.URL, w.Body, err)
+		}
+	}
+	return w, resp
+}
+
+func TestHTTPUpload(t *testing.T) {
+	h := NewHttpHandler(newTestEndpoints(t), nil)
+	r := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(`{"document":{"title":"Title"}}`))
+	r.Header.Set("Upload-Length", "10")
+	w, resp := serve(t, h, r)
+	if w.Code != http.StatusCreated || resp.Upload.TicketID == "" || w.Header().Get("Upload-Offset") != "0" || w.Header().Get("Upload-Length") != "10" {
+		t.Fatalf("POST /upload returned %d %+v %v", w.Code, resp, w.Header())
+	}
+	ticketID := resp.Upload.TicketID
+
+	for _, tt := range []struct {
+		name   string
+		offset string
+		body   string
+		code   int
+		after  string
+	}{
+		{"first chunk", "0", "0123", http.StatusOK, "4"},
+		{"wrong offset", "2", "2345", http.StatusConflict, "4"},
+		{"invalid offset", "x", "4567", http.StatusBadRequest, ""},
+		{"past the size", "4", "456789 and more", http.StatusBadRequest, "10"},
+		{"last chunk", "10", "", http.StatusOK, "10"},
+	} {
+		r := httptest.NewRequest(http.MethodPatch, "/upload?ticketID="+ticketID, strings.NewReader(tt.body))
+		r.Header.Set("Upload-Offset", tt.offset)
+		w, _ := serve(t, h, r)
+		if w.Code != tt.code || w.Header().Get("Upload-Offset") != tt.after {
+			t.Errorf("%s: PATCH returned %d at offset %q, want %d at %q", tt.name, w.Code, w.Header().Get("Upload-Offset"), tt.code, tt.after)
+		}
+	}
+
+	w, resp = serve(t, h, httptest.NewRequest(http.MethodGet, "/upload?ticketID="+ticketID, nil))
+	if w.Code != http.StatusOK || !resp.Upload.Complete || resp.Upload.Document == nil || resp.Upload.Document.ContentSize != 10 {
+		t.Errorf("GET /upload returned %d %+v, want the upload complete", w.Code, resp)
+	}
+	w, _ = serve(t, h, httptest.NewRequest(http.MethodHead, "/upload?ticketID="+ticketID, nil))
+	if w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != "10" {
+		t.Errorf("HEAD /upload returned %d %v", w.Code, w.Header())
+	}
+	if w, _ := serve(t, h, httptest.NewRequest(http.MethodDelete, "/upload?ticketID="+ticketID, nil)); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE /upload returned %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+
+	for _, tt := range []struct {
+		rng          string
+		code         int
+		body         string
+		contentRange string
+	}{
+		{"", http.StatusOK, "0123456789", ""},
+		{"bytes=2-4", http.StatusPartialContent, "234", "bytes 2-4/10"},
+		{"bytes=7-", http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{"bytes=10-", http.StatusRequestedRangeNotSatisfiable, "", "bytes */10"},
+		// unsupported ranges send the whole content
+		{"bytes=-3", http.StatusOK, "0123456789", ""},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/download?ticketID="+ticketID, nil)
+		r.Header.Set("Range", tt.rng)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.code || w.Header().Get("Content-Range") != tt.contentRange {
+			t.Errorf("GET /download of %q returned %d with range %q, want %d with %q", tt.rng, w.Code, w.Header().Get("Content-Range"), tt.code, tt.contentRange)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("GET /download of %q returned %q, want %q", tt.rng, w.Body, tt.body)
+		}
+	}
+}
+
+func TestHTTPUploadUnknownSize(t *testing.T) {
+	h := NewHttpHandler(newTestEndpoints(t), nil)
+	w, resp := serve(t, h, httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(`{"document":{"title":"Title"}}`)))
+	if w.Code != http.StatusCreated || w.Header().Get("Upload-Length") != "" {
+		t.Fatalf("POST /upload returned %d %+v %v", w.Code, resp, w.Header())
+	}
+	ticketID := resp.Upload.TicketID
+
+	for _, tt := range []struct {
+		name     string
+		offset   string
+		complete string
+		body     string
+		code     int
+		done     bool
+	}{
+		{"chunk", "0", "", "0123", http.StatusOK, false},
+		{"not complete", "4", "?0", "45", http.StatusOK, false},
+		{"invalid complete", "6", "yes", "6", http.StatusBadRequest, false},
+		{"last chunk", "6", "?1", "6", http.StatusOK, true},
+	} {
+		r := httptest.NewRequest(http.MethodPatch, "/upload?ticketID="+ticketID, strings.NewReader(tt.body))
+		r.Header.Set("Upload-Offset", tt.offset)
+		if tt.complete != "" {
+			r.Header.Set("Upload-Complete", tt.complete)
+		}
+		w, resp := serve(t, h, r)
+		if w.Code != tt.code || resp.Upload.Complete != tt.done {
+			t.Errorf("%s: PATCH returned %d %+v, want %d complete %v", tt.name, w.Code, resp.Upload, tt.code, tt.done)
+		}
+	}
+	w, resp = serve(t, h, httptest.NewRequest(http.MethodGet, "/upload?ticketID="+ticketID, nil))
+	if w.Code != http.StatusOK || !resp.Upload.Complete || resp.Upload.Document.ContentSize != 7 {
+		t.Errorf("GET /upload returned %d %+v, want 7 bytes complete", w.Code, resp)
+	}
+	if w, _ := serve(t, h, httptest.NewRequest(http.MethodHead, "/upload", nil)); w.Code != http.StatusBadRequest {
+		t.Errorf("HEAD /upload without ticketID returned %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHTTPUploadMultipart(t *testing.T) {
+	h := NewHttpHandler(newTestEndpoints(t), nil)
+	body := "--b\r\n" +
+		"Content-Disposition: form-data; name=\"document\"\r\n\r\n" +
+		`{"title":"Title"}` + "\r\n" +
+		"--b\r\n" +
+		"Content-Disposition: form-data; name=\"content\"\r\n\r\n" +
+		"streamed content\r\n" +
+		"--b--\r\n"
+	r := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(body))
+	r.Header.Set("Content-Type", "multipart/form-data; boundary=b")
+	r.Header.Set("Upload-Length", "16")
+	w, resp := serve(t, h, r)
+	if w.Code != http.StatusCreated || !resp.Upload.Complete || resp.Upload.Offset != 16 {
+		t.Fatalf("POST /upload returned %d %+v, want the upload complete", w.Code, resp)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/download?ticketID="+resp.Upload.TicketID, nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if b, _ := io.ReadAll(rec.Body); string(b) != "streamed content" || rec.Header().Get("ETag") == "" {
+		t.Errorf("GET /download returned %q with ETag %q", b, rec.Header().Get("ETag"))
+	}
+	if doc := resp.Upload.Document; doc == nil || doc.Title != "Title" || doc.ContentSize != 16 {
+		t.Errorf("POST /upload stored %+v", doc)
+	}
+}
+
+func TestDecodeHTTPHandleEventRequest(t *testing.T) {
+	secret := []byte("secret")
+	body := `{"offset":7,"type":"removed","ticketID":"a"}`
+	for _, tt := range []struct {
+		name      string
+		secret    []byte
+		body      string
+		signature string
+		err       error
+	}{
+		{"signed", secret, body, internal.SignEvent(secret, []byte(body)), nil},
+		{"unsigned", secret, body, "", util.ErrForbidden},
+		{"other secret", secret, body, internal.SignEvent([]byte("other"), []byte(body)), util.ErrForbidden},
+		{"changed body", secret, strings.Replace(body, "removed", "purged", 1), internal.SignEvent(secret, []byte(body)), util.ErrForbidden},
+		{"no secret", nil, body, internal.SignEvent(nil, []byte(body)), util.ErrForbidden},
+		{"invalid json", secret, "{", internal.SignEvent(secret, []byte("{")), util.ErrInvalidArgument},
+	} {
+		r := httptest.NewRequest("POST", "/events", strings.NewReader(tt.body))
+		if tt.signature != "" {
+			r.Header.Set(internal.EventSignatureHeader, tt.signature)
+		}
+		req, err := decodeHTTPHandleEventRequest(tt.secret)(context.Background(), r)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: decode returned %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err == nil {
+			if ev := req.(endpoints.HandleEventRequest).Event; ev.Offset != 7 || ev.Type != internal.EventRemoved || ev.TicketID != "a" {
+				t.Errorf("%s: decoded event %+v", tt.name, ev)
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package watermark
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"publisher/internal"
+	"publisher/internal/util"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// maxUploadSize limits the size of an uploaded content, it bounds the bytes
+// received for an upload of unknown size.
+const maxUploadSize = 4 << 30
+
+// uploadStore keeps the content of unfinished uploads as files below dir,
+// <ticketID>.part holds the received bytes and <ticketID>.json the announced
+// size. Both survive a restart so uploads can be resumed.
+type uploadStore struct {
+	dir string
+
+	mu     sync.Mutex
+	active map[string]bool
+}
+
+func newUploadStore(dir string) (*uploadStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &uploadStore{dir: dir, active: map[string]bool{}}, nil
+}
+
+type uploadMeta struct {
+	Size int64 `json:"size"`
+}
+
+func (u *uploadStore) partPath(ticketID string) string {
+	return filepath.Join(u.dir, ticketID+".part")
+}
+
+func (u *uploadStore) metaPath(ticketID string) string {
+	return filepath.Join(u.dir, ticketID+".json")
+}
+
+// create starts the upload of the content of the document.
+func (u *uploadStore) create(ticketID string, size int64) error {
+	b, err := json.Marshal(uploadMeta{Size: size})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(u.partPath(ticketID), nil, 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(u.metaPath(ticketID), b, 0o644)
+}
+
+// lock reserves the upload for a single writer, concurrent writers get
+// util.ErrConflict. The returned function releases it.
+func (u *uploadStore) lock(ticketID string) (func(), error) {
+	if _, err := uuid.Parse(ticketID); err != nil {
+		return nil, util.ErrInvalidArgument
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.active[ticketID] {
+		return nil, util.ErrConflict
+	}
+	u.active[ticketID] = true
+	return func() {
+		u.mu.Lock()
+		delete(u.active, ticketID)
+		u.mu.Unlock()
+	}, nil
+}
+
+// stat returns the state of the upload, util.ErrUnknown if there is none.
+func (u *uploadStore) stat(ticketID string) (internal.Upload, error) {
+	if _, err := uuid.Parse(ticketID); err != nil {
+		return internal.Upload{TicketID: ticketID}, util.ErrInvalidArgument
+	}
+	b, err := os.ReadFile(u.metaPath(ticketID))
+	if os.IsNotExist(err) {
+		return internal.Upload{}, util.ErrUnknown
+	}
+	if err != nil {
+		return internal.Upload{}, err
+	}
+	var meta uploadMeta
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return internal.Upload{}, err
+	}
+	fi, err := os.Stat(u.partPath(ticketID))
+	if err != nil {
+		return internal.Upload{}, err
+	}
+	return internal.Upload{TicketID: ticketID, Size: meta.Size, Offset: fi.Size()}, nil
+}
+
+// setSize announces the size of an upload created with an unknown size.
+func (u *uploadStore) setSize(ticketID string, size int64) error {
+	b, err := json.Marshal(uploadMeta{Size: size})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(u.metaPath(ticketID), b, 0o644)
+}
+
+// write appends r to the received bytes, up to the announced size or up to
+// maxUploadSize if the size is unknown. On an error the bytes written so far
+// are kept and synced so the upload can be resumed after them.
+func (u *uploadStore) write(upload *internal.Upload, r io.Reader) error {
+	f, err := os.OpenFile(u.partPath(upload.TicketID), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	limit := int64(maxUploadSize)
+	if upload.Size > 0 {
+		limit = upload.Size
+	}
+	// one byte more detects content exceeding the limit
+	n, err := io.Copy(f, io.LimitReader(r, limit-upload.Offset+1))
+	upload.Offset += n
+	if syncErr := f.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && upload.Offset > limit {
+		upload.Offset = limit
+		if err := os.Truncate(u.partPath(upload.TicketID), limit); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: content exceeds the size of %d bytes", util.ErrInvalidArgument, limit)
+	}
+	return err
+}
+
+// open returns the received content.
+func (u *uploadStore) open(ticketID string) (*os.File, error) {
+	return os.Open(u.partPath(ticketID))
+}
+
+// remove deletes a finished upload.
+func (u *uploadStore) remove(ticketID string) error {
+	if err := os.Remove(u.metaPath(ticketID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(u.partPath(ticketID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package watermark
+
+import (
+	"context"
+	"errors"
+	"io"
+	"publisher/internal"
+	"publisher/internal/util"
+	"publisher/pkg/blob"
+	"publisher/pkg/database"
+	"strings"
+	"testing"
+)
+
+func newTestService(t *testing.T) Service {
+	t.Helper()
+	db := database.NewService(database.NewMemoryRepository(), blob.NewMemoryStore())
+	q, _ := newTestQueue(t)
+	svc, err := NewService(db, t.TempDir(), NewPool(db, q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+// download returns the bytes of the content from offset, length 0 reads to
+// the end.
+func download(t *testing.T, svc Service, ticketID string, offset, length int64) (internal.Content, string) {
+	t.Helper()
+	content, err := svc.DownloadDocument(context.Background(), ticketID, offset, length)
+	if err != nil {
+		t.Fatalf("DownloadDocument(%d, %d) returned %v", offset, length, err)
+	}
+	defer content.Body.Close()
+	b, err := io.ReadAll(content.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content, string(b)
+}
+
+func TestResumeUpload(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	upload, err := svc.UploadDocument(ctx, &internal.Document{Title: "Title"}, 10, false, strings.NewReader("0123"))
+	if err != nil || upload.TicketID == "" || upload.Offset != 4 || upload.Size != 10 || upload.Complete {
+		t.Fatalf("UploadDocument returned %+v, %v, want 4 of 10 bytes", upload, err)
+	}
+	ticketID := upload.TicketID
+
+	// the upload continues where the received bytes end
+	for _, offset := range []int64{0, 2, 6} {
+		upload, err := svc.ResumeUpload(ctx, ticketID, offset, 0, false, strings.NewReader("456"))
+		if !errors.Is(err, util.ErrConflict) || upload.Offset != 4 {
+			t.Errorf("ResumeUpload at %d returned %+v, %v, want %v at 4", offset, upload, err, util.ErrConflict)
+		}
+	}
+	if upload, err = svc.ResumeUpload(ctx, ticketID, 4, 0, false, strings.NewReader("456")); err != nil || upload.Offset != 7 || upload.Complete {
+		t.Errorf("ResumeUpload at 4 returned %+v, %v, want 7 bytes", upload, err)
+	}
+
+	// the bytes past the announced size are cut off
+	upload, err = svc.ResumeUpload(ctx, ticketID, 7, 0, false, strings.NewReader("789 and more"))
+	if !errors.Is(err, util.ErrInvalidArgument) || upload.Offset != 10 || upload.Complete {
+		t.Errorf("ResumeUpload past the size returned %+v, %v, want %v at 10", upload, err, util.ErrInvalidArgument)
+	}
+	if upload, err = svc.ResumeUpload(ctx, ticketID, 10, 0, false, strings.NewReader("")); err != nil || !upload.Complete {
+		t.Fatalf("ResumeUpload at the size returned %+v, %v, want it complete", upload, err)
+	}
+	if doc := upload.Document; doc == nil || doc.ContentSize != 10 || doc.Title != "Title" {
+		t.Errorf("the complete upload stored %+v", doc)
+	}
+	if _, got := download(t, svc, ticketID, 0, 0); got != "0123456789" {
+		t.Errorf("the upload stored %q, want %q", got, "0123456789")
+	}
+
+	// a complete upload stays complete
+	upload, err = svc.UploadState(ctx, ticketID)
+	if err != nil || !upload.Complete || upload.Offset != 10 || upload.Document == nil {
+		t.Errorf("the state of the complete upload is %+v, %v", upload, err)
+	}
+	if upload, err = svc.ResumeUpload(ctx, ticketID, 10, 0, false, strings.NewReader("more")); err != nil || !upload.Complete {
+		t.Errorf("ResumeUpload of the complete upload returned %+v, %v", upload, err)
+	}
+	if _, got := download(t, svc, ticketID, 0, 0); got != "0123456789" {
+		t.Errorf("the complete upload changed to %q", got)
+	}
+}
+
+func TestResumeUploadUnknownSize(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+
+	// the upload stays open until its final chunk
+	upload, err := svc.UploadDocument(ctx, &internal.Document{Title: "Final"}, 0, false, strings.NewReader("0123"))
+	if err != nil || upload.Offset != 4 || upload.Complete {
+		t.Fatalf("UploadDocument returned %+v, %v, want 4 bytes", upload, err)
+	}
+	final := upload.TicketID
+	if upload, err = svc.ResumeUpload(ctx, final, 4, 0, false, strings.NewReader("45")); err != nil || upload.Offset != 6 || upload.Complete {
+		t.Errorf("ResumeUpload of a chunk returned %+v, %v, want 6 bytes", upload, err)
+	}
+	if upload, err = svc.UploadState(ctx, final); err != nil || upload.Offset != 6 || upload.Size != 0 || upload.Complete {
+		t.Errorf("UploadState returned %+v, %v, want 6 bytes of an unknown size", upload, err)
+	}
+	if upload, err = svc.ResumeUpload(ctx, final, 6, 0, true, strings.NewReader("6")); err != nil || !upload.Complete || upload.Document.ContentSize != 7 {
+		t.Errorf("ResumeUpload of the final chunk returned %+v, %v, want it complete", upload, err)
+	}
+	if _, got := download(t, svc, final, 0, 0); got != "0123456" {
+		t.Errorf("the final upload stored %q, want %q", got, "0123456")
+	}
+
+	// or until its size is announced and received
+	upload, err = svc.UploadDocument(ctx, &internal.Document{Title: "Announced"}, 0, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	announced := upload.TicketID
+	if upload, err = svc.ResumeUpload(ctx, announced, 0, 0, false, strings.NewReader("abc")); err != nil || upload.Complete {
+		t.Errorf("ResumeUpload of a chunk returned %+v, %v, want it open", upload, err)
+	}
+	if upload, err = svc.ResumeUpload(ctx, announced, 3, 5, false, strings.NewReader("de")); err != nil || !upload.Complete || upload.Size != 5 {
+		t.Errorf("ResumeUpload announcing the size returned %+v, %v, want it complete", upload, err)
+	}
+
+	// a multipart upload sends the whole content at once
+	if upload, err = svc.UploadDocument(ctx, &internal.Document{Title: "Whole"}, 0, true, strings.NewReader("whole")); err != nil || !upload.Complete {
+		t.Errorf("UploadDocument of the whole content returned %+v, %v, want it complete", upload, err)
+	}
+
+	// the final chunk of an upload of known size must end at the size
+	upload, err = svc.UploadDocument(ctx, &internal.Document{Title: "Short"}, 10, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upload, err = svc.ResumeUpload(ctx, upload.TicketID, 0, 0, true, strings.NewReader("0123")); !errors.Is(err, util.ErrInvalidArgument) || upload.Offset != 4 || upload.Complete {
+		t.Errorf("ResumeUpload of a short final chunk returned %+v, %v, want %v", upload, err, util.ErrInvalidArgument)
+	}
+}
+
+func TestUploadDocumentInvalid(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	for _, tt := range []struct {
+		name string
+		doc  *internal.Document
+		size int64
+	}{
+		{"no document", nil, 0},
+		{"no title", &internal.Document{Author: "Author"}, 0},
+		{"negative size", &internal.Document{Title: "Title"}, -1},
+		{"too large", &internal.Document{Title: "Title"}, maxUploadSize + 1},
+	} {
+		if _, err := svc.UploadDocument(ctx, tt.doc, tt.size, false, nil); !errors.Is(err, util.ErrInvalidArgument) {
+			t.Errorf("%s: UploadDocument returned %v, want %v", tt.name, err, util.ErrInvalidArgument)
+		}
+	}
+	upload, err := svc.UploadDocument(ctx, &internal.Document{Title: "Title"}, 10, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name         string
+		ticketID     string
+		offset, size int64
+		r            io.Reader
+	}{
+		{"invalid ticket", "not-a-uuid", 0, 0, strings.NewReader("x")},
+		{"negative offset", upload.TicketID, -1, 0, strings.NewReader("x")},
+		{"negative size", upload.TicketID, 0, -1, strings.NewReader("x")},
+		{"no content", upload.TicketID, 0, 0, nil},
+	} {
+		if _, err := svc.ResumeUpload(ctx, tt.ticketID, tt.offset, tt.size, false, tt.r); !errors.Is(err, util.ErrInvalidArgument) {
+			t.Errorf("%s: ResumeUpload returned %v, want %v", tt.name, err, util.ErrInvalidArgument)
+		}
+	}
+	if _, err := svc.UploadState(ctx, "../not-a-uuid"); !errors.Is(err, util.ErrInvalidArgument) {
+		t.Errorf("UploadState of an invalid ticket returned %v, want %v", err, util.ErrInvalidArgument)
+	}
+	if _, err := svc.ResumeUpload(ctx, "0b7e0a0e-9f1b-4d3c-8a43-8b1f1c2d3e4f", 0, 0, false, strings.NewReader("x")); !errors.Is(err, util.ErrUnknown) {
+		t.Errorf("ResumeUpload of an unknown ticket returned %v, want %v", err, util.ErrUnknown)
+	}
+}
+
+func TestDownloadDocumentRange(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	upload, err := svc.UploadDocument(ctx, &internal.Document{Title: "Title"}, 10, false, strings.NewReader("0123456789"))
+	if err != nil || !upload.Complete {
+		t.Fatalf("UploadDocument returned %+v, %v", upload, err)
+	}
+	for _, tt := range []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, 0, "0123456789"},
+		{2, 3, "234"},
+		{7, 0, "789"},
+		// a range past the end is cut at the end
+		{8, 5, "89"},
+	} {
+		content, got := download(t, svc, upload.TicketID, tt.offset, tt.length)
+		if got != tt.want || content.Offset != tt.offset || content.Length != int64(len(tt.want)) || content.Size != 10 {
+			t.Errorf("DownloadDocument(%d, %d) returned %q at %d of %d bytes, want %q", tt.offset, tt.length, got, content.Offset, content.Size, tt.want)
+		}
+		if content.Digest != upload.Document.ContentDigest {
+			t.Errorf("DownloadDocument(%d, %d) returned the digest %s, want %s", tt.offset, tt.length, content.Digest, upload.Document.ContentDigest)
+		}
+	}
+	for _, tt := range []struct {
+		offset, length int64
+		err            error
+	}{
+		{10, 0, util.ErrOutOfRange},
+		{-1, 0, util.ErrInvalidArgument},
+		{0, -1, util.ErrInvalidArgument},
+	} {
+		if _, err := svc.DownloadDocument(ctx, upload.TicketID, tt.offset, tt.length); !errors.Is(err, tt.err) {
+			t.Errorf("DownloadDocument(%d, %d) returned %v, want %v", tt.offset, tt.length, err, tt.err)
+		}
+	}
+}
@@ -2,54 +2,235 @@ package watermark
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"publisher/internal"
+	"publisher/internal/util"
+	"publisher/pkg/database"
+	"strings"
 
 	"github.com/go-kit/log"
-	"github.com/google/uuid"
 )
 
 var logger log.Logger
 
-type watermarkService struct{}
+type watermarkService struct {
+	db      database.Service
+	uploads *uploadStore
+	jobs    *Pool
+}
 
-func NewService() Service {
-	return &watermarkService{}
+// NewService returns the watermark service which stores the documents in
+// the given database service, unfinished uploads are kept below uploadDir.
+// The watermarks are applied by the jobs of the pool.
+func NewService(db database.Service, uploadDir string, jobs *Pool) (Service, error) {
+	uploads, err := newUploadStore(uploadDir)
+	if err != nil {
+		return nil, err
+	}
+	return &watermarkService{db: db, uploads: uploads, jobs: jobs}, nil
 }
 
-func (w *watermarkService) Get(_ context.Context, filters ...internal.Filter) ([]internal.Document, error) {
-	// query the database using the filters and return the list of docuemtns
-	// return errror if the filter (key) is invalid and also return error if no item found
-	doc := internal.Document{
-		Content: "book",
-		Title:   "Harry Potter and Half Blood Prince",
-		Author:  "J.K. Rowling",
-		Topic:   "Fiction and Magic",
+func (w *watermarkService) Get(ctx context.Context, query internal.Query) (internal.Page, error) {
+	// query the database using the filters and return the page of documents
+	// return error if the filter (key) is invalid
+	if err := internal.ValidateFilters(query.Filters); err != nil {
+		return internal.Page{Documents: []internal.Document{}}, err
 	}
+	return w.db.Get(ctx, query)
+}
 
-	return []internal.Document{doc}, nil
+func (w *watermarkService) Search(ctx context.Context, query internal.SearchQuery) ([]internal.SearchResult, error) {
+	return w.db.Search(ctx, query)
 }
 
-func (w *watermarkService) Status(_ context.Context, ticketID string) (internal.Status, error) {
-	// query database using the ticketID and return the document info
-	// return err if the ticketID is invalid or no Document exists for that ticketID
-	return internal.InProgress, nil
+func (w *watermarkService) Status(ctx context.Context, ticketID string) (internal.WatermarkJob, error) {
+	if ticketID == "" {
+		return internal.WatermarkJob{}, util.ErrInvalidArgument
+	}
+	return w.db.WatermarkJob(ctx, ticketID)
 }
 
-func (w *watermarkService) Watermark(_ context.Context, ticketID, mark string) (int, error) {
-	// update the database entry with watermark field as non empty
-	// first check if the watermark status is not already in InProgress, Started or Finished state
-	// If yes, then return invalid request
-	// return error if not item found using the ticketID
-	return http.StatusOK, nil
+// Watermark requests the watermark and queues its job, it returns
+// http.StatusAccepted without waiting for the job. The progress of the job
+// is reported by Status.
+func (w *watermarkService) Watermark(ctx context.Context, ticketID, mark string) (int, error) {
+	if ticketID == "" || strings.TrimSpace(mark) == "" {
+		return code("Watermark", ticketID, util.ErrInvalidArgument)
+	}
+	change := internal.WatermarkTransition{To: internal.Pending, Mark: mark}
+	if _, err := w.db.TransitionWatermark(ctx, ticketID, change); err != nil {
+		return code("Watermark", ticketID, err)
+	}
+	if err := w.jobs.Enqueue(ctx, ticketID); err != nil {
+		w.jobs.fail(ticketID, err)
+		return code("Watermark", ticketID, err)
+	}
+	return http.StatusAccepted, nil
 }
 
-func (w *watermarkService) AddDocument(_ context.Context, doc *internal.Document) (string, error) {
-	// add the document entry in the database by calling the database service
-	// return error if the doc is invalid and/or the database invalid entry error
-	newTicketID := uuid.New()
-	return newTicketID.String(), nil
+// maxCandidates limits the documents looked up for a detected mark
+const maxCandidates = 10
+
+// Detect reads the suspect content, which must not exceed the size of the
+// contents watermarks are embedded into.
+func (w *watermarkService) Detect(ctx context.Context, r io.Reader) (internal.Detection, error) {
+	empty := internal.Detection{Encodings: []string{}}
+	if r == nil {
+		return empty, util.ErrInvalidArgument
+	}
+	content, err := io.ReadAll(io.LimitReader(r, maxMarkedContent+1))
+	if err != nil {
+		return empty, err
+	}
+	if len(content) == 0 || len(content) > maxMarkedContent {
+		return empty, util.ErrInvalidArgument
+	}
+	detection, err := detectContent(content)
+	if err != nil {
+		return detection, err
+	}
+	query := internal.Query{
+		Filters:  []internal.Filter{{Key: internal.KeyWatermark, Op: internal.OpEq, Value: detection.Mark}},
+		PageSize: maxCandidates,
+	}
+	page, err := w.db.Get(ctx, query)
+	if err != nil {
+		return detection, err
+	}
+	switch len(page.Documents) {
+	case 0:
+	case 1:
+		detection.TicketID = page.Documents[0].TicketID
+	default:
+		for _, doc := range page.Documents {
+			detection.Candidates = append(detection.Candidates, doc.TicketID)
+		}
+	}
+	return detection, nil
+}
+
+// AddDocument adds the document to the database. The idempotency key of the
+// context is passed on, so a retried request returns the first ticket.
+func (w *watermarkService) AddDocument(ctx context.Context, doc *internal.Document) (string, error) {
+	if doc == nil || strings.TrimSpace(doc.Title) == "" {
+		return "", util.ErrInvalidArgument
+	}
+	if key := internal.IdempotencyKeyFromContext(ctx); key != "" {
+		if err := internal.ValidateIdempotencyKey(key); err != nil {
+			return "", err
+		}
+	}
+	return w.db.Add(ctx, doc)
+}
+
+func (w *watermarkService) UploadDocument(ctx context.Context, doc *internal.Document, size int64, final bool, r io.Reader) (internal.Upload, error) {
+	if doc == nil || strings.TrimSpace(doc.Title) == "" || size < 0 || size > maxUploadSize {
+		return internal.Upload{}, util.ErrInvalidArgument
+	}
+	// the content is uploaded separately
+	meta := *doc
+	meta.Content = ""
+	ticketID, err := w.db.Add(ctx, &meta)
+	if err != nil {
+		return internal.Upload{}, err
+	}
+	if err := w.uploads.create(ticketID, size); err != nil {
+		logger.Log("method", "UploadDocument", "ticketID", ticketID, "err", err)
+		return internal.Upload{TicketID: ticketID}, err
+	}
+	if r == nil {
+		return internal.Upload{TicketID: ticketID, Size: size}, nil
+	}
+	return w.ResumeUpload(ctx, ticketID, 0, size, final, r)
+}
+
+func (w *watermarkService) ResumeUpload(ctx context.Context, ticketID string, offset, size int64, final bool, r io.Reader) (internal.Upload, error) {
+	if offset < 0 || size < 0 || size > maxUploadSize || r == nil {
+		return internal.Upload{TicketID: ticketID}, util.ErrInvalidArgument
+	}
+	unlock, err := w.uploads.lock(ticketID)
+	if err != nil {
+		return internal.Upload{TicketID: ticketID}, err
+	}
+	defer unlock()
+
+	upload, err := w.upload(ctx, ticketID)
+	if err != nil || upload.Complete {
+		return upload, err
+	}
+	if size > 0 && size != upload.Size {
+		if upload.Size != 0 || size < upload.Offset {
+			return upload, fmt.Errorf("%w: the upload has a size of %d bytes", util.ErrInvalidArgument, upload.Size)
+		}
+		if err := w.uploads.setSize(ticketID, size); err != nil {
+			return upload, err
+		}
+		upload.Size = size
+	}
+	if offset != upload.Offset {
+		return upload, fmt.Errorf("%w: the upload continues at offset %d", util.ErrConflict, upload.Offset)
+	}
+	if err := w.uploads.write(&upload, r); err != nil {
+		return upload, err
+	}
+	if !final && (upload.Size == 0 || upload.Offset < upload.Size) {
+		return upload, nil
+	}
+	if upload.Offset < upload.Size {
+		return upload, fmt.Errorf("%w: the upload ends at %d bytes before its size of %d bytes", util.ErrInvalidArgument, upload.Offset, upload.Size)
+	}
+	return w.completeUpload(ctx, upload)
+}
+
+func (w *watermarkService) UploadState(ctx context.Context, ticketID string) (internal.Upload, error) {
+	return w.upload(ctx, ticketID)
+}
+
+// upload returns the state of the upload, a finished upload is reported
+// complete as long as its document exists.
+func (w *watermarkService) upload(ctx context.Context, ticketID string) (internal.Upload, error) {
+	upload, err := w.uploads.stat(ticketID)
+	if err != util.ErrUnknown {
+		return upload, err
+	}
+	page, err := w.db.Get(ctx, internal.Query{Filters: []internal.Filter{{Key: internal.KeyTicketID, Value: ticketID}}})
+	if err != nil {
+		return internal.Upload{TicketID: ticketID}, err
+	}
+	if len(page.Documents) == 0 {
+		return internal.Upload{TicketID: ticketID}, util.ErrUnknown
+	}
+	doc := page.Documents[0]
+	return internal.Upload{TicketID: ticketID, Size: doc.ContentSize, Offset: doc.ContentSize, Complete: true, Document: &doc}, nil
+}
+
+// completeUpload stores the received content in the database, the upload is
+// kept if that fails so it can be completed by resuming it without content,
+// as final chunk if its size is unknown.
+func (w *watermarkService) completeUpload(ctx context.Context, upload internal.Upload) (internal.Upload, error) {
+	f, err := w.uploads.open(upload.TicketID)
+	if err != nil {
+		return upload, err
+	}
+	defer f.Close()
+	doc, err := w.db.PutContent(ctx, upload.TicketID, f, 0)
+	if err != nil {
+		logger.Log("method", "completeUpload", "ticketID", upload.TicketID, "err", err)
+		return upload, err
+	}
+	if err := w.uploads.remove(upload.TicketID); err != nil {
+		logger.Log("method", "completeUpload", "ticketID", upload.TicketID, "err", err)
+	}
+	upload.Complete, upload.Document = true, &doc
+	return upload, nil
+}
+
+func (w *watermarkService) DownloadDocument(ctx context.Context, ticketID string, offset, length int64) (internal.Content, error) {
+	return w.db.OpenContent(ctx, ticketID, offset, length)
 }
 
 func (w *watermarkService) ServiceStatus(_ context.Context) (int, error) {
@@ -57,6 +238,54 @@ func (w *watermarkService) ServiceStatus(_ context.Context) (int, error) {
 	return http.StatusOK, nil
 }
 
+// HandleEvent discards the unfinished upload of a removed or purged document,
+// an upload in progress fails the event with util.ErrConflict so it is
+// delivered again later.
+func (w *watermarkService) HandleEvent(_ context.Context, ev internal.Event) error {
+	if ev.Offset <= 0 || ev.TicketID == "" || !internal.ValidEventType(ev.Type) {
+		return util.ErrInvalidArgument
+	}
+	switch ev.Type {
+	case internal.EventRemoved, internal.EventPurged:
+	default:
+		return nil
+	}
+	unlock, err := w.uploads.lock(ev.TicketID)
+	if errors.Is(err, util.ErrInvalidArgument) {
+		// no upload is kept for tickets which are not UUIDs
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := w.uploads.remove(ev.TicketID); err != nil {
+		logger.Log("method", "HandleEvent", "ticketID", ev.TicketID, "offset", ev.Offset, "err", err)
+		return err
+	}
+	return nil
+}
+
+// code returns the HTTP status code of the error, unexpected errors are
+// logged.
+func code(method, ticketID string, err error) (int, error) {
+	switch {
+	case err == nil:
+		return http.StatusOK, nil
+	case errors.Is(err, util.ErrUnknown):
+		return http.StatusNotFound, err
+	case errors.Is(err, util.ErrInvalidArgument):
+		return http.StatusBadRequest, err
+	case errors.Is(err, util.ErrConflict):
+		return http.StatusConflict, err
+	case errors.Is(err, ErrPoolClosed):
+		return http.StatusServiceUnavailable, err
+	default:
+		logger.Log("method", method, "ticketID", ticketID, "err", err)
+		return http.StatusInternalServerError, err
+	}
+}
+
 func init() {
 	logger = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
 	logger = log.With(logger, "ts", log.DefaultTimestampUTC)
@@ -0,0 +1,328 @@
+package watermark
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"publisher/internal"
+	"publisher/internal/util"
+	"publisher/pkg/blob"
+	"publisher/pkg/database"
+	"publisher/pkg/watermark/epub"
+	"publisher/pkg/watermark/text"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// detectFixture holds a watermark service on a memory database and the pool
+// applying the marks.
+type detectFixture struct {
+	svc  Service
+	db   database.Service
+	pool *Pool
+}
+
+func newDetectFixture(t *testing.T) *detectFixture {
+	t.Helper()
+	db := database.NewService(database.NewMemoryRepository(), blob.NewMemoryStore(), database.WithDuplicatePolicy(database.DuplicatePolicy{}))
+	q, _ := newTestQueue(t)
+	p := NewPool(db, q)
+	svc, err := NewService(db, t.TempDir(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &detectFixture{svc: svc, db: db, pool: p}
+}
+
+// mark adds a document with the content, applies the mark to it like a job
+// of the pool and returns the ticket and the marked content.
+func (f *detectFixture) mark(t *testing.T, content []byte, mark string) (string, []byte) {
+	t.Helper()
+	ctx := context.Background()
+	ticketID, err := f.db.Add(ctx, &internal.Document{Title: "Title", Author: "Author", Topic: "Topic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.db.PutContent(ctx, ticketID, bytes.NewReader(content), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.pool.apply(ctx, ticketID, mark); err != nil {
+		t.Fatal(err)
+	}
+	marked, err := f.pool.readContent(ctx, ticketID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(marked, content) {
+		t.Fatalf("the mark %q was not embedded", mark)
+	}
+	return ticketID, marked
+}
+
+// sentences returns a plain text of n sentences.
+func sentences(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "The quick brown fox number %d jumps over the lazy dog. ", i)
+		if i%5 == 4 {
+			b.WriteString("\n\n")
+		}
+	}
+	return b.String()
+}
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, 256, 256))
+	for y := 0; y < 256; y++ {
+		for x := 0; x < 256; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: uint8(x + y), A: 255})
+		}
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// testPDF returns a PDF document of a single page.
+func testPDF() []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	var offsets []int
+	for i, body := range []string{
+		"<</Type /Catalog /Pages 2 0 R>>",
+		"<</Type /Pages /Kids [3 0 R] /Count 1>>",
+		"<</Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Contents 4 0 R /Resources <</Font <</F1 5 0 R>>>>>>",
+		"<</Length 37>>\nstream\nBT /F1 12 Tf 72 720 Td (Page) Tj ET\nendstream",
+		"<</Type /Font /Subtype /Type1 /BaseFont /Helvetica>>",
+	} {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, body)
+	}
+	start := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f\r\n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n\r\n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<</Size %d /Root 1 0 R>>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, start)
+	return b.Bytes()
+}
+
+// testEPUB returns an e-book of a single chapter.
+func testEPUB(t *testing.T) []byte {
+	t.Helper()
+	var chapter strings.Builder
+	chapter.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	chapter.WriteString(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Chapter</title></head><body>` + "\n")
+	for i := 0; i < 40; i++ {
+		chapter.WriteString("<p>The quick brown fox <em>jumps</em> over the lazy dog &amp; runs away.</p>\n")
+	}
+	chapter.WriteString("</body></html>\n")
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct{ name, data string }{
+		{"mimetype", "application/epub+zip"},
+		{"META-INF/container.xml", `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`},
+		{"OEBPS/content.opf", `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Book</dc:title></metadata>
+  <manifest><item id="ch1" href="ch1.xhtml" media-type="application/xhtml+xml"/></manifest>
+  <spine><itemref idref="ch1"/></spine>
+</package>`},
+		{"OEBPS/ch1.xhtml", chapter.String()},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetect(t *testing.T) {
+	ctx := context.Background()
+	f := newDetectFixture(t)
+	textTicket, markedText := f.mark(t, []byte(sentences(400)), "reader-text")
+	imageTicket, markedImage := f.mark(t, testPNG(t), "reader-image")
+	pdfTicket, markedPDF := f.mark(t, testPDF(), "reader-pdf")
+	epubTicket, markedEPUB := f.mark(t, testEPUB(t), "reader-epub")
+
+	// a part of the text whose no-break spaces were lost when it was
+	// rewrapped by an editor
+	s := string(markedText)
+	reformatted := strings.ReplaceAll(s[len(s)/4:3*len(s)/4], "\u00a0", " ")
+	reformatted = strings.ReplaceAll(reformatted, "\n\n", "\r\n")
+
+	for _, tt := range []struct {
+		name      string
+		content   []byte
+		ticketID  string
+		mark      string
+		encodings []string
+	}{
+		{"text", markedText, textTicket, "reader-text", []string{string(text.ZeroWidth), string(text.Whitespace)}},
+		{"partial reformatted text", []byte(reformatted), textTicket, "reader-text", []string{string(text.ZeroWidth)}},
+		{"image", markedImage, imageTicket, "reader-image", []string{encodingDCT}},
+		{"pdf", markedPDF, pdfTicket, "reader-pdf", []string{encodingPDFInfo, encodingStamp}},
+		{"epub", markedEPUB, epubTicket, "reader-epub", []string{string(text.ZeroWidth), string(text.Whitespace), epub.EncodingMetadata}},
+	} {
+		detection, err := f.svc.Detect(ctx, bytes.NewReader(tt.content))
+		if err != nil {
+			t.Errorf("%s: Detect returned %v", tt.name, err)
+			continue
+		}
+		if detection.Mark != tt.mark || detection.TicketID != tt.ticketID || len(detection.Candidates) != 0 {
+			t.Errorf("%s: Detect returned %+v, want mark %q of ticket %s", tt.name, detection, tt.mark, tt.ticketID)
+		}
+		if detection.Confidence != 1 || detection.Frames < 1 {
+			t.Errorf("%s: Detect found %d frames with confidence %v, want 1", tt.name, detection.Frames, detection.Confidence)
+		}
+		if !reflect.DeepEqual(detection.Encodings, tt.encodings) {
+			t.Errorf("%s: Detect found the encodings %q, want %q", tt.name, detection.Encodings, tt.encodings)
+		}
+	}
+}
+
+func TestDetectConfidence(t *testing.T) {
+	ctx := context.Background()
+	f := newDetectFixture(t)
+	opts := text.Options{Encodings: []text.Encoding{text.ZeroWidth}, Interval: 20}
+	embed := func(s, mark string) string {
+		marked, err := text.Embed(s, mark, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return marked
+	}
+	// most of the text was handed out to one reader, a quoted part to another
+	majority, minority := embed(sentences(60), "reader-a"), embed(sentences(20), "reader-b")
+	frames := func(s string) int {
+		res, err := text.Extract(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Frames
+	}
+	a, b := frames(majority), frames(minority)
+	if a <= b {
+		t.Fatalf("the majority has %d frames and the minority %d", a, b)
+	}
+
+	detection, err := f.svc.Detect(ctx, strings.NewReader(majority+minority))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := float64(a) / float64(a+b); detection.Mark != "reader-a" || detection.Frames != a || detection.Confidence != want {
+		t.Errorf("Detect returned %+v, want mark reader-a with %d frames and confidence %v", detection, a, want)
+	}
+	// no document carries the mark
+	if detection.TicketID != "" || len(detection.Candidates) != 0 {
+		t.Errorf("Detect returned ticket %q and candidates %q, want none", detection.TicketID, detection.Candidates)
+	}
+}
+
+func TestDetectCandidates(t *testing.T) {
+	ctx := context.Background()
+	f := newDetectFixture(t)
+	first, marked := f.mark(t, []byte(sentences(100)), "shared")
+	second, _ := f.mark(t, []byte(sentences(120)), "shared")
+
+	detection, err := f.svc.Detect(ctx, bytes.NewReader(marked))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, ticketID := range detection.Candidates {
+		got[ticketID] = true
+	}
+	if detection.TicketID != "" || len(got) != 2 || !got[first] || !got[second] {
+		t.Errorf("Detect returned ticket %q and candidates %q, want the candidates %s and %s", detection.TicketID, detection.Candidates, first, second)
+	}
+}
+
+func TestDetectErrors(t *testing.T) {
+	ctx := context.Background()
+	f := newDetectFixture(t)
+	for _, tt := range []struct {
+		name    string
+		content []byte
+		err     error
+	}{
+		{"empty", nil, util.ErrInvalidArgument},
+		{"unmarked text", []byte(sentences(100)), util.ErrUnknown},
+		{"unmarked image", testPNG(t), util.ErrUnknown},
+		{"unmarked pdf", testPDF(), util.ErrUnknown},
+		{"unmarked epub", testEPUB(t), util.ErrUnknown},
+		{"binary", []byte{0xff, 0xfe, 0x00, 0x81, 0x82}, util.ErrUnknown},
+		{"broken pdf", []byte("%PDF-1.4\nbroken"), util.ErrInvalidArgument},
+		{"broken epub", []byte("PK\x03\x04broken"), util.ErrInvalidArgument},
+	} {
+		if _, err := f.svc.Detect(ctx, bytes.NewReader(tt.content)); !errors.Is(err, tt.err) {
+			t.Errorf("%s: Detect returned %v, want %v", tt.name, err, tt.err)
+		}
+	}
+	if _, err := f.svc.Detect(ctx, nil); !errors.Is(err, util.ErrInvalidArgument) {
+		t.Errorf("Detect without content returned %v, want %v", err, util.ErrInvalidArgument)
+	}
+}
+
+func TestWatermarkRejectPolicy(t *testing.T) {
+	ctx := context.Background()
+	repo, blobs := database.NewMemoryRepository(), blob.NewMemoryStore()
+	content := sentences(50)
+
+	// the documents share the content since before duplicates were rejected
+	var ticketIDs []string
+	before := database.NewService(repo, blobs, database.WithDuplicatePolicy(database.DuplicatePolicy{}))
+	for i := 0; i < 2; i++ {
+		ticketID, err := before.Add(ctx, &internal.Document{Title: "Title", Content: content})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ticketIDs = append(ticketIDs, ticketID)
+	}
+
+	db := database.NewService(repo, blobs, database.WithDuplicatePolicy(database.DuplicatePolicy{Threshold: 0.9, Reject: true}))
+	q, _ := newTestQueue(t)
+	p := NewPool(db, q)
+	svc, err := NewService(db, t.TempDir(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rewriting the content with the mark is no submission
+	if err := p.apply(ctx, ticketIDs[0], "reader"); err != nil {
+		t.Fatalf("apply returned %v", err)
+	}
+	marked, err := p.readContent(ctx, ticketIDs[0])
+	if err != nil || string(marked) == content {
+		t.Errorf("apply left the content unmarked, %v", err)
+	}
+
+	// submissions of the content are still rejected
+	if _, err := svc.AddDocument(ctx, &internal.Document{Title: "Title", Content: content}); !errors.Is(err, util.ErrConflict) {
+		t.Errorf("AddDocument of a duplicate returned %v, want %v", err, util.ErrConflict)
+	}
+	upload, err := svc.UploadDocument(ctx, &internal.Document{Title: "Title"}, 0, true, strings.NewReader(content))
+	if !errors.Is(err, util.ErrConflict) || upload.Complete {
+		t.Errorf("UploadDocument of a duplicate returned %+v, %v, want %v", upload, err, util.ErrConflict)
+	}
+}